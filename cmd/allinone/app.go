@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	"github.com/aidosgal/transline-test/pkg/postgres"
+	"github.com/aidosgal/transline-test/pkg/seed"
+	customerstorage "github.com/aidosgal/transline-test/services/customer/storage"
+	shipmentstorage "github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+// newLogger builds the logger of one service writing JSON to w. Records are
+// attached to spans as events; OTLP log export is not available here.
+func newLogger(cfg config.LogConfig, service string, w io.Writer) (*slog.Logger, *customlogger.Levels, error) {
+	redactMode, err := customlogger.ParseRedactMode(cfg.Redact)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	levels, err := customlogger.ParseLevels(cfg.Level, cfg.Levels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	log := slog.New(
+		customlogger.NewLevelHandler(
+			customlogger.NewRedactHandler(
+				customlogger.NewHandler(
+					slog.NewJSONHandler(w, &slog.HandlerOptions{
+						Level: slog.LevelDebug,
+					}),
+					customlogger.ExportSpanEvents,
+					nil,
+					service,
+					slog.LevelDebug,
+				),
+				customlogger.NewRedactor(redactMode, cfg.RedactSalt),
+			),
+			levels,
+		),
+	).With("service", service)
+
+	return log, levels, nil
+}
+
+// storages holds the storage of each service and the checks of their databases
+type storages struct {
+	customer       customerstorage.Storage
+	shipment       shipmentstorage.Storage
+	customerChecks map[string]health.Check
+	shipmentChecks map[string]health.Check
+	dbs            []*sql.DB
+}
+
+func (s *storages) Close() {
+	for _, db := range s.dbs {
+		db.Close()
+	}
+}
+
+// openStorages keeps both services in memory, or connects to their databases
+// and applies the migrations
+func openStorages(ctx context.Context, customerLog, shipmentLog *slog.Logger, mode string,
+	customerCfg *config.Customer, shipmentCfg *config.Shipment) (*storages, error) {
+	if mode == storageMemory {
+		return &storages{
+			customer: customerstorage.NewMemory(customerLog),
+			shipment: shipmentstorage.NewMemory(shipmentLog),
+		}, nil
+	}
+
+	s := &storages{}
+	customerDB, err := openPostgres(ctx, customerLog, customerCfg.Postgres, customerCfg.Name,
+		customerstorage.Migrations, "customer-service")
+	if err != nil {
+		return nil, err
+	}
+	s.dbs = append(s.dbs, customerDB)
+
+	shipmentDB, err := openPostgres(ctx, shipmentLog, shipmentCfg.Postgres, shipmentCfg.Name,
+		shipmentstorage.Migrations, "shipment-service")
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	s.dbs = append(s.dbs, shipmentDB)
+
+	s.customer = customerstorage.New(customerLog, customerDB, nil)
+	s.shipment = shipmentstorage.New(shipmentLog, shipmentDB, nil)
+	s.customerChecks = map[string]health.Check{
+		"postgres":   health.DBCheck(customerDB),
+		"migrations": health.MigrationCheck(customerDB),
+	}
+	s.shipmentChecks = map[string]health.Check{
+		"postgres":   health.DBCheck(shipmentDB),
+		"migrations": health.MigrationCheck(shipmentDB),
+	}
+	return s, nil
+}
+
+// seedDemo writes the default generated data set to both storages
+func seedDemo(ctx context.Context, log *slog.Logger, st *storages) error {
+	d, err := seed.Generate(seed.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	customers, err := d.WriteCustomers(ctx, st.customer)
+	if err != nil {
+		return err
+	}
+	shipments, err := d.WriteShipments(ctx, st.shipment)
+	if err != nil {
+		return err
+	}
+
+	log.Info("demo data seeded", slog.Int("customers", customers), slog.Int("shipments", shipments))
+	return nil
+}
+
+// openPostgres connects to a service database and migrates it under the same
+// lock as the standalone service
+func openPostgres(ctx context.Context, log *slog.Logger, pc config.PostgresConfig, service string,
+	migrations fs.FS, lockName string) (*sql.DB, error) {
+	if pc.ApplicationName == "" {
+		pc.ApplicationName = service
+	}
+
+	db, err := postgres.Open(ctx, log, pc)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := postgres.NewMigrator(log, db, pc.BuildPostgresMigrationURL(), migrations, "migrations", lockName)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply %s migrations: %w", service, err)
+	}
+	log.Info("migrations applied")
+
+	return db, nil
+}
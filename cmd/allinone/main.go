@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aidosgal/transline-test/pkg/cli"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+
+	_ "github.com/lib/pq"
+)
+
+const name = "transline-allinone"
+
+const (
+	storageMemory   = "memory"
+	storagePostgres = "postgres"
+
+	traceStdout = "stdout"
+	traceOTLP   = "otlp"
+	traceNone   = "none"
+)
+
+// options are the allinone flags on top of the two service configs
+type options struct {
+	storage string
+	trace   string
+	demo    bool
+}
+
+func main() {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	customerConfig := flags.String("customer-config", os.Getenv("CUSTOMER_CONFIG_FILE"), "customer-service config file")
+	shipmentConfig := flags.String("shipment-config", os.Getenv("SHIPMENT_CONFIG_FILE"), "shipment-service config file")
+	profile := flags.String("profile", "", "dev, test or prod, overrides PROFILE")
+	opts := options{}
+	flags.StringVar(&opts.storage, "storage", storageMemory, "memory, or postgres to use the databases of both configs")
+	flags.BoolVar(&opts.demo, "demo", false, "fill the storages with generated customers and shipments on start")
+	flags.StringVar(&opts.trace, "trace", traceStdout, "stdout (spans on stderr), otlp (OTEL_EXPORTER_OTLP_ENDPOINT, localhost:4318 by default) or none")
+	flags.Parse(os.Args[1:])
+
+	if opts.storage != storageMemory && opts.storage != storagePostgres {
+		fmt.Fprintf(os.Stderr, "invalid -storage %q, expected memory or postgres\n", opts.storage)
+		os.Exit(2)
+	}
+	if opts.trace != traceStdout && opts.trace != traceOTLP && opts.trace != traceNone {
+		fmt.Fprintf(os.Stderr, "invalid -trace %q, expected stdout, otlp or none\n", opts.trace)
+		os.Exit(2)
+	}
+
+	customerCfg := &config.Customer{}
+	if err := config.Load(customerCfg, *customerConfig, *profile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	shipmentCfg := &config.Shipment{}
+	if err := config.Load(shipmentCfg, *shipmentConfig, *profile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	os.Exit(cli.Run(name, flags.Args(),
+		cli.Command{
+			Name:  "serve",
+			Short: "run customer-service and the shipment HTTP API in one process (default)",
+			Run: func(ctx context.Context, args []string) error {
+				return serve(ctx, opts, customerCfg, shipmentCfg)
+			},
+		},
+	))
+}
+
+// setupTracer returns the tracer provider for mode, nil for none
+func setupTracer(ctx context.Context, log *slog.Logger, mode string) (*sdktrace.TracerProvider, error) {
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch mode {
+	case traceNone:
+		return nil, nil
+	case traceStdout:
+		exporter, err = stdouttrace.New(
+			stdouttrace.WithWriter(os.Stderr),
+			stdouttrace.WithPrettyPrint(),
+		)
+	case traceOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithInsecure())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", mode, err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	)
+
+	log.Info("OpenTelemetry tracer initialized", slog.String("exporter", mode))
+	return tp, nil
+}
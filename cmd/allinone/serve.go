@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	customerserver "github.com/aidosgal/transline-test/services/customer/server"
+	customerusecase "github.com/aidosgal/transline-test/services/customer/usecase"
+	"github.com/aidosgal/transline-test/services/shipment/client"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+	"github.com/aidosgal/transline-test/services/shipment/saga"
+	shipmentserver "github.com/aidosgal/transline-test/services/shipment/server"
+	"github.com/aidosgal/transline-test/services/shipment/sla"
+	shipmentusecase "github.com/aidosgal/transline-test/services/shipment/usecase"
+	adminv1 "github.com/aidosgal/transline-test/specs/proto/admin"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// bufSize is the buffer of the in-memory customer-service listener
+const bufSize = 1 << 20
+
+// serve runs customer-service on an in-memory listener and the shipment HTTP
+// API on its configured port until ctx is cancelled or the server fails
+func serve(ctx context.Context, opts options, customerCfg *config.Customer, shipmentCfg *config.Shipment) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	customerLog, customerLevels, err := newLogger(customerCfg.Log, customerCfg.Name, os.Stdout)
+	if err != nil {
+		return err
+	}
+	shipmentLog, shipmentLevels, err := newLogger(shipmentCfg.Log, shipmentCfg.Name, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	tp, err := setupTracer(ctx, shipmentLog, opts.trace)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	if tp != nil {
+		defer func() {
+			if err := tp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+				shipmentLog.Error("failed to shutdown tracer", slog.String("error", err.Error()))
+			}
+		}()
+		otel.SetTracerProvider(tp)
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	st, err := openStorages(ctx, customerLog, shipmentLog, opts.storage, customerCfg, shipmentCfg)
+	if err != nil {
+		return err
+	}
+	defer st.Close()
+	shipmentLog.Info("storage initialized", slog.String("storage", opts.storage))
+
+	if opts.demo {
+		if err := seedDemo(ctx, shipmentLog, st); err != nil {
+			return err
+		}
+	}
+
+	// customer-service, reachable only through lis
+	customerUsecase := customerusecase.New(customerLog, st.customer)
+	customerServer := customerserver.New(customerLog, customerUsecase)
+
+	interceptors := []grpc.UnaryServerInterceptor{certs.UnaryServerInterceptor()}
+	if customerCfg.AdminToken != "" {
+		interceptors = append(interceptors, customerserver.RequireAdminToken(customerCfg.AdminToken))
+	}
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	)
+	pb.RegisterCustomerServer(grpcServer, customerServer)
+	if customerCfg.AdminToken != "" {
+		adminv1.RegisterAdminServer(grpcServer, customerserver.NewAdmin(customerLog, customerLevels))
+	}
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	customerChecker := health.New(customerLog, 2*time.Second)
+	for name, check := range st.customerChecks {
+		customerChecker.Add(name, check)
+	}
+	go customerChecker.Watch(ctx, healthServer, 5*time.Second, pb.Customer_ServiceDesc.ServiceName)
+
+	lis := bufconn.Listen(bufSize)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			customerLog.Error("server stopped", slog.String("error", err.Error()))
+			cancel()
+		}
+	}()
+	customerLog.Info("gRPC server listening in process")
+
+	// shipment-service, dialing customer-service through lis
+	clientCfg := *shipmentCfg
+	clientCfg.Customer = config.EndpointConfig{URL: "bufnet", Port: customerCfg.Port}
+	clientCfg.CustomerClient.Resolver = config.ResolverPassthrough
+	clientCfg.CustomerClient.TLS = config.TLSConfig{}
+
+	customerClient, err := client.New(shipmentLog, &clientCfg,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	if err != nil {
+		return fmt.Errorf("failed to connect to customer GRPC: %w", err)
+	}
+	defer customerClient.Close()
+
+	shipmentSaga := saga.New(shipmentLog, st.shipment, customerClient, shipmentCfg.Saga.MaxAttempts)
+	go shipmentSaga.Run(ctx, shipmentCfg.Saga.RecoveryInterval, shipmentCfg.Saga.StaleAfter)
+
+	engine, err := pricing.Open(ctx, shipmentLog, &shipmentCfg.Pricing, st.shipment)
+	if err != nil {
+		return err
+	}
+
+	monitor := sla.New(shipmentLog, st.shipment, sla.NewPublisher(shipmentLog, &shipmentCfg.SLA), &shipmentCfg.SLA)
+	go monitor.Run(ctx)
+
+	shipmentUsecase := shipmentusecase.New(shipmentLog, st.shipment, shipmentSaga, engine, monitor)
+	shipmentServer := shipmentserver.New(shipmentLog, shipmentUsecase)
+
+	checker := health.New(shipmentLog, 2*time.Second)
+	for name, check := range st.shipmentChecks {
+		checker.Add(name, check)
+	}
+	checker.Add("customer-service", health.GRPCCheck(customerClient.Conn(), pb.Customer_ServiceDesc.ServiceName))
+
+	router := shipmentserver.NewRouter(shipmentLog, shipmentServer, checker, shipmentLevels, shipmentCfg.AdminToken, &shipmentCfg.Tracking)
+
+	address := fmt.Sprintf(":%d", shipmentCfg.Port)
+	server := &http.Server{
+		Addr:    address,
+		Handler: otelhttp.NewHandler(router, shipmentCfg.Name),
+	}
+
+	go func() {
+		shipmentLog.Info("HTTP server listening", slog.String("address", address))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			shipmentLog.Error("server error", slog.String("error", err.Error()))
+			cancel()
+		}
+	}()
+
+	<-ctx.Done()
+
+	shipmentLog.Info("shutting down gracefully...")
+	customerChecker.Shutdown()
+	healthServer.Shutdown()
+	checker.Drain(max(shipmentCfg.DrainDelay, customerCfg.DrainDelay))
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		shipmentLog.Error("server forced to shutdown", slog.String("error", err.Error()))
+	}
+	grpcServer.GracefulStop()
+
+	shipmentLog.Info("server stopped")
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/cli"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	"github.com/aidosgal/transline-test/pkg/seed"
+	"github.com/aidosgal/transline-test/services/customer/storage"
+)
+
+// fixtures is the format of seed --fixtures files
+type fixtures struct {
+	Customers []struct {
+		IDN string `json:"idn"`
+	} `json:"customers"`
+}
+
+func runMigrate(ctx context.Context, cfg *config.Customer, args []string) error {
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(log, db, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	return migrator.Run(ctx, args, os.Stdout)
+}
+
+// runSeed upserts every customer of the fixtures file, or inserts the
+// generated ones, in one transaction
+func runSeed(ctx context.Context, cfg *config.Customer, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	path := fs.String("fixtures", "", "JSON file with the customers to upsert")
+	generate := fs.Bool("generate", false, "insert generated customers instead of fixtures")
+	seedCfg := seed.DefaultConfig()
+	seedCfg.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*path == "") != *generate {
+		return cli.ErrUsage
+	}
+
+	var fx fixtures
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return fmt.Errorf("failed to parse fixtures: %w", err)
+		}
+	}
+
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *generate {
+		d, err := seed.Generate(seedCfg)
+		if err != nil {
+			return err
+		}
+		created, err := d.WriteCustomers(ctx, storage.New(log, db, nil))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seeded %d generated customers, %d created\n", len(d.Customers), created)
+		return nil
+	}
+
+	created := 0
+	err = storage.New(log, db, nil).WithTx(ctx, func(tx storage.Storage) error {
+		for i, c := range fx.Customers {
+			if c.IDN == "" {
+				return fmt.Errorf("customer %d has no idn", i)
+			}
+			customer, err := tx.UpsertCustomer(ctx, c.IDN, "")
+			if err != nil {
+				return err
+			}
+			if customer.Created {
+				created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to seed customers: %w", err)
+	}
+
+	fmt.Printf("seeded %d customers, %d created\n", len(fx.Customers), created)
+	return nil
+}
+
+func runConfig(cfg *config.Customer, args []string) error {
+	if len(args) != 1 {
+		return cli.ErrUsage
+	}
+	switch args[0] {
+	case "print":
+		return config.Print(os.Stdout, cfg)
+	case "reference":
+		fmt.Print("# customer-service configuration\n\n" +
+			"Generated by `customer-service config reference`, do not edit.\n\n")
+		return config.Reference(os.Stdout, cfg)
+	default:
+		return cli.ErrUsage
+	}
+}
+
+// runCheck runs the readiness checks once and prints the result
+func runCheck(ctx context.Context, cfg *config.Customer) error {
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	// Not openDB, so an unreachable database is reported as a failed check
+	pc := postgresConfig(cfg)
+	db, err := sql.Open("postgres", pc.BuildPostgresURL())
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+
+	checker := health.New(log, 5*time.Second)
+	checker.Add("postgres", health.DBCheck(db))
+	checker.Add("migrations", health.MigrationCheck(db))
+	if replicaConfig, ok := pc.ReplicaConfig(); ok {
+		replica, err := sql.Open("postgres", replicaConfig.BuildPostgresURL())
+		if err != nil {
+			return fmt.Errorf("failed to open replica: %w", err)
+		}
+		defer replica.Close()
+		checker.Add("postgres-replica", health.DBCheck(replica))
+	}
+	checker.Add("otel-collector", health.TCPCheck(collectorEndpoint))
+
+	result, ready := checker.Ready(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	if !ready {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
@@ -2,137 +2,84 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
-	"net"
 	"os"
-	"os/signal"
-	"syscall"
 
+	"github.com/aidosgal/transline-test/pkg/cli"
 	"github.com/aidosgal/transline-test/pkg/config"
-	customlogger "github.com/aidosgal/transline-test/pkg/logger"
-	"github.com/aidosgal/transline-test/services/customer/server"
-	"github.com/aidosgal/transline-test/services/customer/storage"
-	"github.com/aidosgal/transline-test/services/customer/usecase"
-	pb "github.com/aidosgal/transline-test/specs/proto/customer"
-	"github.com/golang-migrate/migrate/v4"
-	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
-	"google.golang.org/grpc"
 
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 )
 
-func main() {
-	cfg := config.MustLoad()
-
-	// Create logger with trace context support
-	log := slog.New(
-		customlogger.NewTraceHandler(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-				Level: slog.LevelInfo,
-			}),
-		),
-	)
+const collectorEndpoint = "otel-collector:4318"
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+//go:generate sh -c "go run . config reference > ../../docs/config/customer.md"
 
-	tp, err := setupTracer(ctx, log)
-	if err != nil {
-		log.Error("failed to initialize tracer", slog.String("error", err.Error()))
+func main() {
+	flags := flag.NewFlagSet("customer-service", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file, overridden by environment variables")
+	profile := flags.String("profile", "", "dev, test or prod, overrides PROFILE")
+	flags.Parse(os.Args[1:])
+
+	cfg := &config.Customer{}
+	if err := config.Load(cfg, *configPath, *profile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	defer func() {
-		if err := tp.Shutdown(ctx); err != nil {
-			log.Error("failed to shutdown tracer", slog.String("error", err.Error()))
-		}
-	}()
 
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
+	os.Exit(cli.Run("customer-service", flags.Args(),
+		cli.Command{
+			Name:  "serve",
+			Short: "run the gRPC server (default)",
+			Run: func(ctx context.Context, args []string) error {
+				return serve(ctx, cfg)
+			},
+		},
+		cli.Command{
+			Name:  "migrate",
+			Usage: "up | down [N] | goto V | version | force V",
+			Short: "manage the database schema",
+			Run: func(ctx context.Context, args []string) error {
+				return runMigrate(ctx, cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "seed",
+			Usage: "--fixtures file.json | --generate [seed flags]",
+			Short: "upsert customers from a fixtures file or insert generated ones",
+			Run: func(ctx context.Context, args []string) error {
+				return runSeed(ctx, cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "config",
+			Usage: "print | reference",
+			Short: "print the effective config with secrets masked, or a reference of every setting",
+			Run: func(ctx context.Context, args []string) error {
+				return runConfig(cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "check",
+			Short: "verify Postgres, migrations and the collector are reachable",
+			Run: func(ctx context.Context, args []string) error {
+				return runCheck(ctx, cfg)
+			},
+		},
 	))
-
-	postgresURL := cfg.CustomerService.Postgres.BuildPostgresURL()
-	log.Info("connecting to database", slog.String("url", postgresURL))
-
-	db, err := sql.Open("postgres", postgresURL)
-	if err != nil {
-		log.Error("failed to open db", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	defer db.Close()
-
-	if err = db.Ping(); err != nil {
-		log.Error("failed to connect to db", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	log.Info("connected to database")
-
-	migrationURL := cfg.CustomerService.Postgres.BuildPostgresMigrationURL()
-	migrationPath := "/app/migrations"
-
-	m, err := migrate.New("file://"+migrationPath, migrationURL)
-	if err != nil {
-		log.Error("failed to init migrations service", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Info("no migrations to apply", slog.String("error", err.Error()))
-		}
-	}
-
-	log.Info("migration applied successfully")
-
-	customerStorage := storage.New(log, db)
-	customerUsecase := usecase.New(log, customerStorage)
-	customerServer := server.New(log, customerUsecase)
-
-	address := fmt.Sprintf(":%d", cfg.CustomerService.Port)
-	lis, err := net.Listen("tcp", address)
-	if err != nil {
-		log.Error("failed to listen", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	log.Info("gRPC server listening", slog.String("address", address))
-
-	grpcServer := grpc.NewServer(
-		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-	)
-	pb.RegisterCustomerServer(grpcServer, customerServer)
-
-	go func() {
-		if err := grpcServer.Serve(lis); err != nil {
-			log.Error("server stopped", slog.String("error", err.Error()))
-			cancel()
-		}
-	}()
-
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Info("shutting down server gracefully...")
-	grpcServer.GracefulStop()
-	log.Info("server stopped")
 }
 
-func setupTracer(ctx context.Context, log *slog.Logger) (*sdktrace.TracerProvider, error) {
+func setupTracer(ctx context.Context, log *slog.Logger, name string) (*sdktrace.TracerProvider, error) {
 	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint("otel-collector:4318"),
+		otlptracehttp.WithEndpoint(collectorEndpoint),
 		otlptracehttp.WithInsecure(),
 	)
 	if err != nil {
@@ -143,10 +90,30 @@ func setupTracer(ctx context.Context, log *slog.Logger) (*sdktrace.TracerProvide
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceName("customer-service"),
+			semconv.ServiceName(name),
 		)),
 	)
 
-	log.Info("OpenTelemetry tracer initialized", slog.String("endpoint", "otel-collector:4318"))
+	log.Info("OpenTelemetry tracer initialized", slog.String("endpoint", collectorEndpoint))
 	return tp, nil
 }
+
+func setupLoggerProvider(ctx context.Context, name string) (*sdklog.LoggerProvider, error) {
+	exporter, err := otlploghttp.New(ctx,
+		otlploghttp.WithEndpoint(collectorEndpoint),
+		otlploghttp.WithInsecure(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
+	}
+
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	)
+
+	return lp, nil
+}
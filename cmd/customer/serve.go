@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	"github.com/aidosgal/transline-test/services/customer/server"
+	"github.com/aidosgal/transline-test/services/customer/storage"
+	"github.com/aidosgal/transline-test/services/customer/usecase"
+	adminv1 "github.com/aidosgal/transline-test/specs/proto/admin"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// serve runs the gRPC server until ctx is cancelled or the server fails
+func serve(ctx context.Context, cfg *config.Customer) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log, levels, shutdownLogger, err := newLogger(ctx, cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	tp, err := setupTracer(ctx, log, cfg.Name)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	defer func() {
+		if err := tp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			log.Error("failed to shutdown tracer", slog.String("error", err.Error()))
+		}
+	}()
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	replica, err := openReplica(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	if replica != nil {
+		defer replica.Close()
+	}
+
+	migrator, err := newMigrator(log, db, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	log.Info("migrations applied")
+
+	customerStorage := storage.New(log, db, replica)
+	customerUsecase := usecase.New(log, customerStorage)
+	customerServer := server.New(log, customerUsecase)
+
+	address := fmt.Sprintf(":%d", cfg.Port)
+	lis, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	log.Info("gRPC server listening", slog.String("address", address))
+
+	interceptors := []grpc.UnaryServerInterceptor{certs.UnaryServerInterceptor()}
+	if cfg.AdminToken != "" {
+		interceptors = append(interceptors, server.RequireAdminToken(cfg.AdminToken))
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+	if cfg.TLS.Enabled {
+		tlsConfig, err := certs.ServerTLS(log, cfg.TLS)
+		if err != nil {
+			return fmt.Errorf("failed to configure TLS: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		log.Info("gRPC server TLS enabled", slog.String("client_auth", cfg.TLS.ClientAuth))
+	}
+
+	grpcServer := grpc.NewServer(serverOpts...)
+	pb.RegisterCustomerServer(grpcServer, customerServer)
+	if cfg.AdminToken != "" {
+		adminv1.RegisterAdminServer(grpcServer, server.NewAdmin(log, levels))
+	}
+
+	healthServer := grpchealth.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	checker := health.New(log, 2*time.Second)
+	checker.Add("postgres", health.DBCheck(db))
+	checker.Add("migrations", health.MigrationCheck(db))
+	if replica != nil {
+		checker.Add("postgres-replica", health.DBCheck(replica))
+	}
+	go checker.Watch(ctx, healthServer, 5*time.Second, pb.Customer_ServiceDesc.ServiceName)
+
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Error("server stopped", slog.String("error", err.Error()))
+			cancel()
+		}
+	}()
+
+	<-ctx.Done()
+
+	log.Info("shutting down server gracefully...")
+	healthServer.Shutdown()
+	checker.Drain(cfg.DrainDelay)
+	grpcServer.GracefulStop()
+	log.Info("server stopped")
+	return nil
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/idn"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// config is the load profile from the flags
+type config struct {
+	url         string
+	mix         []weighted
+	rps         float64
+	concurrency int
+	duration    time.Duration
+	requests    int
+	timeout     time.Duration
+	customers   int
+	binRatio    float64
+	seed        uint64
+	slowest     int
+}
+
+type weighted struct {
+	name   string
+	weight int
+}
+
+func (c config) validate() error {
+	switch {
+	case c.rps < 0:
+		return errors.New("-rps must not be negative")
+	case c.concurrency < 1:
+		return errors.New("-concurrency must be at least 1")
+	case c.duration <= 0:
+		return errors.New("-duration must be positive")
+	case c.requests < 0:
+		return errors.New("-requests must not be negative")
+	case c.customers < 0:
+		return errors.New("-customers must not be negative")
+	case c.binRatio < 0 || c.binRatio > 1:
+		return errors.New("-bin-ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+func (c config) mode() string {
+	if c.rps > 0 {
+		return fmt.Sprintf("fixed %g rps", c.rps)
+	}
+	return "closed-loop"
+}
+
+// parseMix parses "create=1,get=4"; an operation without a weight gets 1
+func parseMix(s string) ([]weighted, error) {
+	var mix []weighted
+	for _, part := range strings.Split(s, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(part), "=")
+		w := 1
+		if found {
+			var err error
+			if w, err = strconv.Atoi(weight); err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid -mix weight %q of %s", weight, name)
+			}
+		}
+		if _, ok := operations[name]; !ok {
+			return nil, fmt.Errorf("unknown -mix operation %q, expected one of %s", name, operationNames())
+		}
+		if w > 0 {
+			mix = append(mix, weighted{name: name, weight: w})
+		}
+	}
+	if len(mix) == 0 {
+		return nil, errors.New("-mix has no operation with a positive weight")
+	}
+	return mix, nil
+}
+
+// load runs the requests of one profile and collects their results
+type load struct {
+	cfg       config
+	client    *http.Client
+	tracer    trace.Tracer
+	total     int
+	customers []string
+	shipments *pool
+	stats     *stats
+	sent      atomic.Int64
+}
+
+func newLoad(cfg config) *load {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = cfg.concurrency
+
+	l := &load{
+		cfg: cfg,
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(transport),
+			Timeout:   cfg.timeout,
+		},
+		tracer:    otel.Tracer(name),
+		shipments: newPool(10000),
+		stats:     newStats(cfg.slowest),
+	}
+	for _, w := range cfg.mix {
+		l.total += w.weight
+	}
+
+	r := rand.New(rand.NewPCG(cfg.seed, 0))
+	for range cfg.customers {
+		l.customers = append(l.customers, randomIDN(r, cfg.binRatio))
+	}
+	return l
+}
+
+// run sends requests until the duration or the request limit is reached, or
+// ctx is cancelled, and waits for the requests in flight
+func (l *load) run(ctx context.Context) *report {
+	stopCtx, cancel := context.WithTimeout(ctx, l.cfg.duration)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	if l.cfg.rps > 0 {
+		scheduled := make(chan time.Time, l.cfg.concurrency)
+		for i := range l.cfg.concurrency {
+			w := l.newWorker(i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for at := range scheduled {
+					w.do(ctx, at)
+				}
+			}()
+		}
+		l.schedule(stopCtx, scheduled)
+		close(scheduled)
+	} else {
+		for i := range l.cfg.concurrency {
+			w := l.newWorker(i)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for stopCtx.Err() == nil && l.take() {
+					w.do(ctx, time.Now())
+				}
+			}()
+		}
+	}
+	wg.Wait()
+
+	return l.stats.report(l.cfg, time.Since(start))
+}
+
+// schedule emits the start time of each request at the fixed rate. When every
+// worker is busy the request is dropped rather than delayed, so the rate the
+// server sees does not adapt to its latency; drops are reported.
+func (l *load) schedule(ctx context.Context, scheduled chan<- time.Time) {
+	interval := time.Duration(float64(time.Second) / l.cfg.rps)
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	next := time.Now()
+	for l.take() {
+		timer.Reset(time.Until(next))
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		select {
+		case scheduled <- next:
+		default:
+			l.sent.Add(-1)
+			l.stats.drop()
+		}
+		next = next.Add(interval)
+	}
+}
+
+// take reserves one request under the -requests limit
+func (l *load) take() bool {
+	return l.cfg.requests == 0 || l.sent.Add(1) <= int64(l.cfg.requests)
+}
+
+func (l *load) newWorker(i int) *worker {
+	return &worker{
+		load: l,
+		rand: rand.New(rand.NewPCG(l.cfg.seed, uint64(i)+1)),
+	}
+}
+
+// worker sends one request at a time; its random source is not shared
+type worker struct {
+	load *load
+	rand *rand.Rand
+}
+
+// do sends one request of the mix; latency counts from start, the scheduled
+// time in fixed-rate mode
+func (w *worker) do(ctx context.Context, start time.Time) {
+	op := w.pick()
+
+	ctx, span := w.load.tracer.Start(ctx, "loadgen "+op, trace.WithAttributes(attribute.String("loadgen.operation", op)))
+	err := operations[op](ctx, w)
+	latency := time.Since(start)
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+
+	if ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	w.load.stats.record(op, latency, span.SpanContext().TraceID().String(), err)
+}
+
+// pick draws an operation by weight; a get before any shipment exists
+// creates one instead
+func (w *worker) pick() string {
+	n := w.rand.IntN(w.load.total)
+	op := w.load.cfg.mix[len(w.load.cfg.mix)-1].name
+	for _, m := range w.load.cfg.mix {
+		if n < m.weight {
+			op = m.name
+			break
+		}
+		n -= m.weight
+	}
+	if op == opGet && w.load.shipments.len() == 0 {
+		return opCreate
+	}
+	return op
+}
+
+// customer returns the IDN of the next create
+func (w *worker) customer() string {
+	if len(w.load.customers) == 0 {
+		return randomIDN(w.rand, w.load.cfg.binRatio)
+	}
+	return w.load.customers[w.rand.IntN(len(w.load.customers))]
+}
+
+func randomIDN(r *rand.Rand, binRatio float64) string {
+	if r.Float64() < binRatio {
+		return idn.RandomBIN(r)
+	}
+	return idn.RandomIIN(r)
+}
+
+// pool keeps the IDs of the last created shipments for gets
+type pool struct {
+	mu   sync.Mutex
+	ids  []string
+	next int
+}
+
+func newPool(size int) *pool {
+	return &pool{ids: make([]string, 0, size)}
+}
+
+func (p *pool) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) < cap(p.ids) {
+		p.ids = append(p.ids, id)
+		return
+	}
+	p.ids[p.next] = id
+	p.next = (p.next + 1) % len(p.ids)
+}
+
+func (p *pool) random(r *rand.Rand) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.ids) == 0 {
+		return "", false
+	}
+	return p.ids[r.IntN(len(p.ids))], true
+}
+
+func (p *pool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.ids)
+}
+
+func operationNames() string {
+	names := make([]string, 0, len(operations))
+	for name := range operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
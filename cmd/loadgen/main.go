@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+const name = "transline-loadgen"
+
+const (
+	traceOTLP = "otlp"
+	traceNone = "none"
+)
+
+func main() {
+	cfg := config{}
+	var mix, jsonOut, trace string
+	flag.StringVar(&cfg.url, "url", "http://localhost:8080", "base URL of the shipment API")
+	flag.StringVar(&mix, "mix", "create=1,get=4", "operations and their weights, of: "+operationNames())
+	flag.Float64Var(&cfg.rps, "rps", 0, "fixed request rate; 0 runs closed-loop, each worker sending the next request after the previous one")
+	flag.IntVar(&cfg.concurrency, "concurrency", 10, "workers, the maximum number of requests in flight")
+	flag.DurationVar(&cfg.duration, "duration", 30*time.Second, "how long to send requests")
+	flag.IntVar(&cfg.requests, "requests", 0, "stop after this many requests; 0 is unlimited")
+	flag.DurationVar(&cfg.timeout, "timeout", 5*time.Second, "per-request timeout")
+	flag.IntVar(&cfg.customers, "customers", 1000, "size of the customer pool that creates draw from; 0 uses a new customer every time")
+	flag.Float64Var(&cfg.binRatio, "bin-ratio", 0.2, "share of customers that are legal entities with a BIN instead of an IIN")
+	flag.Uint64Var(&cfg.seed, "seed", uint64(time.Now().UnixNano()), "random seed for customers, routes and the mix")
+	flag.IntVar(&cfg.slowest, "slowest", 10, "number of slowest requests to report with their trace IDs")
+	flag.StringVar(&jsonOut, "json", "", "also write the report as JSON to this file; - writes it to stdout and the text report to stderr")
+	flag.StringVar(&trace, "trace", traceOTLP, "otlp exports the loadgen spans to OTEL_EXPORTER_OTLP_ENDPOINT (localhost:4318 by default), none only propagates the trace context")
+	flag.Parse()
+
+	var err error
+	if cfg.mix, err = parseMix(mix); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if trace != traceOTLP && trace != traceNone {
+		fmt.Fprintf(os.Stderr, "invalid -trace %q, expected otlp or none\n", trace)
+		os.Exit(2)
+	}
+
+	if err := run(cfg, trace, jsonOut); err != nil {
+		fmt.Fprintln(os.Stderr, "loadgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config, trace, jsonOut string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	log := slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+	tp, err := setupTracer(ctx, trace)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := tp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			log.Error("failed to shutdown tracer", slog.String("error", err.Error()))
+		}
+	}()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	log.Info("load started",
+		slog.String("url", cfg.url),
+		slog.String("mode", cfg.mode()),
+		slog.Int("concurrency", cfg.concurrency),
+		slog.Duration("duration", cfg.duration),
+		slog.Uint64("seed", cfg.seed))
+
+	report := newLoad(cfg).run(ctx)
+
+	// with -json - the text report moves to stderr
+	var text io.Writer = os.Stdout
+	if jsonOut == "-" {
+		text = os.Stderr
+	}
+	report.writeText(text)
+	if jsonOut == "" {
+		return nil
+	}
+	var w io.Writer = os.Stdout
+	if jsonOut != "-" {
+		f, err := os.Create(jsonOut)
+		if err != nil {
+			return fmt.Errorf("failed to create report file: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := report.writeJSON(w); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
+
+// setupTracer always samples, so every request carries a trace ID that the
+// services continue; with none the spans are not exported
+func setupTracer(ctx context.Context, mode string) (*sdktrace.TracerProvider, error) {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	}
+	if mode == traceOTLP {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithInsecure())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+	return sdktrace.NewTracerProvider(opts...), nil
+}
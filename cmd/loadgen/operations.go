@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+const (
+	opCreate = "create"
+	opGet    = "get"
+)
+
+// operation sends one request of its kind. New endpoints, e.g. list or
+// status transitions, are added here and become available in -mix.
+type operation func(ctx context.Context, w *worker) error
+
+var operations = map[string]operation{
+	opCreate: createShipment,
+	opGet:    getShipment,
+}
+
+// cities are the route endpoints of generated shipments
+var cities = []string{
+	"ALMATY", "ASTANA", "SHYMKENT", "KARAGANDA", "AKTOBE", "TARAZ", "PAVLODAR", "OSKEMEN",
+	"SEMEY", "KOSTANAY", "KYZYLORDA", "ATYRAU", "AKTAU", "PETROPAVL", "TURKESTAN", "ORAL",
+}
+
+func createShipment(ctx context.Context, w *worker) error {
+	req := entity.CreateReq{
+		Route:    randomRoute(w.rand),
+		Price:    (50 + w.rand.IntN(4950)) * 100,
+		Customer: entity.CreateCustomerReq{IDN: w.customer()},
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	resp := entity.CreateResp{}
+	if err := w.send(ctx, http.MethodPost, "/api/v1/shipments", body, http.StatusCreated, &resp); err != nil {
+		return err
+	}
+	if resp.ID != "" {
+		w.load.shipments.add(resp.ID)
+	}
+	return nil
+}
+
+func getShipment(ctx context.Context, w *worker) error {
+	id, ok := w.load.shipments.random(w.rand)
+	if !ok {
+		return errors.New("no shipment to get")
+	}
+	return w.send(ctx, http.MethodGet, "/api/v1/shipments/"+url.PathEscape(id), nil, http.StatusOK, nil)
+}
+
+func randomRoute(r *rand.Rand) string {
+	from := r.IntN(len(cities))
+	to := (from + 1 + r.IntN(len(cities)-1)) % len(cities)
+	return cities[from] + "→" + cities[to]
+}
+
+// statusError is a response with an unexpected status code
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.code)
+}
+
+// send makes the request and decodes a response with the expected status
+// into out, if set
+func (w *worker) send(ctx context.Context, method, path string, body []byte, want int, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, w.load.cfg.url+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := w.load.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		io.Copy(io.Discard, resp.Body)
+		return &statusError{code: resp.StatusCode}
+	}
+	if out == nil {
+		_, err = io.Copy(io.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// errorKind groups errors for the breakdown
+func errorKind(err error) string {
+	var status *statusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		return status.Error()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection reset"
+	default:
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			return "invalid response"
+		}
+		return "other"
+	}
+}
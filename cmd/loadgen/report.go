@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const totalName = "total"
+
+// stats collects the results of all workers
+type stats struct {
+	mu      sync.Mutex
+	ops     map[string]*opStats
+	dropped int
+	keep    int
+	slowest []slowRequest
+}
+
+type opStats struct {
+	latencies []time.Duration
+	errors    map[string]int
+	// examples keep the first message of every error kind
+	examples map[string]string
+}
+
+func newStats(slowest int) *stats {
+	return &stats{ops: make(map[string]*opStats), keep: slowest}
+}
+
+func (s *stats) record(op string, latency time.Duration, traceID string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.ops[op]
+	if !ok {
+		st = &opStats{errors: make(map[string]int), examples: make(map[string]string)}
+		s.ops[op] = st
+	}
+	st.latencies = append(st.latencies, latency)
+
+	slow := slowRequest{Operation: op, LatencyMS: ms(latency), TraceID: traceID}
+	if err != nil {
+		kind := errorKind(err)
+		st.errors[kind]++
+		if _, ok := st.examples[kind]; !ok {
+			st.examples[kind] = err.Error()
+		}
+		slow.Error = kind
+	}
+
+	// slowest stays sorted, slowest first
+	if len(s.slowest) < s.keep || (s.keep > 0 && slow.LatencyMS > s.slowest[len(s.slowest)-1].LatencyMS) {
+		i := sort.Search(len(s.slowest), func(i int) bool { return s.slowest[i].LatencyMS < slow.LatencyMS })
+		s.slowest = slices.Insert(s.slowest, i, slow)
+		if len(s.slowest) > s.keep {
+			s.slowest = s.slowest[:s.keep]
+		}
+	}
+}
+
+func (s *stats) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropped++
+}
+
+// report is the result of a run, written as text or JSON
+type report struct {
+	Mode            string        `json:"mode"`
+	Concurrency     int           `json:"concurrency"`
+	Seed            uint64        `json:"seed"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	Dropped         int           `json:"dropped"`
+	Operations      []opReport    `json:"operations"`
+	Total           opReport      `json:"total"`
+	Errors          []errorReport `json:"errors"`
+	Slowest         []slowRequest `json:"slowest"`
+}
+
+type opReport struct {
+	Name     string  `json:"name"`
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	RPS      float64 `json:"rps"`
+	Latency  latency `json:"latency_ms"`
+}
+
+// latency is in milliseconds, over all requests including failed ones
+type latency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+type errorReport struct {
+	Operation string `json:"operation"`
+	Kind      string `json:"kind"`
+	Count     int    `json:"count"`
+	Example   string `json:"example"`
+}
+
+type slowRequest struct {
+	Operation string  `json:"operation"`
+	LatencyMS float64 `json:"latency_ms"`
+	TraceID   string  `json:"trace_id"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func (s *stats) report(cfg config, elapsed time.Duration) *report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r := &report{
+		Mode:            cfg.mode(),
+		Concurrency:     cfg.concurrency,
+		Seed:            cfg.seed,
+		DurationSeconds: elapsed.Seconds(),
+		Dropped:         s.dropped,
+		Errors:          []errorReport{},
+		Slowest:         append([]slowRequest{}, s.slowest...),
+	}
+
+	var all []time.Duration
+	totalErrors := 0
+	for _, name := range slices.Sorted(maps.Keys(s.ops)) {
+		st := s.ops[name]
+		errs := 0
+		for _, kind := range slices.Sorted(maps.Keys(st.errors)) {
+			errs += st.errors[kind]
+			r.Errors = append(r.Errors, errorReport{Operation: name, Kind: kind, Count: st.errors[kind], Example: st.examples[kind]})
+		}
+		r.Operations = append(r.Operations, newOpReport(name, st.latencies, errs, elapsed))
+		all = append(all, st.latencies...)
+		totalErrors += errs
+	}
+	r.Total = newOpReport(totalName, all, totalErrors, elapsed)
+	return r
+}
+
+func newOpReport(name string, latencies []time.Duration, errors int, elapsed time.Duration) opReport {
+	r := opReport{Name: name, Requests: len(latencies), Errors: errors}
+	if len(latencies) == 0 {
+		return r
+	}
+	r.RPS = float64(len(latencies)) / elapsed.Seconds()
+
+	sorted := slices.Clone(latencies)
+	slices.Sort(sorted)
+	var sum time.Duration
+	for _, l := range sorted {
+		sum += l
+	}
+	r.Latency = latency{
+		Min:  ms(sorted[0]),
+		Mean: ms(sum / time.Duration(len(sorted))),
+		P50:  ms(percentile(sorted, 50)),
+		P90:  ms(percentile(sorted, 90)),
+		P95:  ms(percentile(sorted, 95)),
+		P99:  ms(percentile(sorted, 99)),
+		Max:  ms(sorted[len(sorted)-1]),
+	}
+	return r
+}
+
+// percentile uses the nearest-rank method on sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	return sorted[max(0, min(rank, len(sorted)-1))]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (r *report) writeText(w io.Writer) {
+	fmt.Fprintf(w, "%s, concurrency %d, %.1fs, seed %d\n", r.Mode, r.Concurrency, r.DurationSeconds, r.Seed)
+	fmt.Fprintf(w, "requests %d (%.1f/s), errors %d", r.Total.Requests, r.Total.RPS, r.Total.Errors)
+	if r.Total.Requests > 0 {
+		fmt.Fprintf(w, " (%.2f%%)", float64(r.Total.Errors)*100/float64(r.Total.Requests))
+	}
+	if r.Dropped > 0 {
+		fmt.Fprintf(w, ", dropped %d: every worker was busy, raise -concurrency", r.Dropped)
+	}
+	fmt.Fprint(w, "\n\n")
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "operation\trequests\trps\terrors\tmin\tmean\tp50\tp90\tp95\tp99\tmax (ms)\t")
+	for _, op := range append(r.Operations, r.Total) {
+		l := op.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%.1f\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			op.Name, op.Requests, op.RPS, op.Errors, l.Min, l.Mean, l.P50, l.P90, l.P95, l.P99, l.Max)
+	}
+	tw.Flush()
+
+	if len(r.Errors) > 0 {
+		fmt.Fprint(w, "\nerrors:\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, e := range r.Errors {
+			fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\n", e.Operation, e.Kind, e.Count, e.Example)
+		}
+		tw.Flush()
+	}
+
+	if len(r.Slowest) > 0 {
+		fmt.Fprint(w, "\nslowest requests (trace ID in Jaeger):\n")
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for _, s := range r.Slowest {
+			fmt.Fprintf(tw, "  %.1fms\t%s\t%s\t%s\n", s.LatencyMS, s.Operation, s.TraceID, s.Error)
+		}
+		tw.Flush()
+	}
+}
+
+func (r *report) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
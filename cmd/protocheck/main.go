@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing/fstest"
+
+	"github.com/aidosgal/transline-test/pkg/protocheck"
+)
+
+func main() {
+	dir := flag.String("dir", "specs/proto", "directory of the current .proto files, the import root")
+	against := flag.String("against", "HEAD", "git ref holding the previous version of -dir, e.g. origin/main")
+	againstDir := flag.String("against-dir", "", "directory holding the previous version, instead of -against")
+	flag.Parse()
+
+	violations, err := run(context.Background(), *dir, *against, *againstDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "protocheck:", err)
+		os.Exit(2)
+	}
+	for _, v := range violations {
+		fmt.Println(v)
+	}
+	if len(violations) > 0 {
+		fmt.Fprintf(os.Stderr, "protocheck: %d breaking changes\n", len(violations))
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, dir, against, againstDir string) ([]protocheck.Violation, error) {
+	current, err := protocheck.Compile(ctx, os.DirFS(dir))
+	if err != nil {
+		return nil, fmt.Errorf("current: %w", err)
+	}
+
+	var previousFS fs.FS
+	if againstDir != "" {
+		previousFS = os.DirFS(againstDir)
+	} else {
+		previousFS, err = gitFS(against, dir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	previous, err := protocheck.Compile(ctx, previousFS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", against, err)
+	}
+
+	return protocheck.Compare(previous, current), nil
+}
+
+// gitFS reads the .proto files under dir at ref, with paths relative to dir
+func gitFS(ref, dir string) (fs.FS, error) {
+	list, err := git("ls-tree", "-r", "--name-only", ref, "--", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := fstest.MapFS{}
+	prefix := strings.TrimSuffix(path.Clean(dir), "/") + "/"
+	for _, name := range strings.Split(strings.TrimSpace(string(list)), "\n") {
+		if path.Ext(name) != ".proto" {
+			continue
+		}
+		data, err := git("show", ref+":./"+name)
+		if err != nil {
+			return nil, err
+		}
+		fsys[strings.TrimPrefix(name, prefix)] = &fstest.MapFile{Data: data, Mode: 0o644}
+	}
+	return fsys, nil
+}
+
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
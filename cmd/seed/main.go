@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/seed"
+)
+
+// seed fills a running environment through the public shipment API. To keep
+// names, addresses and status histories, seed the databases with the
+// services' own "seed --generate" instead.
+func main() {
+	cfg := seed.DefaultConfig()
+	url := flag.String("url", "http://localhost:8080", "base URL of the shipment API")
+	timeout := flag.Duration("timeout", 10*time.Second, "per-request timeout")
+	cfg.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	d, err := seed.Generate(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	n, err := d.WriteAPI(ctx, &http.Client{Timeout: *timeout}, strings.TrimSuffix(*url, "/"))
+	fmt.Printf("created %d of %d shipments\n", n, len(d.Shipments))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "seed:", err)
+		os.Exit(1)
+	}
+}
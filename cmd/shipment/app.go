@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	"github.com/aidosgal/transline-test/pkg/postgres"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+)
+
+// newLogger builds the service logger writing JSON to w; the returned func
+// flushes OTLP logs
+func newLogger(ctx context.Context, cfg *config.Shipment, w io.Writer) (*slog.Logger, *customlogger.Levels, func(), error) {
+	logExport, err := customlogger.ParseExport(cfg.Log.Export)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	redactMode, err := customlogger.ParseRedactMode(cfg.Log.Redact)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	levels, err := customlogger.ParseLevels(cfg.Log.Level, cfg.Log.Levels)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid log config: %w", err)
+	}
+
+	shutdown := func() {}
+	var lp *sdklog.LoggerProvider
+	if logExport.OTLP() {
+		lp, err = setupLoggerProvider(ctx, cfg.Name)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to initialize logger provider: %w", err)
+		}
+		shutdown = func() {
+			if err := lp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+				slog.Error("failed to shutdown logger provider", slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	// Create logger with trace context support, redacting personal data
+	// before it reaches stdout, span events or OTLP. Levels are filtered
+	// per layer up front, so the inner handlers accept everything.
+	log := slog.New(
+		customlogger.NewLevelHandler(
+			customlogger.NewRedactHandler(
+				customlogger.NewHandler(
+					slog.NewJSONHandler(w, &slog.HandlerOptions{
+						Level: slog.LevelDebug,
+					}),
+					logExport,
+					lp,
+					cfg.Name,
+					slog.LevelDebug,
+				),
+				customlogger.NewRedactor(redactMode, cfg.Log.RedactSalt),
+			),
+			levels,
+		),
+	)
+
+	return log, levels, shutdown, nil
+}
+
+func openDB(ctx context.Context, log *slog.Logger, cfg *config.Shipment) (*sql.DB, error) {
+	return postgres.Open(ctx, log, postgresConfig(cfg))
+}
+
+// openReplica opens the read replica, nil if none is configured
+func openReplica(ctx context.Context, log *slog.Logger, cfg *config.Shipment) (*sql.DB, error) {
+	pc := postgresConfig(cfg)
+	replica, ok := pc.ReplicaConfig()
+	if !ok {
+		return nil, nil
+	}
+	return postgres.Open(ctx, log, replica)
+}
+
+// postgresConfig names the connections after the service unless configured otherwise
+func postgresConfig(cfg *config.Shipment) config.PostgresConfig {
+	pc := cfg.Postgres
+	if pc.ApplicationName == "" {
+		pc.ApplicationName = cfg.Name
+	}
+	return pc
+}
+
+func newMigrator(log *slog.Logger, db *sql.DB, cfg *config.Shipment) (*postgres.Migrator, error) {
+	pc := postgresConfig(cfg)
+	return postgres.NewMigrator(log, db, pc.BuildPostgresMigrationURL(),
+		storage.Migrations, "migrations", "shipment-service")
+}
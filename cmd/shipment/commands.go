@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/cli"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	"github.com/aidosgal/transline-test/pkg/seed"
+	"github.com/aidosgal/transline-test/services/shipment/client"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+)
+
+// fixtures is the format of seed --fixtures files. Shipments reference
+// customers of customer-service by ID; an ID makes re-seeding idempotent.
+type fixtures struct {
+	Shipments []struct {
+		ID         string `json:"id"`
+		Route      string `json:"route"`
+		Price      int    `json:"price"`
+		CustomerID string `json:"customer_id"`
+	} `json:"shipments"`
+}
+
+func runMigrate(ctx context.Context, cfg *config.Shipment, args []string) error {
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := newMigrator(log, db, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	return migrator.Run(ctx, args, os.Stdout)
+}
+
+// runSeed inserts every shipment of the fixtures file, or the generated ones
+// with their histories, in one transaction. Generated shipments reference the
+// customers customer-service generates with the same seed flags.
+func runSeed(ctx context.Context, cfg *config.Shipment, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	path := fs.String("fixtures", "", "JSON file with the shipments to insert")
+	generate := fs.Bool("generate", false, "insert generated shipments instead of fixtures")
+	seedCfg := seed.DefaultConfig()
+	seedCfg.RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*path == "") != *generate {
+		return cli.ErrUsage
+	}
+
+	var fx fixtures
+	if *path != "" {
+		data, err := os.ReadFile(*path)
+		if err != nil {
+			return fmt.Errorf("failed to read fixtures: %w", err)
+		}
+		if err := json.Unmarshal(data, &fx); err != nil {
+			return fmt.Errorf("failed to parse fixtures: %w", err)
+		}
+	}
+
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *generate {
+		d, err := seed.Generate(seedCfg)
+		if err != nil {
+			return err
+		}
+		created, err := d.WriteShipments(ctx, storage.New(log, db, nil))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("seeded %d generated shipments, %d created\n", len(d.Shipments), created)
+		return nil
+	}
+
+	err = storage.New(log, db, nil).WithTx(ctx, func(tx storage.Storage) error {
+		for i, s := range fx.Shipments {
+			if s.CustomerID == "" {
+				return fmt.Errorf("shipment %d has no customer_id", i)
+			}
+			req := &entity.CreateReq{Route: s.Route, Price: s.Price}
+			var err error
+			if s.ID != "" {
+				_, err = tx.CreateShipmentWithID(ctx, s.ID, req, s.CustomerID)
+			} else {
+				_, err = tx.CreateShipment(ctx, req, s.CustomerID)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to seed shipments: %w", err)
+	}
+
+	fmt.Printf("seeded %d shipments\n", len(fx.Shipments))
+	return nil
+}
+
+// runTariff imports a tariff file, which prices quotes from then on with
+// pricing.tariff_source=db, or prints a tariff as JSON
+func runTariff(ctx context.Context, cfg *config.Shipment, args []string) error {
+	if len(args) == 0 {
+		return cli.ErrUsage
+	}
+
+	var tariff *entity.Tariff
+	switch {
+	case args[0] == "default" && len(args) == 1:
+		tariff = pricing.Default()
+	case args[0] == "import" && len(args) == 2, args[0] == "show" && len(args) == 1:
+		log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+		if err != nil {
+			return err
+		}
+		defer shutdownLogger()
+
+		db, err := openDB(ctx, log, cfg)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		st := storage.New(log, db, nil)
+
+		if args[0] == "show" {
+			tariff, err = pricing.NewLoader(&cfg.Pricing, st)(ctx)
+			if err != nil {
+				return err
+			}
+			break
+		}
+
+		tariff, err = pricing.LoadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if err := st.SaveTariff(ctx, tariff); err != nil {
+			return err
+		}
+		fmt.Printf("imported tariff %s with %d lanes\n", tariff.Name, len(tariff.Lanes))
+		return nil
+	default:
+		return cli.ErrUsage
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(tariff)
+}
+
+func runConfig(cfg *config.Shipment, args []string) error {
+	if len(args) != 1 {
+		return cli.ErrUsage
+	}
+	switch args[0] {
+	case "print":
+		return config.Print(os.Stdout, cfg)
+	case "reference":
+		fmt.Print("# shipment-service configuration\n\n" +
+			"Generated by `shipment-service config reference`, do not edit.\n\n")
+		return config.Reference(os.Stdout, cfg)
+	default:
+		return cli.ErrUsage
+	}
+}
+
+// runCheck runs the readiness checks once and prints the result
+func runCheck(ctx context.Context, cfg *config.Shipment) error {
+	log, _, shutdownLogger, err := newLogger(ctx, cfg, os.Stderr)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	// Not openDB, so an unreachable database is reported as a failed check
+	pc := postgresConfig(cfg)
+	db, err := sql.Open("postgres", pc.BuildPostgresURL())
+	if err != nil {
+		return fmt.Errorf("failed to open db: %w", err)
+	}
+	defer db.Close()
+
+	customerClient, err := client.New(log, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create customer client: %w", err)
+	}
+	defer customerClient.Close()
+
+	checker := health.New(log, 5*time.Second)
+	checker.Add("postgres", health.DBCheck(db))
+	checker.Add("migrations", health.MigrationCheck(db))
+	if replicaConfig, ok := pc.ReplicaConfig(); ok {
+		replica, err := sql.Open("postgres", replicaConfig.BuildPostgresURL())
+		if err != nil {
+			return fmt.Errorf("failed to open replica: %w", err)
+		}
+		defer replica.Close()
+		checker.Add("postgres-replica", health.DBCheck(replica))
+	}
+	checker.Add("otel-collector", health.TCPCheck(collectorEndpoint))
+	checker.Add("customer-service", health.GRPCCheck(customerClient.Conn(), customer.Customer_ServiceDesc.ServiceName))
+
+	result, ready := checker.Ready(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		return err
+	}
+	if !ready {
+		return errors.New("some checks failed")
+	}
+	return nil
+}
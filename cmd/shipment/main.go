@@ -2,184 +2,148 @@ package main
 
 import (
 	"context"
-	"database/sql"
-	"errors"
+	"flag"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
+	"github.com/aidosgal/transline-test/pkg/cli"
 	"github.com/aidosgal/transline-test/pkg/config"
-	customlogger "github.com/aidosgal/transline-test/pkg/logger"
-	"github.com/aidosgal/transline-test/services/shipment/client"
-	"github.com/aidosgal/transline-test/services/shipment/server"
-	"github.com/aidosgal/transline-test/services/shipment/storage"
-	"github.com/aidosgal/transline-test/services/shipment/usecase"
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
-	"github.com/golang-migrate/migrate/v4"
-	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
-	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
 
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
 	_ "github.com/lib/pq"
 )
 
-func main() {
-	cfg := config.MustLoad()
-
-	// Create logger with trace context support
-	log := slog.New(
-		customlogger.NewTraceHandler(
-			slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-				Level: slog.LevelInfo,
-			}),
-		),
-	)
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	tp, err := setupTracer(ctx, log)
-	if err != nil {
-		log.Error("failed to initialize tracer", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	defer func() {
-		if err := tp.Shutdown(ctx); err != nil {
-			log.Error("failed to shutdown tracer", slog.String("error", err.Error()))
-		}
-	}()
-
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{},
-		propagation.Baggage{},
-	))
-
-	postgresURL := cfg.Shipment.Postgres.BuildPostgresURL()
-	log.Info("connecting to database", slog.String("url", postgresURL))
+const collectorEndpoint = "otel-collector:4318"
 
-	db, err := sql.Open("postgres", postgresURL)
-	if err != nil {
-		log.Error("failed to open db", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-	defer db.Close()
+//go:generate sh -c "go run . config reference > ../../docs/config/shipment.md"
 
-	if err = db.Ping(); err != nil {
-		log.Error("failed to connect to db", slog.String("error", err.Error()))
+func main() {
+	flags := flag.NewFlagSet("shipment-service", flag.ExitOnError)
+	configPath := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file, overridden by environment variables")
+	profile := flags.String("profile", "", "dev, test or prod, overrides PROFILE")
+	flags.Parse(os.Args[1:])
+
+	cfg := &config.Shipment{}
+	if err := config.Load(cfg, *configPath, *profile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
-	log.Info("connected to database")
 
-	migrationURL := cfg.Shipment.Postgres.BuildPostgresMigrationURL()
-	migrationPath := "/app/migrations"
+	os.Exit(cli.Run("shipment-service", flags.Args(),
+		cli.Command{
+			Name:  "serve",
+			Short: "run the HTTP server (default)",
+			Run: func(ctx context.Context, args []string) error {
+				return serve(ctx, cfg)
+			},
+		},
+		cli.Command{
+			Name:  "migrate",
+			Usage: "up | down [N] | goto V | version | force V",
+			Short: "manage the database schema",
+			Run: func(ctx context.Context, args []string) error {
+				return runMigrate(ctx, cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "seed",
+			Usage: "--fixtures file.json | --generate [seed flags]",
+			Short: "insert shipments from a fixtures file or generated ones",
+			Run: func(ctx context.Context, args []string) error {
+				return runSeed(ctx, cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "tariff",
+			Usage: "import file.json | show | default",
+			Short: "save a tariff to the database, print the configured one or the built-in one",
+			Run: func(ctx context.Context, args []string) error {
+				return runTariff(ctx, cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "config",
+			Usage: "print | reference",
+			Short: "print the effective config with secrets masked, or a reference of every setting",
+			Run: func(ctx context.Context, args []string) error {
+				return runConfig(cfg, args)
+			},
+		},
+		cli.Command{
+			Name:  "check",
+			Short: "verify Postgres, migrations, the collector and customer-service are reachable",
+			Run: func(ctx context.Context, args []string) error {
+				return runCheck(ctx, cfg)
+			},
+		},
+	))
+}
 
-	m, err := migrate.New("file://"+migrationPath, migrationURL)
+func setupTracer(ctx context.Context, log *slog.Logger, name string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpoint(collectorEndpoint),
+		otlptracehttp.WithInsecure(),
+	)
 	if err != nil {
-		log.Error("failed to init migrations service", slog.String("error", err.Error()))
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
 	}
 
-	if err := m.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			log.Info("no migrations to apply", slog.String("error", err.Error()))
-		}
-	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	)
 
-	log.Info("migration applied successfully")
+	log.Info("OpenTelemetry tracer initialized", slog.String("endpoint", collectorEndpoint))
+	return tp, nil
+}
 
-	customerClient, err := client.New(cfg)
+func setupLoggerProvider(ctx context.Context, name string) (*sdklog.LoggerProvider, error) {
+	exporter, err := otlploghttp.New(ctx,
+		otlploghttp.WithEndpoint(collectorEndpoint),
+		otlploghttp.WithInsecure(),
+	)
 	if err != nil {
-		log.Error("failed to connect to customer GRPC", slog.String("error", err.Error()))
-		os.Exit(1)
-	}
-
-	defer customerClient.Close()
-
-	shipmentStorage := storage.New(log, db)
-	shipmentUsecase := usecase.New(log, shipmentStorage, customerClient)
-	shipmentServer := server.New(log, shipmentUsecase)
-
-	router := chi.NewRouter()
-	router.Use(middleware.Logger)
-	router.Use(middleware.URLFormat)
-	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
-
-	router.Route("/api/v1", func(apiRouter chi.Router) {
-		apiRouter.Route("/shipments", func(authRouter chi.Router) {
-			authRouter.Post("/", shipmentServer.CreateShipment)
-			authRouter.Get("/{id}", shipmentServer.GetShipment)
-		})
-	})
-
-	wrappedChi := otelhttp.NewHandler(router, "shipment-service")
-
-	address := fmt.Sprintf(":%d", 8080)
-	server := &http.Server{
-		Addr:    address,
-		Handler: wrappedChi,
+		return nil, fmt.Errorf("failed to create OTLP log exporter: %w", err)
 	}
 
-	go func() {
-		log.Info("HTTP server listenipng", slog.String("address", address))
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Error("server error", slog.String("error", err.Error()))
-			cancel()
-		}
-	}()
-
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Info("shutting down server gracefully...")
-
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer shutdownCancel()
-
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Error("server forced to shutdown", slog.String("error", err.Error()))
-	}
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(name),
+		)),
+	)
 
-	log.Info("server stopped")
+	return lp, nil
 }
 
-func setupTracer(ctx context.Context, log *slog.Logger) (*sdktrace.TracerProvider, error) {
-	exporter, err := otlptracehttp.New(ctx,
-		otlptracehttp.WithEndpoint("otel-collector:4318"),
-		otlptracehttp.WithInsecure(),
+func setupMeterProvider(ctx context.Context, name string) (*sdkmetric.MeterProvider, error) {
+	exporter, err := otlpmetrichttp.New(ctx,
+		otlpmetrichttp.WithEndpoint(collectorEndpoint),
+		otlpmetrichttp.WithInsecure(),
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		return nil, fmt.Errorf("failed to create OTLP metric exporter: %w", err)
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
-		sdktrace.WithResource(resource.NewWithAttributes(
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+		sdkmetric.WithResource(resource.NewWithAttributes(
 			semconv.SchemaURL,
-			semconv.ServiceName("shipment-service"),
+			semconv.ServiceName(name),
 		)),
 	)
 
-	log.Info("OpenTelemetry tracer initialized", slog.String("endpoint", "otel-collector:4318"))
-	return tp, nil
+	return mp, nil
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	"github.com/aidosgal/transline-test/services/shipment/client"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+	"github.com/aidosgal/transline-test/services/shipment/saga"
+	"github.com/aidosgal/transline-test/services/shipment/server"
+	"github.com/aidosgal/transline-test/services/shipment/sla"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+	"github.com/aidosgal/transline-test/services/shipment/usecase"
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// serve runs the HTTP server until ctx is cancelled or the server fails
+func serve(ctx context.Context, cfg *config.Shipment) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	log, levels, shutdownLogger, err := newLogger(ctx, cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+	defer shutdownLogger()
+
+	tp, err := setupTracer(ctx, log, cfg.Name)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracer: %w", err)
+	}
+	defer func() {
+		if err := tp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			log.Error("failed to shutdown tracer", slog.String("error", err.Error()))
+		}
+	}()
+
+	mp, err := setupMeterProvider(ctx, cfg.Name)
+	if err != nil {
+		return fmt.Errorf("failed to initialize meter provider: %w", err)
+	}
+	defer func() {
+		if err := mp.Shutdown(context.WithoutCancel(ctx)); err != nil {
+			log.Error("failed to shutdown meter provider", slog.String("error", err.Error()))
+		}
+	}()
+
+	otel.SetMeterProvider(mp)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	db, err := openDB(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	replica, err := openReplica(ctx, log, cfg)
+	if err != nil {
+		return err
+	}
+	if replica != nil {
+		defer replica.Close()
+	}
+
+	migrator, err := newMigrator(log, db, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	if err := migrator.Up(ctx); err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	log.Info("migrations applied")
+
+	customerClient, err := client.New(log, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to customer GRPC: %w", err)
+	}
+	defer customerClient.Close()
+
+	shipmentStorage := storage.New(log, db, replica)
+	shipmentSaga := saga.New(log, shipmentStorage, customerClient, cfg.Saga.MaxAttempts)
+	go shipmentSaga.Run(ctx, cfg.Saga.RecoveryInterval, cfg.Saga.StaleAfter)
+
+	engine, err := pricing.Open(ctx, log, &cfg.Pricing, shipmentStorage)
+	if err != nil {
+		return err
+	}
+
+	monitor := sla.New(log, shipmentStorage, sla.NewPublisher(log, &cfg.SLA), &cfg.SLA)
+	go monitor.Run(ctx)
+
+	shipmentUsecase := usecase.New(log, shipmentStorage, shipmentSaga, engine, monitor)
+	shipmentServer := server.New(log, shipmentUsecase)
+
+	checker := health.New(log, 2*time.Second)
+	checker.Add("postgres", health.DBCheck(db))
+	checker.Add("migrations", health.MigrationCheck(db))
+	if replica != nil {
+		checker.Add("postgres-replica", health.DBCheck(replica))
+	}
+	checker.Add("customer-service", health.GRPCCheck(customerClient.Conn(), customer.Customer_ServiceDesc.ServiceName))
+
+	router := server.NewRouter(log, shipmentServer, checker, levels, cfg.AdminToken, &cfg.Tracking)
+
+	wrappedChi := otelhttp.NewHandler(router, cfg.Name)
+
+	address := fmt.Sprintf(":%d", cfg.Port)
+	server := &http.Server{
+		Addr:    address,
+		Handler: wrappedChi,
+	}
+
+	go func() {
+		log.Info("HTTP server listenipng", slog.String("address", address))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Error("server error", slog.String("error", err.Error()))
+			cancel()
+		}
+	}()
+
+	<-ctx.Done()
+
+	log.Info("shutting down server gracefully...")
+	checker.Drain(cfg.DrainDelay)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer shutdownCancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Error("server forced to shutdown", slog.String("error", err.Error()))
+	}
+
+	log.Info("server stopped")
+	return nil
+}
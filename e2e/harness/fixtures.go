@@ -0,0 +1,79 @@
+package harness
+
+import (
+	"context"
+
+	customerentity "github.com/aidosgal/transline-test/services/customer/entity"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// DefaultIDN is the customer IDN of requests built by NewCreateReq
+const DefaultIDN = "990101300123"
+
+// CreateReqBuilder builds a valid create-shipment request; each setter
+// overrides one field
+type CreateReqBuilder struct {
+	req entity.CreateReq
+}
+
+func NewCreateReq() *CreateReqBuilder {
+	return &CreateReqBuilder{req: entity.CreateReq{
+		Route:    "ALMATY→ASTANA",
+		Price:    120000,
+		Customer: entity.CreateCustomerReq{IDN: DefaultIDN},
+	}}
+}
+
+func (b *CreateReqBuilder) Route(route string) *CreateReqBuilder {
+	b.req.Route = route
+	return b
+}
+
+func (b *CreateReqBuilder) Price(price int) *CreateReqBuilder {
+	b.req.Price = price
+	return b
+}
+
+func (b *CreateReqBuilder) IDN(idn string) *CreateReqBuilder {
+	b.req.Customer.IDN = idn
+	return b
+}
+
+func (b *CreateReqBuilder) Cargo(cargo *entity.Cargo) *CreateReqBuilder {
+	b.req.Cargo = cargo
+	return b
+}
+
+func (b *CreateReqBuilder) Windows(pickup, delivery *entity.Window) *CreateReqBuilder {
+	b.req.PickupWindow, b.req.DeliveryWindow = pickup, delivery
+	return b
+}
+
+// Build returns a copy, so the builder can be reused
+func (b *CreateReqBuilder) Build() *entity.CreateReq {
+	req := b.req
+	req.Cargo = b.req.Cargo.Clone()
+	return &req
+}
+
+// GivenCustomer stores a customer in customer-service
+func (h *Harness) GivenCustomer(idn string) *customerentity.Customer {
+	h.t.Helper()
+	customer, err := h.CustomerStorage.UpsertCustomer(context.Background(), idn, "")
+	if err != nil {
+		h.t.Fatalf("given customer %s: %v", idn, err)
+	}
+	return customer
+}
+
+// GivenShipment stores a shipment of the request's customer, creating the
+// customer if needed, without going through the API
+func (h *Harness) GivenShipment(req *entity.CreateReq) *entity.Shipment {
+	h.t.Helper()
+	customer := h.GivenCustomer(req.Customer.IDN)
+	shipment, err := h.ShipmentStorage.CreateShipment(context.Background(), req, customer.ID)
+	if err != nil {
+		h.t.Fatalf("given shipment: %v", err)
+	}
+	return shipment
+}
@@ -0,0 +1,83 @@
+package harness
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the actual output")
+
+var (
+	uuidPattern = regexp.MustCompile(`[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+	timePattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+	// trackingPattern matches entity.NewTrackingNumber
+	trackingPattern = regexp.MustCompile(`TL[0-9A-F]{12}`)
+)
+
+// AssertGolden compares the JSON body with testdata/<name>.golden.json,
+// rewriting the file when the test runs with -update. UUIDs, timestamps and
+// tracking numbers are replaced with numbered placeholders in order of
+// appearance, so equal IDs stay equal and the file is stable across runs.
+func AssertGolden(t *testing.T, name string, body []byte) {
+	t.Helper()
+
+	got, err := normalize(body)
+	if err != nil {
+		t.Fatalf("golden %s: %v\n%s", name, err, body)
+	}
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("golden %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("golden %s: %v", name, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("golden %s: %v; run the test with -update to create it", name, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("golden %s mismatch; run the test with -update if the change is intended\ngot:\n%s\nwant:\n%s",
+			name, got, want)
+	}
+}
+
+// normalize indents the JSON and replaces dynamic values with placeholders
+func normalize(body []byte) ([]byte, error) {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	indented, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	indented = placeholders(indented, uuidPattern, "uuid")
+	indented = placeholders(indented, timePattern, "time")
+	indented = placeholders(indented, trackingPattern, "tracking")
+	return append(indented, '\n'), nil
+}
+
+func placeholders(data []byte, pattern *regexp.Regexp, kind string) []byte {
+	seen := map[string]string{}
+	return pattern.ReplaceAllFunc(data, func(match []byte) []byte {
+		placeholder, ok := seen[string(match)]
+		if !ok {
+			placeholder = fmt.Sprintf("<%s-%d>", kind, len(seen)+1)
+			seen[string(match)] = placeholder
+		}
+		return []byte(placeholder)
+	})
+}
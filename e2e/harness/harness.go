@@ -0,0 +1,322 @@
+// Package harness runs customer-service and the shipment HTTP API in the test
+// process: the customer gRPC server on bufconn, the shipment router on an
+// httptest.Server, in-memory storages and a span recorder.
+package harness
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	customerserver "github.com/aidosgal/transline-test/services/customer/server"
+	customerstorage "github.com/aidosgal/transline-test/services/customer/storage"
+	customerusecase "github.com/aidosgal/transline-test/services/customer/usecase"
+	"github.com/aidosgal/transline-test/services/shipment/client"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+	"github.com/aidosgal/transline-test/services/shipment/saga"
+	shipmentserver "github.com/aidosgal/transline-test/services/shipment/server"
+	"github.com/aidosgal/transline-test/services/shipment/sla"
+	shipmentstorage "github.com/aidosgal/transline-test/services/shipment/storage"
+	shipmentusecase "github.com/aidosgal/transline-test/services/shipment/usecase"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// ShipmentServiceName names the HTTP server spans, as in shipment-service
+const ShipmentServiceName = "shipment-service"
+
+const bufSize = 1 << 20
+
+// Harness is one running pair of services. The tracer provider and
+// propagator are process-wide, so tests using a Harness must not run in parallel.
+type Harness struct {
+	t *testing.T
+
+	// CustomerStorage and ShipmentStorage back the services, for fixtures
+	// and assertions on persisted state
+	CustomerStorage customerstorage.Storage
+	ShipmentStorage shipmentstorage.Storage
+
+	// CustomerClient is the client shipment-service uses
+	CustomerClient *client.CustomerClient
+	Saga           saga.Orchestrator
+	// Pricing prices quotes, with the built-in tariff unless WithTariff is given
+	Pricing pricing.Engine
+	// SLA does not run in the background; tests call Check
+	SLA sla.Monitor
+	// Breaches records what SLA publishes
+	Breaches *Breaches
+
+	// Server serves the shipment router
+	Server *httptest.Server
+	Spans  *Spans
+}
+
+// Option changes how a Harness is built
+type Option func(*options)
+
+type options struct {
+	log             *slog.Logger
+	customerStorage customerstorage.Storage
+	shipmentStorage shipmentstorage.Storage
+	client          func(cfg *config.ClientConfig)
+	tracking        func(cfg *config.TrackingConfig)
+	tariff          *entity.Tariff
+}
+
+// WithLogger sends the logs of both services to log instead of discarding them
+func WithLogger(log *slog.Logger) Option {
+	return func(o *options) {
+		o.log = log
+	}
+}
+
+// WithCustomerStorage replaces the in-memory customer storage
+func WithCustomerStorage(s customerstorage.Storage) Option {
+	return func(o *options) {
+		o.customerStorage = s
+	}
+}
+
+// WithShipmentStorage replaces the in-memory shipment storage
+func WithShipmentStorage(s shipmentstorage.Storage) Option {
+	return func(o *options) {
+		o.shipmentStorage = s
+	}
+}
+
+// WithClientConfig changes the customer client settings, e.g. to disable the cache
+func WithClientConfig(fn func(cfg *config.ClientConfig)) Option {
+	return func(o *options) {
+		o.client = fn
+	}
+}
+
+// WithTrackingConfig changes the limits of public tracking
+func WithTrackingConfig(fn func(cfg *config.TrackingConfig)) Option {
+	return func(o *options) {
+		o.tracking = fn
+	}
+}
+
+// WithTariff prices quotes with tariff instead of the built-in one
+func WithTariff(tariff *entity.Tariff) Option {
+	return func(o *options) {
+		o.tariff = tariff
+	}
+}
+
+// New starts the services; they are stopped when the test ends
+func New(t *testing.T, opts ...Option) *Harness {
+	t.Helper()
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.log == nil {
+		o.log = slog.New(customlogger.NewTraceHandler(slog.NewTextHandler(io.Discard, nil)))
+	}
+	if o.customerStorage == nil {
+		o.customerStorage = customerstorage.NewMemory(o.log)
+	}
+	if o.shipmentStorage == nil {
+		o.shipmentStorage = shipmentstorage.NewMemory(o.log)
+	}
+	if o.tariff == nil {
+		o.tariff = pricing.Default()
+	}
+
+	spans := newSpans()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans.recorder))
+	prevTP, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+	t.Cleanup(func() {
+		tp.Shutdown(context.Background())
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+
+	// customer-service
+	customerServer := customerserver.New(o.log, customerusecase.New(o.log, o.customerStorage))
+	grpcServer := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(certs.UnaryServerInterceptor()),
+	)
+	pb.RegisterCustomerServer(grpcServer, customerServer)
+	healthServer := grpchealth.NewServer()
+	healthServer.SetServingStatus(pb.Customer_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	lis := bufconn.Listen(bufSize)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	// shipment-service
+	cfg := &config.Shipment{}
+	if err := config.Load(cfg, "", config.ProfileTest); err != nil {
+		t.Fatalf("harness: load config: %v", err)
+	}
+	cfg.Customer = config.EndpointConfig{URL: "bufnet", Port: cfg.Customer.Port}
+	cfg.CustomerClient.Resolver = config.ResolverPassthrough
+	cfg.CustomerClient.TLS = config.TLSConfig{}
+	if o.client != nil {
+		o.client(&cfg.CustomerClient)
+	}
+	if o.tracking != nil {
+		o.tracking(&cfg.Tracking)
+	}
+
+	customerClient, err := client.New(o.log, cfg,
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatalf("harness: customer client: %v", err)
+	}
+	t.Cleanup(func() { customerClient.Close() })
+
+	shipmentSaga := saga.New(o.log, o.shipmentStorage, customerClient, cfg.Saga.MaxAttempts)
+	engine, err := pricing.New(o.log, o.tariff)
+	if err != nil {
+		t.Fatalf("harness: pricing: %v", err)
+	}
+	breaches := &Breaches{}
+	monitor := sla.New(o.log, o.shipmentStorage, breaches, &cfg.SLA)
+	shipmentServer := shipmentserver.New(o.log, shipmentusecase.New(o.log, o.shipmentStorage, shipmentSaga, engine, monitor))
+
+	checker := health.New(o.log, 2*time.Second)
+	checker.Add("customer-service", health.GRPCCheck(customerClient.Conn(), pb.Customer_ServiceDesc.ServiceName))
+	levels := customlogger.NewLevels(slog.LevelDebug, nil)
+
+	router := shipmentserver.NewRouter(o.log, shipmentServer, checker, levels, "", &cfg.Tracking)
+	server := httptest.NewServer(otelhttp.NewHandler(router, ShipmentServiceName))
+	t.Cleanup(server.Close)
+
+	return &Harness{
+		t:               t,
+		CustomerStorage: o.customerStorage,
+		ShipmentStorage: o.shipmentStorage,
+		CustomerClient:  customerClient,
+		Saga:            shipmentSaga,
+		Pricing:         engine,
+		SLA:             monitor,
+		Breaches:        breaches,
+		Server:          server,
+		Spans:           spans,
+	}
+}
+
+// Breaches is an SLA publisher that keeps what it is given
+type Breaches struct {
+	mu       sync.Mutex
+	breaches []*entity.SLABreach
+}
+
+func (b *Breaches) Publish(ctx context.Context, breach *entity.SLABreach) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.breaches = append(b.breaches, breach)
+	return nil
+}
+
+// All returns the published breaches, oldest first
+func (b *Breaches) All() []*entity.SLABreach {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.breaches)
+}
+
+// Response is a completed HTTP exchange
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Decode unmarshals the body into v, failing the test on error
+func (r *Response) Decode(t *testing.T, v any) {
+	t.Helper()
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		t.Fatalf("decode response %s: %v", r.Body, err)
+	}
+}
+
+// Do sends a request to the shipment router; body is marshalled to JSON
+// unless it is a string or []byte, which are sent as is
+func (h *Harness) Do(method, path string, body any) *Response {
+	h.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = bytes.NewReader([]byte(b))
+	case []byte:
+		reader = bytes.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			h.t.Fatalf("marshal request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, h.Server.URL+path, reader)
+	if err != nil {
+		h.t.Fatalf("new request: %v", err)
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := h.Server.Client().Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		h.t.Fatalf("read response: %v", err)
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: data}
+}
+
+// Post sends body as JSON
+func (h *Harness) Post(path string, body any) *Response {
+	h.t.Helper()
+	return h.Do(http.MethodPost, path, body)
+}
+
+// Get sends a GET without a body
+func (h *Harness) Get(path string) *Response {
+	h.t.Helper()
+	return h.Do(http.MethodGet, path, nil)
+}
@@ -0,0 +1,156 @@
+package harness
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// spanWait is how long assertions wait for spans; a server span ends only
+// after the response was written, possibly after the client read it
+const spanWait = 2 * time.Second
+
+// Spans records every span ended while the Harness runs
+type Spans struct {
+	recorder *tracetest.SpanRecorder
+}
+
+func newSpans() *Spans {
+	return &Spans{recorder: tracetest.NewSpanRecorder()}
+}
+
+// SpanMatcher selects spans by name and kind; an empty Name matches any name
+type SpanMatcher struct {
+	Name string
+	Kind trace.SpanKind
+}
+
+func (m SpanMatcher) match(span sdktrace.ReadOnlySpan) bool {
+	return (m.Name == "" || span.Name() == m.Name) && span.SpanKind() == m.Kind
+}
+
+func (m SpanMatcher) String() string {
+	return fmt.Sprintf("%s %q", m.Kind, m.Name)
+}
+
+// HTTPServer matches the span otelhttp starts for a request to the shipment router
+func HTTPServer() SpanMatcher {
+	return SpanMatcher{Name: ShipmentServiceName, Kind: trace.SpanKindServer}
+}
+
+// GRPCClient matches the client span of a call to fullMethod, e.g. customer.Customer/UpsertCustomer
+func GRPCClient(fullMethod string) SpanMatcher {
+	return SpanMatcher{Name: fullMethod, Kind: trace.SpanKindClient}
+}
+
+// GRPCServer matches the server span of fullMethod
+func GRPCServer(fullMethod string) SpanMatcher {
+	return SpanMatcher{Name: fullMethod, Kind: trace.SpanKindServer}
+}
+
+// Ended returns the spans ended so far
+func (s *Spans) Ended() []sdktrace.ReadOnlySpan {
+	return s.recorder.Ended()
+}
+
+// Find returns the ended spans matching m
+func (s *Spans) Find(m SpanMatcher) []sdktrace.ReadOnlySpan {
+	var found []sdktrace.ReadOnlySpan
+	for _, span := range s.recorder.Ended() {
+		if m.match(span) {
+			found = append(found, span)
+		}
+	}
+	return found
+}
+
+// AssertChain waits until one trace has a span for every matcher, each a
+// descendant of the one before, e.g.
+//
+//	h.Spans.AssertChain(t, HTTPServer(), GRPCClient(m), GRPCServer(m))
+func (s *Spans) AssertChain(t *testing.T, chain ...SpanMatcher) []sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	deadline := time.Now().Add(spanWait)
+	for {
+		spans := s.recorder.Ended()
+		if found := findChain(spans, chain); found != nil {
+			return found
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no trace has the span chain %v; recorded:\n%s", chain, dump(spans))
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// findChain returns the spans forming chain in one trace, nil if there are none
+func findChain(spans []sdktrace.ReadOnlySpan, chain []SpanMatcher) []sdktrace.ReadOnlySpan {
+	if len(chain) == 0 {
+		return []sdktrace.ReadOnlySpan{}
+	}
+
+	byID := make(map[trace.SpanID]sdktrace.ReadOnlySpan, len(spans))
+	for _, span := range spans {
+		byID[span.SpanContext().SpanID()] = span
+	}
+
+	var walk func(parent sdktrace.ReadOnlySpan, rest []SpanMatcher) []sdktrace.ReadOnlySpan
+	walk = func(parent sdktrace.ReadOnlySpan, rest []SpanMatcher) []sdktrace.ReadOnlySpan {
+		if len(rest) == 0 {
+			return []sdktrace.ReadOnlySpan{}
+		}
+		for _, span := range spans {
+			if !rest[0].match(span) || !descends(span, parent, byID) {
+				continue
+			}
+			if tail := walk(span, rest[1:]); tail != nil {
+				return append([]sdktrace.ReadOnlySpan{span}, tail...)
+			}
+		}
+		return nil
+	}
+
+	for _, root := range spans {
+		if !chain[0].match(root) {
+			continue
+		}
+		if tail := walk(root, chain[1:]); tail != nil {
+			return append([]sdktrace.ReadOnlySpan{root}, tail...)
+		}
+	}
+	return nil
+}
+
+// descends reports whether ancestor is on the parent path of span
+func descends(span, ancestor sdktrace.ReadOnlySpan, byID map[trace.SpanID]sdktrace.ReadOnlySpan) bool {
+	if span.SpanContext().TraceID() != ancestor.SpanContext().TraceID() {
+		return false
+	}
+	for parent := span.Parent(); parent.IsValid(); {
+		if parent.SpanID() == ancestor.SpanContext().SpanID() {
+			return true
+		}
+		next, ok := byID[parent.SpanID()]
+		if !ok {
+			return false
+		}
+		parent = next.Parent()
+	}
+	return false
+}
+
+func dump(spans []sdktrace.ReadOnlySpan) string {
+	var b strings.Builder
+	for _, span := range spans {
+		fmt.Fprintf(&b, "  %s %s %q parent=%s\n",
+			span.SpanContext().SpanID(), span.SpanKind(), span.Name(), span.Parent().SpanID())
+	}
+	return b.String()
+}
@@ -0,0 +1,171 @@
+package e2e
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/e2e/harness"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/google/uuid"
+)
+
+func quoteReq() *entity.QuoteReq {
+	return &entity.QuoteReq{
+		Route:    "ALMATY→ASTANA",
+		Customer: entity.CreateCustomerReq{IDN: harness.DefaultIDN},
+		Cargo: &entity.Cargo{
+			Type:          entity.CargoFragile,
+			WeightKg:      420,
+			VolumeM3:      1.5,
+			DeclaredValue: 900000,
+			PackageCount:  2,
+			Packages:      []entity.Package{{LengthCm: 120, WidthCm: 80, HeightCm: 75, Quantity: 2}},
+		},
+	}
+}
+
+func TestQuoteToShipment(t *testing.T) {
+	h := harness.New(t)
+
+	resp := h.Post("/api/v1/quotes", quoteReq())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_quote", resp.Body)
+
+	quote := &entity.Quote{}
+	resp.Decode(t, quote)
+
+	resp = h.Post("/api/v1/shipments", &entity.CreateReq{QuoteID: quote.ID})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	shipment := &entity.Shipment{}
+	resp.Decode(t, shipment)
+	if shipment.Price != quote.Total || shipment.Route != quote.Route || shipment.QuoteID != quote.ID || shipment.Cargo == nil {
+		t.Errorf("shipment = %+v, want the route, total and cargo of quote %+v", shipment, quote)
+	}
+
+	resp = h.Get("/api/v1/quotes/" + quote.ID)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	got := &entity.Quote{}
+	resp.Decode(t, got)
+	if got.ShipmentID != shipment.ID {
+		t.Errorf("quote shipment_id = %q, want %q", got.ShipmentID, shipment.ID)
+	}
+
+	// A quote locks the price of one shipment only
+	resp = h.Post("/api/v1/shipments", &entity.CreateReq{QuoteID: quote.ID})
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("second shipment from the quote: status = %d, want 409: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestQuoteNormalizesRoute(t *testing.T) {
+	h := harness.New(t)
+
+	req := quoteReq()
+	req.Route = "алматы → Нур-Султан"
+	resp := h.Post("/api/v1/quotes", req)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	quote := &entity.Quote{}
+	resp.Decode(t, quote)
+	// The city lane of ALMATY→ASTANA prices it, not the catch-all one
+	if quote.Route != "ALMATY→ASTANA" || quote.DistanceKm != 1215 || quote.Items[0].Description != "base rate ALMATY→ASTANA" {
+		t.Errorf("quote = %+v, want ALMATY→ASTANA priced by its lane, 1215 km", quote)
+	}
+}
+
+func TestQuoteKeepsPrice(t *testing.T) {
+	h := harness.New(t)
+
+	resp := h.Post("/api/v1/quotes", quoteReq())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	quote := &entity.Quote{}
+	resp.Decode(t, quote)
+
+	tariff := *h.Pricing.Tariff()
+	tariff.Name, tariff.FuelSurchargePct = "fuel-up", tariff.FuelSurchargePct*2
+	if err := h.Pricing.SetTariff(&tariff); err != nil {
+		t.Fatal(err)
+	}
+
+	resp = h.Post("/api/v1/shipments", &entity.CreateReq{QuoteID: quote.ID})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	shipment := &entity.Shipment{}
+	resp.Decode(t, shipment)
+	if shipment.Price != quote.Total {
+		t.Errorf("price = %d after a tariff change, want the quoted %d", shipment.Price, quote.Total)
+	}
+}
+
+func TestQuoteRejected(t *testing.T) {
+	h := harness.New(t)
+
+	expired, err := h.ShipmentStorage.CreateQuote(context.Background(), &entity.Quote{
+		Route:     "ALMATY→ASTANA",
+		Customer:  entity.CreateCustomerReq{IDN: harness.DefaultIDN},
+		Cargo:     quoteReq().Cargo,
+		Total:     50000,
+		Currency:  "KZT",
+		Tariff:    "old",
+		CreatedAt: time.Now().Add(-48 * time.Hour),
+		ExpiresAt: time.Now().Add(-24 * time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := h.Post("/api/v1/quotes", quoteReq())
+	quote := &entity.Quote{}
+	resp.Decode(t, quote)
+
+	tests := []struct {
+		name   string
+		req    *entity.CreateReq
+		status int
+	}{
+		{"expired", &entity.CreateReq{QuoteID: expired.ID}, http.StatusGone},
+		{"unknown", &entity.CreateReq{QuoteID: uuid.NewString()}, http.StatusNotFound},
+		{"other customer", &entity.CreateReq{QuoteID: quote.ID, Customer: entity.CreateCustomerReq{IDN: "900101300126"}},
+			http.StatusUnprocessableEntity},
+		{"price given", &entity.CreateReq{QuoteID: quote.ID, Price: 1}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.Post("/api/v1/shipments", tt.req)
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d: %s", resp.StatusCode, tt.status, resp.Body)
+			}
+		})
+	}
+	if calls := h.Spans.Find(harness.GRPCClient(upsertCustomer)); len(calls) != 0 {
+		t.Errorf("rejected requests made %d customer-service calls", len(calls))
+	}
+}
+
+func TestCreateQuoteInvalid(t *testing.T) {
+	h := harness.New(t)
+
+	req := quoteReq()
+	req.Route, req.Cargo = "ALMATY", nil
+	resp := h.Post("/api/v1/quotes", req)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_quote_invalid", resp.Body)
+
+	resp = h.Get("/api/v1/quotes/not-a-uuid")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404: %s", resp.StatusCode, resp.Body)
+	}
+}
@@ -0,0 +1,194 @@
+package e2e
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aidosgal/transline-test/e2e/harness"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+)
+
+// otelgrpc names spans after the full method without the leading slash
+var upsertCustomer = pb.Customer_UpsertCustomer_FullMethodName[1:]
+
+func TestCreateShipment(t *testing.T) {
+	h := harness.New(t)
+
+	resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Build())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_shipment", resp.Body)
+
+	h.Spans.AssertChain(t,
+		harness.HTTPServer(),
+		harness.GRPCClient(upsertCustomer),
+		harness.GRPCServer(upsertCustomer),
+	)
+
+	created := &entity.Shipment{}
+	resp.Decode(t, created)
+
+	resp = h.Get("/api/v1/shipments/" + created.ID)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "get_shipment", resp.Body)
+}
+
+func TestCreateShipmentExistingCustomer(t *testing.T) {
+	h := harness.New(t)
+	customer := h.GivenCustomer(harness.DefaultIDN)
+
+	resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Route("SHYMKENT→ATYRAU").Build())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+
+	created := &entity.Shipment{}
+	resp.Decode(t, created)
+	if created.CustomerID != customer.ID {
+		t.Errorf("customer_id = %s, want the existing customer %s", created.CustomerID, customer.ID)
+	}
+}
+
+func TestCreateShipmentNormalizesRoute(t *testing.T) {
+	h := harness.New(t)
+
+	tests := []struct {
+		route string
+		want  string
+	}{
+		{"Алматы → Нур-Султан", "ALMATY→ASTANA"},
+		{"Öskemen→Semipalatinsk", "OSKEMEN→SEMEY"},
+		{"Шымкент→Warehouse 7", "SHYMKENT→Warehouse 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Route(tt.route).Build())
+			if resp.StatusCode != http.StatusCreated {
+				t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+			}
+			created := &entity.Shipment{}
+			resp.Decode(t, created)
+			if created.Route != tt.want {
+				t.Errorf("route = %q, want %q", created.Route, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetShipment(t *testing.T) {
+	h := harness.New(t)
+	shipment := h.GivenShipment(harness.NewCreateReq().Price(55000).Build())
+
+	resp := h.Get("/api/v1/shipments/" + shipment.ID)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	got := &entity.Shipment{}
+	resp.Decode(t, got)
+	if got.ID != shipment.ID || got.Price != 55000 {
+		t.Errorf("GET = %+v, want %+v", got, shipment)
+	}
+
+	// Reading a shipment does not call customer-service
+	h.Spans.AssertChain(t, harness.HTTPServer())
+	if calls := h.Spans.Find(harness.GRPCClient(upsertCustomer)); len(calls) != 0 {
+		t.Errorf("GET made %d customer-service calls", len(calls))
+	}
+}
+
+func TestGetShipmentUnknown(t *testing.T) {
+	h := harness.New(t)
+
+	for _, id := range []string{"9b2f8a4e-5c1d-4e7a-8f3b-2d6c0e9a1b47", "not-a-uuid"} {
+		resp := h.Get("/api/v1/shipments/" + id)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404: %s", id, resp.StatusCode, resp.Body)
+		}
+	}
+}
+
+func TestCreateShipmentInvalidBody(t *testing.T) {
+	h := harness.New(t)
+
+	resp := h.Post("/api/v1/shipments", `{"route":`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_shipment_invalid_body", resp.Body)
+}
+
+func TestCreateShipmentCargo(t *testing.T) {
+	h := harness.New(t)
+	minC, maxC := 2.0, 8.0
+	cargo := &entity.Cargo{
+		Type:          entity.CargoTemperatureControlled,
+		WeightKg:      640.5,
+		VolumeM3:      3.2,
+		DeclaredValue: 2500000,
+		PackageCount:  4,
+		Packages: []entity.Package{
+			{LengthCm: 120, WidthCm: 80, HeightCm: 100, Quantity: 2},
+			{LengthCm: 80, WidthCm: 60, HeightCm: 90, Quantity: 2},
+		},
+		TemperatureMinC: &minC,
+		TemperatureMaxC: &maxC,
+	}
+
+	resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Cargo(cargo).Build())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_shipment_cargo", resp.Body)
+
+	created := &entity.Shipment{}
+	resp.Decode(t, created)
+
+	resp = h.Get("/api/v1/shipments/" + created.ID)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	got := &entity.Shipment{}
+	resp.Decode(t, got)
+	if !reflect.DeepEqual(got.Cargo, cargo) {
+		t.Errorf("GET cargo = %+v, want %+v", got.Cargo, cargo)
+	}
+}
+
+func TestCreateShipmentInvalidCargo(t *testing.T) {
+	fragile := &entity.Cargo{Type: entity.CargoFragile, WeightKg: 10, VolumeM3: 0.1, PackageCount: 1}
+	dangerous := &entity.Cargo{Type: entity.CargoDangerous, WeightKg: 10, VolumeM3: 0.1, PackageCount: 1, DangerousClass: "10", UNNumber: "1203"}
+	general := &entity.Cargo{
+		Type: entity.CargoGeneral, WeightKg: 0, VolumeM3: 0.5, PackageCount: 3,
+		Packages:       []entity.Package{{LengthCm: 100, WidthCm: 100, HeightCm: 100, Quantity: 2}},
+		DangerousClass: "3",
+	}
+
+	tests := []struct {
+		golden string
+		cargo  *entity.Cargo
+	}{
+		{"create_shipment_invalid_cargo", general},
+		{"", fragile},
+		{"", dangerous},
+		{"", &entity.Cargo{Type: "LIQUID", WeightKg: 10, VolumeM3: 0.1, PackageCount: 1}},
+		{"", &entity.Cargo{Type: entity.CargoTemperatureControlled, WeightKg: 10, VolumeM3: 0.1, PackageCount: 1}},
+	}
+	for _, tt := range tests {
+		h := harness.New(t)
+		resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Cargo(tt.cargo).Build())
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("cargo %+v: status = %d, want 400: %s", tt.cargo, resp.StatusCode, resp.Body)
+		}
+		if tt.golden != "" {
+			harness.AssertGolden(t, tt.golden, resp.Body)
+		}
+		if calls := h.Spans.Find(harness.GRPCClient(upsertCustomer)); len(calls) != 0 {
+			t.Errorf("cargo %+v: an invalid request made %d customer-service calls", tt.cargo, len(calls))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package e2e
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/e2e/harness"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+func TestCreateShipmentWindows(t *testing.T) {
+	h := harness.New(t)
+	from := time.Now().UTC().Truncate(time.Minute).Add(48 * time.Hour)
+	pickup := &entity.Window{From: from, Until: from.Add(4 * time.Hour)}
+	delivery := &entity.Window{From: from.Add(24 * time.Hour), Until: from.Add(36 * time.Hour)}
+
+	resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Windows(pickup, delivery).Build())
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+	created := &entity.Shipment{}
+	resp.Decode(t, created)
+	if *created.PickupWindow != *pickup || *created.DeliveryWindow != *delivery {
+		t.Errorf("windows = %+v, %+v, want the requested %+v, %+v",
+			created.PickupWindow, created.DeliveryWindow, pickup, delivery)
+	}
+	// ALMATY→ASTANA is 1215 km, 22h05m at 55 km/h
+	if want := from.Add(22*time.Hour + 5*time.Minute); created.ETA == nil || !created.ETA.Equal(want) {
+		t.Errorf("eta = %v, want %v", created.ETA, want)
+	}
+	if created.SLAStatus != entity.SLAOnTrack {
+		t.Errorf("sla_status = %q, want ON_TRACK", created.SLAStatus)
+	}
+}
+
+func TestCreateShipmentInvalidWindows(t *testing.T) {
+	h := harness.New(t)
+	from := time.Now().UTC().Add(48 * time.Hour)
+	pickup := &entity.Window{From: from, Until: from.Add(4 * time.Hour)}
+	delivery := &entity.Window{From: from.Add(-24 * time.Hour), Until: from.Add(-time.Hour)}
+
+	resp := h.Post("/api/v1/shipments", harness.NewCreateReq().Windows(pickup, delivery).Build())
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "create_shipment_invalid_windows", resp.Body)
+}
+
+func TestSLABreach(t *testing.T) {
+	h := harness.New(t)
+	from := time.Now().UTC().Add(-72 * time.Hour)
+	late := h.GivenShipment(harness.NewCreateReq().Windows(
+		&entity.Window{From: from, Until: from.Add(4 * time.Hour)},
+		&entity.Window{From: from.Add(24 * time.Hour), Until: from.Add(48 * time.Hour)},
+	).Build())
+	onTime := h.GivenShipment(harness.NewCreateReq().Windows(nil,
+		&entity.Window{From: from, Until: from.Add(240 * time.Hour)},
+	).Build())
+
+	if checked, err := h.SLA.Check(t.Context()); err != nil || checked != 2 {
+		t.Fatalf("Check = %d, %v, want 2 shipments", checked, err)
+	}
+
+	breaches := h.Breaches.All()
+	if len(breaches) != 1 || breaches[0].ShipmentID != late.ID {
+		t.Fatalf("breaches = %+v, want only %s", breaches, late.ID)
+	}
+
+	resp := h.Get("/api/v1/shipments?sla_status=BREACHED")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	list := &entity.ListResp{}
+	resp.Decode(t, list)
+	if len(list.Shipments) != 1 || list.Shipments[0].ID != late.ID || list.Shipments[0].ETA == nil {
+		t.Errorf("BREACHED shipments = %+v, want %s with an ETA", list.Shipments, late.ID)
+	}
+
+	resp = h.Get("/api/v1/shipments/" + onTime.ID)
+	got := &entity.Shipment{}
+	resp.Decode(t, got)
+	if got.SLAStatus != entity.SLAOnTrack {
+		t.Errorf("sla_status of the shipment on time = %q, want ON_TRACK", got.SLAStatus)
+	}
+}
+
+func TestListShipments(t *testing.T) {
+	h := harness.New(t)
+	first := h.GivenShipment(harness.NewCreateReq().Build())
+	time.Sleep(time.Millisecond)
+	second := h.GivenShipment(harness.NewCreateReq().IDN("000740000004").Build())
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{second.ID, first.ID}},
+		{"?limit=1", []string{second.ID}},
+		{"?limit=1&offset=1", []string{first.ID}},
+		{"?customer_id=" + first.CustomerID, []string{first.ID}},
+		{"?status=DELIVERED", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp := h.Get("/api/v1/shipments" + tt.query)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+			}
+			list := &entity.ListResp{}
+			resp.Decode(t, list)
+			var got []string
+			for _, shipment := range list.Shipments {
+				got = append(got, shipment.ID)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("shipments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	resp := h.Get("/api/v1/shipments?status=DELIVERED")
+	harness.AssertGolden(t, "list_shipments_empty", resp.Body)
+}
+
+func TestListShipmentsInvalidFilter(t *testing.T) {
+	h := harness.New(t)
+
+	for _, query := range []string{"?limit=many", "?offset=-1", "?limit=501", "?sla_status=LATE", "?customer_id=42"} {
+		resp := h.Get("/api/v1/shipments" + query)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400: %s", query, resp.StatusCode, resp.Body)
+		}
+	}
+
+	resp := h.Get("/api/v1/shipments?status=LOST&sla_status=LATE")
+	harness.AssertGolden(t, "list_shipments_invalid_filter", resp.Body)
+}
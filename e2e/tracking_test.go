@@ -0,0 +1,151 @@
+package e2e
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/e2e/harness"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+func TestTimeline(t *testing.T) {
+	h := harness.New(t)
+	shipment := h.GivenShipment(harness.NewCreateReq().Build())
+	// Reported after the arrival, which is dated now
+	departed := shipment.CreatedAt
+
+	events := []*entity.EventReq{
+		{Type: entity.EventArrival, Location: "Караганда, терминал", Note: "разгрузка утром"},
+		{
+			Type: entity.EventDeparture, Location: "Алматы, склад 1", Note: "пломба 0451",
+			GPS: &entity.GPS{Lat: 43.2567, Lon: 76.9286}, OccurredAt: &departed,
+		},
+	}
+	for _, req := range events {
+		resp := h.Post("/api/v1/shipments/"+shipment.ID+"/events", req)
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+		}
+	}
+
+	resp := h.Get("/api/v1/shipments/" + shipment.ID + "/timeline")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "timeline", resp.Body)
+
+	timeline := &entity.Timeline{}
+	resp.Decode(t, timeline)
+	var kinds []string
+	for _, e := range timeline.Entries {
+		kinds = append(kinds, e.Kind+":"+e.Status+e.Type)
+	}
+	if got, want := strings.Join(kinds, " "), "status:CREATED event:DEPARTURE event:ARRIVAL"; got != want {
+		t.Errorf("entries = %s, want %s", got, want)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	h := harness.New(t)
+	shipment := h.GivenShipment(harness.NewCreateReq().Build())
+	resp := h.Post("/api/v1/shipments/"+shipment.ID+"/events", &entity.EventReq{
+		Type: entity.EventDelay, Location: "Балхаш, трасса А-3, км 412", Note: "поломка тягача, водитель +7 701 000 00 00",
+		GPS: &entity.GPS{Lat: 46.85, Lon: 74.98},
+	})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want 201: %s", resp.StatusCode, resp.Body)
+	}
+
+	// Recipients dictate the number over the phone
+	number := strings.ToLower(shipment.TrackingNumber[:7] + "-" + shipment.TrackingNumber[7:])
+	resp = h.Get("/api/v1/tracking/" + number)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+	harness.AssertGolden(t, "track", resp.Body)
+
+	for _, private := range []string{shipment.ID, shipment.CustomerID, shipment.Route, "поломка", "46.85", "км 412", "eta"} {
+		if strings.Contains(string(resp.Body), private) {
+			t.Errorf("public tracking shows %q: %s", private, resp.Body)
+		}
+	}
+}
+
+func TestTrackUnknown(t *testing.T) {
+	h := harness.New(t)
+
+	for _, number := range []string{"TL000000000000", "not-a-number"} {
+		resp := h.Get("/api/v1/tracking/" + number)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404: %s", number, resp.StatusCode, resp.Body)
+		}
+	}
+}
+
+func TestTrackRateLimited(t *testing.T) {
+	h := harness.New(t, harness.WithTrackingConfig(func(cfg *config.TrackingConfig) {
+		cfg.RatePerMinute, cfg.Burst = 1, 2
+	}))
+	shipment := h.GivenShipment(harness.NewCreateReq().Build())
+
+	// Unknown numbers count too, or guessing would be free
+	for _, number := range []string{shipment.TrackingNumber, "TL000000000000"} {
+		if resp := h.Get("/api/v1/tracking/" + number); resp.StatusCode == http.StatusTooManyRequests {
+			t.Fatalf("%s: status = 429 within the burst", number)
+		}
+	}
+	resp := h.Get("/api/v1/tracking/" + shipment.TrackingNumber)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 after the burst: %s", resp.StatusCode, resp.Body)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+
+	// Authenticated endpoints are not limited
+	if resp := h.Get("/api/v1/shipments/" + shipment.ID + "/timeline"); resp.StatusCode != http.StatusOK {
+		t.Errorf("timeline status = %d, want 200: %s", resp.StatusCode, resp.Body)
+	}
+}
+
+func TestAddEventErrors(t *testing.T) {
+	h := harness.New(t)
+	shipment := h.GivenShipment(harness.NewCreateReq().Build())
+	before := shipment.CreatedAt.Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	lat := &entity.GPS{Lat: 91, Lon: 76.9}
+
+	tests := []struct {
+		name   string
+		id     string
+		req    *entity.EventReq
+		status int
+		golden string
+	}{
+		{"invalid", shipment.ID, &entity.EventReq{Type: "LOST", Location: " ", GPS: lat}, http.StatusBadRequest, "add_event_invalid"},
+		{"unknown shipment", "4b0c1a57-6a8f-4e55-a55e-5b2f6b3c0e11", &entity.EventReq{Type: entity.EventCheckpoint, Location: "Балхаш"}, http.StatusNotFound, ""},
+		{"before creation", shipment.ID, &entity.EventReq{Type: entity.EventCheckpoint, Location: "Балхаш", OccurredAt: &before}, http.StatusUnprocessableEntity, ""},
+		{"in the future", shipment.ID, &entity.EventReq{Type: entity.EventCheckpoint, Location: "Балхаш", OccurredAt: &future}, http.StatusUnprocessableEntity, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := h.Post("/api/v1/shipments/"+tt.id+"/events", tt.req)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.status, resp.Body)
+			}
+			if tt.golden != "" {
+				harness.AssertGolden(t, tt.golden, resp.Body)
+			}
+		})
+	}
+
+	resp := h.Get("/api/v1/shipments/" + shipment.ID + "/timeline")
+	timeline := &entity.Timeline{}
+	resp.Decode(t, timeline)
+	if len(timeline.Entries) != 1 {
+		t.Errorf("rejected events are on the timeline: %+v", timeline.Entries)
+	}
+}
@@ -0,0 +1,229 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+)
+
+const (
+	ClientAuthNone          = config.ClientAuthNone
+	ClientAuthVerifyIfGiven = config.ClientAuthVerifyIfGiven
+	ClientAuthRequire       = config.ClientAuthRequire
+)
+
+// Reloader keeps a certificate pair and CA bundle in memory and reloads them
+// when the files change on disk. Files are checked lazily, at most once per
+// interval, from the TLS handshake itself, so no background goroutine is needed.
+type Reloader struct {
+	log      *slog.Logger
+	cfg      config.TLSConfig
+	interval time.Duration
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	pool      *x509.CertPool
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+// NewReloader loads the files from cfg and fails if any of them is unusable
+func NewReloader(log *slog.Logger, cfg config.TLSConfig) (*Reloader, error) {
+	r := &Reloader{
+		log:      log.With("layer", "certs"),
+		cfg:      cfg,
+		interval: cfg.ReloadInterval,
+		modTimes: map[string]time.Time{},
+	}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) load() error {
+	var (
+		cert *tls.Certificate
+		pool *x509.CertPool
+	)
+
+	if r.cfg.CertFile != "" || r.cfg.KeyFile != "" {
+		pair, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate: %w", err)
+		}
+		cert = &pair
+	}
+
+	if r.cfg.CAFile != "" {
+		pem, err := os.ReadFile(r.cfg.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in CA file %s", r.cfg.CAFile)
+		}
+	}
+
+	modTimes := map[string]time.Time{}
+	for _, path := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.CAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	r.mu.Lock()
+	r.cert = cert
+	r.pool = pool
+	r.modTimes = modTimes
+	r.checkedAt = time.Now()
+	r.mu.Unlock()
+	return nil
+}
+
+// maybeReload reloads the files if the interval elapsed and any of them changed.
+// A failed reload keeps serving the previous certificates.
+func (r *Reloader) maybeReload() {
+	if r.interval <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	if time.Since(r.checkedAt) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	r.checkedAt = time.Now()
+	changed := false
+	for path, modTime := range r.modTimes {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			changed = true
+			break
+		}
+	}
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := r.load(); err != nil {
+		r.log.Error("failed to reload certificates, keeping previous ones", slog.String("error", err.Error()))
+		return
+	}
+	r.log.Info("certificates reloaded")
+}
+
+// Certificate returns the current certificate pair
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// Pool returns the current CA bundle, nil meaning the system roots
+func (r *Reloader) Pool() *x509.CertPool {
+	r.maybeReload()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// ServerTLS builds a server tls.Config that picks up rotated certificates
+// and, depending on cfg.ClientAuth, verifies client certificates against the CA
+func ServerTLS(log *slog.Logger, cfg config.TLSConfig) (*tls.Config, error) {
+	clientAuth, err := parseClientAuth(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("server TLS requires cert_file and key_file")
+	}
+	if clientAuth != tls.NoClientCert && cfg.CAFile == "" {
+		return nil, errors.New("client certificate verification requires ca_file")
+	}
+
+	r, err := NewReloader(log, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// Resolved per handshake so a rotated CA bundle applies to new connections
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*r.Certificate()},
+				ClientCAs:    r.Pool(),
+				ClientAuth:   clientAuth,
+			}, nil
+		},
+	}, nil
+}
+
+// ClientTLS builds a client tls.Config that picks up rotated certificates
+// and presents a client certificate for mTLS when one is configured
+func ClientTLS(log *slog.Logger, cfg config.TLSConfig) (*tls.Config, error) {
+	r, err := NewReloader(log, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: cfg.ServerName,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			if cert := r.Certificate(); cert != nil {
+				return cert, nil
+			}
+			return &tls.Certificate{}, nil
+		},
+		// RootCAs cannot be swapped on a live tls.Config, so the chain is
+		// verified here against the current pool instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("server presented no certificate")
+			}
+			opts := x509.VerifyOptions{
+				Roots:         r.Pool(),
+				DNSName:       cs.ServerName,
+				Intermediates: x509.NewCertPool(),
+			}
+			for _, cert := range cs.PeerCertificates[1:] {
+				opts.Intermediates.AddCert(cert)
+			}
+			_, err := cs.PeerCertificates[0].Verify(opts)
+			return err
+		},
+	}, nil
+}
+
+func parseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch s {
+	case ClientAuthNone, "":
+		return tls.NoClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth %q, expected one of %s, %s, %s",
+			s, ClientAuthNone, ClientAuthVerifyIfGiven, ClientAuthRequire)
+	}
+}
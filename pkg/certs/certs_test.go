@@ -0,0 +1,282 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+	"github.com/aidosgal/transline-test/pkg/config"
+)
+
+var discard = slog.New(slog.DiscardHandler)
+
+// authority is a test CA that issues leaf certificates
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newAuthority(t *testing.T) *authority {
+	t.Helper()
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "transline test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &authority{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// issue returns the PEM certificate and key of a leaf for name, with a
+// SPIFFE ID of spiffe://transline.local/<name>
+func (a *authority) issue(t *testing.T, name string, serial int64) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key := newKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		URIs:         []*url.URL{{Scheme: "spiffe", Host: "transline.local", Path: "/" + name}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func write(t *testing.T, path string, data []byte) string {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// files writes a leaf of ca for name and ca itself, and returns a TLS config of them
+func files(t *testing.T, ca *authority, name string) config.TLSConfig {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, name, 2)
+	return config.TLSConfig{
+		Enabled:  true,
+		CAFile:   write(t, filepath.Join(dir, "ca.pem"), ca.pem),
+		CertFile: write(t, filepath.Join(dir, name+".pem"), certPEM),
+		KeyFile:  write(t, filepath.Join(dir, name+"-key.pem"), keyPEM),
+	}
+}
+
+// handshake runs a TLS handshake over an in-memory connection and returns
+// the errors of both sides and the state the server saw
+func handshake(server, client *tls.Config) (serverErr, clientErr error, state tls.ConnectionState) {
+	sc, cc := net.Pipe()
+	defer sc.Close()
+	defer cc.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		conn := tls.Server(sc, server)
+		serverErr = conn.Handshake()
+		state = conn.ConnectionState()
+		// Unblock the client if the server rejected it
+		sc.Close()
+	}()
+	clientErr = tls.Client(cc, client).Handshake()
+	cc.Close()
+	<-done
+	return serverErr, clientErr, state
+}
+
+func TestHandshake(t *testing.T) {
+	ca, other := newAuthority(t), newAuthority(t)
+	serverFiles := files(t, ca, "customer-service")
+	clientFiles := files(t, ca, "shipment-service")
+	clientFiles.ServerName = "customer-service"
+
+	tests := []struct {
+		name       string
+		clientAuth string
+		client     config.TLSConfig
+		wantErr    bool
+		wantPeer   string
+	}{
+		{name: "mTLS", clientAuth: certs.ClientAuthRequire, client: clientFiles, wantPeer: "shipment-service"},
+		{name: "server TLS only", clientAuth: certs.ClientAuthNone, client: config.TLSConfig{
+			CAFile: clientFiles.CAFile, ServerName: "customer-service",
+		}},
+		{name: "no client certificate but required", clientAuth: certs.ClientAuthRequire, client: config.TLSConfig{
+			CAFile: clientFiles.CAFile, ServerName: "customer-service",
+		}, wantErr: true},
+		{name: "optional client certificate given", clientAuth: certs.ClientAuthVerifyIfGiven, client: clientFiles,
+			wantPeer: "shipment-service"},
+		{name: "wrong server name", clientAuth: certs.ClientAuthRequire, client: func() config.TLSConfig {
+			c := clientFiles
+			c.ServerName = "postgres"
+			return c
+		}(), wantErr: true},
+		{name: "server of another CA", clientAuth: certs.ClientAuthRequire, client: func() config.TLSConfig {
+			c := clientFiles
+			c.CAFile = write(t, filepath.Join(t.TempDir(), "other.pem"), other.pem)
+			return c
+		}(), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sf := serverFiles
+			sf.ClientAuth = tt.clientAuth
+			server, err := certs.ServerTLS(discard, sf)
+			if err != nil {
+				t.Fatalf("ServerTLS: %v", err)
+			}
+			client, err := certs.ClientTLS(discard, tt.client)
+			if err != nil {
+				t.Fatalf("ClientTLS: %v", err)
+			}
+
+			serverErr, clientErr, state := handshake(server, client)
+			if failed := serverErr != nil || clientErr != nil; failed != tt.wantErr {
+				t.Fatalf("handshake errors = %v, %v, want failure %t", serverErr, clientErr, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var peer string
+			if len(state.VerifiedChains) > 0 {
+				peer = certs.IdentityFromCert(state.VerifiedChains[0][0]).CommonName
+			}
+			if peer != tt.wantPeer {
+				t.Errorf("verified client = %q, want %q", peer, tt.wantPeer)
+			}
+		})
+	}
+}
+
+func TestServerTLSInvalid(t *testing.T) {
+	ca := newAuthority(t)
+	valid := files(t, ca, "customer-service")
+
+	tests := []struct {
+		name   string
+		modify func(c *config.TLSConfig)
+		want   string
+	}{
+		{"unknown client auth", func(c *config.TLSConfig) { c.ClientAuth = "optional" }, "unknown client auth"},
+		{"no key", func(c *config.TLSConfig) { c.KeyFile = "" }, "requires cert_file and key_file"},
+		{"verification without CA", func(c *config.TLSConfig) {
+			c.ClientAuth, c.CAFile = certs.ClientAuthRequire, ""
+		}, "requires ca_file"},
+		{"missing file", func(c *config.TLSConfig) { c.CertFile = filepath.Join(t.TempDir(), "none.pem") }, "failed to load certificate"},
+		{"CA without certificates", func(c *config.TLSConfig) {
+			c.CAFile = write(t, filepath.Join(t.TempDir(), "ca.pem"), []byte("not a certificate"))
+		}, "no certificates found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			_, err := certs.ServerTLS(discard, cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ServerTLS error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	ca := newAuthority(t)
+	cfg := files(t, ca, "customer-service")
+	cfg.ReloadInterval = time.Nanosecond
+
+	r, err := certs.NewReloader(discard, cfg)
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+	serial := func() int64 {
+		leaf, err := x509.ParseCertificate(r.Certificate().Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.Int64()
+	}
+	if got := serial(); got != 2 {
+		t.Fatalf("serial = %d, want 2", got)
+	}
+
+	// A rotated pair is picked up on the next use
+	certPEM, keyPEM := ca.issue(t, "customer-service", 3)
+	write(t, cfg.CertFile, certPEM)
+	write(t, cfg.KeyFile, keyPEM)
+	touch(t, cfg.CertFile, cfg.KeyFile)
+	if got := serial(); got != 3 {
+		t.Errorf("serial after rotation = %d, want 3", got)
+	}
+
+	// A broken file keeps the previous pair
+	write(t, cfg.CertFile, []byte("truncated"))
+	touch(t, cfg.CertFile)
+	if got := serial(); got != 3 {
+		t.Errorf("serial after a failed reload = %d, want 3", got)
+	}
+}
+
+// touch moves the modification time forward, as coarse file system clocks
+// may not tell two writes in a row apart
+func touch(t *testing.T, paths ...string) {
+	t.Helper()
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		at := info.ModTime().Add(time.Second)
+		if err := os.Chtimes(path, at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// Identity is the verified identity of a TLS peer
+type Identity struct {
+	// SPIFFEID is the spiffe://trust-domain/path URI SAN, if the certificate has one
+	SPIFFEID    string
+	TrustDomain string
+	Path        string
+	CommonName  string
+}
+
+type identityKey struct{}
+
+// FromContext returns the peer identity stored by the server interceptor
+func FromContext(ctx context.Context) (*Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(*Identity)
+	return id, ok
+}
+
+// NewContext stores a peer identity in ctx
+func NewContext(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromCert extracts the SPIFFE identity of a certificate
+func IdentityFromCert(cert *x509.Certificate) *Identity {
+	id := &Identity{CommonName: cert.Subject.CommonName}
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+		id.SPIFFEID = uri.String()
+		id.TrustDomain = uri.Host
+		id.Path = uri.Path
+		break
+	}
+	return id
+}
+
+// peerIdentity reads the verified client certificate of the gRPC peer
+func peerIdentity(ctx context.Context) (*Identity, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil, false
+	}
+	return IdentityFromCert(info.State.VerifiedChains[0][0]), true
+}
+
+// UnaryServerInterceptor puts the verified client identity into the request
+// context and annotates the server span with it
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if id, ok := peerIdentity(ctx); ok {
+			ctx = NewContext(ctx, id)
+			trace.SpanFromContext(ctx).SetAttributes(
+				attribute.String("peer.spiffe_id", id.SPIFFEID),
+				attribute.String("peer.common_name", id.CommonName),
+			)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// String returns the SPIFFE ID, falling back to the common name
+func (id *Identity) String() string {
+	if id.SPIFFEID != "" {
+		return id.SPIFFEID
+	}
+	return strings.TrimSpace(id.CommonName)
+}
@@ -0,0 +1,100 @@
+package certs_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+)
+
+func TestIdentityFromCert(t *testing.T) {
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want certs.Identity
+		str  string
+	}{
+		{
+			name: "SPIFFE ID",
+			cert: &x509.Certificate{
+				Subject: pkix.Name{CommonName: "shipment-service"},
+				URIs: []*url.URL{
+					{Scheme: "https", Host: "transline.kz"},
+					{Scheme: "spiffe", Host: "transline.local", Path: "/shipment-service"},
+				},
+			},
+			want: certs.Identity{
+				SPIFFEID:    "spiffe://transline.local/shipment-service",
+				TrustDomain: "transline.local",
+				Path:        "/shipment-service",
+				CommonName:  "shipment-service",
+			},
+			str: "spiffe://transline.local/shipment-service",
+		},
+		{
+			name: "common name only",
+			cert: &x509.Certificate{Subject: pkix.Name{CommonName: " loadgen "}},
+			want: certs.Identity{CommonName: " loadgen "},
+			str:  "loadgen",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := certs.IdentityFromCert(tt.cert)
+			if *id != tt.want {
+				t.Errorf("IdentityFromCert = %+v, want %+v", *id, tt.want)
+			}
+			if got := id.String(); got != tt.str {
+				t.Errorf("String = %q, want %q", got, tt.str)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	client := &x509.Certificate{
+		Subject: pkix.Name{CommonName: "shipment-service"},
+		URIs:    []*url.URL{{Scheme: "spiffe", Host: "transline.local", Path: "/shipment-service"}},
+	}
+	tests := []struct {
+		name string
+		auth credentials.AuthInfo
+		want string
+	}{
+		{"verified client", credentials.TLSInfo{State: tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{client}},
+		}}, "spiffe://transline.local/shipment-service"},
+		// A certificate given to verify_if_given but not verified is no identity
+		{"unverified client", credentials.TLSInfo{State: tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{client},
+		}}, ""},
+		{"plaintext", nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(context.Background(), &peer.Peer{AuthInfo: tt.auth})
+
+			var got string
+			handler := func(ctx context.Context, _ any) (any, error) {
+				if id, ok := certs.FromContext(ctx); ok {
+					got = id.String()
+				}
+				return nil, nil
+			}
+			if _, err := certs.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{}, handler); err != nil {
+				t.Fatalf("interceptor: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("identity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+)
+
+// ErrUsage makes Run print the usage of the failed command
+var ErrUsage = errors.New("invalid usage")
+
+// Command is one subcommand of a service binary
+type Command struct {
+	Name string
+	// Usage is the argument synopsis, e.g. "up | down [N]"
+	Usage string
+	Short string
+	Run   func(ctx context.Context, args []string) error
+}
+
+// Run dispatches args to the matching command and returns the exit code.
+// Without arguments the first command runs, so images keep starting the
+// server by default. The context is cancelled on SIGINT or SIGTERM.
+func Run(name string, args []string, commands ...Command) int {
+	stderr := os.Stderr
+	if len(commands) == 0 {
+		return 1
+	}
+
+	cmd := commands[0]
+	if len(args) > 0 {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			printUsage(stderr, name, commands)
+			return 0
+		}
+		found := false
+		for _, c := range commands {
+			if c.Name == args[0] {
+				cmd, found = c, true
+				break
+			}
+		}
+		if !found {
+			fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+			printUsage(stderr, name, commands)
+			return 2
+		}
+		args = args[1:]
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.Run(ctx, args); err != nil {
+		if errors.Is(err, ErrUsage) || errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(stderr, "usage: %s %s %s\n", name, cmd.Name, cmd.Usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "%s %s: %s\n", name, cmd.Name, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer, name string, commands []Command) {
+	fmt.Fprintf(w, "usage: %s <command> [arguments]\n\ncommands:\n", name)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", c.Name, c.Usage, c.Short)
+	}
+	tw.Flush()
+}
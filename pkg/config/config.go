@@ -2,52 +2,134 @@ package config
 
 import (
 	"fmt"
-	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type PostgresConfig struct {
+	Host     string `yaml:"host" toml:"host" env:"HOST" env-default:"localhost" env-description:"Postgres host"`
+	Port     int    `yaml:"port" toml:"port" env:"PORT" env-default:"5432"`
+	User     string `yaml:"user" toml:"user" env:"USER" env-default:"postgres"`
+	Password string `yaml:"password" toml:"password" env:"PASSWORD" env-default:"postgres" secret:"true"`
+	DBName   string `yaml:"dbname" toml:"dbname" env:"DBNAME" env-default:"postgres" env-description:"Database name"`
+	SSLMode  string `yaml:"sslmode" toml:"sslmode" env:"SSLMODE" env-default:"disable" env-description:"disable, allow, prefer, require, verify-ca or verify-full"`
+
+	ApplicationName  string        `yaml:"application_name" toml:"application_name" env:"APPLICATION_NAME" env-description:"application_name reported to Postgres, the service name if empty"`
+	MaxOpenConns     int           `yaml:"max_open_conns" toml:"max_open_conns" env:"MAX_OPEN_CONNS" env-default:"20" env-description:"Pool size limit, 0 means unlimited"`
+	MaxIdleConns     int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"MAX_IDLE_CONNS" env-default:"10"`
+	ConnMaxLifetime  time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME" env-default:"30m" env-description:"Connections are recycled after this, e.g. to follow a failover"`
+	ConnMaxIdleTime  time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"CONN_MAX_IDLE_TIME" env-default:"5m"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" toml:"statement_timeout" env:"STATEMENT_TIMEOUT" env-default:"30s" env-description:"Server-side limit per statement, 0 disables it; not applied to migrations"`
+	LockTimeout      time.Duration `yaml:"lock_timeout" toml:"lock_timeout" env:"LOCK_TIMEOUT" env-default:"5s" env-description:"Server-side limit on waiting for a lock, 0 disables it; not applied to migrations"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" toml:"connect_timeout" env:"CONNECT_TIMEOUT" env-default:"1m" env-description:"How long startup retries connecting before giving up, 0 tries once"`
+	Replica          ReplicaConfig `yaml:"replica" toml:"replica" env-prefix:"REPLICA_"`
+}
+
+// ReplicaConfig points read-only queries at a streaming replica of the
+// primary, reached with the same credentials and settings
+type ReplicaConfig struct {
+	Host string `yaml:"host" toml:"host" env:"HOST" env-description:"Replica host, read-only queries use the primary if empty"`
+	Port int    `yaml:"port" toml:"port" env:"PORT" env-default:"5432"`
+}
 
-	"github.com/ilyakaznacheev/cleanenv"
+// EndpointConfig is the address of another service
+type EndpointConfig struct {
+	URL  string `yaml:"url" toml:"url" env:"URL" env-default:"localhost" env-description:"Host name, or host:port to override Port"`
+	Port int    `yaml:"port" toml:"port" env:"PORT" env-default:"9090"`
+}
+
+const (
+	ResolverDNS         = "dns"
+	ResolverStatic      = "static"
+	ResolverPassthrough = "passthrough"
 )
 
-type Config struct {
-	CustomerService ServiceConfig `env-prefix:"CUSTOMER_"`
-	Shipment        ServiceConfig `env-prefix:"SHIPMENT_"`
-	Service         AppConfig     `env-prefix:"APP_"`
-	Jaeger          JaegerConfig  `env-prefix:"JAEGER_"`
+// ClientConfig tunes an outgoing gRPC client
+type ClientConfig struct {
+	Timeout         time.Duration `yaml:"timeout" toml:"timeout" env:"TIMEOUT" env-default:"2s" env-description:"Applied to every call whose context has no earlier deadline"`
+	MaxAttempts     int           `yaml:"max_attempts" toml:"max_attempts" env:"MAX_ATTEMPTS" env-default:"3" env-description:"Attempts per call including the first, 1 disables retries"`
+	InitialBackoff  time.Duration `yaml:"initial_backoff" toml:"initial_backoff" env:"INITIAL_BACKOFF" env-default:"100ms"`
+	MaxBackoff      time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"MAX_BACKOFF" env-default:"1s"`
+	BreakerFailures int           `yaml:"breaker_failures" toml:"breaker_failures" env:"BREAKER_FAILURES" env-default:"5" env-description:"Consecutive transient failures that open the circuit"`
+	BreakerOpen     time.Duration `yaml:"breaker_open" toml:"breaker_open" env:"BREAKER_OPEN" env-default:"10s" env-description:"How long an open circuit rejects calls"`
+	Resolver        string        `yaml:"resolver" toml:"resolver" env:"RESOLVER" env-default:"dns" env-description:"dns (every A record of URL), static (Targets) or passthrough (URL as is)"`
+	Targets         []string      `yaml:"targets" toml:"targets" env:"TARGETS" env-separator:"," env-description:"Addresses for the static resolver, comma-separated"`
+	LBPolicy        string        `yaml:"lb_policy" toml:"lb_policy" env:"LB_POLICY" env-default:"round_robin" env-description:"round_robin or pick_first"`
+	TLS             TLSConfig     `yaml:"tls" toml:"tls" env-prefix:"TLS_"`
+	CacheSize       int           `yaml:"cache_size" toml:"cache_size" env:"CACHE_SIZE" env-default:"10000" env-description:"Bound of the IDN -> customer cache, 0 disables it"`
+	CacheTTL        time.Duration `yaml:"cache_ttl" toml:"cache_ttl" env:"CACHE_TTL" env-default:"5m"`
 }
 
-type ServiceConfig struct {
-	Port     int            `env:"PORT" env-default:"9090"`
-	URL      string         `env:"URL" env-default:"localhost"`
-	Postgres PostgresConfig `env-prefix:"POSTGRES_"`
+const (
+	ClientAuthNone          = "none"
+	ClientAuthVerifyIfGiven = "verify_if_given"
+	ClientAuthRequire       = "require"
+)
+
+// TLSConfig enables TLS; mTLS when clients present a certificate and servers verify it
+type TLSConfig struct {
+	Enabled        bool          `yaml:"enabled" toml:"enabled" env:"ENABLED" env-default:"false" env-description:"Use TLS instead of plaintext"`
+	CAFile         string        `yaml:"ca_file" toml:"ca_file" env:"CA_FILE" env-description:"PEM bundle to verify the peer, system roots if empty"`
+	CertFile       string        `yaml:"cert_file" toml:"cert_file" env:"CERT_FILE" env-description:"PEM certificate presented to the peer"`
+	KeyFile        string        `yaml:"key_file" toml:"key_file" env:"KEY_FILE" env-description:"PEM private key of cert_file"`
+	ServerName     string        `yaml:"server_name" toml:"server_name" env:"SERVER_NAME" env-description:"Name expected in the server certificate, used by clients"`
+	ClientAuth     string        `yaml:"client_auth" toml:"client_auth" env:"CLIENT_AUTH" env-default:"none" env-description:"Used by servers: none, verify_if_given or require"`
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval" env:"RELOAD_INTERVAL" env-default:"30s" env-description:"How often the files are checked for changes, 0 disables reloading"`
 }
 
-type PostgresConfig struct {
-	Host     string `env:"HOST" env-default:"localhost"`
-	Port     int    `env:"PORT" env-default:"5432"`
-	User     string `env:"USER" env-default:"postgres"`
-	Password string `env:"PASSWORD" env-default:"postgres"`
-	DBName   string `env:"DBNAME" env-default:"postgres"`
-	SSLMode  string `env:"SSLMODE" env-default:"disable"`
+// SagaConfig tunes the recovery of unfinished sagas
+type SagaConfig struct {
+	RecoveryInterval time.Duration `yaml:"recovery_interval" toml:"recovery_interval" env:"RECOVERY_INTERVAL" env-default:"30s"`
+	StaleAfter       time.Duration `yaml:"stale_after" toml:"stale_after" env:"STALE_AFTER" env-default:"1m" env-description:"How long a saga may go without progress before recovery takes it over"`
+	MaxAttempts      int           `yaml:"max_attempts" toml:"max_attempts" env:"MAX_ATTEMPTS" env-default:"5" env-description:"How many times recovery retries a step before compensating"`
 }
 
-type AppConfig struct {
-	Port int    `env:"PORT" env-default:"8080"`
-	Name string `env:"NAME" env-default:"envoy"`
+// Tariff sources of PricingConfig
+const (
+	TariffBuiltin = "builtin"
+	TariffFile    = "file"
+	TariffDB      = "db"
+)
+
+type PricingConfig struct {
+	TariffSource   string        `yaml:"tariff_source" toml:"tariff_source" env:"TARIFF_SOURCE" env-default:"builtin" env-description:"builtin, file or db: the newest tariff saved with the tariff import command"`
+	TariffFile     string        `yaml:"tariff_file" toml:"tariff_file" env:"TARIFF_FILE" env-default:"" env-description:"JSON tariff for tariff_source=file"`
+	ReloadInterval time.Duration `yaml:"reload_interval" toml:"reload_interval" env:"RELOAD_INTERVAL" env-default:"1m" env-description:"How often the tariff is reloaded from the file or the database"`
 }
 
-type JaegerConfig struct {
-	URL string `env:"URL" env-default:"http://jaeger:14268/api/traces"`
+// SLAConfig tunes the planned windows, the ETA and the SLA monitor
+type SLAConfig struct {
+	PickupWithin   time.Duration `yaml:"pickup_within" toml:"pickup_within" env:"PICKUP_WITHIN" env-default:"24h" env-description:"Length of the pickup window of a shipment created without one"`
+	DeliveryBuffer time.Duration `yaml:"delivery_buffer" toml:"delivery_buffer" env:"DELIVERY_BUFFER" env-default:"12h" env-description:"Slack added to the end of a planned delivery window"`
+	SpeedKmh       float64       `yaml:"speed_kmh" toml:"speed_kmh" env:"SPEED_KMH" env-default:"55" env-description:"Average truck speed, stops included, on lanes with too little history"`
+	DefaultTransit time.Duration `yaml:"default_transit" toml:"default_transit" env:"DEFAULT_TRANSIT" env-default:"72h" env-description:"Transit time of routes with too little history and a stop outside the gazetteer"`
+	MinSamples     int           `yaml:"min_samples" toml:"min_samples" env:"MIN_SAMPLES" env-default:"5" env-description:"Delivered shipments a lane needs before its history replaces the distance estimate"`
+	HistoryWindow  time.Duration `yaml:"history_window" toml:"history_window" env:"HISTORY_WINDOW" env-default:"2160h" env-description:"How far back deliveries count towards the transit time of a lane"`
+	CheckInterval  time.Duration `yaml:"check_interval" toml:"check_interval" env:"CHECK_INTERVAL" env-default:"1m" env-description:"How often open shipments are re-estimated"`
+	BatchSize      int           `yaml:"batch_size" toml:"batch_size" env:"BATCH_SIZE" env-default:"500" env-description:"Shipments re-estimated per check"`
+	WebhookURL     string        `yaml:"webhook_url" toml:"webhook_url" env:"WEBHOOK_URL" env-default:"" env-description:"URL breaches are POSTed to as JSON; breaches are only logged if empty"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" toml:"webhook_timeout" env:"WEBHOOK_TIMEOUT" env-default:"5s" env-description:"Timeout of a webhook request"`
 }
 
-func MustLoad() *Config {
-	var cfg Config
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
-		log.Fatalf("cannot read environment: %v", err)
-	}
-	return &cfg
+// TrackingConfig limits the public tracking endpoint, which needs no
+// credentials, per client IP
+type TrackingConfig struct {
+	RatePerMinute     int  `yaml:"rate_per_minute" toml:"rate_per_minute" env:"RATE_PER_MINUTE" env-default:"60" env-description:"Requests per minute one IP may make, 0 disables the limit"`
+	Burst             int  `yaml:"burst" toml:"burst" env:"BURST" env-default:"20" env-description:"Requests one IP may make at once before the rate applies"`
+	TrustForwardedFor bool `yaml:"trust_forwarded_for" toml:"trust_forwarded_for" env:"TRUST_FORWARDED_FOR" env-default:"false" env-description:"Take the client IP from the last X-Forwarded-For address; only behind a proxy that appends it"`
+}
+
+type LogConfig struct {
+	Level      string `yaml:"level" toml:"level" env:"LEVEL" env-default:"info" env-description:"debug, info, warn or error"`
+	Levels     string `yaml:"levels" toml:"levels" env:"LEVELS" env-default:"" env-description:"Level per layer, e.g. storage=debug,server=warn"`
+	Export     string `yaml:"export" toml:"export" env:"EXPORT" env-default:"span_events" env-description:"span_events, otlp or both"`
+	Redact     string `yaml:"redact" toml:"redact" env:"REDACT" env-default:"mask" env-description:"mask, hash or off"`
+	RedactSalt string `yaml:"redact_salt" toml:"redact_salt" env:"REDACT_SALT" env-default:"" secret:"true" env-description:"HMAC key for redact=hash"`
 }
 
 func (pc *PostgresConfig) BuildPostgresURL() string {
-	return fmt.Sprintf(
+	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
 		pc.Host,
 		pc.Port,
@@ -56,10 +138,21 @@ func (pc *PostgresConfig) BuildPostgresURL() string {
 		pc.DBName,
 		pc.SSLMode,
 	)
+	// lib/pq sends unknown keys as run-time parameters of the session
+	if pc.ApplicationName != "" {
+		dsn += " application_name=" + quoteDSN(pc.ApplicationName)
+	}
+	if pc.StatementTimeout > 0 {
+		dsn += fmt.Sprintf(" statement_timeout=%d", pc.StatementTimeout.Milliseconds())
+	}
+	if pc.LockTimeout > 0 {
+		dsn += fmt.Sprintf(" lock_timeout=%d", pc.LockTimeout.Milliseconds())
+	}
+	return dsn
 }
 
 func (pc *PostgresConfig) BuildPostgresMigrationURL() string {
-	return fmt.Sprintf(
+	migrationURL := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=%s",
 		pc.User,
 		pc.Password,
@@ -68,4 +161,28 @@ func (pc *PostgresConfig) BuildPostgresMigrationURL() string {
 		pc.DBName,
 		pc.SSLMode,
 	)
+	if pc.ApplicationName != "" {
+		migrationURL += "&application_name=" + url.QueryEscape(pc.ApplicationName+"-migrate")
+	}
+	return migrationURL
+}
+
+// ReplicaConfig returns the settings of the read replica, false if none is configured
+func (pc *PostgresConfig) ReplicaConfig() (PostgresConfig, bool) {
+	if pc.Replica.Host == "" {
+		return PostgresConfig{}, false
+	}
+	replica := *pc
+	replica.Host = pc.Replica.Host
+	replica.Port = pc.Replica.Port
+	replica.Replica = ReplicaConfig{}
+	return replica, true
+}
+
+// quoteDSN quotes a key/value DSN value if it has spaces or quotes
+func quoteDSN(s string) string {
+	if !strings.ContainsAny(s, ` '\`) {
+		return s
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
 }
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/ilyakaznacheev/cleanenv"
+)
+
+// Service is a per-service configuration
+type Service interface {
+	Validate() error
+	common() *Common
+}
+
+// Load fills cfg, later sources overriding earlier ones:
+//
+//  1. env-default tags
+//  2. the YAML or TOML file at path, if any
+//  3. the profile overlay next to it, e.g. shipment.prod.yaml for shipment.yaml
+//  4. environment variables that are set
+//
+// A value in a file wins over the default even if it is zero, e.g.
+// cache_size: 0. profile, if not empty, wins over the PROFILE variable and
+// the file. Every validation problem is returned at once.
+func Load(cfg Service, path, profile string) error {
+	// cleanenv fills defaults into zero fields along with the environment, so
+	// it runs before the files, and the variables that are set are copied
+	// again from a second pass afterwards
+	if err := cleanenv.ReadEnv(cfg); err != nil {
+		return fmt.Errorf("failed to read environment: %w", err)
+	}
+	env := reflect.New(reflect.TypeOf(cfg).Elem())
+	if err := cleanenv.ReadEnv(env.Interface()); err != nil {
+		return fmt.Errorf("failed to read environment: %w", err)
+	}
+
+	if path != "" {
+		if err := parseFile(path, cfg); err != nil {
+			return err
+		}
+
+		// The overlay depends on the profile, which may itself come from the
+		// environment or the base file
+		p := profile
+		if p == "" {
+			p = os.Getenv("PROFILE")
+		}
+		if p == "" {
+			p = cfg.common().Profile
+		}
+		if p != "" {
+			overlay := overlayPath(path, p)
+			if err := parseFile(overlay, cfg); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	copySetEnv(reflect.ValueOf(cfg).Elem(), env.Elem(), "")
+	if profile != "" {
+		cfg.common().Profile = profile
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	return nil
+}
+
+// copySetEnv copies from src to dst the fields whose environment variable is
+// set, walking nested structs with their env-prefix the way cleanenv does
+func copySetEnv(dst, src reflect.Value, prefix string) {
+	t := dst.Type()
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct {
+			copySetEnv(dst.Field(i), src.Field(i), prefix+field.Tag.Get("env-prefix"))
+			continue
+		}
+		names, ok := field.Tag.Lookup("env")
+		if !ok || names == "" {
+			continue
+		}
+		for _, name := range strings.Split(names, ",") {
+			if _, ok := os.LookupEnv(prefix + name); ok {
+				dst.Field(i).Set(src.Field(i))
+				break
+			}
+		}
+	}
+}
+
+// overlayPath returns dir/name.profile.ext for dir/name.ext
+func overlayPath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+func parseFile(path string, cfg any) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = cleanenv.ParseYAML(f, cfg)
+	case ".toml":
+		err = cleanenv.ParseTOML(f, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format %q, expected .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
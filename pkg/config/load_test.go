@@ -0,0 +1,192 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		overlay string
+		profile string
+		env     map[string]string
+		check   func(t *testing.T, cfg *config.Shipment)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.CustomerClient.CacheSize != 10000 || cfg.Postgres.StatementTimeout != 30*time.Second ||
+					cfg.Postgres.MaxOpenConns != 20 || cfg.Port != 8080 || cfg.Profile != config.ProfileDev {
+					t.Errorf("defaults not applied: %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "zero in file wins over default",
+			file: "customer_client:\n  cache_size: 0\npostgres:\n  statement_timeout: 0s\n  max_open_conns: 0\n",
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.CustomerClient.CacheSize != 0 || cfg.Postgres.StatementTimeout != 0 || cfg.Postgres.MaxOpenConns != 0 {
+					t.Errorf("cache_size, statement_timeout, max_open_conns = %d, %s, %d, want the zeros of the file",
+						cfg.CustomerClient.CacheSize, cfg.Postgres.StatementTimeout, cfg.Postgres.MaxOpenConns)
+				}
+				if cfg.Postgres.LockTimeout != 5*time.Second {
+					t.Errorf("lock_timeout = %s, want the default 5s for a key not in the file", cfg.Postgres.LockTimeout)
+				}
+			},
+		},
+		{
+			name: "set env wins over file",
+			file: "port: 8181\ncustomer_client:\n  cache_size: 0\nlog:\n  levels: storage=debug\n",
+			env:  map[string]string{"CUSTOMER_CLIENT_CACHE_SIZE": "50", "LOG_LEVELS": ""},
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.CustomerClient.CacheSize != 50 {
+					t.Errorf("cache_size = %d, want 50 from the environment", cfg.CustomerClient.CacheSize)
+				}
+				if cfg.Log.Levels != "" {
+					t.Errorf("log.levels = %q, want empty from the environment", cfg.Log.Levels)
+				}
+				if cfg.Port != 8181 {
+					t.Errorf("port = %d, want 8181 from the file", cfg.Port)
+				}
+			},
+		},
+		{
+			name: "second env name",
+			file: "port: 8181\n",
+			env:  map[string]string{"SHIPMENT_PORT": "8282"},
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.Port != 8282 {
+					t.Errorf("port = %d, want 8282 from SHIPMENT_PORT", cfg.Port)
+				}
+			},
+		},
+		{
+			name:    "overlay of the profile in the file",
+			file:    "profile: test\nport: 8181\nsaga:\n  max_attempts: 2\n",
+			overlay: "port: 8383\n",
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.Port != 8383 || cfg.Saga.MaxAttempts != 2 || cfg.Profile != config.ProfileTest {
+					t.Errorf("port, saga.max_attempts, profile = %d, %d, %s, want 8383 from the overlay, 2 and test",
+						cfg.Port, cfg.Saga.MaxAttempts, cfg.Profile)
+				}
+			},
+		},
+		{
+			name:    "profile argument wins",
+			file:    "profile: dev\n",
+			overlay: "port: 8383\n",
+			profile: config.ProfileTest,
+			env:     map[string]string{"PROFILE": "dev"},
+			check: func(t *testing.T, cfg *config.Shipment) {
+				if cfg.Profile != config.ProfileTest || cfg.Port != 8383 {
+					t.Errorf("profile, port = %s, %d, want test and 8383 from its overlay", cfg.Profile, cfg.Port)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			dir := t.TempDir()
+			var path string
+			if tt.file != "" {
+				path = writeFile(t, dir, "shipment.yaml", tt.file)
+			}
+			if tt.overlay != "" {
+				writeFile(t, dir, "shipment.test.yaml", tt.overlay)
+			}
+
+			cfg := &config.Shipment{}
+			if err := config.Load(cfg, path, tt.profile); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		file     string
+		env      map[string]string
+		// want are the keys every one of which must be reported
+		want []string
+	}{
+		{
+			name: "every problem at once",
+			file: "port: 0\nsaga:\n  max_attempts: 0\n",
+			want: []string{"port:", "saga.max_attempts:"},
+		},
+		{
+			name: "env makes it invalid",
+			env:  map[string]string{"CUSTOMER_CLIENT_RESOLVER": "static"},
+			want: []string{"customer_client.targets:"},
+		},
+		{
+			name: "prod rules",
+			file: "profile: prod\nlog:\n  redact: \"off\"\n",
+			want: []string{"log.redact:", "postgres.sslmode:"},
+		},
+		{
+			name: "hash without a salt in dev",
+			env:  map[string]string{"LOG_REDACT": "hash"},
+			want: []string{"log.redact_salt:"},
+		},
+		{
+			name: "negative drain delay",
+			env:  map[string]string{"DRAIN_DELAY": "-1s"},
+			want: []string{"drain_delay:"},
+		},
+		{
+			name:     "unknown format",
+			fileName: "shipment.json",
+			file:     "{}",
+			want:     []string{"unsupported config file format"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var path string
+			if tt.file != "" {
+				name := tt.fileName
+				if name == "" {
+					name = "shipment.yaml"
+				}
+				path = writeFile(t, t.TempDir(), name, tt.file)
+			}
+
+			err := config.Load(&config.Shipment{}, path, "")
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const masked = "********"
+
+// setting is one leaf field of a configuration
+type setting struct {
+	key         string
+	envs        []string
+	typ         string
+	def         string
+	description string
+	secret      bool
+	value       reflect.Value
+}
+
+// Print writes the effective configuration as environment variables, one per
+// line, with fields tagged secret:"true" masked
+func Print(w io.Writer, cfg Service) error {
+	for _, s := range settings(cfg) {
+		value := format(s.value)
+		if s.secret && value != "" {
+			value = masked
+		}
+		if _, err := fmt.Fprintf(w, "%s=%s\n", s.envs[0], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reference writes a Markdown table of every setting of cfg: its file key,
+// environment variables, type, default and description
+func Reference(w io.Writer, cfg Service) error {
+	if _, err := fmt.Fprint(w, "| File key | Environment | Type | Default | Description |\n|---|---|---|---|---|\n"); err != nil {
+		return err
+	}
+	for _, s := range settings(cfg) {
+		def := s.def
+		if def != "" {
+			def = "`" + def + "`"
+		}
+		_, err := fmt.Fprintf(w, "| `%s` | `%s` | %s | %s | %s |\n",
+			s.key, strings.Join(s.envs, "`, `"), s.typ, def, s.description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func settings(cfg Service) []setting {
+	var out []setting
+	collect(reflect.ValueOf(cfg).Elem(), "", "", &out)
+	return out
+}
+
+func collect(v reflect.Value, keyPrefix, envPrefix string, out *[]setting) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous {
+			collect(value, keyPrefix, envPrefix, out)
+			continue
+		}
+		key := keyPrefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if p, ok := field.Tag.Lookup("env-prefix"); ok {
+			collect(value, key+".", envPrefix+p, out)
+			continue
+		}
+
+		env, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		envs := strings.Split(env, ",")
+		for i := range envs {
+			envs[i] = envPrefix + envs[i]
+		}
+
+		*out = append(*out, setting{
+			key:         key,
+			envs:        envs,
+			typ:         typeName(field.Type),
+			def:         field.Tag.Get("env-default"),
+			description: field.Tag.Get("env-description"),
+			secret:      field.Tag.Get("secret") == "true",
+			value:       value,
+		})
+	}
+}
+
+func typeName(t reflect.Type) string {
+	switch {
+	case t == reflect.TypeOf(time.Duration(0)):
+		return "duration"
+	case t.Kind() == reflect.Slice:
+		return "list of " + typeName(t.Elem())
+	default:
+		return t.Kind().String()
+	}
+}
+
+func format(v reflect.Value) string {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if v.Kind() == reflect.Slice {
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,71 @@
+package config
+
+import "time"
+
+const (
+	ProfileDev  = "dev"
+	ProfileTest = "test"
+	ProfileProd = "prod"
+)
+
+// Common holds the settings every service has
+type Common struct {
+	Profile string    `yaml:"profile" toml:"profile" env:"PROFILE" env-default:"dev" env-description:"dev, test or prod; selects the <file>.<profile> overlay and the validation rules"`
+	Log     LogConfig `yaml:"log" toml:"log" env-prefix:"LOG_"`
+	// DrainDelay should exceed the probe period of the load balancer times
+	// its failure threshold
+	DrainDelay time.Duration `yaml:"drain_delay" toml:"drain_delay" env:"DRAIN_DELAY" env-default:"5s" env-description:"how long to report not ready on shutdown before closing the listeners"`
+}
+
+func (c *Common) common() *Common {
+	return c
+}
+
+// Customer is the configuration of customer-service
+type Customer struct {
+	Common     `yaml:",inline"`
+	Name       string         `yaml:"name" toml:"name" env:"SERVICE_NAME" env-default:"customer-service" env-description:"service.name of traces, logs and metrics"`
+	Port       int            `yaml:"port" toml:"port" env:"SERVICE_PORT,CUSTOMER_PORT" env-default:"9090" env-description:"gRPC listen port"`
+	Postgres   PostgresConfig `yaml:"postgres" toml:"postgres" env-prefix:"CUSTOMER_POSTGRES_"`
+	TLS        TLSConfig      `yaml:"tls" toml:"tls" env-prefix:"CUSTOMER_TLS_"`
+	AdminToken string         `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN" env-default:"" secret:"true" env-description:"Bearer token of the admin.v1 gRPC service, which is disabled if empty"`
+}
+
+// Shipment is the configuration of shipment-service
+type Shipment struct {
+	Common         `yaml:",inline"`
+	Name           string         `yaml:"name" toml:"name" env:"SERVICE_NAME" env-default:"shipment-service" env-description:"service.name of traces, logs and metrics"`
+	Port           int            `yaml:"port" toml:"port" env:"SERVICE_PORT,SHIPMENT_PORT" env-default:"8080" env-description:"HTTP listen port"`
+	Postgres       PostgresConfig `yaml:"postgres" toml:"postgres" env-prefix:"SHIPMENT_POSTGRES_"`
+	Customer       EndpointConfig `yaml:"customer" toml:"customer" env-prefix:"CUSTOMER_"`
+	CustomerClient ClientConfig   `yaml:"customer_client" toml:"customer_client" env-prefix:"CUSTOMER_CLIENT_"`
+	Saga           SagaConfig     `yaml:"saga" toml:"saga" env-prefix:"SAGA_"`
+	Pricing        PricingConfig  `yaml:"pricing" toml:"pricing" env-prefix:"PRICING_"`
+	SLA            SLAConfig      `yaml:"sla" toml:"sla" env-prefix:"SLA_"`
+	Tracking       TrackingConfig `yaml:"tracking" toml:"tracking" env-prefix:"TRACKING_"`
+	AdminToken     string         `yaml:"admin_token" toml:"admin_token" env:"ADMIN_TOKEN" env-default:"" secret:"true" env-description:"Bearer token of the /admin endpoints, which are disabled if empty"`
+}
+
+func (c *Customer) Validate() error {
+	v := &validator{}
+	c.Common.validate(v)
+	v.port("port", c.Port)
+	c.Postgres.validate(v, "postgres", c.Profile)
+	c.TLS.validateServer(v, "tls")
+	return v.err()
+}
+
+func (c *Shipment) Validate() error {
+	v := &validator{}
+	c.Common.validate(v)
+	v.port("port", c.Port)
+	c.Postgres.validate(v, "postgres", c.Profile)
+	v.check(c.Customer.URL != "", "customer.url", "must not be empty")
+	v.port("customer.port", c.Customer.Port)
+	c.CustomerClient.validate(v, "customer_client")
+	c.Saga.validate(v, "saga")
+	c.Pricing.validate(v, "pricing")
+	c.SLA.validate(v, "sla")
+	c.Tracking.validate(v, "tracking")
+	return v.err()
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/logger"
+)
+
+// validator collects every problem so they can be reported together
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, key, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) oneOf(key, value string, allowed ...string) {
+	v.check(slices.Contains(allowed, value), key, "%q is not one of %v", value, allowed)
+}
+
+func (v *validator) port(key string, port int) {
+	v.check(port > 0 && port <= 65535, key, "%d is not a valid port", port)
+}
+
+func (v *validator) positive(key string, d time.Duration) {
+	v.check(d > 0, key, "must be positive, got %s", d)
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+func (c *Common) validate(v *validator) {
+	v.oneOf("profile", c.Profile, ProfileDev, ProfileTest, ProfileProd)
+	v.check(c.DrainDelay >= 0, "drain_delay", "must not be negative")
+
+	if _, err := logger.ParseLevels(c.Log.Level, c.Log.Levels); err != nil {
+		v.check(false, "log.levels", "%s", err)
+	}
+	if _, err := logger.ParseExport(c.Log.Export); err != nil {
+		v.check(false, "log.export", "%s", err)
+	}
+	redact, err := logger.ParseRedactMode(c.Log.Redact)
+	if err != nil {
+		v.check(false, "log.redact", "%s", err)
+	}
+
+	v.check(redact != logger.RedactHash || c.Log.RedactSalt != "", "log.redact_salt",
+		"is required for redact=hash")
+	if c.Profile == ProfileProd {
+		v.check(redact != logger.RedactOff, "log.redact", "must not be off in prod")
+	}
+}
+
+func (pc *PostgresConfig) validate(v *validator, key, profile string) {
+	v.check(pc.Host != "", key+".host", "must not be empty")
+	v.port(key+".port", pc.Port)
+	v.check(pc.User != "", key+".user", "must not be empty")
+	v.check(pc.DBName != "", key+".dbname", "must not be empty")
+	v.oneOf(key+".sslmode", pc.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+
+	v.check(pc.MaxOpenConns >= 0, key+".max_open_conns", "must not be negative")
+	v.check(pc.MaxIdleConns >= 0, key+".max_idle_conns", "must not be negative")
+	v.check(pc.MaxOpenConns == 0 || pc.MaxIdleConns <= pc.MaxOpenConns, key+".max_idle_conns",
+		"must not exceed max_open_conns")
+	v.check(pc.ConnMaxLifetime >= 0, key+".conn_max_lifetime", "must not be negative")
+	v.check(pc.ConnMaxIdleTime >= 0, key+".conn_max_idle_time", "must not be negative")
+	v.check(pc.StatementTimeout >= 0, key+".statement_timeout", "must not be negative")
+	v.check(pc.LockTimeout >= 0, key+".lock_timeout", "must not be negative")
+	v.check(pc.ConnectTimeout >= 0, key+".connect_timeout", "must not be negative")
+	if pc.Replica.Host != "" {
+		v.port(key+".replica.port", pc.Replica.Port)
+	}
+
+	if profile == ProfileProd {
+		v.check(pc.SSLMode != "disable", key+".sslmode", "must not be disable in prod")
+	}
+}
+
+func (tc *TLSConfig) validateServer(v *validator, key string) {
+	v.oneOf(key+".client_auth", tc.ClientAuth, ClientAuthNone, ClientAuthVerifyIfGiven, ClientAuthRequire)
+	if !tc.Enabled {
+		return
+	}
+	v.check(tc.CertFile != "" && tc.KeyFile != "", key, "cert_file and key_file are required when enabled")
+	v.check(tc.ClientAuth == ClientAuthNone || tc.CAFile != "", key+".ca_file",
+		"is required to verify client certificates")
+	v.check(tc.ReloadInterval >= 0, key+".reload_interval", "must not be negative")
+}
+
+func (tc *TLSConfig) validateClient(v *validator, key string) {
+	if !tc.Enabled {
+		return
+	}
+	v.check((tc.CertFile == "") == (tc.KeyFile == ""), key, "cert_file and key_file must be set together")
+	v.check(tc.ReloadInterval >= 0, key+".reload_interval", "must not be negative")
+}
+
+func (cc *ClientConfig) validate(v *validator, key string) {
+	v.positive(key+".timeout", cc.Timeout)
+	v.check(cc.MaxAttempts >= 1, key+".max_attempts", "must be at least 1")
+	v.positive(key+".initial_backoff", cc.InitialBackoff)
+	v.check(cc.MaxBackoff >= cc.InitialBackoff, key+".max_backoff", "must not be below initial_backoff")
+	v.check(cc.BreakerFailures >= 1, key+".breaker_failures", "must be at least 1")
+	v.positive(key+".breaker_open", cc.BreakerOpen)
+	v.oneOf(key+".resolver", cc.Resolver, ResolverDNS, ResolverStatic, ResolverPassthrough)
+	v.check(cc.Resolver != ResolverStatic || len(cc.Targets) > 0, key+".targets",
+		"at least one target is required for the static resolver")
+	v.oneOf(key+".lb_policy", cc.LBPolicy, "round_robin", "pick_first")
+	cc.TLS.validateClient(v, key+".tls")
+	v.check(cc.CacheSize >= 0, key+".cache_size", "must not be negative")
+	v.check(cc.CacheSize == 0 || cc.CacheTTL > 0, key+".cache_ttl", "must be positive when the cache is enabled")
+}
+
+func (sc *SagaConfig) validate(v *validator, key string) {
+	v.positive(key+".recovery_interval", sc.RecoveryInterval)
+	v.positive(key+".stale_after", sc.StaleAfter)
+	v.check(sc.MaxAttempts >= 1, key+".max_attempts", "must be at least 1")
+}
+
+func (pc *PricingConfig) validate(v *validator, key string) {
+	v.oneOf(key+".tariff_source", pc.TariffSource, TariffBuiltin, TariffFile, TariffDB)
+	v.check(pc.TariffSource != TariffFile || pc.TariffFile != "", key+".tariff_file",
+		"is required for tariff_source=file")
+	v.positive(key+".reload_interval", pc.ReloadInterval)
+}
+
+func (tc *TrackingConfig) validate(v *validator, key string) {
+	v.check(tc.RatePerMinute >= 0, key+".rate_per_minute", "must not be negative")
+	v.check(tc.RatePerMinute == 0 || tc.Burst >= 1, key+".burst", "must be at least 1 when the rate is limited")
+}
+
+func (sc *SLAConfig) validate(v *validator, key string) {
+	v.positive(key+".pickup_within", sc.PickupWithin)
+	v.check(sc.DeliveryBuffer >= 0, key+".delivery_buffer", "must not be negative")
+	v.check(sc.SpeedKmh > 0, key+".speed_kmh", "must be positive")
+	v.positive(key+".default_transit", sc.DefaultTransit)
+	v.check(sc.MinSamples >= 1, key+".min_samples", "must be at least 1")
+	v.positive(key+".history_window", sc.HistoryWindow)
+	v.positive(key+".check_interval", sc.CheckInterval)
+	v.check(sc.BatchSize >= 1, key+".batch_size", "must be at least 1")
+	if sc.WebhookURL != "" {
+		u, err := url.Parse(sc.WebhookURL)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", key+".webhook_url",
+			"%q is not an http(s) URL", sc.WebhookURL)
+		v.positive(key+".webhook_timeout", sc.WebhookTimeout)
+	}
+}
@@ -0,0 +1,115 @@
+// Package contract records what a consumer expects from a gRPC provider as a
+// contract file and replays the file against the real provider.
+//
+// The consumer test describes interactions on a Mock and runs its client
+// against it; the interactions are written to specs/contracts. The provider
+// test loads the files naming it and runs Verify against its server.
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Matcher rules for values that differ between the recorded example and a
+// real response. Fields without a matcher must be equal.
+const (
+	// MatchUUID accepts any UUID
+	MatchUUID = "uuid"
+	// MatchNonEmpty accepts any non-empty value
+	MatchNonEmpty = "nonempty"
+	// MatchAny accepts any value, including none
+	MatchAny = "any"
+	// MatchStatePrefix, followed by a key, requires the value the provider
+	// state returned under that key, e.g. state:customer_id. In a request the
+	// value is replaced before the call.
+	MatchStatePrefix = "state:"
+)
+
+// Contract is the set of interactions a consumer relies on
+type Contract struct {
+	Consumer     string        `json:"consumer"`
+	Provider     string        `json:"provider"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one call and its expected outcome
+type Interaction struct {
+	Description string `json:"description"`
+	// Given are the provider states to set up before the call
+	Given []State `json:"given,omitempty"`
+	// Method is the full gRPC method, e.g. /customer.Customer/UpsertCustomer
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error is the expected status instead of a response
+	Error *Status `json:"error,omitempty"`
+	// Matchers maps request.<field> and response.<field> paths to a rule
+	Matchers map[string]string `json:"matchers,omitempty"`
+}
+
+// State is a named provider state with its parameters
+type State struct {
+	Name   string            `json:"name"`
+	Params map[string]string `json:"params,omitempty"`
+}
+
+// Status is an expected gRPC error
+type Status struct {
+	// Code is the name of a codes.Code, e.g. NotFound
+	Code string `json:"code"`
+}
+
+// FileName is the contract file of consumer and provider
+func FileName(consumer, provider string) string {
+	return consumer + "_" + provider + ".json"
+}
+
+// Load reads a contract file
+func Load(path string) (*Contract, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract: %w", err)
+	}
+	c := &Contract{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse contract %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// LoadProvider reads every contract in dir whose provider is provider
+func LoadProvider(dir, provider string) ([]*Contract, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*_"+provider+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list contracts: %w", err)
+	}
+	var contracts []*Contract
+	for _, path := range paths {
+		c, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if c.Provider == provider {
+			contracts = append(contracts, c)
+		}
+	}
+	return contracts, nil
+}
+
+// Marshal encodes the contract as it is stored, indented with a trailing newline
+func (c *Contract) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal contract: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// stateKey returns the key of a state: matcher, false for other rules
+func stateKey(rule string) (string, bool) {
+	return strings.CutPrefix(rule, MatchStatePrefix)
+}
@@ -0,0 +1,201 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	requestPrefix  = "request."
+	responsePrefix = "response."
+)
+
+// marshalOptions keep the .proto field names and zero values, so a contract
+// states e.g. created: false explicitly
+var marshalOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// encode returns m as JSON with sorted keys; protojson output is not stable
+func encode(m proto.Message) (json.RawMessage, error) {
+	data, err := marshalOptions.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s: %w", m.ProtoReflect().Descriptor().FullName(), err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// compare reports how got differs from the example want. Paths under prefix
+// are looked up in matchers; state resolves state: rules, which require the
+// example value when state is nil.
+func compare(prefix string, want, got json.RawMessage, matchers map[string]string, state map[string]string) []string {
+	var w, g any
+	if err := json.Unmarshal(want, &w); err != nil {
+		return []string{fmt.Sprintf("invalid example: %v", err)}
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		return []string{fmt.Sprintf("invalid message: %v", err)}
+	}
+
+	var diffs []string
+	var walk func(path string, w, g any)
+	walk = func(path string, w, g any) {
+		if rule, ok := matchers[prefix+path]; ok && path != "" {
+			if diff := match(rule, w, g, state); diff != "" {
+				diffs = append(diffs, fmt.Sprintf("%s: %s", path, diff))
+			}
+			return
+		}
+
+		switch wv := w.(type) {
+		case map[string]any:
+			gv, ok := g.(map[string]any)
+			if !ok {
+				break
+			}
+			keys := make(map[string]bool)
+			for k := range wv {
+				keys[k] = true
+			}
+			for k := range gv {
+				keys[k] = true
+			}
+			for _, k := range sortedKeys(keys) {
+				walk(join(path, k), wv[k], gv[k])
+			}
+			return
+		case []any:
+			gv, ok := g.([]any)
+			if !ok || len(gv) != len(wv) {
+				break
+			}
+			for i := range wv {
+				walk(join(path, strconv.Itoa(i)), wv[i], gv[i])
+			}
+			return
+		}
+
+		if !reflect.DeepEqual(w, g) {
+			diffs = append(diffs, fmt.Sprintf("%s: got %s, want %s", displayPath(path), show(g), show(w)))
+		}
+	}
+	walk("", w, g)
+	return diffs
+}
+
+// match applies one matcher rule, returning a description of a mismatch
+func match(rule string, want, got any, state map[string]string) string {
+	if key, ok := stateKey(rule); ok {
+		if state == nil {
+			if !reflect.DeepEqual(want, got) {
+				return fmt.Sprintf("got %s, want %s", show(got), show(want))
+			}
+			return ""
+		}
+		value, ok := state[key]
+		if !ok {
+			return fmt.Sprintf("provider state returned no %q", key)
+		}
+		if s, _ := got.(string); s != value {
+			return fmt.Sprintf("got %s, want %q from the provider state", show(got), value)
+		}
+		return ""
+	}
+
+	switch rule {
+	case MatchAny:
+		return ""
+	case MatchNonEmpty:
+		if got == nil || got == "" {
+			return "got an empty value"
+		}
+		return ""
+	case MatchUUID:
+		s, _ := got.(string)
+		if _, err := uuid.Parse(s); err != nil {
+			return fmt.Sprintf("got %s, want a UUID", show(got))
+		}
+		return ""
+	default:
+		return fmt.Sprintf("unknown matcher %q", rule)
+	}
+}
+
+// substitute replaces request fields with state: matchers by the state values
+func substitute(request json.RawMessage, matchers map[string]string, state map[string]string) (json.RawMessage, error) {
+	var v map[string]any
+	if err := json.Unmarshal(request, &v); err != nil {
+		return nil, fmt.Errorf("invalid request example: %w", err)
+	}
+	for path, rule := range matchers {
+		field, ok := strings.CutPrefix(path, requestPrefix)
+		if !ok {
+			continue
+		}
+		key, ok := stateKey(rule)
+		if !ok {
+			continue
+		}
+		value, ok := state[key]
+		if !ok {
+			return nil, fmt.Errorf("provider state returned no %q for %s", key, path)
+		}
+		if err := set(v, strings.Split(field, "."), value); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return json.Marshal(v)
+}
+
+func set(v map[string]any, path []string, value string) error {
+	for _, key := range path[:len(path)-1] {
+		next, ok := v[key].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%q is not a message", key)
+		}
+		v = next
+	}
+	v[path[len(path)-1]] = value
+	return nil
+}
+
+func sortedKeys(keys map[string]bool) []string {
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "message"
+	}
+	return path
+}
+
+func show(v any) string {
+	if v == nil {
+		return "nothing"
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,242 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+var update = flag.Bool("update-contracts", false, "rewrite contract files from the consumer tests")
+
+const bufSize = 1 << 20
+
+// Expectation is an interaction described with the generated messages
+type Expectation struct {
+	Description string
+	Given       []State
+	// Method is the full method, e.g. customer.Customer_UpsertCustomer_FullMethodName
+	Method   string
+	Request  proto.Message
+	Response proto.Message
+	// Code, if not OK, is returned instead of Response
+	Code     codes.Code
+	Matchers map[string]string
+}
+
+// Mock is an in-process gRPC server that answers unary calls from the
+// interactions of a consumer test. When the test ends every interaction must
+// have been called, and the contract is compared with its file, or written
+// with -update-contracts.
+type Mock struct {
+	t        *testing.T
+	path     string
+	contract Contract
+
+	mu         sync.Mutex
+	called     []bool
+	unexpected []string
+
+	lis    *bufconn.Listener
+	server *grpc.Server
+}
+
+// NewMock starts a mock of provider for consumer; the contract file is kept in dir
+func NewMock(t *testing.T, dir, consumer, provider string) *Mock {
+	m := &Mock{
+		t:        t,
+		path:     filepath.Join(dir, FileName(consumer, provider)),
+		contract: Contract{Consumer: consumer, Provider: provider},
+		lis:      bufconn.Listen(bufSize),
+	}
+	m.server = grpc.NewServer(grpc.UnknownServiceHandler(m.handle))
+	go m.server.Serve(m.lis)
+	t.Cleanup(m.finish)
+	return m
+}
+
+// Expect adds an interaction the consumer relies on
+func (m *Mock) Expect(e Expectation) {
+	m.t.Helper()
+
+	request, err := encode(e.Request)
+	if err != nil {
+		m.t.Fatalf("contract: %s: %v", e.Description, err)
+	}
+	i := Interaction{
+		Description: e.Description,
+		Given:       e.Given,
+		Method:      e.Method,
+		Request:     request,
+		Matchers:    e.Matchers,
+	}
+	if e.Code != codes.OK {
+		i.Error = &Status{Code: e.Code.String()}
+	} else {
+		if i.Response, err = encode(e.Response); err != nil {
+			m.t.Fatalf("contract: %s: %v", e.Description, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.contract.Interactions = append(m.contract.Interactions, i)
+	m.called = append(m.called, false)
+}
+
+// DialOption connects a client to the mock; any target with the passthrough
+// resolver works, e.g. passthrough:///mock
+func (m *Mock) DialOption() grpc.DialOption {
+	return grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return m.lis.DialContext(ctx)
+	})
+}
+
+func (m *Mock) handle(_ any, stream grpc.ServerStream) error {
+	method, _ := grpc.MethodFromServerStream(stream)
+	md, err := findMethod(method)
+	if err != nil {
+		return status.Error(codes.Unimplemented, err.Error())
+	}
+
+	req := dynamicpb.NewMessage(md.Input())
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	got, err := encode(req)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	i, ok := m.find(method, got)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "contract: no interaction for %s %s", method, got)
+	}
+	if i.Error != nil {
+		code, _ := parseCode(i.Error.Code)
+		return status.Error(code, i.Description)
+	}
+
+	resp := dynamicpb.NewMessage(md.Output())
+	if err := protojson.Unmarshal(i.Response, resp); err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.SendMsg(resp)
+}
+
+// find returns the first interaction matching the call, preferring ones not
+// called yet, and records the call
+func (m *Mock) find(method string, request json.RawMessage) (Interaction, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	match := -1
+	for idx, i := range m.contract.Interactions {
+		if i.Method != method || len(compare(requestPrefix, i.Request, request, i.Matchers, nil)) > 0 {
+			continue
+		}
+		if !m.called[idx] {
+			match = idx
+			break
+		}
+		if match < 0 {
+			match = idx
+		}
+	}
+	if match < 0 {
+		m.unexpected = append(m.unexpected, fmt.Sprintf("%s %s", method, request))
+		return Interaction{}, false
+	}
+	m.called[match] = true
+	return m.contract.Interactions[match], true
+}
+
+func (m *Mock) finish() {
+	m.server.Stop()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, call := range m.unexpected {
+		m.t.Errorf("contract: unexpected call %s", call)
+	}
+	for idx, called := range m.called {
+		if !called {
+			m.t.Errorf("contract: interaction %q was not called", m.contract.Interactions[idx].Description)
+		}
+	}
+	if m.t.Failed() {
+		return
+	}
+
+	data, err := m.contract.Marshal()
+	if err != nil {
+		m.t.Error(err)
+		return
+	}
+	if *update {
+		if err := os.WriteFile(m.path, data, 0o644); err != nil {
+			m.t.Errorf("contract: %v", err)
+		}
+		return
+	}
+	existing, err := os.ReadFile(m.path)
+	if err != nil {
+		m.t.Errorf("contract: %v; run the test with -update-contracts to create it", err)
+		return
+	}
+	if !bytes.Equal(existing, data) {
+		m.t.Errorf("contract: %s is out of date; run the test with -update-contracts and verify the provider\ngot:\n%s",
+			m.path, data)
+	}
+}
+
+// findMethod resolves a full method name against the linked-in descriptors
+func findMethod(fullMethod string) (protoreflect.MethodDescriptor, error) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid method %q", fullMethod)
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("unknown service %q: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("unknown method %q", fullMethod)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("streaming method %q is not supported", fullMethod)
+	}
+	return md, nil
+}
+
+func parseCode(name string) (codes.Code, error) {
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return codes.Unknown, fmt.Errorf("unknown status code %q", name)
+}
@@ -0,0 +1,85 @@
+package contract
+
+import (
+	"context"
+	"maps"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// StateHandler sets up a provider state and returns the values that state:
+// matchers refer to
+type StateHandler func(ctx context.Context, params map[string]string) (map[string]string, error)
+
+// Provider is the real server under verification
+type Provider struct {
+	// Setup starts an empty provider for one interaction and returns a
+	// connection to it; the state handlers act on the provider it started
+	Setup  func(t *testing.T) grpc.ClientConnInterface
+	States map[string]StateHandler
+}
+
+// Verify replays every interaction of c against p, one subtest each
+func Verify(t *testing.T, c *Contract, p Provider) {
+	for _, i := range c.Interactions {
+		t.Run(c.Consumer+"/"+i.Description, func(t *testing.T) {
+			verify(t, i, p)
+		})
+	}
+}
+
+func verify(t *testing.T, i Interaction, p Provider) {
+	ctx := context.Background()
+	conn := p.Setup(t)
+
+	state := map[string]string{}
+	for _, s := range i.Given {
+		handler, ok := p.States[s.Name]
+		if !ok {
+			t.Fatalf("no handler for provider state %q", s.Name)
+		}
+		values, err := handler(ctx, s.Params)
+		if err != nil {
+			t.Fatalf("provider state %q: %v", s.Name, err)
+		}
+		maps.Copy(state, values)
+	}
+
+	md, err := findMethod(i.Method)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := substitute(i.Request, i.Matchers, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := dynamicpb.NewMessage(md.Input())
+	if err := protojson.Unmarshal(request, in); err != nil {
+		t.Fatalf("request does not match %s: %v", md.Input().FullName(), err)
+	}
+	out := dynamicpb.NewMessage(md.Output())
+
+	err = conn.Invoke(ctx, i.Method, in, out)
+	if i.Error != nil {
+		if got := status.Code(err).String(); got != i.Error.Code {
+			t.Fatalf("status = %s (%v), want %s", got, err, i.Error.Code)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("%s: %v", i.Method, err)
+	}
+
+	got, err := encode(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diffs := compare(responsePrefix, i.Response, got, i.Matchers, state); len(diffs) > 0 {
+		t.Errorf("response does not match the contract:\n  %s", strings.Join(diffs, "\n  "))
+	}
+}
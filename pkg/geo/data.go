@@ -0,0 +1,70 @@
+package geo
+
+// places are the cities of republican significance, the regional centres and
+// the larger towns. Former names are aliases, so old addresses still resolve.
+var places = []Place{
+	{"ALMATY", "Алматы", "Алматы", "Almaty", "Almaty", 43.2389, 76.8897, []string{"Алма-Ата", "Alma-Ata"}},
+	{"ASTANA", "Астана", "Астана", "Astana", "Astana", 51.1694, 71.4491, []string{"Нур-Султан", "Nur-Sultan", "Целиноград", "Акмолинск"}},
+	{"SHYMKENT", "Шымкент", "Шымкент", "Shymkent", "Shymkent", 42.3417, 69.5901, []string{"Чимкент", "Chimkent"}},
+
+	{"KONAEV", "Конаев", "Қонаев", "Konaev", "Almaty Region", 43.8667, 77.0667, []string{"Капчагай", "Kapchagay", "Kapshagay"}},
+	{"KASKELEN", "Каскелен", "Қаскелең", "Kaskelen", "Almaty Region", 43.2000, 76.6167, nil},
+	{"TALGAR", "Талгар", "Талғар", "Talgar", "Almaty Region", 43.3000, 77.2333, nil},
+	{"ESIK", "Есик", "Есік", "Esik", "Almaty Region", 43.3500, 77.4500, []string{"Иссык", "Issyk"}},
+	{"TALDYKORGAN", "Талдыкорган", "Талдықорған", "Taldykorgan", "Jetisu Region", 45.0167, 78.3667, nil},
+	{"ZHARKENT", "Жаркент", "Жаркент", "Zharkent", "Jetisu Region", 44.1667, 80.0000, []string{"Панфилов"}},
+	{"KOKSHETAU", "Кокшетау", "Көкшетау", "Kokshetau", "Akmola Region", 53.2833, 69.3833, []string{"Кокчетав", "Kokchetav"}},
+	{"STEPNOGORSK", "Степногорск", "Степногор", "Stepnogorsk", "Akmola Region", 52.3500, 71.8833, nil},
+	{"SHCHUCHINSK", "Щучинск", "Щучинск", "Shchuchinsk", "Akmola Region", 52.9333, 70.2000, nil},
+	{"AKTOBE", "Актобе", "Ақтөбе", "Aktobe", "Aktobe Region", 50.2839, 57.1670, []string{"Актюбинск", "Aktyubinsk"}},
+	{"KHROMTAU", "Хромтау", "Хромтау", "Khromtau", "Aktobe Region", 50.2500, 58.4333, nil},
+	{"ATYRAU", "Атырау", "Атырау", "Atyrau", "Atyrau Region", 47.1167, 51.8833, []string{"Гурьев", "Guryev"}},
+	{"KULSARY", "Кульсары", "Құлсары", "Kulsary", "Atyrau Region", 46.9833, 54.0167, nil},
+	{"OSKEMEN", "Усть-Каменогорск", "Өскемен", "Oskemen", "East Kazakhstan Region", 49.9481, 82.6279, []string{"Ust-Kamenogorsk"}},
+	{"RIDDER", "Риддер", "Риддер", "Ridder", "East Kazakhstan Region", 50.3500, 83.5167, []string{"Лениногорск", "Leninogorsk"}},
+	{"SEMEY", "Семей", "Семей", "Semey", "Abai Region", 50.4111, 80.2275, []string{"Семипалатинск", "Semipalatinsk"}},
+	{"AYAGOZ", "Аягоз", "Аягөз", "Ayagoz", "Abai Region", 47.9667, 80.4333, []string{"Аягуз"}},
+	{"TARAZ", "Тараз", "Тараз", "Taraz", "Jambyl Region", 42.9000, 71.3667, []string{"Джамбул", "Zhambyl", "Dzhambul"}},
+	{"SHU", "Шу", "Шу", "Shu", "Jambyl Region", 43.6000, 73.7667, []string{"Чу", "Chu"}},
+	{"KARAGANDA", "Караганда", "Қарағанды", "Karaganda", "Karaganda Region", 49.8047, 73.1094, nil},
+	{"TEMIRTAU", "Темиртау", "Теміртау", "Temirtau", "Karaganda Region", 50.0586, 72.9486, nil},
+	{"BALKHASH", "Балхаш", "Балқаш", "Balkhash", "Karaganda Region", 46.8481, 74.9950, nil},
+	{"ZHEZKAZGAN", "Жезказган", "Жезқазған", "Zhezkazgan", "Ulytau Region", 47.7833, 67.7000, []string{"Джезказган", "Dzhezkazgan"}},
+	{"SATPAYEV", "Сатпаев", "Сәтбаев", "Satpayev", "Ulytau Region", 47.9000, 67.5333, nil},
+	{"KOSTANAY", "Костанай", "Қостанай", "Kostanay", "Kostanay Region", 53.2144, 63.6246, []string{"Кустанай", "Kustanai"}},
+	{"RUDNY", "Рудный", "Рудный", "Rudny", "Kostanay Region", 52.9667, 63.1167, nil},
+	{"ARKALYK", "Аркалык", "Арқалық", "Arkalyk", "Kostanay Region", 50.2500, 66.9167, nil},
+	{"KYZYLORDA", "Кызылорда", "Қызылорда", "Kyzylorda", "Kyzylorda Region", 44.8488, 65.4823, []string{"Кзыл-Орда"}},
+	{"BAIKONYR", "Байконур", "Байқоңыр", "Baikonyr", "Kyzylorda Region", 45.6167, 63.3167, []string{"Baikonur", "Ленинск"}},
+	{"ARAL", "Аральск", "Арал", "Aral", "Kyzylorda Region", 46.8000, 61.6667, []string{"Aralsk"}},
+	{"AKTAU", "Актау", "Ақтау", "Aktau", "Mangystau Region", 43.6500, 51.1500, []string{"Шевченко"}},
+	{"ZHANAOZEN", "Жанаозен", "Жаңаөзен", "Zhanaozen", "Mangystau Region", 43.3412, 52.8619, []string{"Новый Узень"}},
+	{"PAVLODAR", "Павлодар", "Павлодар", "Pavlodar", "Pavlodar Region", 52.2873, 76.9674, nil},
+	{"EKIBASTUZ", "Экибастуз", "Екібастұз", "Ekibastuz", "Pavlodar Region", 51.7231, 75.3233, nil},
+	{"AKSU", "Аксу", "Ақсу", "Aksu", "Pavlodar Region", 52.0333, 76.9167, []string{"Ермак", "Yermak"}},
+	{"PETROPAVL", "Петропавловск", "Петропавл", "Petropavl", "North Kazakhstan Region", 54.8667, 69.1500, []string{"Petropavlovsk"}},
+	{"TURKESTAN", "Туркестан", "Түркістан", "Turkistan", "Turkistan Region", 43.3000, 68.2500, []string{"Turkestan"}},
+	{"KENTAU", "Кентау", "Кентау", "Kentau", "Turkistan Region", 43.5167, 68.5167, nil},
+	{"SARYAGASH", "Сарыагаш", "Сарыағаш", "Saryagash", "Turkistan Region", 41.4500, 69.1667, nil},
+	{"ORAL", "Уральск", "Орал", "Oral", "West Kazakhstan Region", 51.2333, 51.3667, []string{"Uralsk"}},
+}
+
+// roads are the road distances in kilometres of the busiest lanes, where the
+// great-circle estimate is furthest off
+var roads = map[[2]string]float64{
+	{"ALMATY", "ASTANA"}:       1215,
+	{"ALMATY", "SHYMKENT"}:     690,
+	{"ALMATY", "TARAZ"}:        490,
+	{"ALMATY", "TALDYKORGAN"}:  270,
+	{"ALMATY", "KONAEV"}:       75,
+	{"SHYMKENT", "TARAZ"}:      185,
+	{"SHYMKENT", "TURKESTAN"}:  165,
+	{"ASTANA", "KARAGANDA"}:    215,
+	{"ASTANA", "PAVLODAR"}:     440,
+	{"ASTANA", "KOKSHETAU"}:    300,
+	{"KOKSHETAU", "PETROPAVL"}: 190,
+	{"PAVLODAR", "EKIBASTUZ"}:  140,
+	{"SEMEY", "OSKEMEN"}:       200,
+	{"ATYRAU", "ORAL"}:         490,
+	{"KARAGANDA", "TEMIRTAU"}:  35,
+}
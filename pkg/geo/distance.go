@@ -0,0 +1,62 @@
+package geo
+
+import "math"
+
+const (
+	earthRadiusKm = 6371.0088
+	// RoadFactor is how much longer a road is than the great circle between
+	// its ends, the average over the lanes of roads
+	RoadFactor = 1.25
+)
+
+// GreatCircleKm returns the shortest distance between a and b over the
+// surface of the Earth
+func GreatCircleKm(a, b Place) float64 {
+	lat1, lat2 := radians(a.Lat), radians(b.Lat)
+	dLat, dLon := lat2-lat1, radians(b.Lon-a.Lon)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// RoadKm returns the road distance between a and b: the known one for the
+// busiest lanes, the great-circle distance times RoadFactor otherwise
+func RoadKm(a, b Place) float64 {
+	if km, ok := roads[[2]string{a.Code, b.Code}]; ok {
+		return km
+	}
+	if km, ok := roads[[2]string{b.Code, a.Code}]; ok {
+		return km
+	}
+	return GreatCircleKm(a, b) * RoadFactor
+}
+
+// RoadKmByCode returns RoadKm between the places with codes from and to,
+// false unless both are in the gazetteer
+func RoadKmByCode(from, to string) (float64, bool) {
+	a, okA := Get(from)
+	b, okB := Get(to)
+	if !okA || !okB {
+		return 0, false
+	}
+	return RoadKm(a, b), true
+}
+
+// Matrix returns the road distances between every pair of places, row i
+// holding the distances from places[i]
+func Matrix(places []Place) [][]float64 {
+	m := make([][]float64, len(places))
+	for i, a := range places {
+		m[i] = make([]float64, len(places))
+		for j, b := range places {
+			if i != j {
+				m[i][j] = RoadKm(a, b)
+			}
+		}
+	}
+	return m
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
@@ -0,0 +1,147 @@
+// Package geo is an offline gazetteer of Kazakhstan settlements. It resolves
+// the Russian, Kazakh and Latin names of a place, in Cyrillic or any common
+// transliteration and with small typos, to one code, and estimates road
+// distances between places.
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrUnknownPlace   = errors.New("unknown place")
+	ErrAmbiguousPlace = errors.New("ambiguous place")
+)
+
+type Place struct {
+	// Code is the Latin name in upper case, used in routes and tariffs
+	Code      string  `json:"code"`
+	NameRu    string  `json:"name_ru"`
+	NameKk    string  `json:"name_kk"`
+	NameLatin string  `json:"name_latin"`
+	Region    string  `json:"region"`
+	Lat       float64 `json:"lat"`
+	Lon       float64 `json:"lon"`
+	// Aliases are former names and other spellings
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// index maps the key of every name of a place to its index in places, or to
+// -1 if places share it
+var index = map[string]int{}
+
+func init() {
+	for i, p := range places {
+		for _, name := range append([]string{p.Code, p.NameRu, p.NameKk, p.NameLatin}, p.Aliases...) {
+			k := key(name)
+			if j, ok := index[k]; ok && j != i {
+				index[k] = -1
+				continue
+			}
+			index[k] = i
+		}
+	}
+}
+
+// Places returns every place of the gazetteer
+func Places() []Place {
+	return append([]Place(nil), places...)
+}
+
+// Get returns the place with code
+func Get(code string) (Place, bool) {
+	for _, p := range places {
+		if p.Code == code {
+			return p, true
+		}
+	}
+	return Place{}, false
+}
+
+// Lookup returns the place named name. Names are compared after
+// transliteration to Latin, so "Алматы", "almaty" and "ALMATY" are the same
+// place; failing an exact match, the single closest name within a typo or
+// two is taken.
+func Lookup(name string) (Place, error) {
+	k := key(name)
+	if k == "" {
+		return Place{}, fmt.Errorf("%w %q", ErrUnknownPlace, name)
+	}
+	if i, ok := index[k]; ok {
+		if i < 0 {
+			return Place{}, fmt.Errorf("%w %q", ErrAmbiguousPlace, name)
+		}
+		return places[i], nil
+	}
+
+	// One edit is allowed per four letters
+	best, bestDist, found, tie := 0, len(k)/4, false, false
+	for candidate, i := range index {
+		d := levenshtein(k, candidate)
+		switch {
+		case d > bestDist:
+		case !found || d < bestDist:
+			best, bestDist, found, tie = i, d, true, i < 0
+		case i != best:
+			tie = true
+		}
+	}
+	switch {
+	case !found:
+		return Place{}, fmt.Errorf("%w %q", ErrUnknownPlace, name)
+	case tie:
+		return Place{}, fmt.Errorf("%w %q", ErrAmbiguousPlace, name)
+	}
+	return places[best], nil
+}
+
+// translit maps Russian and Kazakh Cyrillic and Kazakh Latin letters to ASCII
+var translit = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'ә': "a", 'ғ': "g", 'қ': "k", 'ң': "n", 'ө': "o", 'ұ': "u", 'ү': "u",
+	'һ': "h", 'і': "i",
+	'ä': "a", 'ğ': "g", 'ı': "i", 'ñ': "n", 'ö': "o", 'ū': "u", 'ü': "u", 'ş': "sh",
+	'ç': "ch", 'é': "e",
+}
+
+// folds merge spellings transliterations disagree on
+var folds = strings.NewReplacer("dzh", "zh", "j", "zh", "gh", "g", "kh", "h", "q", "k", "y", "i")
+
+// key reduces name to lower-case ASCII letters with spelling variants folded
+func key(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if s, ok := translit[r]; ok {
+			b.WriteString(s)
+		} else if r <= unicode.MaxASCII && unicode.IsLetter(r) {
+			b.WriteRune(r)
+		}
+	}
+	return folds.Replace(b.String())
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
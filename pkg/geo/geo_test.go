@@ -0,0 +1,90 @@
+package geo_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/aidosgal/transline-test/pkg/geo"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		err  error
+	}{
+		{"ALMATY", "ALMATY", nil},
+		{"Алматы", "ALMATY", nil},
+		{" almaty ", "ALMATY", nil},
+		{"Алма-Ата", "ALMATY", nil},
+		{"Almata", "ALMATY", nil},
+		{"Қарағанды", "KARAGANDA", nil},
+		{"Qaraghandy", "KARAGANDA", nil},
+		{"Карагада", "KARAGANDA", nil},
+		{"Усть-Каменогорск", "OSKEMEN", nil},
+		{"Ust Kamenogorsk", "OSKEMEN", nil},
+		{"Öskemen", "OSKEMEN", nil},
+		{"Nur-Sultan", "ASTANA", nil},
+		{"Jezkazgan", "ZHEZKAZGAN", nil},
+		{"Семей", "SEMEY", nil},
+		{"Petropavlovsk", "PETROPAVL", nil},
+		{"Шу", "SHU", nil},
+		{"Ural", "", geo.ErrAmbiguousPlace},
+		{"Moscow", "", geo.ErrUnknownPlace},
+		{"Tashkent", "", geo.ErrUnknownPlace},
+		{"", "", geo.ErrUnknownPlace},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := geo.Lookup(tt.name)
+			if !errors.Is(err, tt.err) || p.Code != tt.want {
+				t.Errorf("Lookup(%q) = %s, %v; want %s, %v", tt.name, p.Code, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestPlaces(t *testing.T) {
+	for _, p := range geo.Places() {
+		// Every name must lead back to its place, or two places share it
+		for _, name := range append([]string{p.Code, p.NameRu, p.NameKk, p.NameLatin}, p.Aliases...) {
+			if got, err := geo.Lookup(name); err != nil || got.Code != p.Code {
+				t.Errorf("Lookup(%q) = %s, %v; want %s", name, got.Code, err, p.Code)
+			}
+		}
+		if p.Lat < 40.5 || p.Lat > 55.5 || p.Lon < 46.5 || p.Lon > 87.5 {
+			t.Errorf("%s at %g, %g is outside Kazakhstan", p.Code, p.Lat, p.Lon)
+		}
+	}
+}
+
+func TestDistance(t *testing.T) {
+	get := func(code string) geo.Place {
+		p, ok := geo.Get(code)
+		if !ok {
+			t.Fatalf("no place %s", code)
+		}
+		return p
+	}
+	almaty, astana, aktau := get("ALMATY"), get("ASTANA"), get("AKTAU")
+
+	if km := geo.GreatCircleKm(almaty, astana); math.Abs(km-970) > 10 {
+		t.Errorf("great circle ALMATY→ASTANA = %.0f km, want about 970", km)
+	}
+	if km := geo.RoadKm(astana, almaty); km != 1215 {
+		t.Errorf("road ASTANA→ALMATY = %.0f km, want the known 1215", km)
+	}
+	if km, gc := geo.RoadKm(almaty, aktau), geo.GreatCircleKm(almaty, aktau); km != gc*geo.RoadFactor {
+		t.Errorf("road ALMATY→AKTAU = %.0f km, want %.0f × %g", km, gc, geo.RoadFactor)
+	}
+
+	m := geo.Matrix([]geo.Place{almaty, astana, aktau})
+	for i := range m {
+		for j := range m {
+			if m[i][j] != m[j][i] || (i == j) != (m[i][j] == 0) {
+				t.Errorf("matrix is not symmetric with a zero diagonal: %v", m)
+			}
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/json"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of running every readiness check
+type Result struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Checker runs readiness checks and tracks graceful shutdown
+type Checker struct {
+	log          *slog.Logger
+	timeout      time.Duration
+	mu           sync.RWMutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+}
+
+func New(log *slog.Logger, timeout time.Duration) *Checker {
+	return &Checker{
+		log:     log.With("layer", "health"),
+		timeout: timeout,
+	}
+}
+
+// Add registers a readiness check under name
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Shutdown marks the service as not ready so load balancers drain it
+func (c *Checker) Shutdown() {
+	c.shuttingDown.Store(true)
+}
+
+// Drain marks the service as not ready and waits delay, so load balancers
+// see the failing probe and stop sending new requests before the caller
+// closes its listeners
+func (c *Checker) Drain(delay time.Duration) {
+	c.Shutdown()
+	if delay <= 0 {
+		return
+	}
+	c.log.Info("draining before closing listeners", slog.Duration("delay", delay))
+	time.Sleep(delay)
+}
+
+// Ready runs every check concurrently and reports whether all of them passed
+func (c *Checker) Ready(ctx context.Context) (*Result, bool) {
+	if c.shuttingDown.Load() {
+		return &Result{Status: StatusShuttingDown, Checks: map[string]string{}}, false
+	}
+
+	c.mu.RLock()
+	checks := append([]namedCheck(nil), c.checks...)
+	c.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	errs := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = nc.check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	result := &Result{Status: StatusOK, Checks: make(map[string]string, len(checks))}
+	ready := true
+	for i, nc := range checks {
+		if errs[i] != nil {
+			ready = false
+			result.Checks[nc.name] = errs[i].Error()
+			c.log.WarnContext(ctx, "readiness check failed",
+				slog.String("check", nc.name),
+				slog.String("error", errs[i].Error()))
+			continue
+		}
+		result.Checks[nc.name] = StatusOK
+	}
+	if !ready {
+		result.Status = StatusFailing
+	}
+	return result, ready
+}
+
+// LiveHandler answers liveness probes: the process is up and serving HTTP
+func (c *Checker) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	json.WriteJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// ReadyHandler answers readiness probes with the result of every check
+func (c *Checker) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	result, ready := c.Ready(r.Context())
+	if !ready {
+		json.WriteJSON(w, http.StatusServiceUnavailable, result)
+		return
+	}
+	json.WriteJSON(w, http.StatusOK, result)
+}
+
+// Watch runs the checks every interval and mirrors the outcome into the gRPC
+// health server for the given services ("" is the overall server status).
+// It returns when ctx is done.
+func (c *Checker) Watch(ctx context.Context, srv *grpchealth.Server, interval time.Duration, services ...string) {
+	services = append([]string{""}, services...)
+	update := func() {
+		status := healthpb.HealthCheckResponse_SERVING
+		if _, ready := c.Ready(ctx); !ready {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		for _, service := range services {
+			srv.SetServingStatus(service, status)
+		}
+	}
+
+	update()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.shuttingDown.Load() {
+				return
+			}
+			update()
+		}
+	}
+}
+
+// DBCheck pings the database
+func DBCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to ping db: %w", err)
+		}
+		return nil
+	}
+}
+
+// MigrationCheck verifies that golang-migrate applied at least one
+// migration and did not leave the schema dirty
+func MigrationCheck(db *sql.DB) Check {
+	return func(ctx context.Context) error {
+		var (
+			version int64
+			dirty   bool
+		)
+		err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).
+			Scan(&version, &dirty)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errors.New("no migrations applied")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read migration version: %w", err)
+		}
+		if dirty {
+			return fmt.Errorf("migration %d is dirty", version)
+		}
+		return nil
+	}
+}
+
+// GRPCCheck asks a gRPC server for the status of service via grpc.health.v1
+func GRPCCheck(conn grpc.ClientConnInterface, service string) Check {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return fmt.Errorf("failed to check %q health: %w", service, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%q is %s", service, resp.GetStatus())
+		}
+		return nil
+	}
+}
+
+// TCPCheck verifies that address accepts TCP connections, e.g. a collector
+// that has no health API of its own on the exporter port
+func TCPCheck(address string) Check {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return fmt.Errorf("failed to connect to %s: %w", address, err)
+		}
+		return conn.Close()
+	}
+}
@@ -0,0 +1,47 @@
+package health
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	c := New(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second)
+	c.Add("db", func(context.Context) error { return nil })
+
+	done := make(chan struct{})
+	start := time.Now()
+	go func() {
+		c.Drain(100 * time.Millisecond)
+		close(done)
+	}()
+
+	// Not ready while still draining, so the load balancer stops routing
+	// before the listeners close
+	time.Sleep(20 * time.Millisecond)
+	res, ok := c.Ready(context.Background())
+	if ok || res.Status != StatusShuttingDown {
+		t.Fatalf("Ready during drain = %+v, %v, want %s", res, ok, StatusShuttingDown)
+	}
+	select {
+	case <-done:
+		t.Fatal("Drain returned before the delay")
+	default:
+	}
+
+	<-done
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Drain took %v, want at least 100ms", elapsed)
+	}
+}
+
+func TestDrainZero(t *testing.T) {
+	c := New(slog.New(slog.NewTextHandler(io.Discard, nil)), time.Second)
+	c.Drain(0)
+	if res, ok := c.Ready(context.Background()); ok || res.Status != StatusShuttingDown {
+		t.Fatalf("Ready = %+v, %v, want %s", res, ok, StatusShuttingDown)
+	}
+}
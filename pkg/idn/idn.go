@@ -0,0 +1,126 @@
+// Package idn generates and validates Kazakhstan identification numbers:
+// IIN of individuals and BIN of legal entities. Both are 12 digits, the last
+// one a check digit.
+package idn
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Length is the number of digits in an IIN or BIN
+const Length = 12
+
+// BIN entity types, the fifth digit
+const (
+	BINResident     = 4
+	BINNonResident  = 5
+	BINEntrepreneur = 6
+)
+
+// BIN divisions, the sixth digit
+const (
+	BINHead           = 0
+	BINBranch         = 1
+	BINRepresentative = 2
+	BINPeasantFarm    = 3
+)
+
+var (
+	weights      = [Length - 1]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	retryWeights = [Length - 1]int{3, 4, 5, 6, 7, 8, 9, 10, 11, 1, 2}
+)
+
+// Valid reports whether s is 12 digits with a correct check digit
+func Valid(s string) bool {
+	if len(s) != Length {
+		return false
+	}
+	var digits [Length]int
+	for i := range Length {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		digits[i] = int(s[i] - '0')
+	}
+	check, ok := checkDigit(digits[:Length-1])
+	return ok && check == digits[Length-1]
+}
+
+// IsBIN reports whether s, a valid IDN, is a BIN: its fifth digit is the
+// entity type, where an IIN has the tens of the birth day
+func IsBIN(s string) bool {
+	return len(s) == Length && s[4] >= '0'+BINResident && s[4] <= '0'+BINEntrepreneur
+}
+
+// IIN returns an IIN of a person born on birth with a random serial number.
+// Births before 1800 or after 2099 are not representable.
+func IIN(r *rand.Rand, birth time.Time, female bool) string {
+	century := (birth.Year()-1800)/100*2 + 1
+	if female {
+		century++
+	}
+	prefix := fmt.Sprintf("%02d%02d%02d%d", birth.Year()%100, int(birth.Month()), birth.Day(), century)
+	return withSerial(r, prefix)
+}
+
+// BIN returns a BIN registered in the month of registered with a random
+// serial number
+func BIN(r *rand.Rand, registered time.Time, entity, division int) string {
+	prefix := fmt.Sprintf("%02d%02d%d%d", registered.Year()%100, int(registered.Month()), entity, division)
+	return withSerial(r, prefix)
+}
+
+// RandomIIN returns an IIN of a person born between 1950 and 2005
+func RandomIIN(r *rand.Rand) string {
+	from := time.Date(1950, 1, 1, 0, 0, 0, 0, time.UTC)
+	days := int(time.Date(2006, 1, 1, 0, 0, 0, 0, time.UTC).Sub(from).Hours() / 24)
+	return IIN(r, from.AddDate(0, 0, r.IntN(days)), r.IntN(2) == 0)
+}
+
+// RandomBIN returns a BIN of a resident head office or branch registered
+// between 2000 and 2024
+func RandomBIN(r *rand.Rand) string {
+	registered := time.Date(2000+r.IntN(25), time.Month(1+r.IntN(12)), 1, 0, 0, 0, 0, time.UTC)
+	return BIN(r, registered, BINResident, r.IntN(2))
+}
+
+// withSerial appends random serial digits and the check digit to prefix,
+// drawing again for the serials that have no valid check digit
+func withSerial(r *rand.Rand, prefix string) string {
+	n := Length - 1 - len(prefix)
+	for {
+		s := prefix + fmt.Sprintf("%0*d", n, r.IntN(pow10(n)))
+		var digits [Length - 1]int
+		for i := range digits {
+			digits[i] = int(s[i] - '0')
+		}
+		if check, ok := checkDigit(digits[:]); ok {
+			return s + string(rune('0'+check))
+		}
+	}
+}
+
+// checkDigit computes the check digit of the first 11 digits; some numbers
+// have none and are never issued
+func checkDigit(digits []int) (int, bool) {
+	for _, w := range [][Length - 1]int{weights, retryWeights} {
+		sum := 0
+		for i, d := range digits {
+			sum += d * w[i]
+		}
+		if sum%11 != 10 {
+			return sum % 11, true
+		}
+	}
+	return 0, false
+}
+
+func pow10(n int) int {
+	p := 1
+	for range n {
+		p *= 10
+	}
+	return p
+}
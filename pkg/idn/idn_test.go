@@ -0,0 +1,59 @@
+package idn_test
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/idn"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		idn  string
+		want bool
+	}{
+		{"990101300123", false},
+		{"900101300126", true},
+		{"000740000004", true},
+		{"900101300125", false},
+		{"90010130012", false},
+		{"90010130012a", false},
+	}
+	for _, tt := range tests {
+		if got := idn.Valid(tt.idn); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.idn, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	r := rand.New(rand.NewPCG(1, 2))
+	birth := time.Date(1985, time.March, 7, 0, 0, 0, 0, time.UTC)
+
+	for range 1000 {
+		iin := idn.IIN(r, birth, true)
+		if !idn.Valid(iin) || !strings.HasPrefix(iin, "8503074") {
+			t.Fatalf("IIN = %s, want a valid 8503074…", iin)
+		}
+		if bin := idn.RandomBIN(r); !idn.Valid(bin) || bin[4] != '4' || !idn.IsBIN(bin) {
+			t.Fatalf("RandomBIN = %s, want a valid resident BIN", bin)
+		}
+		if iin := idn.RandomIIN(r); !idn.Valid(iin) || idn.IsBIN(iin) {
+			t.Fatalf("RandomIIN = %s is invalid", iin)
+		}
+	}
+
+	if iin := idn.IIN(r, time.Date(2003, time.December, 31, 0, 0, 0, 0, time.UTC), false); iin[6] != '5' {
+		t.Errorf("IIN = %s, want century digit 5 for a man born in 2003", iin)
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a := idn.RandomIIN(rand.New(rand.NewPCG(7, 7)))
+	b := idn.RandomIIN(rand.New(rand.NewPCG(7, 7)))
+	if a != b {
+		t.Errorf("same seed gave %s and %s", a, b)
+	}
+}
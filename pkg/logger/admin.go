@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/json"
+)
+
+type (
+	// LevelsResp is the admin view of the current log levels
+	LevelsResp struct {
+		Default string            `json:"default"`
+		Layers  map[string]string `json:"layers"`
+	}
+
+	// SetLevelReq changes the level of one layer, "" meaning the default.
+	// RevertAfter is a Go duration such as "15m"; empty keeps the level.
+	SetLevelReq struct {
+		Layer       string `json:"layer"`
+		Level       string `json:"level"`
+		RevertAfter string `json:"revert_after"`
+	}
+)
+
+// MakeLevelsResp converts a levels snapshot into its admin view
+func MakeLevelsResp(levels *Levels) *LevelsResp {
+	resp := &LevelsResp{Layers: map[string]string{}}
+	for layer, level := range levels.Snapshot() {
+		if layer == "" {
+			resp.Default = level.String()
+			continue
+		}
+		resp.Layers[layer] = level.String()
+	}
+	return resp
+}
+
+// Apply validates the request and sets the level
+func (req *SetLevelReq) Apply(levels *Levels) error {
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		return err
+	}
+
+	var revertAfter time.Duration
+	if req.RevertAfter != "" {
+		revertAfter, err = time.ParseDuration(req.RevertAfter)
+		if err != nil {
+			return fmt.Errorf("invalid revert_after %q: %w", req.RevertAfter, err)
+		}
+	}
+
+	levels.Set(req.Layer, level, revertAfter)
+	return nil
+}
+
+// LevelsHandler serves GET (current levels) and PUT (change a level) for the admin API
+func LevelsHandler(log *slog.Logger, levels *Levels) http.HandlerFunc {
+	log = log.With("layer", "admin")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			json.WriteJSON(w, http.StatusOK, MakeLevelsResp(levels))
+		case http.MethodPut, http.MethodPost:
+			req := &SetLevelReq{}
+			if err := json.ParseJSON(r, req); err != nil {
+				json.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := req.Apply(levels); err != nil {
+				json.WriteError(w, http.StatusBadRequest, err)
+				return
+			}
+
+			log.InfoContext(r.Context(), "log level changed",
+				slog.String("target_layer", req.Layer),
+				slog.String("level", req.Level),
+				slog.String("revert_after", req.RevertAfter))
+			json.WriteJSON(w, http.StatusOK, MakeLevelsResp(levels))
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			json.WriteError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+		}
+	}
+}
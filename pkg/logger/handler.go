@@ -3,6 +3,8 @@ package logger
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"time"
 
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
@@ -11,12 +13,40 @@ import (
 
 // TraceHandler wraps an slog.Handler and adds trace context + span events
 type TraceHandler struct {
-	handler slog.Handler
+	handler    slog.Handler
+	spanEvents bool
+	prefix     string
+	attrs      []attribute.KeyValue
+	// root is handler before the first group, and groups are the groups
+	// opened since with their attrs. A record with trace IDs logged inside
+	// groups goes to root with its attrs nested in groups, so trace_id and
+	// span_id stay at the top level.
+	root   slog.Handler
+	groups []traceGroup
+}
+
+type traceGroup struct {
+	name  string
+	attrs []slog.Attr
+}
+
+// TraceOption configures a TraceHandler
+type TraceOption func(*TraceHandler)
+
+// WithoutSpanEvents keeps trace_id/span_id on records but stops copying them into span events
+func WithoutSpanEvents() TraceOption {
+	return func(h *TraceHandler) {
+		h.spanEvents = false
+	}
 }
 
 // NewTraceHandler creates a new handler that adds trace_id, span_id and span events
-func NewTraceHandler(h slog.Handler) *TraceHandler {
-	return &TraceHandler{handler: h}
+func NewTraceHandler(h slog.Handler, opts ...TraceOption) *TraceHandler {
+	th := &TraceHandler{handler: h, root: h, spanEvents: true}
+	for _, opt := range opts {
+		opt(th)
+	}
+	return th
 }
 
 func (h *TraceHandler) Enabled(ctx context.Context, level slog.Level) bool {
@@ -27,29 +57,14 @@ func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
 	// Extract trace context from the context
 	spanCtx := trace.SpanContextFromContext(ctx)
 	if spanCtx.IsValid() {
-		r.AddAttrs(
-			slog.String("trace_id", spanCtx.TraceID().String()),
-			slog.String("span_id", spanCtx.SpanID().String()),
-		)
-
-		// Add log as span event (visible in Jaeger UI)
+		// Add log as span event (visible in Jaeger UI), which has the trace
+		// context already
 		span := trace.SpanFromContext(ctx)
-		if span.IsRecording() {
-			attrs := make([]attribute.KeyValue, 0)
+		if h.spanEvents && span.IsRecording() {
+			attrs := make([]attribute.KeyValue, 0, len(h.attrs)+r.NumAttrs()+1)
+			attrs = append(attrs, h.attrs...)
 			r.Attrs(func(a slog.Attr) bool {
-				// Convert slog attributes to OTel attributes
-				switch a.Value.Kind() {
-				case slog.KindString:
-					attrs = append(attrs, attribute.String(a.Key, a.Value.String()))
-				case slog.KindInt64:
-					attrs = append(attrs, attribute.Int64(a.Key, a.Value.Int64()))
-				case slog.KindFloat64:
-					attrs = append(attrs, attribute.Float64(a.Key, a.Value.Float64()))
-				case slog.KindBool:
-					attrs = append(attrs, attribute.Bool(a.Key, a.Value.Bool()))
-				default:
-					attrs = append(attrs, attribute.String(a.Key, a.Value.String()))
-				}
+				attrs = appendSpanAttr(attrs, h.prefix, a)
 				return true
 			})
 
@@ -64,14 +79,114 @@ func (h *TraceHandler) Handle(ctx context.Context, r slog.Record) error {
 				span.SetStatus(codes.Error, r.Message)
 			}
 		}
+
+		ids := []slog.Attr{
+			slog.String("trace_id", spanCtx.TraceID().String()),
+			slog.String("span_id", spanCtx.SpanID().String()),
+		}
+		if len(h.groups) == 0 {
+			r.AddAttrs(ids...)
+			return h.handler.Handle(ctx, r)
+		}
+		nested := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		nested.AddAttrs(ids...)
+		nested.AddAttrs(h.nest(r))
+		return h.root.Handle(ctx, nested)
 	}
 	return h.handler.Handle(ctx, r)
 }
 
+// nest returns the attrs of r inside the groups of h, as h.handler would
+// have put them
+func (h *TraceHandler) nest(r slog.Record) slog.Attr {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		attrs = []slog.Attr{{Key: g.name, Value: slog.GroupValue(slices.Concat(g.attrs, attrs)...)}}
+	}
+	return attrs[0]
+}
+
 func (h *TraceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &TraceHandler{handler: h.handler.WithAttrs(attrs)}
+	clone := h.clone()
+	clone.handler = h.handler.WithAttrs(attrs)
+	if n := len(clone.groups); n > 0 {
+		clone.groups[n-1].attrs = append(clone.groups[n-1].attrs, attrs...)
+	} else {
+		clone.root = clone.handler
+	}
+	for _, a := range attrs {
+		clone.attrs = appendSpanAttr(clone.attrs, h.prefix, a)
+	}
+	return clone
 }
 
 func (h *TraceHandler) WithGroup(name string) slog.Handler {
-	return &TraceHandler{handler: h.handler.WithGroup(name)}
+	if name == "" {
+		return h
+	}
+	clone := h.clone()
+	clone.handler = h.handler.WithGroup(name)
+	clone.prefix = h.prefix + name + "."
+	clone.groups = append(clone.groups, traceGroup{name: name})
+	return clone
+}
+
+func (h *TraceHandler) clone() *TraceHandler {
+	groups := make([]traceGroup, len(h.groups))
+	for i, g := range h.groups {
+		groups[i] = traceGroup{name: g.name, attrs: append([]slog.Attr(nil), g.attrs...)}
+	}
+	return &TraceHandler{
+		handler:    h.handler,
+		spanEvents: h.spanEvents,
+		prefix:     h.prefix,
+		attrs:      append([]attribute.KeyValue(nil), h.attrs...),
+		root:       h.root,
+		groups:     groups,
+	}
+}
+
+// appendSpanAttr converts an slog attribute to OTel span attributes.
+// Span attributes are flat, so groups become dotted key prefixes.
+func appendSpanAttr(attrs []attribute.KeyValue, prefix string, a slog.Attr) []attribute.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if a.Key != "" {
+			groupPrefix = prefix + a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			attrs = appendSpanAttr(attrs, groupPrefix, ga)
+		}
+		return attrs
+	}
+
+	key := prefix + a.Key
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return append(attrs, attribute.String(key, a.Value.String()))
+	case slog.KindInt64:
+		return append(attrs, attribute.Int64(key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(attrs, attribute.Int64(key, int64(a.Value.Uint64())))
+	case slog.KindFloat64:
+		return append(attrs, attribute.Float64(key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(attrs, attribute.Bool(key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(attrs, attribute.String(key, a.Value.Duration().String()))
+	case slog.KindTime:
+		return append(attrs, attribute.String(key, a.Value.Time().Format(time.RFC3339Nano)))
+	default:
+		return append(attrs, attribute.String(key, a.Value.String()))
+	}
 }
@@ -0,0 +1,216 @@
+package logger_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	otellog "go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/log/embedded"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/aidosgal/transline-test/pkg/logger"
+)
+
+// provider hands out rec as every logger
+type provider struct {
+	embedded.LoggerProvider
+	rec *recorder
+}
+
+func (p provider) Logger(string, ...otellog.LoggerOption) otellog.Logger {
+	return p.rec
+}
+
+// recorder is an OTel logger keeping the attributes of every record
+type recorder struct {
+	embedded.Logger
+	records []map[string]any
+}
+
+func (r *recorder) Emit(_ context.Context, record otellog.Record) {
+	attrs := map[string]any{}
+	record.WalkAttributes(func(kv otellog.KeyValue) bool {
+		attrs[kv.Key] = plain(kv.Value)
+		return true
+	})
+	r.records = append(r.records, attrs)
+}
+
+func (r *recorder) Enabled(context.Context, otellog.EnabledParameters) bool {
+	return true
+}
+
+// plain converts a log value to Go values, maps to map[string]any
+func plain(v otellog.Value) any {
+	switch v.Kind() {
+	case otellog.KindMap:
+		m := map[string]any{}
+		for _, kv := range v.AsMap() {
+			m[kv.Key] = plain(kv.Value)
+		}
+		return m
+	case otellog.KindInt64:
+		return v.AsInt64()
+	case otellog.KindBool:
+		return v.AsBool()
+	default:
+		return v.AsString()
+	}
+}
+
+// groupCases log with groups and nesting; want is the OTel record, wantSpan
+// the flat attributes of the span event
+var groupCases = []struct {
+	name     string
+	log      func(log *slog.Logger)
+	want     map[string]any
+	wantSpan map[string]any
+}{
+	{
+		name:     "flat",
+		log:      func(log *slog.Logger) { log.Info("msg", "a", 1, "b", "x") },
+		want:     map[string]any{"a": int64(1), "b": "x"},
+		wantSpan: map[string]any{"a": int64(1), "b": "x"},
+	},
+	{
+		name: "group attr",
+		log: func(log *slog.Logger) {
+			log.Info("msg", slog.Group("req", "method", "GET", slog.Group("url", "path", "/")))
+		},
+		want:     map[string]any{"req": map[string]any{"method": "GET", "url": map[string]any{"path": "/"}}},
+		wantSpan: map[string]any{"req.method": "GET", "req.url.path": "/"},
+	},
+	{
+		name:     "inline group",
+		log:      func(log *slog.Logger) { log.Info("msg", slog.Group("", "a", 1)) },
+		want:     map[string]any{"a": int64(1)},
+		wantSpan: map[string]any{"a": int64(1)},
+	},
+	{
+		name:     "empty group dropped",
+		log:      func(log *slog.Logger) { log.Info("msg", slog.Group("empty"), "a", 1) },
+		want:     map[string]any{"a": int64(1)},
+		wantSpan: map[string]any{"a": int64(1)},
+	},
+	{
+		name:     "WithGroup nests record attrs",
+		log:      func(log *slog.Logger) { log.WithGroup("g").WithGroup("h").Info("msg", "a", 1) },
+		want:     map[string]any{"g": map[string]any{"h": map[string]any{"a": int64(1)}}},
+		wantSpan: map[string]any{"g.h.a": int64(1)},
+	},
+	{
+		name: "With before and inside groups",
+		log: func(log *slog.Logger) {
+			log.With("service", "s").WithGroup("g").With("layer", "l").WithGroup("h").Info("msg", "a", 1)
+		},
+		want: map[string]any{
+			"service": "s",
+			"g":       map[string]any{"layer": "l", "h": map[string]any{"a": int64(1)}},
+		},
+		wantSpan: map[string]any{"service": "s", "g.layer": "l", "g.h.a": int64(1)},
+	},
+	{
+		name:     "WithGroup without attrs dropped",
+		log:      func(log *slog.Logger) { log.With("service", "s").WithGroup("g").Info("msg") },
+		want:     map[string]any{"service": "s"},
+		wantSpan: map[string]any{"service": "s"},
+	},
+	{
+		name:     "empty WithGroup ignored",
+		log:      func(log *slog.Logger) { log.WithGroup("").Info("msg", "a", 1) },
+		want:     map[string]any{"a": int64(1)},
+		wantSpan: map[string]any{"a": int64(1)},
+	},
+}
+
+func TestOTelHandlerGroups(t *testing.T) {
+	for _, tt := range groupCases {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &recorder{}
+			tt.log(slog.New(logger.NewOTelHandler(provider{rec: rec}, "test", slog.LevelDebug)))
+
+			if len(rec.records) != 1 {
+				t.Fatalf("records = %d, want 1", len(rec.records))
+			}
+			if got := rec.records[0]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attributes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceHandlerGroups(t *testing.T) {
+	for _, tt := range groupCases {
+		t.Run(tt.name, func(t *testing.T) {
+			spans := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+			ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+
+			h := logger.NewTraceHandler(slog.NewTextHandler(io.Discard, nil))
+			tt.log(slog.New(contextHandler{h, ctx}))
+			span.End()
+
+			events := spans.Ended()[0].Events()
+			if len(events) != 1 {
+				t.Fatalf("span events = %d, want 1", len(events))
+			}
+			got := map[string]any{}
+			for _, kv := range events[0].Attributes {
+				if kv.Key != "level" {
+					got[string(kv.Key)] = kv.Value.AsInterface()
+				}
+			}
+			if !reflect.DeepEqual(got, tt.wantSpan) {
+				t.Errorf("event attributes = %v, want %v", got, tt.wantSpan)
+			}
+		})
+	}
+}
+
+// contextHandler logs every record with ctx, as the service code does with
+// the *Context methods
+type contextHandler struct {
+	slog.Handler
+	ctx context.Context
+}
+
+func (h contextHandler) Handle(_ context.Context, r slog.Record) error {
+	return h.Handler.Handle(h.ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs), h.ctx}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name), h.ctx}
+}
+
+func TestTraceHandlerIDs(t *testing.T) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+
+	var buf bytes.Buffer
+	log := slog.New(logger.NewTraceHandler(slog.NewJSONHandler(&buf, nil)))
+	log.With("service", "s").WithGroup("g").With("layer", "l").WithGroup("h").With("b", 2).InfoContext(ctx, "msg", "a", 1)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal %q: %v", buf.String(), err)
+	}
+	// IDs stay at the top level, where log search expects them, inside groups too
+	if record["trace_id"] != span.SpanContext().TraceID().String() || record["span_id"] != span.SpanContext().SpanID().String() {
+		t.Errorf("record = %v, want the trace_id and span_id of the span at the top level", record)
+	}
+	want := map[string]any{"layer": "l", "h": map[string]any{"b": float64(2), "a": float64(1)}}
+	if !reflect.DeepEqual(record["g"], want) || record["service"] != "s" {
+		t.Errorf("record = %v, want service s and group g %v", record, want)
+	}
+}
@@ -0,0 +1,205 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LayerKey is the attribute every layer logger is tagged with
+const LayerKey = "layer"
+
+// Levels holds a default level and per-layer overrides that can be changed at runtime
+type Levels struct {
+	mu      sync.RWMutex
+	initial map[string]slog.Level
+	vars    map[string]*slog.LevelVar
+	timers  map[string]*time.Timer
+	// gens counts the changes of every layer, so a revert timer that fired
+	// while a newer Set or Reset held the lock does nothing
+	gens map[string]uint64
+}
+
+// NewLevels creates levels with def as the default and the given per-layer overrides
+func NewLevels(def slog.Level, layers map[string]slog.Level) *Levels {
+	l := &Levels{
+		initial: map[string]slog.Level{"": def},
+		vars:    map[string]*slog.LevelVar{"": newLevelVar(def)},
+		timers:  map[string]*time.Timer{},
+		gens:    map[string]uint64{},
+	}
+	for layer, level := range layers {
+		layer = strings.ToLower(layer)
+		l.initial[layer] = level
+		l.vars[layer] = newLevelVar(level)
+	}
+	return l
+}
+
+// ParseLevels parses a default level and a "layer=level,layer=level" override list
+func ParseLevels(def, spec string) (*Levels, error) {
+	defLevel, err := ParseLevel(def)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := map[string]slog.Level{}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		layer, level, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(layer) == "" {
+			return nil, fmt.Errorf("invalid layer level %q, expected layer=level", pair)
+		}
+		lvl, err := ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		layers[strings.TrimSpace(layer)] = lvl
+	}
+
+	return NewLevels(defLevel, layers), nil
+}
+
+// ParseLevel parses a level name such as debug, info, warn, error or info+2
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", s, err)
+	}
+	return level, nil
+}
+
+// Level returns the effective level of a layer, falling back to the default
+func (l *Levels) Level(layer string) slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if v, ok := l.vars[layer]; ok {
+		return v.Level()
+	}
+	return l.vars[""].Level()
+}
+
+// Set changes the level of a layer ("" for the default). When revertAfter is
+// positive the layer goes back to its startup level once it elapses.
+func (l *Levels) Set(layer string, level slog.Level, revertAfter time.Duration) {
+	layer = strings.ToLower(layer)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if v, ok := l.vars[layer]; ok {
+		v.Set(level)
+	} else {
+		l.vars[layer] = newLevelVar(level)
+	}
+
+	gen := l.stopTimer(layer)
+	if revertAfter > 0 {
+		l.timers[layer] = time.AfterFunc(revertAfter, func() {
+			l.revert(layer, gen)
+		})
+	}
+}
+
+// Reset puts a layer back to its startup level
+func (l *Levels) Reset(layer string) {
+	layer = strings.ToLower(layer)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.reset(layer)
+}
+
+// revert resets a layer unless it changed after the timer of gen was started
+func (l *Levels) revert(layer string, gen uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.gens[layer] != gen {
+		return
+	}
+	l.reset(layer)
+}
+
+// stopTimer stops the revert timer of a layer and starts its next generation.
+// Stop does not wait for a timer that has fired already, the generation
+// check in revert covers that.
+func (l *Levels) stopTimer(layer string) uint64 {
+	if t, ok := l.timers[layer]; ok {
+		t.Stop()
+		delete(l.timers, layer)
+	}
+	l.gens[layer]++
+	return l.gens[layer]
+}
+
+func (l *Levels) reset(layer string) {
+	l.stopTimer(layer)
+	if level, ok := l.initial[layer]; ok {
+		l.vars[layer].Set(level)
+		return
+	}
+	delete(l.vars, layer)
+}
+
+// Snapshot returns the current level of every layer, with "" as the default
+func (l *Levels) Snapshot() map[string]slog.Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	levels := make(map[string]slog.Level, len(l.vars))
+	for layer, v := range l.vars {
+		levels[layer] = v.Level()
+	}
+	return levels
+}
+
+func newLevelVar(level slog.Level) *slog.LevelVar {
+	v := &slog.LevelVar{}
+	v.Set(level)
+	return v
+}
+
+// LevelHandler wraps an slog.Handler and filters records by the level of
+// the layer the logger was created for
+type LevelHandler struct {
+	handler slog.Handler
+	levels  *Levels
+	layer   string
+}
+
+// NewLevelHandler creates a new handler that filters records using levels.
+// The wrapped handler should accept every level.
+func NewLevelHandler(h slog.Handler, levels *Levels) *LevelHandler {
+	return &LevelHandler{handler: h, levels: levels}
+}
+
+func (h *LevelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.levels.Level(h.layer) && h.handler.Enabled(ctx, level)
+}
+
+func (h *LevelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *LevelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	layer := h.layer
+	for _, a := range attrs {
+		if a.Key == LayerKey {
+			layer = strings.ToLower(a.Value.String())
+		}
+	}
+	return &LevelHandler{handler: h.handler.WithAttrs(attrs), levels: h.levels, layer: layer}
+}
+
+func (h *LevelHandler) WithGroup(name string) slog.Handler {
+	return &LevelHandler{handler: h.handler.WithGroup(name), levels: h.levels, layer: h.layer}
+}
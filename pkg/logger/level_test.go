@@ -0,0 +1,110 @@
+package logger_test
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/logger"
+)
+
+func TestLevelsSet(t *testing.T) {
+	levels := logger.NewLevels(slog.LevelInfo, map[string]slog.Level{"storage": slog.LevelWarn})
+
+	levels.Set("Storage", slog.LevelDebug, 0)
+	levels.Set("server", slog.LevelError, 0)
+	levels.Set("", slog.LevelWarn, 0)
+
+	tests := []struct {
+		layer string
+		want  slog.Level
+	}{
+		{"storage", slog.LevelDebug},
+		{"server", slog.LevelError},
+		{"saga", slog.LevelWarn},
+		{"", slog.LevelWarn},
+	}
+	for _, tt := range tests {
+		if got := levels.Level(tt.layer); got != tt.want {
+			t.Errorf("Level(%q) = %s, want %s", tt.layer, got, tt.want)
+		}
+	}
+}
+
+func TestLevelsReset(t *testing.T) {
+	levels := logger.NewLevels(slog.LevelInfo, map[string]slog.Level{"storage": slog.LevelWarn})
+	levels.Set("storage", slog.LevelDebug, 0)
+	levels.Set("server", slog.LevelDebug, 0)
+
+	levels.Reset("storage")
+	levels.Reset("server")
+
+	if got := levels.Level("storage"); got != slog.LevelWarn {
+		t.Errorf("storage = %s, want its startup level WARN", got)
+	}
+	if got := levels.Level("server"); got != slog.LevelInfo {
+		t.Errorf("server = %s, want the default INFO", got)
+	}
+	if _, ok := levels.Snapshot()["server"]; ok {
+		t.Error("layer without a startup level still in the snapshot after Reset")
+	}
+}
+
+func TestLevelsExpiry(t *testing.T) {
+	levels := logger.NewLevels(slog.LevelInfo, nil)
+
+	levels.Set("storage", slog.LevelDebug, 10*time.Millisecond)
+	if got := levels.Level("storage"); got != slog.LevelDebug {
+		t.Fatalf("storage = %s, want DEBUG before expiry", got)
+	}
+	waitLevel(t, levels, "storage", slog.LevelInfo)
+}
+
+func TestLevelsExpiryReplaced(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace func(levels *logger.Levels)
+		want    slog.Level
+	}{
+		{"set without revert", func(l *logger.Levels) { l.Set("storage", slog.LevelWarn, 0) }, slog.LevelWarn},
+		{"reset then set", func(l *logger.Levels) {
+			l.Reset("storage")
+			l.Set("storage", slog.LevelError, 0)
+		}, slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			levels := logger.NewLevels(slog.LevelInfo, nil)
+			levels.Set("storage", slog.LevelDebug, 10*time.Millisecond)
+			tt.replace(levels)
+
+			// Long enough for the first timer to have fired, had it not been replaced
+			time.Sleep(50 * time.Millisecond)
+			if got := levels.Level("storage"); got != tt.want {
+				t.Errorf("storage = %s, want %s: the replaced timer reverted it", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLevelsExpiryExtended(t *testing.T) {
+	levels := logger.NewLevels(slog.LevelInfo, nil)
+	levels.Set("storage", slog.LevelDebug, 10*time.Millisecond)
+	levels.Set("storage", slog.LevelDebug, time.Hour)
+
+	time.Sleep(50 * time.Millisecond)
+	if got := levels.Level("storage"); got != slog.LevelDebug {
+		t.Errorf("storage = %s, want DEBUG until the later revert_after", got)
+	}
+}
+
+func waitLevel(t *testing.T, levels *logger.Levels, layer string, want slog.Level) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for levels.Level(layer) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s = %s, want %s after revert_after", layer, levels.Level(layer), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// Export selects where log records go besides stdout
+type Export string
+
+const (
+	// ExportSpanEvents copies log records into events of the active span
+	ExportSpanEvents Export = "span_events"
+	// ExportOTLP emits log records through the OTel logs pipeline
+	ExportOTLP Export = "otlp"
+	// ExportBoth does both of the above
+	ExportBoth Export = "both"
+)
+
+// ParseExport validates an export mode read from config
+func ParseExport(s string) (Export, error) {
+	switch e := Export(s); e {
+	case ExportSpanEvents, ExportOTLP, ExportBoth:
+		return e, nil
+	default:
+		return "", fmt.Errorf("unknown log export %q, expected one of %s, %s, %s",
+			s, ExportSpanEvents, ExportOTLP, ExportBoth)
+	}
+}
+
+// SpanEvents reports whether log records are copied into span events
+func (e Export) SpanEvents() bool {
+	return e == ExportSpanEvents || e == ExportBoth
+}
+
+// OTLP reports whether log records are emitted as OTel log records
+func (e Export) OTLP() bool {
+	return e == ExportOTLP || e == ExportBoth
+}
+
+// NewHandler builds the handler chain for the given export mode.
+// Records always reach next with trace_id and span_id attached;
+// provider is only used when the mode includes OTLP.
+func NewHandler(next slog.Handler, export Export, provider otellog.LoggerProvider, name string, level slog.Leveler) slog.Handler {
+	var opts []TraceOption
+	if !export.SpanEvents() {
+		opts = append(opts, WithoutSpanEvents())
+	}
+	handler := slog.Handler(NewTraceHandler(next, opts...))
+
+	if export.OTLP() {
+		handler = NewMultiHandler(handler, NewOTelHandler(provider, name, level))
+	}
+	return handler
+}
+
+// MultiHandler fans every record out to all of its handlers
+type MultiHandler struct {
+	handlers []slog.Handler
+}
+
+// NewMultiHandler creates a handler that writes to every handler in order
+func NewMultiHandler(handlers ...slog.Handler) *MultiHandler {
+	return &MultiHandler{handlers: handlers}
+}
+
+func (h *MultiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *MultiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := handler.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *MultiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &MultiHandler{handlers: handlers}
+}
+
+func (h *MultiHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &MultiHandler{handlers: handlers}
+}
@@ -0,0 +1,172 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"math"
+
+	otellog "go.opentelemetry.io/otel/log"
+)
+
+// OTelHandler emits slog records as OpenTelemetry log records.
+// Trace and span IDs are taken from the record context by the SDK.
+type OTelHandler struct {
+	logger otellog.Logger
+	level  slog.Leveler
+	attrs  []otellog.KeyValue
+	groups []otelGroup
+}
+
+type otelGroup struct {
+	name  string
+	attrs []otellog.KeyValue
+}
+
+// NewOTelHandler creates a handler that emits records through the given logger provider
+func NewOTelHandler(provider otellog.LoggerProvider, name string, level slog.Leveler) *OTelHandler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+	return &OTelHandler{
+		logger: provider.Logger(name),
+		level:  level,
+	}
+}
+
+func (h *OTelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level < h.level.Level() {
+		return false
+	}
+	return h.logger.Enabled(ctx, otellog.EnabledParameters{Severity: severity(level)})
+}
+
+func (h *OTelHandler) Handle(ctx context.Context, r slog.Record) error {
+	var record otellog.Record
+	record.SetTimestamp(r.Time)
+	record.SetBody(otellog.StringValue(r.Message))
+	record.SetSeverity(severity(r.Level))
+	record.SetSeverityText(r.Level.String())
+
+	kvs := make([]otellog.KeyValue, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		kvs = appendLogAttr(kvs, a)
+		return true
+	})
+
+	// Wrap the record attributes into the open groups, innermost first.
+	// Empty groups are dropped, the same way slog's built-in handlers do.
+	for i := len(h.groups) - 1; i >= 0; i-- {
+		g := h.groups[i]
+		kvs = append(append([]otellog.KeyValue(nil), g.attrs...), kvs...)
+		if len(kvs) == 0 {
+			continue
+		}
+		kvs = []otellog.KeyValue{otellog.Map(g.name, kvs...)}
+	}
+
+	record.AddAttributes(h.attrs...)
+	record.AddAttributes(kvs...)
+
+	h.logger.Emit(ctx, record)
+	return nil
+}
+
+func (h *OTelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := h.clone()
+	var kvs []otellog.KeyValue
+	for _, a := range attrs {
+		kvs = appendLogAttr(kvs, a)
+	}
+	if n := len(clone.groups); n > 0 {
+		clone.groups[n-1].attrs = append(clone.groups[n-1].attrs, kvs...)
+	} else {
+		clone.attrs = append(clone.attrs, kvs...)
+	}
+	return clone
+}
+
+func (h *OTelHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := h.clone()
+	clone.groups = append(clone.groups, otelGroup{name: name})
+	return clone
+}
+
+func (h *OTelHandler) clone() *OTelHandler {
+	groups := make([]otelGroup, len(h.groups))
+	for i, g := range h.groups {
+		groups[i] = otelGroup{
+			name:  g.name,
+			attrs: append([]otellog.KeyValue(nil), g.attrs...),
+		}
+	}
+	return &OTelHandler{
+		logger: h.logger,
+		level:  h.level,
+		attrs:  append([]otellog.KeyValue(nil), h.attrs...),
+		groups: groups,
+	}
+}
+
+// severity maps slog levels onto the OTel severity range:
+// Debug -> DEBUG, Info -> INFO, Warn -> WARN, Error -> ERROR.
+func severity(level slog.Level) otellog.Severity {
+	return otellog.Severity(level + 9)
+}
+
+func appendLogAttr(kvs []otellog.KeyValue, a slog.Attr) []otellog.KeyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		var group []otellog.KeyValue
+		for _, ga := range a.Value.Group() {
+			group = appendLogAttr(group, ga)
+		}
+		if len(group) == 0 {
+			return kvs
+		}
+		// Inline groups (empty key) are spliced into the parent.
+		if a.Key == "" {
+			return append(kvs, group...)
+		}
+		return append(kvs, otellog.Map(a.Key, group...))
+	}
+
+	return append(kvs, otellog.KeyValue{Key: a.Key, Value: logValue(a.Value)})
+}
+
+func logValue(v slog.Value) otellog.Value {
+	switch v.Kind() {
+	case slog.KindString:
+		return otellog.StringValue(v.String())
+	case slog.KindInt64:
+		return otellog.Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return otellog.Int64Value(int64(u))
+		}
+		return otellog.StringValue(v.String())
+	case slog.KindFloat64:
+		return otellog.Float64Value(v.Float64())
+	case slog.KindBool:
+		return otellog.BoolValue(v.Bool())
+	case slog.KindDuration:
+		return otellog.Int64Value(v.Duration().Nanoseconds())
+	case slog.KindTime:
+		return otellog.Int64Value(v.Time().UnixNano())
+	}
+
+	switch val := v.Any().(type) {
+	case []byte:
+		return otellog.BytesValue(val)
+	case error:
+		return otellog.StringValue(val.Error())
+	default:
+		return otellog.StringValue(v.String())
+	}
+}
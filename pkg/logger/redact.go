@@ -0,0 +1,262 @@
+package logger
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// RedactMode selects how personal data is hidden
+type RedactMode string
+
+const (
+	// RedactMask keeps a short suffix of the value and masks the rest
+	RedactMask RedactMode = "mask"
+	// RedactHash replaces the value with a keyed hash so equal values still correlate
+	RedactHash RedactMode = "hash"
+	// RedactOff disables redaction, for local development only
+	RedactOff RedactMode = "off"
+)
+
+// ParseRedactMode validates a redaction mode read from config
+func ParseRedactMode(s string) (RedactMode, error) {
+	switch m := RedactMode(s); m {
+	case RedactMask, RedactHash, RedactOff:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown redact mode %q, expected one of %s, %s, %s",
+			s, RedactMask, RedactHash, RedactOff)
+	}
+}
+
+// Sensitive is the kind of personal data a value holds
+type Sensitive int
+
+const (
+	SensitiveNone Sensitive = iota
+	SensitiveIDN
+	SensitivePassword
+	SensitiveDSN
+	SensitiveEmail
+	SensitivePhone
+)
+
+// DefaultRedactKeys maps well-known attribute keys to the data they carry
+var DefaultRedactKeys = map[string]Sensitive{
+	"idn":          SensitiveIDN,
+	"customer_idn": SensitiveIDN,
+	"iin":          SensitiveIDN,
+	"bin":          SensitiveIDN,
+	"password":     SensitivePassword,
+	"secret":       SensitivePassword,
+	"token":        SensitivePassword,
+	"dsn":          SensitiveDSN,
+	"email":        SensitiveEmail,
+	"phone":        SensitivePhone,
+}
+
+var (
+	idnPattern      = regexp.MustCompile(`\b\d{12}\b`)
+	emailPattern    = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	phonePattern    = regexp.MustCompile(`^\+?[78][\s\-(]*\d{3}[\s\-)]*\d{3}[\s\-]*\d{2}[\s\-]*\d{2}$`)
+	dsnPassword     = regexp.MustCompile(`(password=)(\S+)`)
+	urlPassword     = regexp.MustCompile(`(://[^:/@\s]+:)([^@\s]+)(@)`)
+	redactedLiteral = "[REDACTED]"
+)
+
+// Redactor hides personal data in log and span attributes.
+// Values are matched by attribute key first and then by their shape,
+// so an IDN logged under an unexpected key or inside an error message is
+// still caught.
+type Redactor struct {
+	mode RedactMode
+	keys map[string]Sensitive
+	salt []byte
+}
+
+// NewRedactor creates a redactor using DefaultRedactKeys
+func NewRedactor(mode RedactMode, salt string) *Redactor {
+	keys := make(map[string]Sensitive, len(DefaultRedactKeys))
+	for k, v := range DefaultRedactKeys {
+		keys[k] = v
+	}
+	return &Redactor{
+		mode: mode,
+		keys: keys,
+		salt: []byte(salt),
+	}
+}
+
+// Classify reports what kind of personal data a keyed string value holds.
+// Emails and phones must make up the whole value, IDNs and DSN passwords
+// may be part of it.
+func (r *Redactor) Classify(key, value string) Sensitive {
+	if s, ok := r.keys[strings.ToLower(key)]; ok {
+		return s
+	}
+	switch {
+	case emailPattern.MatchString(value):
+		return SensitiveEmail
+	case phonePattern.MatchString(value):
+		return SensitivePhone
+	case idnPattern.MatchString(value):
+		return SensitiveIDN
+	case dsnPassword.MatchString(value), urlPassword.MatchString(value):
+		return SensitiveDSN
+	default:
+		return SensitiveNone
+	}
+}
+
+// String redacts a keyed string value
+func (r *Redactor) String(key, value string) string {
+	if r.mode == RedactOff || value == "" {
+		return value
+	}
+	if s, ok := r.keys[strings.ToLower(key)]; ok {
+		return r.redact(s, value)
+	}
+
+	switch s := r.Classify(key, value); s {
+	case SensitiveEmail, SensitivePhone:
+		return r.redact(s, value)
+	case SensitiveIDN, SensitiveDSN:
+		// Hide every match, e.g. both IDNs of an error message, and keep the rest
+		value = idnPattern.ReplaceAllStringFunc(value, func(idn string) string {
+			return r.hide(idn, 4)
+		})
+		return r.redact(SensitiveDSN, value)
+	default:
+		return value
+	}
+}
+
+// redact hides a whole value known to hold data of kind s
+func (r *Redactor) redact(s Sensitive, value string) string {
+	switch s {
+	case SensitiveIDN, SensitivePhone:
+		return r.hide(value, 4)
+	case SensitiveEmail:
+		if r.mode == RedactHash {
+			return r.hash(value)
+		}
+		local, domain, _ := strings.Cut(value, "@")
+		return mask(local, 1, true) + "@" + domain
+	case SensitivePassword:
+		return redactedLiteral
+	case SensitiveDSN:
+		value = dsnPassword.ReplaceAllString(value, "${1}"+redactedLiteral)
+		return urlPassword.ReplaceAllString(value, "${1}"+redactedLiteral+"${3}")
+	default:
+		return value
+	}
+}
+
+// Attr redacts an slog attribute, descending into groups
+func (r *Redactor) Attr(a slog.Attr) slog.Attr {
+	if r.mode == RedactOff {
+		return a
+	}
+
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(a.Key, a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = r.Attr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	case slog.KindAny:
+		if _, ok := r.keys[strings.ToLower(a.Key)]; ok {
+			return slog.String(a.Key, r.String(a.Key, a.Value.String()))
+		}
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, r.String(a.Key, v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, r.String(a.Key, v.String()))
+		}
+		// Structs, maps and slices keep their form unless their text holds
+		// personal data, e.g. the IDN of a logged request
+		text := fmt.Sprintf("%+v", a.Value.Any())
+		if redacted := r.String(a.Key, text); redacted != text {
+			return slog.String(a.Key, redacted)
+		}
+		return a
+	default:
+		if _, ok := r.keys[strings.ToLower(a.Key)]; ok {
+			return slog.String(a.Key, r.String(a.Key, a.Value.String()))
+		}
+		return a
+	}
+}
+
+func (r *Redactor) hide(value string, keep int) string {
+	if r.mode == RedactHash {
+		return r.hash(value)
+	}
+	return mask(value, keep, false)
+}
+
+func (r *Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.salt)
+	mac.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// mask replaces all but keep runes with '*', keeping either the head or the tail
+func mask(value string, keep int, head bool) string {
+	runes := []rune(value)
+	if len(runes) <= keep {
+		return strings.Repeat("*", len(runes))
+	}
+	if head {
+		return string(runes[:keep]) + strings.Repeat("*", len(runes)-keep)
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+// RedactHandler wraps an slog.Handler and redacts personal data before it
+// reaches stdout, span events or OTLP logs
+type RedactHandler struct {
+	handler  slog.Handler
+	redactor *Redactor
+}
+
+// NewRedactHandler creates a new handler that redacts every attribute with r
+func NewRedactHandler(h slog.Handler, r *Redactor) *RedactHandler {
+	return &RedactHandler{handler: h, redactor: r}
+}
+
+func (h *RedactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *RedactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.Attr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h *RedactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redactor.Attr(a)
+	}
+	return &RedactHandler{handler: h.handler.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *RedactHandler) WithGroup(name string) slog.Handler {
+	return &RedactHandler{handler: h.handler.WithGroup(name), redactor: h.redactor}
+}
@@ -0,0 +1,177 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/aidosgal/transline-test/pkg/logger"
+)
+
+func TestRedactorString(t *testing.T) {
+	mask := logger.NewRedactor(logger.RedactMask, "")
+	hash := logger.NewRedactor(logger.RedactHash, "salt")
+	off := logger.NewRedactor(logger.RedactOff, "")
+
+	tests := []struct {
+		name     string
+		redactor *logger.Redactor
+		key      string
+		value    string
+		want     string
+	}{
+		{"idn key", mask, "idn", "990101300123", "********0123"},
+		{"key case", mask, "Customer_IDN", "990101300123", "********0123"},
+		{"password key", mask, "password", "hunter2", "[REDACTED]"},
+		{"token key", mask, "token", "abc", "[REDACTED]"},
+		{"email key", mask, "email", "aidos@example.kz", "a****@example.kz"},
+		{"idn value", mask, "value", "990101300123", "********0123"},
+		{"idns in a message", mask, "error", "customer 990101300123 conflicts with 870412450018",
+			"customer ********0123 conflicts with ********0018"},
+		{"idn in a path", mask, "url", "/customers/990101300123/shipments", "/customers/********0123/shipments"},
+		{"longer number kept", mask, "order", "1234567890123", "1234567890123"},
+		{"shorter number kept", mask, "count", "12345", "12345"},
+		{"email value", mask, "to", "aidos@example.kz", "a****@example.kz"},
+		{"phone value", mask, "contact", "+7 701 123 45 67", "************5 67"},
+		{"dsn password", mask, "conn", "host=db user=app password=secret dbname=app",
+			"host=db user=app password=[REDACTED] dbname=app"},
+		{"url password", mask, "conn", "postgres://app:secret@db:5432/app", "postgres://app:[REDACTED]@db:5432/app"},
+		{"dsn with an idn", mask, "conn", "password=secret application_name=990101300123",
+			"password=[REDACTED] application_name=********0123"},
+		{"plain value", mask, "route", "ALMATY→ASTANA", "ALMATY→ASTANA"},
+		{"hash idn", hash, "idn", "990101300123", hashOf(hash, "990101300123")},
+		{"hash idn in a message", hash, "error", "customer 990101300123 not found",
+			"customer " + hashOf(hash, "990101300123") + " not found"},
+		{"off", off, "idn", "990101300123", "990101300123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.redactor.String(tt.key, tt.value); got != tt.want {
+				t.Errorf("String(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+// hashOf is the hash redaction of an IDN, which the hash mode applies
+// the same way under any key
+func hashOf(r *logger.Redactor, idn string) string {
+	return r.String("idn", idn)
+}
+
+func TestRedactorHash(t *testing.T) {
+	a := logger.NewRedactor(logger.RedactHash, "a")
+	b := logger.NewRedactor(logger.RedactHash, "b")
+
+	got := a.String("idn", "990101300123")
+	if !strings.HasPrefix(got, "sha256:") || strings.Contains(got, "0123") {
+		t.Errorf("hash = %q, want a sha256: prefix and no part of the IDN", got)
+	}
+	if got != a.String("iin", "990101300123") {
+		t.Error("equal values hash differently, so they do not correlate")
+	}
+	if got == b.String("idn", "990101300123") {
+		t.Error("hash does not depend on the salt")
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(log *slog.Logger)
+		// want maps a dotted path of the JSON record to its value
+		want map[string]string
+	}{
+		{
+			name: "attrs by key and value",
+			log: func(log *slog.Logger) {
+				log.Info("msg", "idn", "990101300123", "note", "from 870412450018", "route", "ALMATY→ASTANA")
+			},
+			want: map[string]string{"idn": "********0123", "note": "from ********0018", "route": "ALMATY→ASTANA"},
+		},
+		{
+			name: "non-string values of sensitive keys",
+			log: func(log *slog.Logger) {
+				log.Info("msg", slog.Int("token", 12345), slog.Any("error", errors.New("bad idn 990101300123")))
+			},
+			want: map[string]string{"token": "[REDACTED]", "error": "bad idn ********0123"},
+		},
+		{
+			name: "any values",
+			log: func(log *slog.Logger) {
+				type request struct {
+					IDN  string
+					City string
+				}
+				type stop struct{ City string }
+				log.Info("msg",
+					slog.Any("req", &request{IDN: "990101300123", City: "Almaty"}),
+					slog.Any("idns", map[string]string{"sender": "870412450018"}),
+					slog.Any("customer", stringer("customer 990101300123")),
+					slog.Any("stop", stop{City: "Almaty"}))
+			},
+			want: map[string]string{
+				"req":       "&{IDN:********0123 City:Almaty}",
+				"idns":      "map[sender:********0018]",
+				"customer":  "customer ********0123",
+				"stop.City": "Almaty",
+			},
+		},
+		{
+			name: "nested groups",
+			log: func(log *slog.Logger) {
+				log.Info("msg", slog.Group("customer",
+					slog.String("idn", "990101300123"),
+					slog.Group("contact", slog.String("email", "aidos@example.kz"), slog.String("city", "Almaty"))))
+			},
+			want: map[string]string{
+				"customer.idn":           "********0123",
+				"customer.contact.email": "a****@example.kz",
+				"customer.contact.city":  "Almaty",
+			},
+		},
+		{
+			name: "With and WithGroup",
+			log: func(log *slog.Logger) {
+				log.With("password", "hunter2").WithGroup("req").Info("msg", "phone", "87011234567")
+			},
+			want: map[string]string{"password": "[REDACTED]", "req.phone": "*******4567"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			h := logger.NewRedactHandler(slog.NewJSONHandler(&buf, nil), logger.NewRedactor(logger.RedactMask, ""))
+			tt.log(slog.New(h))
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("unmarshal %q: %v", buf.String(), err)
+			}
+			for path, want := range tt.want {
+				if got := lookup(record, path); got != want {
+					t.Errorf("%s = %v, want %q", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func lookup(record map[string]any, path string) any {
+	var v any = record
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+type stringer string
+
+func (s stringer) String() string { return string(s) }
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/fs"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+)
+
+// Migrator applies a service's embedded migrations. Every command runs under
+// a session-level advisory lock, so replicas starting together apply them once.
+type Migrator struct {
+	log     *slog.Logger
+	db      *sql.DB
+	lockKey int64
+	m       *migrate.Migrate
+}
+
+// NewMigrator reads the migrations from dir in fsys and applies them to the
+// database at url. name identifies the service in the advisory lock key.
+func NewMigrator(log *slog.Logger, db *sql.DB, url string, fsys fs.FS, dir string, name string) (*Migrator, error) {
+	src, err := iofs.New(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open embedded migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithSourceInstance("iofs", src, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init migrations: %w", err)
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte("migrate:" + name))
+
+	return &Migrator{
+		log:     log.With("layer", "migrate"),
+		db:      db,
+		lockKey: int64(h.Sum64()),
+		m:       m,
+	}, nil
+}
+
+// WithMigrationsTable returns the migration url with the version kept in table
+// instead of schema_migrations, so several services can share one database
+func WithMigrationsTable(url, table string) string {
+	sep := "?"
+	if strings.Contains(url, "?") {
+		sep = "&"
+	}
+	return url + sep + "x-migrations-table=" + table
+}
+
+// Up applies every pending migration
+func (mg *Migrator) Up(ctx context.Context) error {
+	return mg.withLock(ctx, func() error {
+		return mg.m.Up()
+	})
+}
+
+// Down rolls back the last steps migrations
+func (mg *Migrator) Down(ctx context.Context, steps int) error {
+	return mg.withLock(ctx, func() error {
+		return mg.m.Steps(-steps)
+	})
+}
+
+// Goto migrates up or down to version
+func (mg *Migrator) Goto(ctx context.Context, version uint) error {
+	return mg.withLock(ctx, func() error {
+		return mg.m.Migrate(version)
+	})
+}
+
+// Force sets the version without running migrations, clearing the dirty flag.
+// -1 means no version.
+func (mg *Migrator) Force(ctx context.Context, version int) error {
+	return mg.withLock(ctx, func() error {
+		return mg.m.Force(version)
+	})
+}
+
+// Version returns the current version, 0 if no migration was applied
+func (mg *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read migration version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr)
+}
+
+// Run executes a migrate subcommand:
+//
+//	up | down [N] | goto V | version | force V
+func (mg *Migrator) Run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: migrate up | down [N] | goto V | version | force V")
+	}
+
+	switch cmd := args[0]; cmd {
+	case "up":
+		if err := mg.Up(ctx); err != nil {
+			return err
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		if err := mg.Down(ctx, steps); err != nil {
+			return err
+		}
+	case "goto":
+		if len(args) < 2 {
+			return errors.New("usage: migrate goto V")
+		}
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := mg.Goto(ctx, uint(version)); err != nil {
+			return err
+		}
+	case "force":
+		if len(args) < 2 {
+			return errors.New("usage: migrate force V")
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		if err := mg.Force(ctx, version); err != nil {
+			return err
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+
+	version, dirty, err := mg.Version()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "version %d, dirty %t\n", version, dirty)
+	return nil
+}
+
+// withLock holds the advisory lock on a dedicated connection while fn runs.
+// ErrNoChange is not an error for the caller.
+func (mg *Migrator) withLock(ctx context.Context, fn func() error) error {
+	conn, err := mg.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection for migration lock: %w", err)
+	}
+	defer conn.Close()
+
+	// Another replica may hold the lock for as long as its migrations run, so
+	// the pool's statement and lock timeouts must not apply while waiting
+	if _, err := conn.ExecContext(ctx, `SET statement_timeout = 0; SET lock_timeout = 0`); err != nil {
+		return fmt.Errorf("failed to disable timeouts for migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `RESET statement_timeout; RESET lock_timeout`); err != nil {
+			mg.log.ErrorContext(ctx, "failed to restore timeouts", slog.String("error", err.Error()))
+		}
+	}()
+
+	mg.log.DebugContext(ctx, "waiting for migration lock", slog.Int64("lock_key", mg.lockKey))
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, mg.lockKey); err != nil {
+		return fmt.Errorf("failed to take migration lock: %w", err)
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, mg.lockKey); err != nil {
+			mg.log.ErrorContext(ctx, "failed to release migration lock", slog.String("error", err.Error()))
+		}
+	}()
+
+	if err := fn(); err != nil {
+		if errors.Is(err, migrate.ErrNoChange) {
+			mg.log.InfoContext(ctx, "no migrations to apply")
+			return nil
+		}
+		return fmt.Errorf("failed to migrate: %w", err)
+	}
+	return nil
+}
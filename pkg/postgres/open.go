@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+)
+
+const (
+	connectInitialBackoff = 500 * time.Millisecond
+	connectMaxBackoff     = 5 * time.Second
+)
+
+// Open opens a connection pool tuned by cfg and pings it, retrying with
+// exponential backoff for up to cfg.ConnectTimeout, so a service that starts
+// before its database waits for it instead of exiting
+func Open(ctx context.Context, log *slog.Logger, cfg config.PostgresConfig) (*sql.DB, error) {
+	log = log.With("layer", "postgres", "host", cfg.Host)
+
+	dsn := cfg.BuildPostgresURL()
+	log.Info("connecting to database", slog.String("dsn", dsn))
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open db: %w", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := ping(ctx, log, db, cfg.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
+	}
+	log.Info("connected to database")
+
+	return db, nil
+}
+
+func ping(ctx context.Context, log *slog.Logger, db *sql.DB, timeout time.Duration) error {
+	if timeout <= 0 {
+		if err := db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to connect to db: %w", err)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	backoff := connectInitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Warn("database not ready, retrying",
+			slog.Int("attempt", attempt),
+			slog.String("backoff", backoff.String()),
+			slog.String("error", err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to connect to db after %d attempts: %w", attempt, err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, connectMaxBackoff)
+	}
+}
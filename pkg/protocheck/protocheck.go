@@ -0,0 +1,243 @@
+// Package protocheck reports changes between two versions of .proto files
+// that break existing clients on the wire, in JSON or in generated code
+package protocheck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Violation is one breaking change
+type Violation struct {
+	File    string
+	Rule    string
+	Message string
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s (%s)", v.File, v.Message, v.Rule)
+}
+
+// Compile parses every .proto file in fsys; imports resolve relative to its root
+func Compile(ctx context.Context, fsys fs.FS) ([]protoreflect.FileDescriptor, error) {
+	var paths []string
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && path.Ext(p) == ".proto" {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list proto files: %w", err)
+	}
+
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			Accessor: func(p string) (io.ReadCloser, error) {
+				return fsys.Open(p)
+			},
+		}),
+	}
+	files, err := compiler.Compile(ctx, paths...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile proto files: %w", err)
+	}
+
+	descriptors := make([]protoreflect.FileDescriptor, len(files))
+	for i, f := range files {
+		descriptors[i] = f
+	}
+	return descriptors, nil
+}
+
+// Compare returns the breaking changes from previous to current
+func Compare(previous, current []protoreflect.FileDescriptor) []Violation {
+	c := &checker{}
+
+	byPath := make(map[string]protoreflect.FileDescriptor, len(current))
+	for _, f := range current {
+		byPath[f.Path()] = f
+	}
+
+	for _, prev := range previous {
+		c.file = prev.Path()
+		cur, ok := byPath[prev.Path()]
+		if !ok {
+			c.report("FILE_NO_DELETE", "file was deleted")
+			continue
+		}
+		if prev.Package() != cur.Package() {
+			c.report("FILE_SAME_PACKAGE", "package changed from %q to %q", prev.Package(), cur.Package())
+		}
+		if prevGo, curGo := goPackage(prev), goPackage(cur); prevGo != curGo {
+			c.report("FILE_SAME_GO_PACKAGE", "go_package changed from %q to %q", prevGo, curGo)
+		}
+		c.services(prev.Services(), cur.Services())
+		c.messages(prev.Messages(), cur.Messages())
+		c.enums(prev.Enums(), cur.Enums())
+	}
+
+	return c.violations
+}
+
+type checker struct {
+	file       string
+	violations []Violation
+}
+
+func (c *checker) report(rule, format string, args ...any) {
+	c.violations = append(c.violations, Violation{File: c.file, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) services(prev, cur protoreflect.ServiceDescriptors) {
+	for i := range prev.Len() {
+		ps := prev.Get(i)
+		cs := cur.ByName(ps.Name())
+		if cs == nil {
+			c.report("SERVICE_NO_DELETE", "service %s was deleted", ps.FullName())
+			continue
+		}
+		for j := range ps.Methods().Len() {
+			pm := ps.Methods().Get(j)
+			cm := cs.Methods().ByName(pm.Name())
+			if cm == nil {
+				c.report("RPC_NO_DELETE", "rpc %s was deleted", pm.FullName())
+				continue
+			}
+			if pm.Input().FullName() != cm.Input().FullName() {
+				c.report("RPC_SAME_REQUEST_TYPE", "rpc %s request changed from %s to %s",
+					pm.FullName(), pm.Input().FullName(), cm.Input().FullName())
+			}
+			if pm.Output().FullName() != cm.Output().FullName() {
+				c.report("RPC_SAME_RESPONSE_TYPE", "rpc %s response changed from %s to %s",
+					pm.FullName(), pm.Output().FullName(), cm.Output().FullName())
+			}
+			if pm.IsStreamingClient() != cm.IsStreamingClient() {
+				c.report("RPC_SAME_CLIENT_STREAMING", "rpc %s client streaming changed", pm.FullName())
+			}
+			if pm.IsStreamingServer() != cm.IsStreamingServer() {
+				c.report("RPC_SAME_SERVER_STREAMING", "rpc %s server streaming changed", pm.FullName())
+			}
+		}
+	}
+}
+
+func (c *checker) messages(prev, cur protoreflect.MessageDescriptors) {
+	for i := range prev.Len() {
+		pm := prev.Get(i)
+		cm := cur.ByName(pm.Name())
+		if cm == nil {
+			c.report("MESSAGE_NO_DELETE", "message %s was deleted", pm.FullName())
+			continue
+		}
+		c.fields(pm, cm)
+		c.messages(pm.Messages(), cm.Messages())
+		c.enums(pm.Enums(), cm.Enums())
+	}
+}
+
+func (c *checker) fields(prev, cur protoreflect.MessageDescriptor) {
+	for i := range prev.Fields().Len() {
+		pf := prev.Fields().Get(i)
+		cf := cur.Fields().ByNumber(pf.Number())
+		if cf == nil {
+			if !cur.ReservedRanges().Has(pf.Number()) || !cur.ReservedNames().Has(pf.Name()) {
+				c.report("FIELD_NO_DELETE_UNLESS_RESERVED",
+					"field %d %q of %s was deleted without reserving its number and name",
+					pf.Number(), pf.Name(), prev.FullName())
+			}
+			continue
+		}
+		if pf.Name() != cf.Name() {
+			c.report("FIELD_SAME_NAME", "field %d of %s was renamed from %q to %q",
+				pf.Number(), prev.FullName(), pf.Name(), cf.Name())
+		}
+		if typeName(pf) != typeName(cf) {
+			c.report("FIELD_SAME_TYPE", "field %q of %s changed type from %s to %s",
+				pf.Name(), prev.FullName(), typeName(pf), typeName(cf))
+		}
+		if cardinality(pf) != cardinality(cf) {
+			c.report("FIELD_SAME_CARDINALITY", "field %q of %s changed from %s to %s",
+				pf.Name(), prev.FullName(), cardinality(pf), cardinality(cf))
+		}
+		if oneofName(pf) != oneofName(cf) {
+			c.report("FIELD_SAME_ONEOF", "field %q of %s moved from oneof %q to %q",
+				pf.Name(), prev.FullName(), oneofName(pf), oneofName(cf))
+		}
+	}
+}
+
+func (c *checker) enums(prev, cur protoreflect.EnumDescriptors) {
+	for i := range prev.Len() {
+		pe := prev.Get(i)
+		ce := cur.ByName(pe.Name())
+		if ce == nil {
+			c.report("ENUM_NO_DELETE", "enum %s was deleted", pe.FullName())
+			continue
+		}
+		for j := range pe.Values().Len() {
+			pv := pe.Values().Get(j)
+			cv := ce.Values().ByNumber(pv.Number())
+			if cv == nil {
+				if !ce.ReservedRanges().Has(pv.Number()) || !ce.ReservedNames().Has(pv.Name()) {
+					c.report("ENUM_VALUE_NO_DELETE_UNLESS_RESERVED",
+						"value %d %q of %s was deleted without reserving its number and name",
+						pv.Number(), pv.Name(), pe.FullName())
+				}
+				continue
+			}
+			if pv.Name() != cv.Name() {
+				c.report("ENUM_VALUE_SAME_NAME", "value %d of %s was renamed from %q to %q",
+					pv.Number(), pe.FullName(), pv.Name(), cv.Name())
+			}
+		}
+	}
+}
+
+func goPackage(f protoreflect.FileDescriptor) string {
+	opts, _ := f.Options().(*descriptorpb.FileOptions)
+	return opts.GetGoPackage()
+}
+
+func typeName(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return fmt.Sprintf("map<%s, %s>", typeName(f.MapKey()), typeName(f.MapValue()))
+	case f.Message() != nil:
+		return string(f.Message().FullName())
+	case f.Enum() != nil:
+		return string(f.Enum().FullName())
+	default:
+		return f.Kind().String()
+	}
+}
+
+func cardinality(f protoreflect.FieldDescriptor) string {
+	switch {
+	case f.IsMap():
+		return "map"
+	case f.IsList():
+		return "repeated"
+	case f.HasPresence() && f.Message() == nil && oneofName(f) == "":
+		return "optional"
+	default:
+		return "singular"
+	}
+}
+
+func oneofName(f protoreflect.FieldDescriptor) string {
+	if o := f.ContainingOneof(); o != nil && !o.IsSynthetic() {
+		return string(o.Name())
+	}
+	return ""
+}
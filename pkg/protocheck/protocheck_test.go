@@ -0,0 +1,112 @@
+package protocheck_test
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/aidosgal/transline-test/pkg/protocheck"
+)
+
+const base = `syntax = "proto3";
+package test;
+option go_package = "specs/proto/test";
+
+service Test {
+  rpc Get (GetRequest) returns (Item);
+}
+
+message GetRequest {
+  string id = 1;
+}
+
+message Item {
+  string id = 1;
+  string name = 2;
+  Kind kind = 3;
+}
+
+enum Kind {
+  KIND_UNSPECIFIED = 0;
+  KIND_PARCEL = 1;
+}
+`
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name    string
+		current string
+		rules   []string
+	}{
+		{"unchanged", base, nil},
+		{"added field", replace(base, "Kind kind = 3;", "Kind kind = 3;\n  int64 weight = 4;"), nil},
+		{"deleted reserved field", replace(base, "string name = 2;", "reserved 2;\n  reserved \"name\";"), nil},
+		{"deleted field", replace(base, "string name = 2;", ""), []string{"FIELD_NO_DELETE_UNLESS_RESERVED"}},
+		{"renamed field", replace(base, "string name = 2;", "string title = 2;"), []string{"FIELD_SAME_NAME"}},
+		{"changed type", replace(base, "string name = 2;", "bytes name = 2;"), []string{"FIELD_SAME_TYPE"}},
+		{"made optional", replace(base, "string name = 2;", "optional string name = 2;"), []string{"FIELD_SAME_CARDINALITY"}},
+		{"made repeated", replace(base, "string name = 2;", "repeated string name = 2;"), []string{"FIELD_SAME_CARDINALITY"}},
+		{"deleted rpc", replace(base, "rpc Get (GetRequest) returns (Item);", ""), []string{"RPC_NO_DELETE"}},
+		{"changed response", replace(base, "returns (Item)", "returns (GetRequest)"), []string{"RPC_SAME_RESPONSE_TYPE"}},
+		{"deleted enum value", replace(base, "KIND_PARCEL = 1;", ""), []string{"ENUM_VALUE_NO_DELETE_UNLESS_RESERVED"}},
+		{"changed go_package", replace(base, "specs/proto/test", "specs/proto/test/v2"), []string{"FILE_SAME_GO_PACKAGE"}},
+	}
+
+	ctx := context.Background()
+	previous, err := protocheck.Compile(ctx, fstest.MapFS{"test/test.proto": {Data: []byte(base)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, err := protocheck.Compile(ctx, fstest.MapFS{"test/test.proto": {Data: []byte(tt.current)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			violations := protocheck.Compare(previous, current)
+			var rules []string
+			for _, v := range violations {
+				rules = append(rules, v.Rule)
+			}
+			if !equal(rules, tt.rules) {
+				t.Errorf("violations = %v, want rules %v", violations, tt.rules)
+			}
+		})
+	}
+}
+
+func TestCompareDeletedFile(t *testing.T) {
+	ctx := context.Background()
+	previous, err := protocheck.Compile(ctx, fstest.MapFS{"test/test.proto": {Data: []byte(base)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	violations := protocheck.Compare(previous, nil)
+	if len(violations) != 1 || violations[0].Rule != "FILE_NO_DELETE" {
+		t.Errorf("violations = %v, want FILE_NO_DELETE", violations)
+	}
+}
+
+func replace(s, old, new string) string {
+	for i := 0; i+len(old) <= len(s); i++ {
+		if s[i:i+len(old)] == old {
+			return s[:i] + new + s[i+len(old):]
+		}
+	}
+	panic("replace: " + old + " not found")
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package seed
+
+import (
+	"math"
+	"math/rand/v2"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// dangerousGoods are common ADR goods as class and UN number
+var dangerousGoods = [][2]string{
+	{"3", "UN1203"},   // бензин
+	{"3", "UN1202"},   // дизельное топливо
+	{"2.1", "UN1075"}, // сжиженный газ
+	{"8", "UN1789"},   // соляная кислота
+	{"9", "UN3480"},   // литий-ионные аккумуляторы
+	{"6.1", "UN2810"}, // токсичные жидкости
+}
+
+// temperatureRanges are the usual reefer settings: chilled, frozen, pharma
+var temperatureRanges = [][2]float64{{2, 8}, {-18, -15}, {15, 25}}
+
+// newCargo draws mostly general cargo on pallets or in boxes
+func newCargo(r *rand.Rand) *entity.Cargo {
+	c := &entity.Cargo{Type: entity.CargoGeneral}
+	switch n := r.IntN(100); {
+	case n < 15:
+		c.Type = entity.CargoFragile
+	case n < 22:
+		c.Type = entity.CargoDangerous
+		goods := dangerousGoods[r.IntN(len(dangerousGoods))]
+		c.DangerousClass, c.UNNumber = goods[0], goods[1]
+	case n < 30:
+		c.Type = entity.CargoTemperatureControlled
+		t := temperatureRanges[r.IntN(len(temperatureRanges))]
+		minC, maxC := t[0], t[1]
+		c.TemperatureMinC, c.TemperatureMaxC = &minC, &maxC
+	}
+
+	var p entity.Package
+	var kg float64
+	if r.IntN(3) == 0 {
+		p = entity.Package{LengthCm: 60, WidthCm: 40, HeightCm: 20 + 10*r.IntN(5), Quantity: 1 + r.IntN(80)}
+		kg = 5 + 20*r.Float64()
+	} else {
+		p = entity.Package{LengthCm: 120, WidthCm: 80, HeightCm: 80 + 10*r.IntN(11), Quantity: 1 + r.IntN(24)}
+		kg = 150 + 650*r.Float64()
+	}
+	c.Packages = []entity.Package{p}
+	c.PackageCount = p.Quantity
+	c.WeightKg = math.Round(kg*float64(p.Quantity)*10) / 10
+	c.VolumeM3 = math.Ceil(p.VolumeM3()*100) / 100
+
+	if c.Type == entity.CargoFragile || r.IntN(2) == 0 {
+		c.DeclaredValue = (50 + r.IntN(5000)) * 1000
+	}
+	return c
+}
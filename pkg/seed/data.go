@@ -0,0 +1,61 @@
+package seed
+
+// city is a route endpoint, a code of the gazetteer; weight is the population
+// in thousands and makes large cities both the usual origin and destination
+type city struct {
+	code   string
+	weight int
+}
+
+var cities = []city{
+	{"ALMATY", 2200},
+	{"ASTANA", 1350},
+	{"SHYMKENT", 1200},
+	{"KARAGANDA", 500},
+	{"AKTOBE", 560},
+	{"TARAZ", 360},
+	{"PAVLODAR", 340},
+	{"OSKEMEN", 330},
+	{"SEMEY", 350},
+	{"ATYRAU", 300},
+	{"KOSTANAY", 260},
+	{"KYZYLORDA", 320},
+	{"ORAL", 330},
+	{"PETROPAVL", 220},
+	{"AKTAU", 260},
+	{"TURKESTAN", 200},
+	{"KOKSHETAU", 150},
+	{"TALDYKORGAN", 150},
+	{"EKIBASTUZ", 150},
+	{"ZHEZKAZGAN", 90},
+}
+
+var (
+	maleNames = []string{
+		"Нурлан", "Ерлан", "Данияр", "Асхат", "Бауыржан", "Айдос", "Тимур", "Арман",
+		"Ержан", "Самат", "Олжас", "Алибек", "Мирас", "Руслан", "Серик", "Жандос",
+	}
+	femaleNames = []string{
+		"Айгерим", "Алия", "Динара", "Жанна", "Гульнара", "Асель", "Мадина", "Камила",
+		"Салтанат", "Айжан", "Дана", "Мариям", "Томирис", "Жансая",
+	}
+	// surnames are male forms; all take -а in the female form
+	surnames = []string{
+		"Касымов", "Нургалиев", "Жумабаев", "Ахметов", "Садыков", "Искаков", "Байжанов", "Оспанов",
+		"Тулегенов", "Абенов", "Мусин", "Ибраев", "Сулейменов", "Кенжебаев", "Омаров", "Есенов",
+		"Бекмуханов", "Серикбаев",
+	}
+
+	legalForms   = []string{"ТОО", "ТОО", "ТОО", "АО"}
+	companyNames = []string{
+		"Алтын Жол", "Дала Транс", "Каспий Логистик", "Сарыарка Агро", "Жетысу Трейд", "Тенгри Строй",
+		"Атамекен Импекс", "Номад Карго", "Береке Фуд", "Ертис Пром", "Байтерек Сервис", "Шанырак Дистрибьюшн",
+		"Кокжиек Маркет", "Алатау Фарм", "Самрук Снаб", "Есиль Металл",
+	}
+
+	streets = []string{
+		"ул. Абая", "пр. Абылай хана", "ул. Толе би", "ул. Кабанбай батыра", "ул. Жибек жолы",
+		"пр. Республики", "ул. Сатпаева", "ул. Ауэзова", "ул. Желтоксан", "ул. Байтурсынова",
+		"ул. Кунаева", "ул. Момышулы", "ул. Сейфуллина", "пр. Достык",
+	}
+)
@@ -0,0 +1,294 @@
+// Package seed generates demo data for both services: customers with valid
+// IIN or BIN, names and addresses, and their shipments between Kazakh cities
+// with status histories. The same Config always yields the same data, IDs
+// included, so each service can seed its own database independently and the
+// shipments still reference the seeded customers.
+package seed
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/pkg/geo"
+	"github.com/aidosgal/transline-test/pkg/idn"
+	customerentity "github.com/aidosgal/transline-test/services/customer/entity"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+const dateLayout = "2006-01-02"
+
+// DefaultUntil is the end of the default period. It is fixed rather than
+// today, so services seeded on different days still agree on the IDs.
+var DefaultUntil = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Config describes the data to generate
+type Config struct {
+	Seed      uint64
+	Customers int
+	Shipments int
+	// Until is the end of the generated period; histories stop at it, so
+	// recent shipments are still on their way
+	Until time.Time
+	// Days is the length of the period shipments are created in
+	Days int
+	// BINRatio is the share of customers that are companies
+	BINRatio float64
+}
+
+// DefaultConfig generates a small data set up to DefaultUntil
+func DefaultConfig() Config {
+	return Config{
+		Seed:      1,
+		Customers: 100,
+		Shipments: 500,
+		Until:     DefaultUntil,
+		Days:      90,
+		BINRatio:  0.3,
+	}
+}
+
+// RegisterFlags adds the config fields to fs with the values of c as defaults
+func (c *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.Uint64Var(&c.Seed, "seed", c.Seed, "random seed; the same seed and sizes give the same data")
+	fs.IntVar(&c.Customers, "customers", c.Customers, "number of customers")
+	fs.IntVar(&c.Shipments, "shipments", c.Shipments, "number of shipments")
+	fs.IntVar(&c.Days, "days", c.Days, "shipments are created during this many days before -until")
+	fs.Float64Var(&c.BINRatio, "bin-ratio", c.BINRatio, "share of customers that are companies with a BIN")
+	fs.Func("until", "end of the period as YYYY-MM-DD (default "+DefaultUntil.Format(dateLayout)+")", func(s string) error {
+		t, err := time.Parse(dateLayout, s)
+		if err != nil {
+			return err
+		}
+		c.Until = t
+		return nil
+	})
+}
+
+func (c Config) validate() error {
+	switch {
+	case c.Customers < 1 && c.Shipments > 0:
+		return errors.New("shipments need at least one customer")
+	case c.Customers < 0 || c.Shipments < 0:
+		return errors.New("sizes must not be negative")
+	case c.Days < 1:
+		return errors.New("days must be at least 1")
+	case c.BINRatio < 0 || c.BINRatio > 1:
+		return errors.New("bin ratio must be between 0 and 1")
+	case c.Until.IsZero():
+		return errors.New("until is not set")
+	}
+	return nil
+}
+
+// Dataset is the generated data, in the order it should be written
+type Dataset struct {
+	Customers []*customerentity.Customer
+	Shipments []*Shipment
+}
+
+// Shipment is a shipment with its history and the IDN of its customer, which
+// the public API takes instead of the customer ID
+type Shipment struct {
+	*entity.Shipment
+	History     []entity.StatusChange
+	CustomerIDN string
+}
+
+// Generate builds the data set of c. Customers and shipments use separate
+// random streams, so the customers do not depend on the number of shipments.
+func Generate(c Config) (*Dataset, error) {
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("invalid seed config: %w", err)
+	}
+	until := c.Until.UTC()
+	from := until.AddDate(0, 0, -c.Days)
+
+	d := &Dataset{}
+	homes := make([]int, c.Customers)
+
+	// An IDN drawn twice would be skipped on insert and leave its shipments
+	// without a customer, so it is drawn again
+	seen := make(map[string]bool, c.Customers)
+	r := rand.New(rand.NewPCG(c.Seed, 1))
+	for i := range c.Customers {
+		homes[i] = weightedCity(r, -1)
+		customer := newCustomer(r, from, homes[i], c.BINRatio)
+		for seen[customer.IDN] {
+			customer = newCustomer(r, from, homes[i], c.BINRatio)
+		}
+		seen[customer.IDN] = true
+		d.Customers = append(d.Customers, customer)
+	}
+
+	r = rand.New(rand.NewPCG(c.Seed, 2))
+	for range c.Shipments {
+		// A few customers send most of the shipments
+		u := r.Float64()
+		i := int(u * u * float64(c.Customers))
+		d.Shipments = append(d.Shipments, newShipment(r, d.Customers[i], homes[i], from, until))
+	}
+
+	return d, nil
+}
+
+func newCustomer(r *rand.Rand, before time.Time, home int, binRatio float64) *customerentity.Customer {
+	c := &customerentity.Customer{
+		ID:        newUUID(r),
+		CreatedAt: randomTime(r, before.AddDate(-2, 0, 0), before),
+	}
+
+	street := pick(r, streets)
+	building := 1 + r.IntN(150)
+	if r.Float64() < binRatio {
+		c.IDN = idn.RandomBIN(r)
+		c.Name = fmt.Sprintf("%s «%s»", pick(r, legalForms), pick(r, companyNames))
+		c.Address = fmt.Sprintf("г. %s, %s, д. %d, офис %d", cities[home].place().NameRu, street, building, 1+r.IntN(400))
+		return c
+	}
+
+	female := r.IntN(2) == 0
+	first, last := pick(r, maleNames), pick(r, surnames)
+	if female {
+		first, last = pick(r, femaleNames), last+"а"
+	}
+	birth := randomTime(r, time.Date(1955, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2004, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.IDN = idn.IIN(r, birth, female)
+	c.Name = first + " " + last
+	c.Address = fmt.Sprintf("г. %s, %s, д. %d, кв. %d", cities[home].place().NameRu, street, building, 1+r.IntN(200))
+	return c
+}
+
+func newShipment(r *rand.Rand, customer *customerentity.Customer, home int, from, until time.Time) *Shipment {
+	origin := home
+	if r.IntN(10) >= 6 {
+		origin = weightedCity(r, -1)
+	}
+	destination := weightedCity(r, origin)
+	km := geo.RoadKm(cities[origin].place(), cities[destination].place())
+
+	created := randomTime(r, from, until)
+	id := newUUID(r)
+	s := &Shipment{
+		Shipment: &entity.Shipment{
+			ID:         id,
+			Route:      cities[origin].code + "→" + cities[destination].code,
+			Price:      int(15000+km*float64(60+r.IntN(80))) / 100 * 100,
+			CustomerID: customer.ID,
+			CreatedAt:  created,
+			// The random tail of the ID keeps the number as stable as the ID
+			TrackingNumber: entity.TrackingPrefix + strings.ToUpper(strings.ReplaceAll(id, "-", "")[20:]),
+		},
+		CustomerIDN: customer.IDN,
+	}
+	s.History = history(r, created, until, km)
+	s.Status = s.History[len(s.History)-1].Status
+	s.Cargo = newCargo(r)
+	s.PickupWindow, s.DeliveryWindow, s.SLAStatus = windows(s.History, until, km)
+	return s
+}
+
+// windows plans the windows the way the service does for a lane without
+// history, and marks shipments delivered after, or still open at until past,
+// their delivery window as breached
+func windows(h []entity.StatusChange, until time.Time, km float64) (*entity.Window, *entity.Window, string) {
+	transit := time.Duration(km / 55 * float64(time.Hour)).Round(time.Minute)
+	from := h[0].ChangedAt.Truncate(time.Minute)
+	pickup := &entity.Window{From: from, Until: from.Add(24 * time.Hour)}
+	delivery := &entity.Window{From: from.Add(transit), Until: from.Add(24*time.Hour + transit + 12*time.Hour)}
+
+	last := h[len(h)-1]
+	delivered := last.Status == entity.StatusDelivered
+	open := !delivered && last.Status != entity.StatusCancelled
+	if delivered && last.ChangedAt.After(delivery.Until) || open && until.After(delivery.Until) {
+		return pickup, delivery, entity.SLABreached
+	}
+	return pickup, delivery, entity.SLAOnTrack
+}
+
+// history walks a shipment through its statuses; steps after until have not
+// happened yet
+func history(r *rand.Rand, created, until time.Time, km float64) []entity.StatusChange {
+	h := []entity.StatusChange{{Status: entity.StatusCreated, ChangedAt: created}}
+	at := created
+	step := func(status string, min, max time.Duration) bool {
+		at = at.Add(min + time.Duration(r.Int64N(int64(max-min)))).Truncate(time.Second)
+		if at.After(until) {
+			return false
+		}
+		h = append(h, entity.StatusChange{Status: status, ChangedAt: at})
+		return true
+	}
+
+	cancel := r.IntN(100)
+	switch {
+	case cancel < 4:
+		step(entity.StatusCancelled, time.Hour, 48*time.Hour)
+		return h
+	case cancel < 6:
+		if step(entity.StatusPickedUp, 2*time.Hour, 36*time.Hour) {
+			step(entity.StatusCancelled, time.Hour, 24*time.Hour)
+		}
+		return h
+	}
+
+	// Trucks average 55 km/h with stops; delays stretch the trip up to 60%
+	transit := time.Duration(km / 55 * float64(time.Hour))
+	if step(entity.StatusPickedUp, 2*time.Hour, 36*time.Hour) && step(entity.StatusInTransit, 30*time.Minute, 4*time.Hour) {
+		step(entity.StatusDelivered, transit*4/5, transit*8/5)
+	}
+	return h
+}
+
+func (c city) place() geo.Place {
+	p, ok := geo.Get(c.code)
+	if !ok {
+		panic("seed city " + c.code + " is not in the gazetteer")
+	}
+	return p
+}
+
+// weightedCity picks a city by population, other than except
+func weightedCity(r *rand.Rand, except int) int {
+	total := 0
+	for i, c := range cities {
+		if i != except {
+			total += c.weight
+		}
+	}
+	n := r.IntN(total)
+	for i, c := range cities {
+		if i == except {
+			continue
+		}
+		if n < c.weight {
+			return i
+		}
+		n -= c.weight
+	}
+	return len(cities) - 1
+}
+
+func randomTime(r *rand.Rand, from, to time.Time) time.Time {
+	return from.Add(time.Duration(r.Int64N(int64(to.Sub(from))))).Truncate(time.Second)
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.IntN(len(values))]
+}
+
+// newUUID returns a version 4 UUID drawn from r
+func newUUID(r *rand.Rand) string {
+	var u uuid.UUID
+	for i := range u {
+		u[i] = byte(r.Uint32())
+	}
+	u[6] = u[6]&0x0f | 0x40
+	u[8] = u[8]&0x3f | 0x80
+	return u.String()
+}
@@ -0,0 +1,196 @@
+package seed_test
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/e2e/harness"
+	"github.com/aidosgal/transline-test/pkg/idn"
+	"github.com/aidosgal/transline-test/pkg/seed"
+	customerstorage "github.com/aidosgal/transline-test/services/customer/storage"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	shipmentstorage "github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+func config() seed.Config {
+	c := seed.DefaultConfig()
+	c.Seed = 42
+	c.Until = time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC)
+	return c
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	a, err := seed.Generate(config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := seed.Generate(config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Error("the same config generated different data")
+	}
+
+	more := config()
+	more.Shipments *= 2
+	c, err := seed.Generate(more)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a.Customers, c.Customers) {
+		t.Error("customers depend on the number of shipments")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	cfg := config()
+	d, err := seed.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Customers) != cfg.Customers || len(d.Shipments) != cfg.Shipments {
+		t.Fatalf("generated %d customers and %d shipments, want %d and %d",
+			len(d.Customers), len(d.Shipments), cfg.Customers, cfg.Shipments)
+	}
+
+	customers := map[string]string{}
+	for _, c := range d.Customers {
+		if !idn.Valid(c.IDN) {
+			t.Errorf("customer %s has an invalid IDN %s", c.ID, c.IDN)
+		}
+		if c.Name == "" || !strings.HasPrefix(c.Address, "г. ") {
+			t.Errorf("customer %+v has no name or address", c)
+		}
+		if _, ok := customers[c.IDN]; ok {
+			t.Errorf("IDN %s is generated twice", c.IDN)
+		}
+		customers[c.ID] = c.IDN
+	}
+
+	from := cfg.Until.AddDate(0, 0, -cfg.Days)
+	statuses, slaStatuses := map[string]int{}, map[string]int{}
+	for _, s := range d.Shipments {
+		if customers[s.CustomerID] != s.CustomerIDN {
+			t.Errorf("shipment %s references an unknown customer", s.ID)
+		}
+		if origin, destination, ok := strings.Cut(s.Route, "→"); !ok || origin == destination {
+			t.Errorf("shipment %s has route %q", s.ID, s.Route)
+		}
+		if s.CreatedAt.Before(from) || s.CreatedAt.After(cfg.Until) {
+			t.Errorf("shipment %s created at %v, outside the period", s.ID, s.CreatedAt)
+		}
+		if s.History[0].Status != entity.StatusCreated || !s.History[0].ChangedAt.Equal(s.CreatedAt) {
+			t.Errorf("shipment %s history starts with %+v", s.ID, s.History[0])
+		}
+		if !slices.IsSortedFunc(s.History, func(a, b entity.StatusChange) int { return a.ChangedAt.Compare(b.ChangedAt) }) {
+			t.Errorf("shipment %s history is not in order", s.ID)
+		}
+		if last := s.History[len(s.History)-1]; last.Status != s.Status || last.ChangedAt.After(cfg.Until) {
+			t.Errorf("shipment %s has status %s, history ends with %+v", s.ID, s.Status, last)
+		}
+		if err := (&entity.CreateReq{Cargo: s.Cargo}).Validate(); s.Cargo == nil || err != nil {
+			t.Errorf("shipment %s has invalid cargo %+v: %v", s.ID, s.Cargo, err)
+		}
+		if number, ok := entity.NormalizeTrackingNumber(s.TrackingNumber); !ok || number != s.TrackingNumber {
+			t.Errorf("shipment %s has tracking number %q", s.ID, s.TrackingNumber)
+		}
+		if s.PickupWindow == nil || s.DeliveryWindow == nil || !s.DeliveryWindow.Until.After(s.PickupWindow.From) {
+			t.Errorf("shipment %s has windows %+v, %+v", s.ID, s.PickupWindow, s.DeliveryWindow)
+		}
+		statuses[s.Status]++
+		slaStatuses[s.SLAStatus]++
+	}
+	for _, status := range []string{entity.SLAOnTrack, entity.SLABreached} {
+		if slaStatuses[status] == 0 {
+			t.Errorf("no shipment is %s: %v", status, slaStatuses)
+		}
+	}
+	for _, status := range []string{entity.StatusCreated, entity.StatusInTransit, entity.StatusDelivered, entity.StatusCancelled} {
+		if statuses[status] == 0 {
+			t.Errorf("no shipment is %s: %v", status, statuses)
+		}
+	}
+}
+
+func TestWriteStorages(t *testing.T) {
+	ctx := context.Background()
+	log := slog.New(slog.DiscardHandler)
+	customers, shipments := customerstorage.NewMemory(log), shipmentstorage.NewMemory(log)
+
+	d, err := seed.Generate(config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := d.WriteCustomers(ctx, customers); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.WriteShipments(ctx, shipments); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := d.Customers[0]
+	got, err := customers.GetCustomerByIDN(ctx, c.IDN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != c.ID || got.Name != c.Name || got.Address != c.Address {
+		t.Errorf("customer = %+v, want %+v", got, c)
+	}
+
+	s := d.Shipments[0]
+	history, err := shipments.GetStatusHistory(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != len(s.History) {
+		t.Errorf("history after seeding twice = %+v, want %+v", history, s.History)
+	}
+}
+
+func TestWriteRepeated(t *testing.T) {
+	ctx := context.Background()
+	customers := customerstorage.NewMemory(slog.New(slog.DiscardHandler))
+
+	d, err := seed.Generate(config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := d.WriteCustomers(ctx, customers); err != nil || n != len(d.Customers) {
+		t.Fatalf("WriteCustomers = %d, %v, want %d", n, err, len(d.Customers))
+	}
+	if n, err := d.WriteCustomers(ctx, customers); err != nil || n != 0 {
+		t.Errorf("repeated WriteCustomers = %d, %v, want 0", n, err)
+	}
+}
+
+func TestWriteAPI(t *testing.T) {
+	h := harness.New(t)
+
+	cfg := config()
+	cfg.Customers, cfg.Shipments = 5, 20
+	d, err := seed.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := d.WriteAPI(context.Background(), h.Server.Client(), h.Server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != cfg.Shipments {
+		t.Errorf("WriteAPI created %d shipments, want %d", n, cfg.Shipments)
+	}
+	for _, s := range d.Shipments {
+		if _, err := h.CustomerStorage.GetCustomerByIDN(context.Background(), s.CustomerIDN); err != nil {
+			t.Errorf("customer %s was not created: %v", s.CustomerIDN, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	customerstorage "github.com/aidosgal/transline-test/services/customer/storage"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	shipmentstorage "github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+// WriteCustomers inserts the customers in one transaction and returns how
+// many were new; existing IDNs are left as they are
+func (d *Dataset) WriteCustomers(ctx context.Context, s customerstorage.Storage) (int, error) {
+	created := 0
+	err := s.WithTx(ctx, func(tx customerstorage.Storage) error {
+		for _, c := range d.Customers {
+			customer, err := tx.InsertCustomer(ctx, c)
+			if err != nil {
+				return err
+			}
+			if customer.Created {
+				created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to seed customers: %w", err)
+	}
+	return created, nil
+}
+
+// WriteShipments inserts the shipments with their histories in one
+// transaction and returns how many were new
+func (d *Dataset) WriteShipments(ctx context.Context, s shipmentstorage.Storage) (int, error) {
+	created := 0
+	err := s.WithTx(ctx, func(tx shipmentstorage.Storage) error {
+		for _, sh := range d.Shipments {
+			inserted, err := tx.InsertShipment(ctx, sh.Shipment, sh.History)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				created++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to seed shipments: %w", err)
+	}
+	return created, nil
+}
+
+// WriteAPI creates every shipment through POST /api/v1/shipments of the
+// shipment API at baseURL, which also creates the customers. The services
+// assign IDs and times and the API takes neither names, addresses nor
+// histories, so only routes, prices, cargo and IDNs are kept. Returns the
+// number of shipments created before an error.
+func (d *Dataset) WriteAPI(ctx context.Context, client *http.Client, baseURL string) (int, error) {
+	for i, sh := range d.Shipments {
+		body, err := json.Marshal(entity.CreateReq{
+			Route:    sh.Route,
+			Price:    sh.Price,
+			Customer: entity.CreateCustomerReq{IDN: sh.CustomerIDN},
+			Cargo:    sh.Cargo,
+		})
+		if err != nil {
+			return i, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+"/api/v1/shipments", bytes.NewReader(body))
+		if err != nil {
+			return i, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return i, fmt.Errorf("failed to create shipment %d: %w", i, err)
+		}
+		msg, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated {
+			return i, fmt.Errorf("failed to create shipment %d: %s: %s", i, resp.Status, bytes.TrimSpace(msg))
+		}
+	}
+	return len(d.Shipments), nil
+}
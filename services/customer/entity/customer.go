@@ -1,23 +1,45 @@
 package entity
 
 import (
+	"errors"
+	"log/slog"
 	"time"
 
 	customerv1 "github.com/aidosgal/transline-test/specs/proto/customer"
 )
 
+var (
+	ErrNotFound        = errors.New("customer not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+)
+
 type (
 	Customer struct {
 		ID        string    `json:"id"`
 		IDN       string    `json:"idn"`
+		Name      string    `json:"name"`
+		Address   string    `json:"address"`
 		CreatedAt time.Time `json:"created_at"`
+		// Created is set by an upsert that inserted the customer
+		Created bool `json:"-"`
 	}
 )
 
 func MakeCustomerEntityToPb(customer *Customer) *customerv1.CustomerResponse {
 	return &customerv1.CustomerResponse{
-		Id: customer.ID,
-		Idn: customer.IDN,
+		Id:        customer.ID,
+		Idn:       customer.IDN,
 		CreatedAt: customer.CreatedAt.String(),
+		Created:   customer.Created,
 	}
 }
+
+// LogValue keeps the IDN under its own key so the log redactor can find it;
+// name and address are left out
+func (c *Customer) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", c.ID),
+		slog.String("idn", c.IDN),
+		slog.Time("created_at", c.CreatedAt),
+	)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"strings"
+
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	adminv1 "github.com/aidosgal/transline-test/specs/proto/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type admin struct {
+	adminv1.UnimplementedAdminServer
+	log    *slog.Logger
+	levels *customlogger.Levels
+}
+
+func NewAdmin(log *slog.Logger, levels *customlogger.Levels) *admin {
+	return &admin{
+		log:    log.With("layer", "admin"),
+		levels: levels,
+	}
+}
+
+func (a *admin) GetLogLevels(ctx context.Context, req *adminv1.GetLogLevelsRequest) (*adminv1.LogLevelsResponse, error) {
+	return makeLogLevelsPb(a.levels), nil
+}
+
+func (a *admin) SetLogLevel(ctx context.Context, req *adminv1.SetLogLevelRequest) (*adminv1.LogLevelsResponse, error) {
+	log := a.log.With("method", "SetLogLevel")
+
+	level, err := customlogger.ParseLevel(req.GetLevel())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	revertAfter := req.GetRevertAfter().AsDuration()
+	if revertAfter < 0 {
+		return nil, status.Error(codes.InvalidArgument, "revert_after must not be negative")
+	}
+
+	a.levels.Set(req.GetLayer(), level, revertAfter)
+
+	log.InfoContext(ctx, "log level changed",
+		slog.String("target_layer", req.GetLayer()),
+		slog.String("level", level.String()),
+		slog.Duration("revert_after", revertAfter))
+	return makeLogLevelsPb(a.levels), nil
+}
+
+// RequireAdminToken rejects calls to the admin.v1 service without
+// "authorization: Bearer <token>" metadata; other services pass through
+func RequireAdminToken(token string) grpc.UnaryServerInterceptor {
+	prefix := "/" + adminv1.Admin_ServiceDesc.ServiceName + "/"
+	want := []byte("Bearer " + token)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !strings.HasPrefix(info.FullMethod, prefix) {
+			return handler(ctx, req)
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		got := md.Get("authorization")
+		if len(got) != 1 || subtle.ConstantTimeCompare([]byte(got[0]), want) != 1 {
+			return nil, status.Error(codes.Unauthenticated, "invalid or missing admin token")
+		}
+		return handler(ctx, req)
+	}
+}
+
+func makeLogLevelsPb(levels *customlogger.Levels) *adminv1.LogLevelsResponse {
+	resp := customlogger.MakeLevelsResp(levels)
+	return &adminv1.LogLevelsResponse{
+		Default: resp.Default,
+		Layers:  resp.Layers,
+	}
+}
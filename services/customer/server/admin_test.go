@@ -0,0 +1,70 @@
+package server_test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	"github.com/aidosgal/transline-test/services/customer/server"
+	adminv1 "github.com/aidosgal/transline-test/specs/proto/admin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestRequireAdminToken(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+
+	grpcServer := grpc.NewServer(grpc.ChainUnaryInterceptor(server.RequireAdminToken("secret")))
+	adminv1.RegisterAdminServer(grpcServer, server.NewAdmin(log, customlogger.NewLevels(slog.LevelInfo, nil)))
+	healthpb.RegisterHealthServer(grpcServer, grpchealth.NewServer())
+	lis := bufconn.Listen(1 << 20)
+	go grpcServer.Serve(lis)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///customer-service",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	admin := adminv1.NewAdminClient(conn)
+
+	tests := []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{name: "no token", want: codes.Unauthenticated},
+		{name: "wrong token", token: "Bearer guess", want: codes.Unauthenticated},
+		{name: "no scheme", token: "secret", want: codes.Unauthenticated},
+		{name: "valid token", token: "Bearer secret", want: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.token != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tt.token)
+			}
+			_, err := admin.SetLogLevel(ctx, &adminv1.SetLogLevelRequest{Layer: "storage", Level: "debug"})
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("SetLogLevel code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+
+	// Other services do not need the token
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("Health.Check without a token: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package server_test
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/aidosgal/transline-test/pkg/contract"
+	"github.com/aidosgal/transline-test/services/customer/server"
+	"github.com/aidosgal/transline-test/services/customer/storage"
+	"github.com/aidosgal/transline-test/services/customer/usecase"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const contractsDir = "../../../specs/contracts"
+
+// TestContracts verifies the server against every consumer's contract
+func TestContracts(t *testing.T) {
+	contracts, err := contract.LoadProvider(contractsDir, "customer-service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(contracts) == 0 {
+		t.Fatalf("no contracts for customer-service in %s", contractsDir)
+	}
+
+	log := slog.New(slog.DiscardHandler)
+	var customers storage.Storage
+
+	provider := contract.Provider{
+		Setup: func(t *testing.T) grpc.ClientConnInterface {
+			customers = storage.NewMemory(log)
+
+			grpcServer := grpc.NewServer()
+			pb.RegisterCustomerServer(grpcServer, server.New(log, usecase.New(log, customers)))
+			lis := bufconn.Listen(1 << 20)
+			go grpcServer.Serve(lis)
+			t.Cleanup(grpcServer.Stop)
+
+			conn, err := grpc.NewClient("passthrough:///customer-service",
+				grpc.WithTransportCredentials(insecure.NewCredentials()),
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { conn.Close() })
+			return conn
+		},
+		States: map[string]contract.StateHandler{
+			"no customer": func(ctx context.Context, params map[string]string) (map[string]string, error) {
+				return nil, nil
+			},
+			"customer exists": func(ctx context.Context, params map[string]string) (map[string]string, error) {
+				customer, err := customers.UpsertCustomer(ctx, params["idn"], "")
+				if err != nil {
+					return nil, err
+				}
+				return map[string]string{"customer_id": customer.ID}, nil
+			},
+			"customer created by saga": func(ctx context.Context, params map[string]string) (map[string]string, error) {
+				customer, err := customers.UpsertCustomer(ctx, params["idn"], params["saga_id"])
+				if err != nil {
+					return nil, err
+				}
+				return map[string]string{"customer_id": customer.ID}, nil
+			},
+		},
+	}
+
+	for _, c := range contracts {
+		contract.Verify(t, c, provider)
+	}
+}
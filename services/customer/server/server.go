@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
+	"github.com/aidosgal/transline-test/pkg/certs"
 	"github.com/aidosgal/transline-test/services/customer/entity"
 	"github.com/aidosgal/transline-test/services/customer/usecase"
 	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type server struct {
@@ -23,14 +27,14 @@ func New(log *slog.Logger, usecase usecase.Usecase) *server {
 }
 
 func (s *server) UpsertCustomer(ctx context.Context, req *pb.UpsertCustomerRequest) (*pb.CustomerResponse, error) {
-	log := s.log.With("method", "UpsertCustomer")
+	log := withCaller(ctx, s.log.With("method", "UpsertCustomer"))
 
 	log.InfoContext(ctx, "received upsert customer request", slog.String("idn", req.GetIdn()))
 
-	resp, err := s.usecase.UpsertCustomer(ctx, req.GetIdn())
+	resp, err := s.usecase.UpsertCustomer(ctx, req.GetIdn(), req.GetSagaId())
 	if err != nil {
 		log.ErrorContext(ctx, "failed to upsert customer", slog.String("error", err.Error()))
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	log.InfoContext(ctx, "customer upserted successfully",
@@ -40,14 +44,14 @@ func (s *server) UpsertCustomer(ctx context.Context, req *pb.UpsertCustomerReque
 }
 
 func (s *server) GetCustomer(ctx context.Context, req *pb.GetCustomerRequest) (*pb.CustomerResponse, error) {
-	log := s.log.With("method", "GetCustomer")
+	log := withCaller(ctx, s.log.With("method", "GetCustomer"))
 
 	log.InfoContext(ctx, "received get customer request", slog.String("idn", req.Idn))
 
 	resp, err := s.usecase.GetCustomer(ctx, req.Idn)
 	if err != nil {
 		log.ErrorContext(ctx, "failed to get customer", slog.String("error", err.Error()))
-		return nil, err
+		return nil, statusError(err)
 	}
 
 	log.InfoContext(ctx, "customer retrieved successfully",
@@ -55,3 +59,43 @@ func (s *server) GetCustomer(ctx context.Context, req *pb.GetCustomerRequest) (*
 		slog.String("idn", resp.IDN))
 	return entity.MakeCustomerEntityToPb(resp), nil
 }
+
+func (s *server) DeleteCustomer(ctx context.Context, req *pb.DeleteCustomerRequest) (*pb.DeleteCustomerResponse, error) {
+	log := withCaller(ctx, s.log.With("method", "DeleteCustomer"))
+
+	log.InfoContext(ctx, "received delete customer request",
+		slog.String("customer_id", req.GetId()),
+		slog.String("saga_id", req.GetSagaId()))
+
+	deleted, err := s.usecase.DeleteCustomer(ctx, req.GetId(), req.GetSagaId())
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete customer", slog.String("error", err.Error()))
+		return nil, statusError(err)
+	}
+
+	log.InfoContext(ctx, "delete customer request handled",
+		slog.String("customer_id", req.GetId()),
+		slog.Bool("deleted", deleted))
+	return &pb.DeleteCustomerResponse{Deleted: deleted}, nil
+}
+
+// statusError maps usecase errors to gRPC codes, so callers can tell a
+// missing customer from a failure
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, entity.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, entity.ErrInvalidArgument):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
+
+// withCaller tags the logger with the mTLS identity of the calling service
+func withCaller(ctx context.Context, log *slog.Logger) *slog.Logger {
+	if id, ok := certs.FromContext(ctx); ok {
+		return log.With("caller", id.String())
+	}
+	return log
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/services/customer/entity"
+)
+
+// customerSagas are the created_by_saga column, empty for NULL, and reused
+type customerSagas struct {
+	createdBy string
+	reused    bool
+}
+
+type memoryState struct {
+	customers map[string]entity.Customer
+	// byIDN maps an IDN to the customer ID, the unique index on idn
+	byIDN map[string]string
+	// sagas maps a customer ID to the saga that created it
+	sagas map[string]customerSagas
+}
+
+func (st *memoryState) clone() *memoryState {
+	return &memoryState{
+		customers: maps.Clone(st.customers),
+		byIDN:     maps.Clone(st.byIDN),
+		sagas:     maps.Clone(st.sagas),
+	}
+}
+
+type memory struct {
+	log *slog.Logger
+	// mu serializes every call and is held for the whole of a transaction
+	mu    *sync.Mutex
+	state *memoryState
+	// inTx is set on the Storage passed to WithTx; mu is already held and
+	// now is fixed, as NOW() is in a Postgres transaction
+	inTx bool
+	now  time.Time
+}
+
+// NewMemory returns a Storage that keeps customers in memory with the same
+// semantics as the Postgres one. Transactions are serialized: they work on a
+// copy that replaces the state on commit, and calls on the outer Storage wait
+// until the transaction ends.
+func NewMemory(log *slog.Logger) Storage {
+	return &memory{
+		log: log.With("layer", "storage"),
+		mu:  &sync.Mutex{},
+		state: &memoryState{
+			customers: make(map[string]entity.Customer),
+			byIDN:     make(map[string]string),
+			sagas:     make(map[string]customerSagas),
+		},
+	}
+}
+
+func (s *memory) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *memory) clock() time.Time {
+	if s.inTx {
+		return s.now
+	}
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (s *memory) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memory{log: s.log, mu: s.mu, state: s.state.clone(), inTx: true, now: s.clock()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*s.state = *tx.state
+	return nil
+}
+
+func (s *memory) GetCustomerByIDN(ctx context.Context, idn string) (*entity.Customer, error) {
+	defer s.lock()()
+
+	id, ok := s.state.byIDN[idn]
+	if !ok {
+		return nil, fmt.Errorf("failed to get customer: %w", sql.ErrNoRows)
+	}
+	customer := s.state.customers[id]
+	return &customer, nil
+}
+
+func (s *memory) UpsertCustomer(ctx context.Context, idn, sagaID string) (*entity.Customer, error) {
+	defer s.lock()()
+
+	if id, ok := s.state.byIDN[idn]; ok {
+		customer := s.state.customers[id]
+		sagas := s.state.sagas[id]
+		if sagas.createdBy != sagaID {
+			sagas.reused = true
+			s.state.sagas[id] = sagas
+		}
+		customer.Created = sagaID != "" && sagas.createdBy == sagaID
+		return &customer, nil
+	}
+
+	customer := entity.Customer{
+		ID:        uuid.NewString(),
+		IDN:       idn,
+		CreatedAt: s.clock(),
+	}
+	s.state.customers[customer.ID] = customer
+	s.state.byIDN[idn] = customer.ID
+	s.state.sagas[customer.ID] = customerSagas{createdBy: sagaID}
+
+	s.log.Debug("customer inserted", slog.String("method", "UpsertCustomer"), slog.Any("customer", &customer))
+
+	customer.Created = true
+	return &customer, nil
+}
+
+func (s *memory) InsertCustomer(ctx context.Context, c *entity.Customer) (*entity.Customer, error) {
+	parsed, err := uuid.Parse(c.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	defer s.lock()()
+
+	if id, ok := s.state.byIDN[c.IDN]; ok {
+		customer := s.state.customers[id]
+		return &customer, nil
+	}
+	if _, ok := s.state.customers[parsed.String()]; ok {
+		return nil, fmt.Errorf("failed to insert customer: duplicate id %s", parsed)
+	}
+
+	customer := entity.Customer{
+		ID:        parsed.String(),
+		IDN:       c.IDN,
+		Name:      c.Name,
+		Address:   c.Address,
+		CreatedAt: c.CreatedAt.UTC().Truncate(time.Microsecond),
+	}
+	s.state.customers[customer.ID] = customer
+	s.state.byIDN[customer.IDN] = customer.ID
+
+	s.log.Debug("customer inserted", slog.String("method", "InsertCustomer"), slog.Any("customer", &customer))
+
+	customer.Created = true
+	return &customer, nil
+}
+
+func (s *memory) DeleteCustomer(ctx context.Context, id, sagaID string) (bool, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete customer: %w", err)
+	}
+
+	defer s.lock()()
+
+	customer, ok := s.state.customers[parsed.String()]
+	if !ok {
+		return false, nil
+	}
+	sagas := s.state.sagas[customer.ID]
+	if sagaID == "" || sagas.createdBy != sagaID || sagas.reused {
+		return false, nil
+	}
+	delete(s.state.customers, customer.ID)
+	delete(s.state.byIDN, customer.IDN)
+	delete(s.state.sagas, customer.ID)
+	return true, nil
+}
@@ -0,0 +1,3 @@
+ALTER TABLE customers
+    DROP COLUMN IF EXISTS name,
+    DROP COLUMN IF EXISTS address;
@@ -0,0 +1,6 @@
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS name TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '';
+
+COMMENT ON COLUMN customers.name IS 'Full name of a person or legal name of a company, empty if unknown';
+COMMENT ON COLUMN customers.address IS 'Postal address, empty if unknown';
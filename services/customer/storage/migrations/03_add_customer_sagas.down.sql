@@ -0,0 +1,3 @@
+ALTER TABLE customers
+    DROP COLUMN IF EXISTS created_by_saga,
+    DROP COLUMN IF EXISTS reused;
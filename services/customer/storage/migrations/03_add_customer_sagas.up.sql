@@ -0,0 +1,6 @@
+ALTER TABLE customers
+    ADD COLUMN IF NOT EXISTS created_by_saga TEXT,
+    ADD COLUMN IF NOT EXISTS reused BOOLEAN NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN customers.created_by_saga IS 'Saga that inserted the customer, NULL if none';
+COMMENT ON COLUMN customers.reused IS 'Set once anyone but the creating saga upserts the customer; a reused customer is never deleted';
@@ -6,24 +6,64 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/aidosgal/transline-test/pkg/postgres"
 	"github.com/aidosgal/transline-test/services/customer/entity"
 )
 
 type storage struct {
 	log *slog.Logger
-	db  *sql.DB
+	db  postgres.DBTX
+	// replica serves read-only queries; the primary or the transaction if
+	// there is no replica or inside a transaction
+	replica postgres.DBTX
+	// conn is nil inside a transaction
+	conn *sql.DB
 }
 
 type Storage interface {
+	// WithTx runs fn against a Storage bound to one transaction, committing
+	// if fn returns nil. Calls on a transactional Storage join its transaction.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
+	// GetCustomerByIDN reads from the primary: saga compensation looks up a
+	// customer it may have just created and takes a miss as nothing to delete
 	GetCustomerByIDN(ctx context.Context, idn string) (*entity.Customer, error)
-	UpsertCustomer(ctx context.Context, idn string) (*entity.Customer, error)
+	// UpsertCustomer returns the customer with idn, inserting it for sagaID,
+	// which may be empty, if there is none. Created is also set when sagaID
+	// inserted the customer earlier; any other caller marks it reused.
+	UpsertCustomer(ctx context.Context, idn, sagaID string) (*entity.Customer, error)
+	// InsertCustomer inserts a customer with its ID, profile and creation
+	// time. A customer with the same IDN is returned unchanged with
+	// Created=false, so seeding can be repeated.
+	InsertCustomer(ctx context.Context, customer *entity.Customer) (*entity.Customer, error)
+	// DeleteCustomer deletes the customer only if sagaID created it and it is
+	// not reused, so a customer someone else relies on is kept; it reports
+	// whether the customer was deleted
+	DeleteCustomer(ctx context.Context, id, sagaID string) (bool, error)
+}
+
+// New returns a Storage on db; replica, if not nil, serves read-only queries
+// that tolerate replication lag
+func New(log *slog.Logger, db *sql.DB, replica *sql.DB) Storage {
+	s := &storage{
+		log:     log.With("layer", "storage"),
+		db:      db,
+		replica: db,
+		conn:    db,
+	}
+	if replica != nil {
+		s.replica = replica
+	}
+	return s
 }
 
-func New(log *slog.Logger, db *sql.DB) Storage {
-	return &storage{
-		log: log.With("layer", "Storage"),
-		db:  db,
+func (s *storage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.conn == nil {
+		return fn(s)
 	}
+	return postgres.InTx(ctx, s.conn, func(tx *sql.Tx) error {
+		return fn(&storage{log: s.log, db: tx, replica: tx})
+	})
 }
 
 func (s *storage) GetCustomerByIDN(ctx context.Context, idn string) (*entity.Customer, error) {
@@ -31,36 +71,48 @@ func (s *storage) GetCustomerByIDN(ctx context.Context, idn string) (*entity.Cus
 	customer := &entity.Customer{}
 
 	log.Debug("select query started", slog.String("idn", idn))
-	err := s.db.QueryRowContext(ctx, `SELECT id, idn, created_at FROM customers WHERE idn=$1`, idn).
-		Scan(&customer.ID, &customer.IDN, &customer.CreatedAt)
+	err := s.db.QueryRowContext(ctx, `SELECT id, idn, name, address, created_at FROM customers WHERE idn=$1`, idn).
+		Scan(&customer.ID, &customer.IDN, &customer.Name, &customer.Address, &customer.CreatedAt)
 	if err != nil {
-		log.Error("failed to SELECT id, idn, created_at FROM customers WHERE idn=%s", idn,
+		log.Error("select customer failed",
 			slog.String("idn", idn),
 			slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to get customer: %w", err)
 	}
 	log.Debug("finished query", slog.Any("customer", customer))
-	
+
 	return customer, nil
 }
 
-func (s *storage) UpsertCustomer(ctx context.Context, idn string) (*entity.Customer, error) {
+// sagaArg is the SQL value of a saga ID, NULL if empty
+func sagaArg(sagaID string) sql.NullString {
+	return sql.NullString{String: sagaID, Valid: sagaID != ""}
+}
+
+func (s *storage) UpsertCustomer(ctx context.Context, idn, sagaID string) (*entity.Customer, error) {
 	log := s.log.With("method", "UpsertCustomer")
 	customer := &entity.Customer{}
 
+	// A retry of the saga that inserted the customer still reports it as
+	// created, so the saga knows it has something to compensate
 	query := `
-		INSERT INTO customers (id, idn)
-		VALUES (gen_random_uuid(), $1)
-		ON CONFLICT (idn) DO UPDATE SET idn = EXCLUDED.idn
-		RETURNING id, idn, created_at;
+		INSERT INTO customers (id, idn, created_by_saga)
+		VALUES (gen_random_uuid(), $1, $2)
+		ON CONFLICT (idn) DO UPDATE
+			SET reused = customers.reused OR customers.created_by_saga IS DISTINCT FROM EXCLUDED.created_by_saga
+		RETURNING id, idn, name, address, created_at,
+			(xmax = 0 OR COALESCE(customers.created_by_saga = $2, false)) AS created;
 	`
 
-	log.Debug("executing upsert", slog.String("idn", idn))
+	log.Debug("executing upsert", slog.String("idn", idn), slog.String("saga_id", sagaID))
 
-	err := s.db.QueryRowContext(ctx, query, idn).Scan(
+	err := s.db.QueryRowContext(ctx, query, idn, sagaArg(sagaID)).Scan(
 		&customer.ID,
 		&customer.IDN,
+		&customer.Name,
+		&customer.Address,
 		&customer.CreatedAt,
+		&customer.Created,
 	)
 	if err != nil {
 		log.Error("upsert failed",
@@ -73,3 +125,62 @@ func (s *storage) UpsertCustomer(ctx context.Context, idn string) (*entity.Custo
 	log.Debug("upsert successful", slog.Any("customer", customer))
 	return customer, nil
 }
+
+func (s *storage) InsertCustomer(ctx context.Context, c *entity.Customer) (*entity.Customer, error) {
+	log := s.log.With("method", "InsertCustomer")
+	customer := &entity.Customer{}
+
+	// The no-op update makes RETURNING yield the existing row on conflict
+	query := `
+		INSERT INTO customers (id, idn, name, address, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (idn) DO UPDATE SET idn = EXCLUDED.idn
+		RETURNING id, idn, name, address, created_at, (xmax = 0) AS created;
+	`
+
+	log.Debug("executing insert", slog.String("customer_id", c.ID), slog.String("idn", c.IDN))
+
+	err := s.db.QueryRowContext(ctx, query, c.ID, c.IDN, c.Name, c.Address, c.CreatedAt).Scan(
+		&customer.ID,
+		&customer.IDN,
+		&customer.Name,
+		&customer.Address,
+		&customer.CreatedAt,
+		&customer.Created,
+	)
+	if err != nil {
+		log.Error("insert failed",
+			slog.String("customer_id", c.ID),
+			slog.String("error", err.Error()),
+		)
+		return nil, fmt.Errorf("failed to insert customer: %w", err)
+	}
+
+	log.Debug("insert successful", slog.Any("customer", customer))
+	return customer, nil
+}
+
+func (s *storage) DeleteCustomer(ctx context.Context, id, sagaID string) (bool, error) {
+	log := s.log.With("method", "DeleteCustomer")
+
+	log.Debug("executing delete", slog.String("customer_id", id), slog.String("saga_id", sagaID))
+
+	// One statement, so an upsert by another caller either lands before it
+	// and keeps the customer or after it and inserts a new one
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM customers WHERE id=$1 AND created_by_saga=$2 AND NOT reused`, id, sagaArg(sagaID))
+	if err != nil {
+		log.Error("delete failed",
+			slog.String("customer_id", id),
+			slog.String("error", err.Error()),
+		)
+		return false, fmt.Errorf("failed to delete customer: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete customer: %w", err)
+	}
+
+	log.Debug("delete finished", slog.String("customer_id", id), slog.Bool("deleted", n > 0))
+	return n > 0, nil
+}
@@ -0,0 +1,397 @@
+// Package storagetest is the conformance suite every customer Storage must pass
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/services/customer/entity"
+	"github.com/aidosgal/transline-test/services/customer/storage"
+)
+
+var errRollback = errors.New("rollback")
+
+// Run runs the suite; newStorage returns an empty Storage for each test
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"UpsertInserts", testUpsertInserts},
+		{"UpsertReturnsExisting", testUpsertReturnsExisting},
+		{"UpsertConcurrent", testUpsertConcurrent},
+		{"Insert", testInsert},
+		{"InsertExistingIDN", testInsertExistingIDN},
+		{"GetNotFound", testGetNotFound},
+		{"UpsertRetryBySaga", testUpsertRetryBySaga},
+		{"Delete", testDelete},
+		{"DeleteOtherSaga", testDeleteOtherSaga},
+		{"DeleteUpsertedSince", testDeleteUpsertedSince},
+		{"DeleteInvalidID", testDeleteInvalidID},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"TxNested", testTxNested},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func testUpsertInserts(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	customer, err := s.UpsertCustomer(ctx, "990101300123", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if _, err := uuid.Parse(customer.ID); err != nil {
+		t.Errorf("ID %q is not a UUID", customer.ID)
+	}
+	if customer.IDN != "990101300123" {
+		t.Errorf("IDN = %q, want 990101300123", customer.IDN)
+	}
+	if customer.CreatedAt.IsZero() {
+		t.Error("CreatedAt is zero")
+	}
+	if !customer.Created {
+		t.Error("Created = false for a new customer")
+	}
+
+	got, err := s.GetCustomerByIDN(ctx, "990101300123")
+	if err != nil {
+		t.Fatalf("GetCustomerByIDN: %v", err)
+	}
+	if got.ID != customer.ID || !got.CreatedAt.Equal(customer.CreatedAt) {
+		t.Errorf("GetCustomerByIDN = %+v, want %+v", got, customer)
+	}
+	if got.Created {
+		t.Error("Created = true on a read")
+	}
+}
+
+func testUpsertReturnsExisting(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	first, err := s.UpsertCustomer(ctx, "990101300123", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	second, err := s.UpsertCustomer(ctx, "990101300123", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("ID = %q, want the existing %q", second.ID, first.ID)
+	}
+	if !second.CreatedAt.Equal(first.CreatedAt) {
+		t.Errorf("CreatedAt = %v, want the existing %v", second.CreatedAt, first.CreatedAt)
+	}
+	if second.Created {
+		t.Error("Created = true for an existing customer")
+	}
+
+	other, err := s.UpsertCustomer(ctx, "880202400456", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if other.ID == first.ID {
+		t.Error("different IDNs share an ID")
+	}
+}
+
+func testUpsertConcurrent(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	const n = 10
+	customers := make([]string, n)
+	created := make([]bool, n)
+	errs := make([]error, n)
+
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			customer, err := s.UpsertCustomer(ctx, "990101300123", "")
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			customers[i], created[i] = customer.ID, customer.Created
+		}()
+	}
+	wg.Wait()
+
+	inserts := 0
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatalf("UpsertCustomer: %v", errs[i])
+		}
+		if customers[i] != customers[0] {
+			t.Errorf("concurrent upserts returned IDs %q and %q", customers[0], customers[i])
+		}
+		if created[i] {
+			inserts++
+		}
+	}
+	if inserts != 1 {
+		t.Errorf("%d upserts report Created, want 1", inserts)
+	}
+}
+
+func testInsert(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	want := &entity.Customer{
+		ID:        uuid.NewString(),
+		IDN:       "900101300126",
+		Name:      "Нурлан Касымов",
+		Address:   "г. Алматы, ул. Абая, д. 15",
+		CreatedAt: time.Date(2024, time.March, 1, 9, 30, 0, 0, time.UTC),
+	}
+	customer, err := s.InsertCustomer(ctx, want)
+	if err != nil {
+		t.Fatalf("InsertCustomer: %v", err)
+	}
+	if !customer.Created {
+		t.Error("Created = false for a new customer")
+	}
+
+	got, err := s.GetCustomerByIDN(ctx, want.IDN)
+	if err != nil {
+		t.Fatalf("GetCustomerByIDN: %v", err)
+	}
+	if got.ID != want.ID || got.Name != want.Name || got.Address != want.Address || !got.CreatedAt.Equal(want.CreatedAt) {
+		t.Errorf("GetCustomerByIDN = %+v, want %+v", got, want)
+	}
+}
+
+func testInsertExistingIDN(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	existing, err := s.UpsertCustomer(ctx, "900101300126", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	customer, err := s.InsertCustomer(ctx, &entity.Customer{
+		ID:        uuid.NewString(),
+		IDN:       "900101300126",
+		Name:      "Нурлан Касымов",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("InsertCustomer: %v", err)
+	}
+	if customer.Created || customer.ID != existing.ID || customer.Name != "" {
+		t.Errorf("InsertCustomer = %+v, want the existing %+v unchanged", customer, existing)
+	}
+}
+
+func testGetNotFound(t *testing.T, s storage.Storage) {
+	_, err := s.GetCustomerByIDN(context.Background(), "990101300123")
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetCustomerByIDN error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testUpsertRetryBySaga(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	saga := uuid.NewString()
+
+	first, err := s.UpsertCustomer(ctx, "990101300123", saga)
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	retry, err := s.UpsertCustomer(ctx, "990101300123", saga)
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if !retry.Created || retry.ID != first.ID {
+		t.Errorf("retry of the creating saga = %+v, want %s reported as created", retry, first.ID)
+	}
+
+	other, err := s.UpsertCustomer(ctx, "990101300123", uuid.NewString())
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if other.Created {
+		t.Error("Created = true for another saga")
+	}
+}
+
+func testDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	saga := uuid.NewString()
+
+	customer, err := s.UpsertCustomer(ctx, "990101300123", saga)
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if deleted, err := s.DeleteCustomer(ctx, customer.ID, saga); err != nil || !deleted {
+		t.Fatalf("DeleteCustomer = %t, %v, want deleted", deleted, err)
+	}
+	if _, err := s.GetCustomerByIDN(ctx, "990101300123"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetCustomerByIDN after delete error = %v, want sql.ErrNoRows", err)
+	}
+	if deleted, err := s.DeleteCustomer(ctx, customer.ID, saga); err != nil || deleted {
+		t.Errorf("DeleteCustomer of a deleted customer = %t, %v, want not deleted", deleted, err)
+	}
+
+	again, err := s.UpsertCustomer(ctx, "990101300123", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if !again.Created || again.ID == customer.ID {
+		t.Errorf("upsert after delete = %+v, want a new customer", again)
+	}
+}
+
+func testDeleteOtherSaga(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	customer, err := s.UpsertCustomer(ctx, "990101300123", uuid.NewString())
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	for _, saga := range []string{uuid.NewString(), ""} {
+		if deleted, err := s.DeleteCustomer(ctx, customer.ID, saga); err != nil || deleted {
+			t.Errorf("DeleteCustomer by saga %q = %t, %v, want kept", saga, deleted, err)
+		}
+	}
+
+	seeded, err := s.UpsertCustomer(ctx, "880202400456", "")
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if deleted, err := s.DeleteCustomer(ctx, seeded.ID, ""); err != nil || deleted {
+		t.Errorf("DeleteCustomer of a customer no saga created = %t, %v, want kept", deleted, err)
+	}
+
+	for _, idn := range []string{"990101300123", "880202400456"} {
+		if _, err := s.GetCustomerByIDN(ctx, idn); err != nil {
+			t.Errorf("GetCustomerByIDN(%s) after a refused delete: %v", idn, err)
+		}
+	}
+}
+
+func testDeleteUpsertedSince(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	saga := uuid.NewString()
+
+	customer, err := s.UpsertCustomer(ctx, "990101300123", saga)
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	// Another saga now relies on the customer, even after the creator retries
+	if _, err := s.UpsertCustomer(ctx, "990101300123", uuid.NewString()); err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if _, err := s.UpsertCustomer(ctx, "990101300123", saga); err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if deleted, err := s.DeleteCustomer(ctx, customer.ID, saga); err != nil || deleted {
+		t.Errorf("DeleteCustomer after another upsert = %t, %v, want kept", deleted, err)
+	}
+	if _, err := s.GetCustomerByIDN(ctx, "990101300123"); err != nil {
+		t.Errorf("GetCustomerByIDN after a refused delete: %v", err)
+	}
+}
+
+func testDeleteInvalidID(t *testing.T, s storage.Storage) {
+	if _, err := s.DeleteCustomer(context.Background(), "not-a-uuid", uuid.NewString()); err == nil {
+		t.Error("DeleteCustomer with an invalid ID succeeded")
+	}
+}
+
+func testTxCommit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var id string
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		customer, err := tx.UpsertCustomer(ctx, "990101300123", "")
+		if err != nil {
+			return err
+		}
+		id = customer.ID
+
+		got, err := tx.GetCustomerByIDN(ctx, "990101300123")
+		if err != nil {
+			return err
+		}
+		if got.ID != id {
+			t.Errorf("read in tx ID = %q, want %q", got.ID, id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	got, err := s.GetCustomerByIDN(ctx, "990101300123")
+	if err != nil {
+		t.Fatalf("GetCustomerByIDN after commit: %v", err)
+	}
+	if got.ID != id {
+		t.Errorf("ID after commit = %q, want %q", got.ID, id)
+	}
+}
+
+func testTxRollback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	saga := uuid.NewString()
+	existing, err := s.UpsertCustomer(ctx, "880202400456", saga)
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.UpsertCustomer(ctx, "990101300123", ""); err != nil {
+			return err
+		}
+		if _, err := tx.DeleteCustomer(ctx, existing.ID, saga); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithTx error = %v, want the error of fn", err)
+	}
+
+	if _, err := s.GetCustomerByIDN(ctx, "990101300123"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("customer inserted in a rolled back tx: error = %v", err)
+	}
+	if _, err := s.GetCustomerByIDN(ctx, "880202400456"); err != nil {
+		t.Errorf("customer deleted in a rolled back tx: %v", err)
+	}
+}
+
+func testTxNested(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		err := tx.WithTx(ctx, func(inner storage.Storage) error {
+			_, err := inner.UpsertCustomer(ctx, "990101300123", "")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithTx error = %v, want the error of fn", err)
+	}
+
+	if _, err := s.GetCustomerByIDN(ctx, "990101300123"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("nested tx was not rolled back with the outer one: error = %v", err)
+	}
+}
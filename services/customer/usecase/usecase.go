@@ -2,6 +2,8 @@ package usecase
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
 
@@ -16,7 +18,8 @@ type usecase struct {
 
 type Usecase interface {
 	GetCustomer(ctx context.Context, idn string) (*entity.Customer, error)
-	UpsertCustomer(ctx context.Context, idn string) (*entity.Customer, error)
+	UpsertCustomer(ctx context.Context, idn, sagaID string) (*entity.Customer, error)
+	DeleteCustomer(ctx context.Context, id, sagaID string) (bool, error)
 }
 
 func New(log *slog.Logger, storage storage.Storage) Usecase {
@@ -31,6 +34,10 @@ func (u *usecase) GetCustomer(ctx context.Context, idn string) (*entity.Customer
 
 	log.Info("getting customer from storage")
 	customer, err := u.storage.GetCustomerByIDN(ctx, idn)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Info("customer not found")
+		return nil, fmt.Errorf("%w: %s", entity.ErrNotFound, idn)
+	}
 	if err != nil {
 		log.Error("failed to get customer from storage", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to storage.GetCustomerByIDN: %w", err)
@@ -42,12 +49,12 @@ func (u *usecase) GetCustomer(ctx context.Context, idn string) (*entity.Customer
 	return customer, nil
 }
 
-func (u *usecase) UpsertCustomer(ctx context.Context, idn string) (*entity.Customer, error) {
-	log := u.log.With("method", "UpsertCustomer", "idn", idn)
+func (u *usecase) UpsertCustomer(ctx context.Context, idn, sagaID string) (*entity.Customer, error) {
+	log := u.log.With("method", "UpsertCustomer", "idn", idn, "saga_id", sagaID)
 
 	log.InfoContext(ctx, "upserting customer in storage")
 
-	customer, err := u.storage.UpsertCustomer(ctx, idn)
+	customer, err := u.storage.UpsertCustomer(ctx, idn, sagaID)
 	if err != nil {
 		log.ErrorContext(ctx, "failed to upsert customer in storage", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to storage.UpsertCustomer: %w", err)
@@ -59,3 +66,26 @@ func (u *usecase) UpsertCustomer(ctx context.Context, idn string) (*entity.Custo
 		slog.String("created_at", customer.CreatedAt.String()))
 	return customer, nil
 }
+
+func (u *usecase) DeleteCustomer(ctx context.Context, id, sagaID string) (bool, error) {
+	log := u.log.With("method", "DeleteCustomer", "customer_id", id, "saga_id", sagaID)
+
+	if sagaID == "" {
+		return false, fmt.Errorf("%w: saga_id is required", entity.ErrInvalidArgument)
+	}
+
+	log.InfoContext(ctx, "deleting customer from storage")
+
+	deleted, err := u.storage.DeleteCustomer(ctx, id, sagaID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to delete customer from storage", slog.String("error", err.Error()))
+		return false, fmt.Errorf("failed to storage.DeleteCustomer: %w", err)
+	}
+
+	if !deleted {
+		log.InfoContext(ctx, "customer kept, it was not created by the saga or is used by another one")
+		return false, nil
+	}
+	log.InfoContext(ctx, "customer deleted successfully")
+	return true, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen is returned without calling customer-service while the breaker is open
+var ErrCircuitOpen = status.Error(codes.Unavailable, "customer-service circuit breaker is open")
+
+// breaker is a consecutive-failure circuit breaker. After threshold transient
+// failures it fails fast for openTimeout, then lets a single probe call through.
+type breaker struct {
+	mu          sync.Mutex
+	state       breakerState
+	failures    int
+	threshold   int
+	openTimeout time.Duration
+	openedAt    time.Time
+	probing     bool
+	now         func() time.Time
+}
+
+func newBreaker(threshold int, openTimeout time.Duration) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+	}
+}
+
+// allow reports whether a call may proceed
+func (b *breaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return nil
+	case breakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of a call and returns the new state
+func (b *breaker) record(err error) breakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The caller gave up, which says nothing about customer-service
+	if status.Code(err) == codes.Canceled {
+		b.probing = false
+		return b.state
+	}
+
+	if !isTransient(err) {
+		b.state = breakerClosed
+		b.failures = 0
+		b.probing = false
+		return b.state
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.state = breakerOpen
+		b.openedAt = b.now()
+	}
+	b.probing = false
+	return b.state
+}
+
+// isTransient reports whether err means customer-service is unreachable or
+// overloaded, as opposed to rejecting the request itself
+func isTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	errUnavailable = status.Error(codes.Unavailable, "connection refused")
+	errInvalid     = status.Error(codes.InvalidArgument, "bad idn")
+)
+
+func newTestBreaker(threshold int) (*breaker, *time.Time) {
+	b := newBreaker(threshold, 10*time.Second)
+	now := time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+// call lets a call through the breaker if allowed and records err
+func call(b *breaker, err error) error {
+	if err := b.allow(); err != nil {
+		return err
+	}
+	b.record(err)
+	return nil
+}
+
+func TestBreakerOpens(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []error
+		want     breakerState
+	}{
+		{"below the threshold", []error{errUnavailable, errUnavailable}, breakerClosed},
+		{"threshold reached", []error{errUnavailable, errUnavailable, errUnavailable}, breakerOpen},
+		{"deadline counts", []error{errUnavailable, status.Error(codes.DeadlineExceeded, "slow"), errUnavailable}, breakerOpen},
+		{"success resets", []error{errUnavailable, errUnavailable, nil, errUnavailable, errUnavailable}, breakerClosed},
+		{"rejection resets", []error{errUnavailable, errUnavailable, errInvalid, errUnavailable}, breakerClosed},
+		{"cancellation ignored", []error{errUnavailable, errUnavailable, status.Error(codes.Canceled, "gone"), errUnavailable}, breakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newTestBreaker(3)
+			var state breakerState
+			for _, err := range tt.outcomes {
+				if err := b.allow(); err != nil {
+					t.Fatalf("allow = %v before the last outcome", err)
+				}
+				state = b.record(err)
+			}
+			if state != tt.want {
+				t.Errorf("state = %s, want %s", state, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe error
+		want  breakerState
+	}{
+		{"probe succeeds", nil, breakerClosed},
+		{"probe rejected by the server", errInvalid, breakerClosed},
+		{"probe fails", errUnavailable, breakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, now := newTestBreaker(1)
+			call(b, errUnavailable)
+
+			if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Fatalf("allow while open = %v, want ErrCircuitOpen", err)
+			}
+
+			*now = now.Add(10 * time.Second)
+			if err := b.allow(); err != nil {
+				t.Fatalf("allow after the open timeout = %v, want the probe let through", err)
+			}
+			// Only one probe at a time
+			if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("allow during the probe = %v, want ErrCircuitOpen", err)
+			}
+
+			if state := b.record(tt.probe); state != tt.want {
+				t.Errorf("state after the probe = %s, want %s", state, tt.want)
+			}
+			if tt.want == breakerOpen {
+				if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+					t.Errorf("allow after a failed probe = %v, want the open timeout to restart", err)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerCanceledProbe(t *testing.T) {
+	b, now := newTestBreaker(1)
+	call(b, errUnavailable)
+	*now = now.Add(10 * time.Second)
+
+	if err := call(b, status.FromContextError(context.Canceled).Err()); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	// The abandoned probe frees the slot for the next one
+	if err := b.allow(); err != nil {
+		t.Errorf("allow after a canceled probe = %v, want another probe", err)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b, _ := newTestBreaker(0)
+	for range 10 {
+		if err := call(b, errUnavailable); err != nil {
+			t.Fatalf("disabled breaker rejected a call: %v", err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+type cacheEntry struct {
+	idn      string
+	customer *customer.CustomerResponse
+	expires  time.Time
+}
+
+// customerCache is a size-bounded LRU of IDN -> customer with a TTL per entry.
+// Customers are only deleted by compensation of the saga that created them,
+// and customer-service refuses that once anyone else has upserted the customer.
+// A response that created the customer is therefore never cached or shared:
+// every other caller upserts it on customer-service itself, and only the
+// responses of those upserts are cached, on any replica.
+type customerCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	items map[string]*list.Element
+	lru   *list.List
+	now   func() time.Time
+
+	group singleflight.Group
+	// fetches tracks the IDNs being fetched, so a response that was in
+	// flight when the IDN was invalidated is not cached
+	fetches map[string]*fetchEpoch
+
+	hits   metric.Int64Counter
+	misses metric.Int64Counter
+}
+
+func newCustomerCache(size int, ttl time.Duration) (*customerCache, error) {
+	meter := otel.Meter(instrumentationName)
+
+	hits, err := meter.Int64Counter("customer_client.cache.hits",
+		metric.WithDescription("Customer lookups answered from the local cache"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache hits counter: %w", err)
+	}
+	misses, err := meter.Int64Counter("customer_client.cache.misses",
+		metric.WithDescription("Customer lookups that went to customer-service"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache misses counter: %w", err)
+	}
+
+	return &customerCache{
+		ttl:     ttl,
+		size:    size,
+		items:   make(map[string]*list.Element, size),
+		lru:     list.New(),
+		now:     time.Now,
+		fetches: map[string]*fetchEpoch{},
+		hits:    hits,
+		misses:  misses,
+	}, nil
+}
+
+// fetchEpoch counts the invalidations of an IDN while refs fetches of it run
+type fetchEpoch struct {
+	epoch uint64
+	refs  int
+}
+
+// begin registers a fetch of idn and returns the epoch to pass to end
+func (c *customerCache) begin(idn string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f, ok := c.fetches[idn]
+	if !ok {
+		f = &fetchEpoch{}
+		c.fetches[idn] = f
+	}
+	f.refs++
+	return f.epoch
+}
+
+// end unregisters a fetch of idn and caches its response, unless resp is nil
+// or idn was invalidated since begin returned epoch
+func (c *customerCache) end(idn string, epoch uint64, resp *customer.CustomerResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := c.fetches[idn]
+	if f.refs--; f.refs == 0 {
+		delete(c.fetches, idn)
+	}
+	if resp != nil && f.epoch == epoch {
+		c.putLocked(idn, resp)
+	}
+}
+
+func (c *customerCache) get(idn string) (*customer.CustomerResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[idn]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.lru.Remove(elem)
+		delete(c.items, idn)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.customer, true
+}
+
+// putLocked caches resp; c.mu must be held
+func (c *customerCache) putLocked(idn string, resp *customer.CustomerResponse) {
+	expires := c.now().Add(c.ttl)
+	if elem, ok := c.items[idn]; ok {
+		entry := elem.Value.(*cacheEntry)
+		entry.customer = resp
+		entry.expires = expires
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[idn] = c.lru.PushFront(&cacheEntry{idn: idn, customer: resp, expires: expires})
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).idn)
+	}
+}
+
+func (c *customerCache) invalidate(idn string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[idn]; ok {
+		c.lru.Remove(elem)
+		delete(c.items, idn)
+	}
+	if f, ok := c.fetches[idn]; ok {
+		f.epoch++
+	}
+	c.group.Forget(idn)
+}
+
+func (c *customerCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.items = make(map[string]*list.Element, c.size)
+	c.lru.Init()
+	for idn, f := range c.fetches {
+		f.epoch++
+		c.group.Forget(idn)
+	}
+}
+
+// upsert answers from the cache or calls fetch once for all concurrent callers
+// of the same IDN. The shared call is detached from the first caller's
+// cancellation so one abandoned request does not fail the others.
+func (c *customerCache) upsert(ctx context.Context, idn string,
+	fetch func(ctx context.Context) (*customer.CustomerResponse, error)) (*customer.CustomerResponse, error) {
+	span := trace.SpanFromContext(ctx)
+
+	if resp, ok := c.get(idn); ok {
+		c.hits.Add(ctx, 1)
+		span.SetAttributes(attribute.Bool("customer_client.cache_hit", true))
+		return notCreated(resp), nil
+	}
+	c.misses.Add(ctx, 1)
+	span.SetAttributes(attribute.Bool("customer_client.cache_hit", false))
+
+	ch := c.group.DoChan(idn, func() (any, error) {
+		epoch := c.begin(idn)
+		resp, err := fetch(context.WithoutCancel(ctx))
+		var cached *customer.CustomerResponse
+		if err == nil && !resp.GetCreated() {
+			cached = resp
+		}
+		c.end(idn, epoch, cached)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		resp := res.Val.(*customer.CustomerResponse)
+		// The creating saga may still delete the customer, so each caller
+		// upserts it itself: customer-service then keeps it for the others and
+		// tells the creator apart by its saga ID
+		if res.Shared && resp.GetCreated() {
+			return fetch(ctx)
+		}
+		return proto.Clone(resp).(*customer.CustomerResponse), nil
+	}
+}
+
+// notCreated copies a cached or shared response, clearing Created since this
+// caller did not insert the customer
+func notCreated(resp *customer.CustomerResponse) *customer.CustomerResponse {
+	clone := proto.Clone(resp).(*customer.CustomerResponse)
+	clone.Created = false
+	return clone
+}
+
+// UpsertCustomer shadows the generated client method with a read-through
+// cache keyed by IDN when the cache is enabled
+func (c *CustomerClient) UpsertCustomer(ctx context.Context, in *customer.UpsertCustomerRequest,
+	opts ...grpc.CallOption) (*customer.CustomerResponse, error) {
+	if c.cache == nil {
+		return c.CustomerClient.UpsertCustomer(ctx, in, opts...)
+	}
+	return c.cache.upsert(ctx, in.GetIdn(), func(ctx context.Context) (*customer.CustomerResponse, error) {
+		return c.CustomerClient.UpsertCustomer(ctx, in, opts...)
+	})
+}
+
+// Invalidate drops a cached customer; call it when a customer change event arrives
+func (c *CustomerClient) Invalidate(idn string) {
+	if c.cache != nil {
+		c.cache.invalidate(idn)
+	}
+}
+
+// Purge drops every cached customer, e.g. after losing the event stream
+func (c *CustomerClient) Purge() {
+	if c.cache != nil {
+		c.cache.purge()
+	}
+}
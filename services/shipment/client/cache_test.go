@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+)
+
+const testIDN = "990101300123"
+
+// fetcher counts the calls to customer-service and answers with resp
+type fetcher struct {
+	calls atomic.Int32
+	resp  *customer.CustomerResponse
+	err   error
+	// release, if set, blocks every call until it is closed
+	release chan struct{}
+}
+
+func (f *fetcher) fetch(context.Context) (*customer.CustomerResponse, error) {
+	f.calls.Add(1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.resp, nil
+}
+
+func newTestCache(t *testing.T, size int) (*customerCache, *time.Time) {
+	t.Helper()
+	c, err := newCustomerCache(size, time.Minute)
+	if err != nil {
+		t.Fatalf("newCustomerCache: %v", err)
+	}
+	now := time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+func existing(idn string) *customer.CustomerResponse {
+	return &customer.CustomerResponse{Id: "id-" + idn, Idn: idn}
+}
+
+func TestCacheHitMiss(t *testing.T) {
+	c, _ := newTestCache(t, 10)
+	f := &fetcher{resp: existing(testIDN)}
+
+	for i := range 3 {
+		resp, err := c.upsert(context.Background(), testIDN, f.fetch)
+		if err != nil {
+			t.Fatalf("upsert %d: %v", i+1, err)
+		}
+		if resp.GetId() != "id-"+testIDN {
+			t.Errorf("upsert %d = %v, want the customer", i+1, resp)
+		}
+	}
+	if got := f.calls.Load(); got != 1 {
+		t.Errorf("customer-service calls = %d, want 1 miss and 2 hits", got)
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	c, now := newTestCache(t, 10)
+	f := &fetcher{resp: existing(testIDN)}
+
+	c.upsert(context.Background(), testIDN, f.fetch)
+	*now = now.Add(time.Minute)
+	c.upsert(context.Background(), testIDN, f.fetch)
+	if got := f.calls.Load(); got != 1 {
+		t.Fatalf("calls at the TTL = %d, want 1", got)
+	}
+	*now = now.Add(time.Second)
+	c.upsert(context.Background(), testIDN, f.fetch)
+	if got := f.calls.Load(); got != 2 {
+		t.Errorf("calls after the TTL = %d, want 2", got)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	c, _ := newTestCache(t, 2)
+	fetchers := map[string]*fetcher{}
+	for _, idn := range []string{"1", "2", "1", "3"} {
+		if fetchers[idn] == nil {
+			fetchers[idn] = &fetcher{resp: existing(idn)}
+		}
+		c.upsert(context.Background(), idn, fetchers[idn].fetch)
+	}
+
+	// 2 is the least recently used when 3 is added
+	if _, ok := c.get("2"); ok {
+		t.Error("least recently used IDN still cached")
+	}
+	for _, idn := range []string{"1", "3"} {
+		if _, ok := c.get(idn); !ok {
+			t.Errorf("%s not cached", idn)
+		}
+	}
+}
+
+func TestCacheNotCached(t *testing.T) {
+	tests := []struct {
+		name string
+		f    *fetcher
+	}{
+		{"created", &fetcher{resp: &customer.CustomerResponse{Id: "id", Idn: testIDN, Created: true}}},
+		{"error", &fetcher{err: errors.New("unavailable")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, 10)
+			c.upsert(context.Background(), testIDN, tt.f.fetch)
+			c.upsert(context.Background(), testIDN, tt.f.fetch)
+			if got := tt.f.calls.Load(); got != 2 {
+				t.Errorf("calls = %d, want 2", got)
+			}
+		})
+	}
+}
+
+func TestCacheInvalidate(t *testing.T) {
+	c, _ := newTestCache(t, 10)
+	f := &fetcher{resp: existing(testIDN)}
+
+	c.upsert(context.Background(), testIDN, f.fetch)
+	c.invalidate(testIDN)
+	c.upsert(context.Background(), testIDN, f.fetch)
+	if got := f.calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want 2: invalidate did not drop the entry", got)
+	}
+
+	c.purge()
+	c.upsert(context.Background(), testIDN, f.fetch)
+	if got := f.calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3: purge did not drop the entry", got)
+	}
+}
+
+func TestCacheInvalidateInFlight(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *customerCache)
+	}{
+		{"invalidate", func(c *customerCache) { c.invalidate(testIDN) }},
+		{"purge", func(c *customerCache) { c.purge() }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, 10)
+			f := &fetcher{resp: existing(testIDN), release: make(chan struct{})}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c.upsert(context.Background(), testIDN, f.fetch)
+			}()
+			for f.calls.Load() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			// The change event arrives while the stale response is on its way
+			tt.invalidate(c)
+			close(f.release)
+			<-done
+
+			if _, ok := c.get(testIDN); ok {
+				t.Error("response fetched before the invalidation was cached")
+			}
+			if len(c.fetches) != 0 {
+				t.Errorf("fetches = %v, want none after the fetch ended", c.fetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package client_test
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/contract"
+	"github.com/aidosgal/transline-test/services/shipment/client"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const contractsDir = "../../../specs/contracts"
+
+const (
+	exampleCustomerID = "6f1c2d3e-4a5b-4c6d-8e7f-9a0b1c2d3e4f"
+	exampleCreatedAt  = "2025-01-01 09:00:00 +0000 UTC"
+	exampleSagaID     = "3b2a1c0d-9e8f-4a7b-8c6d-5e4f3a2b1c0d"
+)
+
+// TestCustomerContract records what shipment-service relies on from
+// customer-service; services/customer/server verifies it
+func TestCustomerContract(t *testing.T) {
+	mock := contract.NewMock(t, contractsDir, "shipment-service", "customer-service")
+
+	mock.Expect(contract.Expectation{
+		Description: "upsert of a new IDN creates the customer",
+		Given:       []contract.State{{Name: "no customer", Params: map[string]string{"idn": "990101300123"}}},
+		Method:      pb.Customer_UpsertCustomer_FullMethodName,
+		Request:     &pb.UpsertCustomerRequest{Idn: "990101300123", SagaId: exampleSagaID},
+		Response: &pb.CustomerResponse{
+			Id: exampleCustomerID, Idn: "990101300123", CreatedAt: exampleCreatedAt, Created: true,
+		},
+		Matchers: map[string]string{
+			"response.id":         contract.MatchUUID,
+			"response.created_at": contract.MatchNonEmpty,
+		},
+	})
+	mock.Expect(contract.Expectation{
+		Description: "upsert of a known IDN returns the existing customer",
+		Given:       []contract.State{{Name: "customer exists", Params: map[string]string{"idn": "880202400456"}}},
+		Method:      pb.Customer_UpsertCustomer_FullMethodName,
+		Request:     &pb.UpsertCustomerRequest{Idn: "880202400456", SagaId: exampleSagaID},
+		Response: &pb.CustomerResponse{
+			Id: exampleCustomerID, Idn: "880202400456", CreatedAt: exampleCreatedAt, Created: false,
+		},
+		Matchers: map[string]string{
+			"response.id":         contract.MatchStatePrefix + "customer_id",
+			"response.created_at": contract.MatchNonEmpty,
+		},
+	})
+	mock.Expect(contract.Expectation{
+		Description: "get of an unknown IDN is not found, so compensation knows nothing was created",
+		Given:       []contract.State{{Name: "no customer", Params: map[string]string{"idn": "660404600111"}}},
+		Method:      pb.Customer_GetCustomer_FullMethodName,
+		Request:     &pb.GetCustomerRequest{Idn: "660404600111"},
+		Code:        codes.NotFound,
+	})
+	mock.Expect(contract.Expectation{
+		Description: "delete of a customer created by the saga",
+		Given: []contract.State{{Name: "customer created by saga",
+			Params: map[string]string{"idn": "770303500789", "saga_id": exampleSagaID}}},
+		Method:   pb.Customer_DeleteCustomer_FullMethodName,
+		Request:  &pb.DeleteCustomerRequest{Id: exampleCustomerID, SagaId: exampleSagaID},
+		Response: &pb.DeleteCustomerResponse{Deleted: true},
+		Matchers: map[string]string{
+			"request.id": contract.MatchStatePrefix + "customer_id",
+		},
+	})
+	mock.Expect(contract.Expectation{
+		Description: "delete of a customer the saga did not create keeps it",
+		Given:       []contract.State{{Name: "customer exists", Params: map[string]string{"idn": "550505700222"}}},
+		Method:      pb.Customer_DeleteCustomer_FullMethodName,
+		Request:     &pb.DeleteCustomerRequest{Id: "1d2c3b4a-5f6e-4d7c-8b9a-0f1e2d3c4b5a", SagaId: exampleSagaID},
+		Response:    &pb.DeleteCustomerResponse{Deleted: false},
+		Matchers: map[string]string{
+			"request.id": contract.MatchStatePrefix + "customer_id",
+		},
+	})
+	mock.Expect(contract.Expectation{
+		Description: "delete of an unknown customer succeeds, so compensation can be repeated",
+		Method:      pb.Customer_DeleteCustomer_FullMethodName,
+		Request:     &pb.DeleteCustomerRequest{Id: "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", SagaId: exampleSagaID},
+		Response:    &pb.DeleteCustomerResponse{},
+	})
+
+	c := newClient(t, mock)
+	ctx := context.Background()
+
+	created, err := c.UpsertCustomer(ctx, &pb.UpsertCustomerRequest{Idn: "990101300123", SagaId: exampleSagaID})
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if !created.GetCreated() || created.GetId() == "" {
+		t.Errorf("upsert of a new IDN = %v, want a created customer with an ID", created)
+	}
+
+	existing, err := c.UpsertCustomer(ctx, &pb.UpsertCustomerRequest{Idn: "880202400456", SagaId: exampleSagaID})
+	if err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+	if existing.GetCreated() || existing.GetId() != exampleCustomerID {
+		t.Errorf("upsert of a known IDN = %v, want the existing customer, not created", existing)
+	}
+
+	if _, err := c.GetCustomer(ctx, &pb.GetCustomerRequest{Idn: "660404600111"}); status.Code(err) != codes.NotFound {
+		t.Errorf("GetCustomer of an unknown IDN error = %v, want NotFound", err)
+	}
+
+	deleted, err := c.DeleteCustomer(ctx, &pb.DeleteCustomerRequest{Id: exampleCustomerID, SagaId: exampleSagaID})
+	if err != nil || !deleted.GetDeleted() {
+		t.Errorf("DeleteCustomer = %v, %v, want deleted", deleted, err)
+	}
+	kept, err := c.DeleteCustomer(ctx, &pb.DeleteCustomerRequest{
+		Id: "1d2c3b4a-5f6e-4d7c-8b9a-0f1e2d3c4b5a", SagaId: exampleSagaID})
+	if err != nil || kept.GetDeleted() {
+		t.Errorf("DeleteCustomer of another caller's customer = %v, %v, want kept", kept, err)
+	}
+	if _, err := c.DeleteCustomer(ctx, &pb.DeleteCustomerRequest{
+		Id: "0a1b2c3d-4e5f-4a6b-8c7d-9e0f1a2b3c4d", SagaId: exampleSagaID}); err != nil {
+		t.Errorf("DeleteCustomer of an unknown customer: %v", err)
+	}
+}
+
+// newClient connects the real client to mock, without cache and retries so
+// every call reaches it once
+func newClient(t *testing.T, mock *contract.Mock) *client.CustomerClient {
+	cfg := &config.Shipment{}
+	if err := config.Load(cfg, "", config.ProfileTest); err != nil {
+		t.Fatalf("load config: %v", err)
+	}
+	cfg.Customer = config.EndpointConfig{URL: "contract-mock", Port: 9090}
+	cfg.CustomerClient.Resolver = config.ResolverPassthrough
+	cfg.CustomerClient.TLS = config.TLSConfig{}
+	cfg.CustomerClient.CacheSize = 0
+	cfg.CustomerClient.MaxAttempts = 1
+
+	c, err := client.New(slog.New(slog.DiscardHandler), cfg, mock.DialOption())
+	if err != nil {
+		t.Fatalf("client.New: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
@@ -2,35 +2,78 @@ package client
 
 import (
 	"fmt"
+	"log/slog"
 
 	"github.com/aidosgal/transline-test/pkg/config"
 	"github.com/aidosgal/transline-test/specs/proto/customer"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 type CustomerClient struct {
-	conn *grpc.ClientConn
+	conn  *grpc.ClientConn
+	cache *customerCache
 	customer.CustomerClient
 }
 
-func New(cfg *config.Config) (*CustomerClient, error) {
-	conn, err := grpc.NewClient(
-		fmt.Sprintf("%s:%d", "customer-service", cfg.CustomerService.Port),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+// New connects to customer-service as configured; extra options are applied
+// last, e.g. a context dialer for an in-process listener
+func New(log *slog.Logger, cfg *config.Shipment, extra ...grpc.DialOption) (*CustomerClient, error) {
+	target, opts, err := dialTarget(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	creds, err := transportCredentials(log, cfg.CustomerClient.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := serviceConfig(cfg.CustomerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := newResilience(cfg.CustomerClient)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		grpc.WithTransportCredentials(creds),
 		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithStatsHandler(attemptCounter{}),
+		grpc.WithDefaultServiceConfig(sc),
+		grpc.WithUnaryInterceptor(res.unaryInterceptor),
 	)
+	opts = append(opts, extra...)
+
+	conn, err := grpc.NewClient(target, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create grpc connection: %w", err)
 	}
 
+	var cache *customerCache
+	if cfg.CustomerClient.CacheSize > 0 && cfg.CustomerClient.CacheTTL > 0 {
+		cache, err = newCustomerCache(cfg.CustomerClient.CacheSize, cfg.CustomerClient.CacheTTL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
 	return &CustomerClient{
 		conn:           conn,
+		cache:          cache,
 		CustomerClient: customer.NewCustomerClient(conn),
 	}, nil
 }
 
+// Conn exposes the underlying connection, e.g. for health checks
+func (c *CustomerClient) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
 func (c *CustomerClient) Close() error {
 	if c.conn != nil {
 		return c.conn.Close()
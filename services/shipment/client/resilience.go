@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+const instrumentationName = "github.com/aidosgal/transline-test/services/shipment/client"
+
+// serviceConfig builds the gRPC service config: the load balancing policy and
+// a retry policy for every Customer RPC on transient codes. Both RPCs are
+// idempotent: UpsertCustomer returns the existing customer for a known IDN.
+func serviceConfig(cfg config.ClientConfig) (string, error) {
+	sc := map[string]any{}
+
+	switch cfg.LBPolicy {
+	case "":
+	case "round_robin", "pick_first":
+		sc["loadBalancingConfig"] = []map[string]any{{cfg.LBPolicy: map[string]any{}}}
+	default:
+		return "", fmt.Errorf("unknown lb policy %q, expected round_robin or pick_first", cfg.LBPolicy)
+	}
+
+	// gRPC requires at least two attempts in a retry policy
+	if cfg.MaxAttempts >= 2 {
+		sc["methodConfig"] = []map[string]any{{
+			"name": []map[string]string{{"service": customer.Customer_ServiceDesc.ServiceName}},
+			"retryPolicy": map[string]any{
+				"maxAttempts":          cfg.MaxAttempts,
+				"initialBackoff":       durationJSON(cfg.InitialBackoff),
+				"maxBackoff":           durationJSON(cfg.MaxBackoff),
+				"backoffMultiplier":    2.0,
+				"retryableStatusCodes": []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"},
+			},
+		}}
+	}
+
+	data, err := json.Marshal(sc)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal service config: %w", err)
+	}
+	return string(data), nil
+}
+
+// durationJSON formats a duration the way gRPC service configs expect ("0.1s")
+func durationJSON(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+type attemptsKey struct{}
+
+// resilience holds the per-call deadline, circuit breaker and retry telemetry
+// shared by every call on a connection
+type resilience struct {
+	timeout  time.Duration
+	breaker  *breaker
+	attempts metric.Int64Histogram
+	rejected metric.Int64Counter
+}
+
+func newResilience(cfg config.ClientConfig) (*resilience, error) {
+	meter := otel.Meter(instrumentationName)
+
+	attempts, err := meter.Int64Histogram("customer_client.attempts",
+		metric.WithDescription("Attempts made per customer-service call, including retries"),
+		metric.WithExplicitBucketBoundaries(1, 2, 3, 4, 5))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attempts histogram: %w", err)
+	}
+	rejected, err := meter.Int64Counter("customer_client.breaker_rejections",
+		metric.WithDescription("Calls rejected because the circuit breaker was open"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rejections counter: %w", err)
+	}
+
+	return &resilience{
+		timeout:  cfg.Timeout,
+		breaker:  newBreaker(cfg.BreakerFailures, cfg.BreakerOpen),
+		attempts: attempts,
+		rejected: rejected,
+	}, nil
+}
+
+// unaryInterceptor runs once per logical call, outside of gRPC retries
+func (r *resilience) unaryInterceptor(ctx context.Context, method string, req, reply any,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	span := trace.SpanFromContext(ctx)
+	methodAttr := attribute.String("rpc.method", method)
+
+	if err := r.breaker.allow(); err != nil {
+		r.rejected.Add(ctx, 1, metric.WithAttributes(methodAttr))
+		span.AddEvent("customer client circuit open", trace.WithAttributes(methodAttr))
+		return err
+	}
+
+	if r.timeout > 0 {
+		if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > r.timeout {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+		}
+	}
+
+	attempts := &atomic.Int64{}
+	ctx = context.WithValue(ctx, attemptsKey{}, attempts)
+
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	state := r.breaker.record(err)
+
+	n := attempts.Load()
+	r.attempts.Record(ctx, n, metric.WithAttributes(
+		methodAttr,
+		attribute.String("rpc.grpc.status_code", status.Code(err).String()),
+	))
+	span.SetAttributes(
+		attribute.Int64("customer_client.attempts", n),
+		attribute.String("customer_client.breaker_state", state.String()),
+	)
+	return err
+}
+
+// attemptCounter is a stats handler that counts the attempts of each call,
+// since gRPC retries happen below the interceptor
+type attemptCounter struct{}
+
+func (attemptCounter) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptCounter) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if _, ok := s.(*stats.Begin); !ok {
+		return
+	}
+	attempts, ok := ctx.Value(attemptsKey{}).(*atomic.Int64)
+	if !ok {
+		return
+	}
+	if n := attempts.Add(1); n > 1 {
+		trace.SpanFromContext(ctx).AddEvent("customer client retry",
+			trace.WithAttributes(attribute.Int64("attempt", n)))
+	}
+}
+
+func (attemptCounter) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (attemptCounter) HandleConn(context.Context, stats.ConnStats) {}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+)
+
+func TestServiceConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       config.ClientConfig
+		wantRetry bool
+		wantErr   bool
+	}{
+		{"retries", config.ClientConfig{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, LBPolicy: "round_robin"}, true, false},
+		{"single attempt", config.ClientConfig{MaxAttempts: 1, LBPolicy: "pick_first"}, false, false},
+		{"unknown lb policy", config.ClientConfig{MaxAttempts: 1, LBPolicy: "random"}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, err := serviceConfig(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("serviceConfig error = %v, want error %t", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var parsed struct {
+				LoadBalancingConfig []map[string]any `json:"loadBalancingConfig"`
+				MethodConfig        []struct {
+					RetryPolicy struct {
+						MaxAttempts    int    `json:"maxAttempts"`
+						InitialBackoff string `json:"initialBackoff"`
+						MaxBackoff     string `json:"maxBackoff"`
+					} `json:"retryPolicy"`
+				} `json:"methodConfig"`
+			}
+			if err := json.Unmarshal([]byte(sc), &parsed); err != nil {
+				t.Fatalf("unmarshal %s: %v", sc, err)
+			}
+			if len(parsed.LoadBalancingConfig) != 1 || parsed.LoadBalancingConfig[0][tt.cfg.LBPolicy] == nil {
+				t.Errorf("loadBalancingConfig = %v, want %s", parsed.LoadBalancingConfig, tt.cfg.LBPolicy)
+			}
+			if got := len(parsed.MethodConfig) == 1; got != tt.wantRetry {
+				t.Fatalf("retry policy = %t, want %t: %s", got, tt.wantRetry, sc)
+			}
+			if tt.wantRetry {
+				p := parsed.MethodConfig[0].RetryPolicy
+				if p.MaxAttempts != 3 || p.InitialBackoff != "0.1s" || p.MaxBackoff != "1s" {
+					t.Errorf("retryPolicy = %+v, want 3 attempts from 0.1s to 1s", p)
+				}
+			}
+		})
+	}
+}
+
+func newTestResilience(t *testing.T, cfg config.ClientConfig) *resilience {
+	t.Helper()
+	r, err := newResilience(cfg)
+	if err != nil {
+		t.Fatalf("newResilience: %v", err)
+	}
+	return r
+}
+
+func TestInterceptorDeadline(t *testing.T) {
+	r := newTestResilience(t, config.ClientConfig{Timeout: time.Second, BreakerFailures: 5, BreakerOpen: time.Second})
+
+	tests := []struct {
+		name   string
+		parent time.Duration
+		want   time.Duration
+	}{
+		{"none", 0, time.Second},
+		{"later", time.Minute, time.Second},
+		{"earlier", 100 * time.Millisecond, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.parent > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.parent)
+				defer cancel()
+			}
+
+			var left time.Duration
+			invoker := func(ctx context.Context, _ string, _, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+				deadline, ok := ctx.Deadline()
+				if !ok {
+					t.Fatal("call without a deadline")
+				}
+				left = time.Until(deadline)
+				return nil
+			}
+			if err := r.unaryInterceptor(ctx, "/customer.Customer/GetCustomer", nil, nil, nil, invoker); err != nil {
+				t.Fatalf("unaryInterceptor: %v", err)
+			}
+			if left > tt.want || left < tt.want-50*time.Millisecond {
+				t.Errorf("time left = %s, want about %s", left, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptorBreaker(t *testing.T) {
+	r := newTestResilience(t, config.ClientConfig{Timeout: time.Second, BreakerFailures: 2, BreakerOpen: time.Minute})
+
+	var calls int
+	invoker := func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+		calls++
+		return errUnavailable
+	}
+	for range 2 {
+		r.unaryInterceptor(context.Background(), "/customer.Customer/UpsertCustomer", nil, nil, nil, invoker)
+	}
+
+	err := r.unaryInterceptor(context.Background(), "/customer.Customer/UpsertCustomer", nil, nil, nil, invoker)
+	if err != ErrCircuitOpen {
+		t.Errorf("error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2: the open breaker called customer-service", calls)
+	}
+}
+
+// flakyServer fails the first failures calls with code
+type flakyServer struct {
+	customer.UnimplementedCustomerServer
+	calls    atomic.Int32
+	failures int32
+	code     codes.Code
+}
+
+func (s *flakyServer) GetCustomer(context.Context, *customer.GetCustomerRequest) (*customer.CustomerResponse, error) {
+	if s.calls.Add(1) <= s.failures {
+		return nil, status.Error(s.code, "failing")
+	}
+	return &customer.CustomerResponse{Id: "id", Idn: testIDN}, nil
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		code      codes.Code
+		wantCode  codes.Code
+		wantCalls int32
+	}{
+		{"recovers", 2, codes.Unavailable, codes.OK, 3},
+		{"gives up", 5, codes.Unavailable, codes.Unavailable, 3},
+		{"not retried", 5, codes.InvalidArgument, codes.InvalidArgument, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &flakyServer{failures: tt.failures, code: tt.code}
+			lis := bufconn.Listen(1 << 20)
+			grpcServer := grpc.NewServer()
+			customer.RegisterCustomerServer(grpcServer, server)
+			go grpcServer.Serve(lis)
+			t.Cleanup(grpcServer.Stop)
+
+			cfg := &config.Shipment{}
+			if err := config.Load(cfg, "", config.ProfileTest); err != nil {
+				t.Fatalf("load config: %v", err)
+			}
+			cfg.Customer = config.EndpointConfig{URL: "bufnet", Port: 9090}
+			cfg.CustomerClient.Resolver = config.ResolverPassthrough
+			cfg.CustomerClient.MaxAttempts = 3
+			cfg.CustomerClient.InitialBackoff, cfg.CustomerClient.MaxBackoff = time.Millisecond, 5*time.Millisecond
+
+			c, err := New(slog.New(slog.DiscardHandler), cfg,
+				grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+					return lis.DialContext(ctx)
+				}))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			t.Cleanup(func() { c.Close() })
+
+			_, err = c.GetCustomer(context.Background(), &customer.GetCustomerRequest{Idn: testIDN})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %s, want %s", code, tt.wantCode)
+			}
+			if calls := server.calls.Load(); calls != tt.wantCalls {
+				t.Errorf("server calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestAttemptCounter(t *testing.T) {
+	attempts := &atomic.Int64{}
+	ctx := context.WithValue(context.Background(), attemptsKey{}, attempts)
+
+	for range 3 {
+		attemptCounter{}.HandleRPC(ctx, &stats.Begin{})
+		attemptCounter{}.HandleRPC(ctx, &stats.End{})
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3: one per Begin", n)
+	}
+	// Calls that did not go through the interceptor are not counted
+	attemptCounter{}.HandleRPC(context.Background(), &stats.Begin{})
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"github.com/aidosgal/transline-test/pkg/certs"
+	"github.com/aidosgal/transline-test/pkg/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+)
+
+const (
+	ResolverDNS         = config.ResolverDNS
+	ResolverStatic      = config.ResolverStatic
+	ResolverPassthrough = config.ResolverPassthrough
+)
+
+// dialTarget resolves the customer-service endpoint from config into a gRPC
+// target plus the dial options its resolver needs
+func dialTarget(cfg *config.Shipment) (string, []grpc.DialOption, error) {
+	address := hostPort(cfg.Customer.URL, cfg.Customer.Port)
+
+	switch cfg.CustomerClient.Resolver {
+	case ResolverDNS, "":
+		return "dns:///" + address, nil, nil
+	case ResolverPassthrough:
+		return "passthrough:///" + address, nil, nil
+	case ResolverStatic:
+		if len(cfg.CustomerClient.Targets) == 0 {
+			return "", nil, errors.New("static resolver requires at least one target")
+		}
+		addrs := make([]resolver.Address, len(cfg.CustomerClient.Targets))
+		for i, target := range cfg.CustomerClient.Targets {
+			addrs[i] = resolver.Address{Addr: hostPort(target, cfg.Customer.Port)}
+		}
+		r := manual.NewBuilderWithScheme("customer-static")
+		r.InitialState(resolver.State{Addresses: addrs})
+		return r.Scheme() + ":///customer-service", []grpc.DialOption{grpc.WithResolvers(r)}, nil
+	default:
+		return "", nil, fmt.Errorf("unknown resolver %q, expected one of %s, %s, %s",
+			cfg.CustomerClient.Resolver, ResolverDNS, ResolverStatic, ResolverPassthrough)
+	}
+}
+
+// hostPort appends the default port unless address already has one
+func hostPort(address string, port int) string {
+	if _, _, err := net.SplitHostPort(address); err == nil {
+		return address
+	}
+	return net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// transportCredentials returns TLS credentials when enabled, presenting a
+// client certificate for mTLS if one is configured, and plaintext otherwise
+func transportCredentials(log *slog.Logger, cfg config.TLSConfig) (credentials.TransportCredentials, error) {
+	if !cfg.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsConfig, err := certs.ClientTLS(log, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
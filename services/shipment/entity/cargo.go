@@ -0,0 +1,187 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type CargoType string
+
+const (
+	CargoGeneral               CargoType = "GENERAL"
+	CargoFragile               CargoType = "FRAGILE"
+	CargoDangerous             CargoType = "DANGEROUS"
+	CargoTemperatureControlled CargoType = "TEMPERATURE_CONTROLLED"
+)
+
+// Limits of a single shipment, one semi-trailer
+const (
+	MaxWeightKg     = 40000
+	MaxVolumeM3     = 120
+	MaxPackages     = 10000
+	MaxDimensionCm  = 1400
+	MinTemperatureC = -30
+	MaxTemperatureC = 30
+)
+
+// DangerousClasses are the ADR classes and divisions of dangerous goods
+var DangerousClasses = []string{
+	"1", "2.1", "2.2", "2.3", "3", "4.1", "4.2", "4.3", "5.1", "5.2", "6.1", "6.2", "7", "8", "9",
+}
+
+var unNumberPattern = regexp.MustCompile(`^UN\d{4}$`)
+
+type (
+	// Cargo is what a shipment carries
+	Cargo struct {
+		Type CargoType `json:"type"`
+		// WeightKg is the gross weight, packaging included
+		WeightKg      float64   `json:"weight_kg"`
+		VolumeM3      float64   `json:"volume_m3"`
+		DeclaredValue int       `json:"declared_value"`
+		PackageCount  int       `json:"package_count"`
+		Packages      []Package `json:"packages,omitempty"`
+		// DangerousClass and UNNumber are set for DANGEROUS only
+		DangerousClass string `json:"dangerous_class,omitempty"`
+		UNNumber       string `json:"un_number,omitempty"`
+		// TemperatureMinC and TemperatureMaxC are set for TEMPERATURE_CONTROLLED only
+		TemperatureMinC *float64 `json:"temperature_min_c,omitempty"`
+		TemperatureMaxC *float64 `json:"temperature_max_c,omitempty"`
+	}
+
+	// Package is one kind of package with its outer dimensions
+	Package struct {
+		LengthCm int `json:"length_cm"`
+		WidthCm  int `json:"width_cm"`
+		HeightCm int `json:"height_cm"`
+		Quantity int `json:"quantity"`
+	}
+)
+
+// ValidationError lists every problem of a request
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid request: " + strings.Join(e.Problems, "; ")
+}
+
+// validator collects every problem so they can be reported together
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, key, format string, args ...any) {
+	if !ok {
+		v.problems = append(v.problems, key+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: v.problems}
+}
+
+// Validate checks the request; cargo is optional
+func (r *CreateReq) Validate() error {
+	v := &validator{}
+	if r.QuoteID != "" {
+		validateQuoteID(v, "quote_id", r.QuoteID)
+		v.check(r.Route == "", "route", "must be omitted with quote_id, the quote sets it")
+		v.check(r.Price == 0, "price", "must be omitted with quote_id, the quote sets it")
+		v.check(r.Cargo == nil, "cargo", "must be omitted with quote_id, the quote sets it")
+	}
+	if r.Cargo != nil {
+		r.Cargo.validate(v, "cargo")
+	}
+	if r.PickupWindow != nil {
+		r.PickupWindow.validate(v, "pickup_window")
+	}
+	if r.DeliveryWindow != nil {
+		r.DeliveryWindow.validate(v, "delivery_window")
+	}
+	if r.PickupWindow != nil && r.DeliveryWindow != nil {
+		v.check(r.DeliveryWindow.Until.After(r.PickupWindow.From), "delivery_window.until", "must be after pickup_window.from")
+	}
+	return v.err()
+}
+
+func (c *Cargo) validate(v *validator, key string) {
+	types := []CargoType{CargoGeneral, CargoFragile, CargoDangerous, CargoTemperatureControlled}
+	v.check(slices.Contains(types, c.Type), key+".type", "%q is not one of %v", c.Type, types)
+	v.check(c.WeightKg > 0 && c.WeightKg <= MaxWeightKg, key+".weight_kg", "must be in (0, %d], got %g", MaxWeightKg, c.WeightKg)
+	v.check(c.VolumeM3 > 0 && c.VolumeM3 <= MaxVolumeM3, key+".volume_m3", "must be in (0, %d], got %g", MaxVolumeM3, c.VolumeM3)
+	v.check(c.DeclaredValue >= 0, key+".declared_value", "must not be negative")
+	v.check(c.PackageCount >= 1 && c.PackageCount <= MaxPackages, key+".package_count", "must be in [1, %d], got %d", MaxPackages, c.PackageCount)
+
+	if len(c.Packages) > 0 {
+		quantity := 0
+		volume := 0.0
+		for i, p := range c.Packages {
+			pkey := fmt.Sprintf("%s.packages[%d]", key, i)
+			for _, d := range []struct {
+				name  string
+				value int
+			}{{"length_cm", p.LengthCm}, {"width_cm", p.WidthCm}, {"height_cm", p.HeightCm}} {
+				v.check(d.value > 0 && d.value <= MaxDimensionCm, pkey+"."+d.name, "must be in (0, %d], got %d", MaxDimensionCm, d.value)
+			}
+			v.check(p.Quantity >= 1, pkey+".quantity", "must be at least 1")
+			quantity += p.Quantity
+			volume += p.VolumeM3()
+		}
+		v.check(quantity == c.PackageCount, key+".packages", "quantities add up to %d, package_count is %d", quantity, c.PackageCount)
+		// 1% covers rounding of the declared volume
+		v.check(volume <= c.VolumeM3*1.01, key+".packages", "occupy %.3f m³, more than volume_m3 %g", volume, c.VolumeM3)
+	}
+
+	switch c.Type {
+	case CargoFragile:
+		v.check(c.DeclaredValue > 0, key+".declared_value", "is required for FRAGILE cargo")
+	case CargoDangerous:
+		v.check(slices.Contains(DangerousClasses, c.DangerousClass), key+".dangerous_class",
+			"%q is not an ADR class, expected one of %v", c.DangerousClass, DangerousClasses)
+		v.check(unNumberPattern.MatchString(c.UNNumber), key+".un_number", "%q is not a UN number like UN1203", c.UNNumber)
+	case CargoTemperatureControlled:
+		v.check(c.TemperatureMinC != nil && c.TemperatureMaxC != nil, key+".temperature_min_c",
+			"temperature_min_c and temperature_max_c are required for TEMPERATURE_CONTROLLED cargo")
+		if c.TemperatureMinC != nil && c.TemperatureMaxC != nil {
+			v.check(*c.TemperatureMinC <= *c.TemperatureMaxC, key+".temperature_min_c", "must not exceed temperature_max_c")
+			v.check(*c.TemperatureMinC >= MinTemperatureC && *c.TemperatureMaxC <= MaxTemperatureC, key+".temperature_min_c",
+				"the range must be within [%d, %d] °C", MinTemperatureC, MaxTemperatureC)
+		}
+	}
+	if c.Type != CargoDangerous {
+		v.check(c.DangerousClass == "" && c.UNNumber == "", key+".dangerous_class", "is only allowed for DANGEROUS cargo")
+	}
+	if c.Type != CargoTemperatureControlled {
+		v.check(c.TemperatureMinC == nil && c.TemperatureMaxC == nil, key+".temperature_min_c", "is only allowed for TEMPERATURE_CONTROLLED cargo")
+	}
+}
+
+// VolumeM3 is the outer volume of all packages of this kind
+func (p Package) VolumeM3() float64 {
+	return float64(p.LengthCm) * float64(p.WidthCm) * float64(p.HeightCm) * float64(p.Quantity) / 1e6
+}
+
+// Clone returns a deep copy, nil for nil
+func (c *Cargo) Clone() *Cargo {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	clone.Packages = slices.Clone(c.Packages)
+	if c.TemperatureMinC != nil {
+		min := *c.TemperatureMinC
+		clone.TemperatureMinC = &min
+	}
+	if c.TemperatureMaxC != nil {
+		max := *c.TemperatureMaxC
+		clone.TemperatureMaxC = &max
+	}
+	return &clone
+}
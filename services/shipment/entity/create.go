@@ -5,6 +5,14 @@ type (
 		Route    string            `json:"route"`
 		Price    int               `json:"price"`
 		Customer CreateCustomerReq `json:"customer"`
+		Cargo    *Cargo            `json:"cargo,omitempty"`
+		// QuoteID creates the shipment from a quote, which sets the route,
+		// price and cargo; the request must omit them
+		QuoteID string `json:"quote_id,omitempty"`
+		// PickupWindow and DeliveryWindow are planned from the route when
+		// omitted
+		PickupWindow   *Window `json:"pickup_window,omitempty"`
+		DeliveryWindow *Window `json:"delivery_window,omitempty"`
 	}
 
 	CreateCustomerReq struct {
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrQuoteNotFound = errors.New("quote not found")
+	ErrQuoteExpired  = errors.New("quote has expired")
+	ErrQuoteUsed     = errors.New("quote has already been used")
+	ErrQuoteCustomer = errors.New("quote was issued to another customer")
+	// ErrNoLane means the tariff has no price for the route
+	ErrNoLane = errors.New("no tariff lane for the route")
+)
+
+// RouteSeparator separates the origin and destination of a route
+const RouteSeparator = "→"
+
+type (
+	QuoteReq struct {
+		Route    string            `json:"route"`
+		Customer CreateCustomerReq `json:"customer"`
+		Cargo    *Cargo            `json:"cargo"`
+	}
+
+	// Quote is a price for a route, customer and cargo, valid until
+	// ExpiresAt; a shipment created from it keeps its total
+	Quote struct {
+		ID       string            `json:"id"`
+		Route    string            `json:"route"`
+		Customer CreateCustomerReq `json:"customer"`
+		Cargo    *Cargo            `json:"cargo"`
+		// DistanceKm is the road distance of the route, 0 if a stop is not
+		// in the gazetteer
+		DistanceKm int         `json:"distance_km,omitempty"`
+		Items      []QuoteItem `json:"items"`
+		Total      int         `json:"total"`
+		Currency   string      `json:"currency"`
+		// Tariff names the tariff the quote was priced with
+		Tariff    string    `json:"tariff"`
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresAt time.Time `json:"expires_at"`
+		// ShipmentID is set once a shipment was created from the quote
+		ShipmentID string `json:"shipment_id,omitempty"`
+	}
+
+	// QuoteItem is one line of a quote; discounts are negative
+	QuoteItem struct {
+		Code        string `json:"code"`
+		Description string `json:"description"`
+		Amount      int    `json:"amount"`
+	}
+)
+
+// Quote item codes
+const (
+	ItemBase             = "base"
+	ItemWeight           = "weight"
+	ItemCargoSurcharge   = "cargo_surcharge"
+	ItemFuelSurcharge    = "fuel_surcharge"
+	ItemMinimumCharge    = "minimum_charge"
+	ItemCustomerDiscount = "customer_discount"
+)
+
+// Validate checks the request; unlike a shipment, a quote needs cargo
+func (r *QuoteReq) Validate() error {
+	v := &validator{}
+	validateRoute(v, "route", r.Route)
+	v.check(r.Customer.IDN != "", "customer.idn", "must not be empty")
+	v.check(r.Cargo != nil, "cargo", "is required for a quote")
+	if r.Cargo != nil {
+		r.Cargo.validate(v, "cargo")
+	}
+	return v.err()
+}
+
+// Expired reports whether the quote can no longer be used at now
+func (q *Quote) Expired(now time.Time) bool {
+	return !now.Before(q.ExpiresAt)
+}
+
+// SplitRoute returns the origin and destination of a route
+func SplitRoute(route string) (string, string, bool) {
+	origin, destination, ok := strings.Cut(route, RouteSeparator)
+	origin, destination = strings.TrimSpace(origin), strings.TrimSpace(destination)
+	return origin, destination, ok && origin != "" && destination != ""
+}
+
+func validateRoute(v *validator, key, route string) {
+	_, _, ok := SplitRoute(route)
+	v.check(ok, key, "%q is not ORIGIN%sDESTINATION", route, RouteSeparator)
+}
+
+func validateQuoteID(v *validator, key, id string) {
+	_, err := uuid.Parse(id)
+	v.check(err == nil, key, "%q is not a UUID", id)
+}
@@ -0,0 +1,41 @@
+package entity
+
+import (
+	"time"
+)
+
+type SagaState string
+
+const (
+	SagaStarted          SagaState = "STARTED"
+	SagaCustomerUpserted SagaState = "CUSTOMER_UPSERTED"
+	SagaCompleted        SagaState = "COMPLETED"
+	SagaCompensating     SagaState = "COMPENSATING"
+	SagaCompensated      SagaState = "COMPENSATED"
+)
+
+const SagaTypeCreateShipment = "create_shipment"
+
+type (
+	Saga struct {
+		ID              string    `json:"id"`
+		Type            string    `json:"type"`
+		State           SagaState `json:"state"`
+		Request         CreateReq `json:"request"`
+		ShipmentID      string    `json:"shipment_id"`
+		CustomerID      string    `json:"customer_id"`
+		CustomerCreated bool      `json:"customer_created"`
+		Error           string    `json:"error"`
+		Attempts        int       `json:"attempts"`
+		CreatedAt       time.Time `json:"created_at"`
+		UpdatedAt       time.Time `json:"updated_at"`
+
+		// Shipment is the inserted row, set when the saga completes in this process
+		Shipment *Shipment `json:"-"`
+	}
+)
+
+// Terminal reports whether the saga needs no further work
+func (s *Saga) Terminal() bool {
+	return s.State == SagaCompleted || s.State == SagaCompensated
+}
@@ -4,6 +4,16 @@ import (
 	"time"
 )
 
+// Shipment statuses, in the order a delivered shipment goes through them
+const (
+	StatusCreated   = "CREATED"
+	StatusPickedUp  = "PICKED_UP"
+	StatusInTransit = "IN_TRANSIT"
+	StatusDelivered = "DELIVERED"
+	// StatusCancelled follows CREATED or PICKED_UP
+	StatusCancelled = "CANCELLED"
+)
+
 type (
 	Shipment struct {
 		ID         string    `json:"id"`
@@ -11,6 +21,23 @@ type (
 		Price      int       `json:"price"`
 		Status     string    `json:"status"`
 		CustomerID string    `json:"customer_id"`
+		Cargo      *Cargo    `json:"cargo,omitempty"`
+		QuoteID    string    `json:"quote_id,omitempty"`
 		CreatedAt  time.Time `json:"created_at"`
+		// TrackingNumber identifies the shipment on the public tracking page
+		TrackingNumber string `json:"tracking_number,omitempty"`
+
+		PickupWindow   *Window `json:"pickup_window,omitempty"`
+		DeliveryWindow *Window `json:"delivery_window,omitempty"`
+		// ETA is the estimated delivery time of an open shipment
+		ETA *time.Time `json:"eta,omitempty"`
+		// SLAStatus is set for shipments with a delivery window
+		SLAStatus string `json:"sla_status,omitempty"`
+	}
+
+	// StatusChange is one entry of the status history of a shipment
+	StatusChange struct {
+		Status    string    `json:"status"`
+		ChangedAt time.Time `json:"changed_at"`
 	}
 )
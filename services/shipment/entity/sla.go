@@ -0,0 +1,97 @@
+package entity
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SLA statuses of a shipment with a planned delivery window
+const (
+	SLAOnTrack = "ON_TRACK"
+	// SLAAtRisk means the ETA is past the delivery window or the pickup
+	// window passed without a pickup
+	SLAAtRisk = "AT_RISK"
+	// SLABreached means the delivery window passed before delivery; it is final
+	SLABreached = "BREACHED"
+)
+
+// SLAStatuses are the values of Shipment.SLAStatus
+var SLAStatuses = []string{SLAOnTrack, SLAAtRisk, SLABreached}
+
+type (
+	ListResp struct {
+		Shipments []*Shipment `json:"shipments"`
+	}
+
+	// Window is a planned period; Until is the deadline
+	Window struct {
+		From  time.Time `json:"from"`
+		Until time.Time `json:"until"`
+	}
+
+	// LaneTransit summarises the pickup-to-delivery times of the shipments
+	// delivered on a route
+	LaneTransit struct {
+		Samples int
+		Median  time.Duration
+	}
+
+	// SLABreach is published when a shipment misses its delivery window. It is
+	// published before the status is stored, so delivery is at-least-once:
+	// a failed store or two replicas checking at once may repeat it, and
+	// receivers should deduplicate by ShipmentID.
+	SLABreach struct {
+		ShipmentID string     `json:"shipment_id"`
+		Route      string     `json:"route"`
+		Status     string     `json:"status"`
+		CustomerID string     `json:"customer_id"`
+		Deadline   time.Time  `json:"deadline"`
+		ETA        *time.Time `json:"eta,omitempty"`
+		DetectedAt time.Time  `json:"detected_at"`
+	}
+
+	// ShipmentFilter selects the shipments of a listing; empty fields match
+	// all, a zero Limit is DefaultListLimit
+	ShipmentFilter struct {
+		Status     string
+		SLAStatus  string
+		CustomerID string
+		Limit      int
+		Offset     int
+	}
+)
+
+// Listing limits
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// Open reports whether the shipment is still to be delivered
+func (s *Shipment) Open() bool {
+	return s.Status != StatusDelivered && s.Status != StatusCancelled
+}
+
+// Validate checks the filter values and the page bounds
+func (f *ShipmentFilter) Validate() error {
+	v := &validator{}
+	statuses := []string{StatusCreated, StatusPickedUp, StatusInTransit, StatusDelivered, StatusCancelled}
+	v.check(f.Status == "" || slices.Contains(statuses, f.Status), "status", "%q is not one of %v", f.Status, statuses)
+	v.check(f.SLAStatus == "" || slices.Contains(SLAStatuses, f.SLAStatus), "sla_status",
+		"%q is not one of %v", f.SLAStatus, SLAStatuses)
+	if f.CustomerID != "" {
+		_, err := uuid.Parse(f.CustomerID)
+		v.check(err == nil, "customer_id", "%q is not a UUID", f.CustomerID)
+	}
+	v.check(f.Limit >= 0 && f.Limit <= MaxListLimit, "limit",
+		"must be in [1, %d], or 0 for the default %d, got %d", MaxListLimit, DefaultListLimit, f.Limit)
+	v.check(f.Offset >= 0, "offset", "must not be negative")
+	return v.err()
+}
+
+func (w *Window) validate(v *validator, key string) {
+	v.check(!w.From.IsZero() && !w.Until.IsZero(), key, "from and until are required")
+	v.check(w.Until.After(w.From), key+".until", "must be after from")
+}
@@ -0,0 +1,125 @@
+package entity
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CustomerType is the kind of customer discounts apply to, told apart by the IDN
+type CustomerType string
+
+const (
+	CustomerIndividual CustomerType = "INDIVIDUAL"
+	CustomerBusiness   CustomerType = "BUSINESS"
+)
+
+// AnyEndpoint matches every city in a lane
+const AnyEndpoint = "*"
+
+type (
+	// Tariff is a price list. A lane connects two endpoints, each a city
+	// code, a zone of Zones or AnyEndpoint, and applies in both directions.
+	// The most specific lane matching a route prices it.
+	Tariff struct {
+		Name     string `json:"name"`
+		Currency string `json:"currency"`
+		// QuoteTTL is how long a quote stays valid, e.g. "24h"
+		QuoteTTL Duration `json:"quote_ttl"`
+		// VolumetricKgPerM3 converts volume to weight; the higher of the
+		// actual and volumetric weight is charged
+		VolumetricKgPerM3 float64 `json:"volumetric_kg_per_m3"`
+		// MinCharge is the lowest total before discounts
+		MinCharge int `json:"min_charge"`
+		// FuelSurchargePct is added to the freight
+		FuelSurchargePct float64 `json:"fuel_surcharge_pct"`
+		// Zones maps city codes to zones
+		Zones map[string]string `json:"zones"`
+		Lanes []Lane            `json:"lanes"`
+		// CargoSurchargePct is added to the freight per cargo type
+		CargoSurchargePct map[CargoType]float64 `json:"cargo_surcharge_pct"`
+		// CustomerDiscountPct is taken off the subtotal per customer type
+		CustomerDiscountPct map[CustomerType]float64 `json:"customer_discount_pct"`
+	}
+
+	Lane struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+		// Base is charged once per shipment
+		Base int `json:"base"`
+		// Brackets are in ascending order, the last one without a limit
+		Brackets []Bracket `json:"brackets"`
+	}
+
+	// Bracket prices every chargeable kilogram of a shipment up to UpToKg
+	Bracket struct {
+		// UpToKg is 0 for the last, unlimited bracket
+		UpToKg float64 `json:"up_to_kg"`
+		PerKg  float64 `json:"per_kg"`
+	}
+)
+
+// Duration is a time.Duration written as a string in JSON, e.g. "30m"
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Duration(d).String() + `"`), nil
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("invalid duration %s: %w", b, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Validate checks the tariff is complete and consistent
+func (t *Tariff) Validate() error {
+	v := &validator{}
+	v.check(t.Name != "", "name", "must not be empty")
+	v.check(t.Currency != "", "currency", "must not be empty")
+	v.check(t.QuoteTTL > 0, "quote_ttl", "must be positive, got %s", time.Duration(t.QuoteTTL))
+	v.check(t.VolumetricKgPerM3 > 0, "volumetric_kg_per_m3", "must be positive")
+	v.check(t.MinCharge >= 0, "min_charge", "must not be negative")
+	v.check(t.FuelSurchargePct >= 0 && t.FuelSurchargePct <= 100, "fuel_surcharge_pct", "must be in [0, 100]")
+	for city, zone := range t.Zones {
+		v.check(zone != "" && zone != AnyEndpoint, "zones."+city, "%q is not a zone name", zone)
+	}
+	v.check(len(t.Lanes) > 0, "lanes", "must not be empty")
+	for i, l := range t.Lanes {
+		key := fmt.Sprintf("lanes[%d]", i)
+		v.check(l.From != "" && l.To != "", key, "from and to must not be empty")
+		v.check(l.Base >= 0, key+".base", "must not be negative")
+		v.check(len(l.Brackets) > 0, key+".brackets", "must not be empty")
+		for j, b := range l.Brackets {
+			bkey := fmt.Sprintf("%s.brackets[%d]", key, j)
+			v.check(b.PerKg >= 0, bkey+".per_kg", "must not be negative")
+			if j == len(l.Brackets)-1 {
+				v.check(b.UpToKg == 0, bkey+".up_to_kg", "must be 0, the last bracket has no limit")
+			} else {
+				v.check(b.UpToKg > 0 && (j == 0 || b.UpToKg > l.Brackets[j-1].UpToKg), bkey+".up_to_kg",
+					"must be positive and above the previous bracket")
+			}
+		}
+	}
+	types := []CargoType{CargoGeneral, CargoFragile, CargoDangerous, CargoTemperatureControlled}
+	for typ, pct := range t.CargoSurchargePct {
+		v.check(slices.Contains(types, typ), "cargo_surcharge_pct", "%q is not one of %v", typ, types)
+		v.check(pct >= 0 && pct <= 100, "cargo_surcharge_pct."+string(typ), "must be in [0, 100]")
+	}
+	customers := []CustomerType{CustomerIndividual, CustomerBusiness}
+	for typ, pct := range t.CustomerDiscountPct {
+		v.check(slices.Contains(customers, typ), "customer_discount_pct", "%q is not one of %v", typ, customers)
+		v.check(pct >= 0 && pct < 100, "customer_discount_pct."+string(typ), "must be in [0, 100)")
+	}
+
+	if len(v.problems) > 0 {
+		slices.Sort(v.problems)
+		return fmt.Errorf("invalid tariff %q: %s", t.Name, strings.Join(v.problems, "; "))
+	}
+	return nil
+}
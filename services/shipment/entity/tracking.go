@@ -0,0 +1,186 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	ErrShipmentNotFound = errors.New("shipment not found")
+	// ErrEventTime means the event is dated before the shipment was created
+	// or in the future
+	ErrEventTime = errors.New("event time is outside the life of the shipment")
+)
+
+// Tracking event types
+const (
+	EventCheckpoint = "CHECKPOINT"
+	EventArrival    = "ARRIVAL"
+	EventDeparture  = "DEPARTURE"
+	EventDelay      = "DELAY"
+	// EventException is damage, a failed delivery attempt or another problem
+	// needing attention
+	EventException = "EXCEPTION"
+)
+
+// EventTypes are the values of Event.Type
+var EventTypes = []string{EventCheckpoint, EventArrival, EventDeparture, EventDelay, EventException}
+
+// Limits of a tracking event
+const (
+	MaxLocationLength = 200
+	MaxNoteLength     = 1000
+	// MaxClockSkew is how far in the future an event may be dated
+	MaxClockSkew = 5 * time.Minute
+)
+
+// Timeline entry kinds
+const (
+	EntryStatus = "status"
+	EntryEvent  = "event"
+)
+
+// TrackingPrefix starts every tracking number, followed by 12 hex digits
+const TrackingPrefix = "TL"
+
+var trackingPattern = regexp.MustCompile(`^` + TrackingPrefix + `[0-9A-F]{12}$`)
+
+type (
+	// GPS is a position in WGS 84 degrees
+	GPS struct {
+		Lat float64 `json:"lat"`
+		Lon float64 `json:"lon"`
+	}
+
+	EventReq struct {
+		Type     string `json:"type"`
+		Location string `json:"location"`
+		Note     string `json:"note,omitempty"`
+		GPS      *GPS   `json:"gps,omitempty"`
+		// OccurredAt is when the event happened, the time of the request if
+		// omitted
+		OccurredAt *time.Time `json:"occurred_at,omitempty"`
+	}
+
+	// Event is a tracking checkpoint of a shipment
+	Event struct {
+		ID         string    `json:"id"`
+		ShipmentID string    `json:"shipment_id"`
+		Type       string    `json:"type"`
+		Location   string    `json:"location"`
+		Note       string    `json:"note,omitempty"`
+		GPS        *GPS      `json:"gps,omitempty"`
+		OccurredAt time.Time `json:"occurred_at"`
+		CreatedAt  time.Time `json:"created_at"`
+	}
+
+	// TimelineEntry is a status change or a tracking event
+	TimelineEntry struct {
+		Kind     string    `json:"kind"`
+		At       time.Time `json:"at"`
+		Status   string    `json:"status,omitempty"`
+		Type     string    `json:"type,omitempty"`
+		Location string    `json:"location,omitempty"`
+		Note     string    `json:"note,omitempty"`
+		GPS      *GPS      `json:"gps,omitempty"`
+	}
+
+	// Timeline is the history of a shipment, oldest first
+	Timeline struct {
+		ShipmentID     string          `json:"shipment_id,omitempty"`
+		TrackingNumber string          `json:"tracking_number"`
+		Route          string          `json:"route,omitempty"`
+		Status         string          `json:"status"`
+		ETA            *time.Time      `json:"eta,omitempty"`
+		DeliveryWindow *Window         `json:"delivery_window,omitempty"`
+		Entries        []TimelineEntry `json:"entries"`
+	}
+)
+
+func (r *EventReq) Validate() error {
+	v := &validator{}
+	v.check(slices.Contains(EventTypes, r.Type), "type", "%q is not one of %v", r.Type, EventTypes)
+	location := strings.TrimSpace(r.Location)
+	v.check(location != "", "location", "is required")
+	v.check(utf8.RuneCountInString(location) <= MaxLocationLength, "location",
+		"must be at most %d characters", MaxLocationLength)
+	v.check(utf8.RuneCountInString(r.Note) <= MaxNoteLength, "note", "must be at most %d characters", MaxNoteLength)
+	if r.GPS != nil {
+		v.check(r.GPS.Lat >= -90 && r.GPS.Lat <= 90, "gps.lat", "must be in [-90, 90], got %g", r.GPS.Lat)
+		v.check(r.GPS.Lon >= -180 && r.GPS.Lon <= 180, "gps.lon", "must be in [-180, 180], got %g", r.GPS.Lon)
+	}
+	v.check(r.OccurredAt == nil || !r.OccurredAt.IsZero(), "occurred_at", "must be a time or omitted")
+	return v.err()
+}
+
+// NewTimeline merges the status history and the events of shipment, both
+// oldest first. A status change comes before an event at the same time.
+func NewTimeline(shipment *Shipment, history []StatusChange, events []*Event) *Timeline {
+	t := &Timeline{
+		ShipmentID:     shipment.ID,
+		TrackingNumber: shipment.TrackingNumber,
+		Route:          shipment.Route,
+		Status:         shipment.Status,
+		ETA:            shipment.ETA,
+		DeliveryWindow: shipment.DeliveryWindow,
+		Entries:        make([]TimelineEntry, 0, len(history)+len(events)),
+	}
+	for len(history) > 0 || len(events) > 0 {
+		if len(events) == 0 || len(history) > 0 && !history[0].ChangedAt.After(events[0].OccurredAt) {
+			t.Entries = append(t.Entries, TimelineEntry{Kind: EntryStatus, At: history[0].ChangedAt, Status: history[0].Status})
+			history = history[1:]
+			continue
+		}
+		e := events[0]
+		t.Entries = append(t.Entries, TimelineEntry{
+			Kind: EntryEvent, At: e.OccurredAt, Type: e.Type, Location: e.Location, Note: e.Note, GPS: e.GPS,
+		})
+		events = events[1:]
+	}
+	return t
+}
+
+// Public returns what anyone with the tracking number may see: the status
+// and the city of every checkpoint. The shipment ID, route, ETA, delivery
+// window, notes, positions and addresses are for the sender and the carrier.
+func (t *Timeline) Public() *Timeline {
+	public := &Timeline{
+		TrackingNumber: t.TrackingNumber,
+		Status:         t.Status,
+		Entries:        make([]TimelineEntry, len(t.Entries)),
+	}
+	for i, e := range t.Entries {
+		public.Entries[i] = TimelineEntry{Kind: e.Kind, At: e.At, Status: e.Status, Type: e.Type, Location: city(e.Location)}
+	}
+	return public
+}
+
+// city returns the city of a location such as "Алматы, склад 1"
+func city(location string) string {
+	city, _, _ := strings.Cut(location, ",")
+	return strings.TrimSpace(city)
+}
+
+// NewTrackingNumber returns a random tracking number
+func NewTrackingNumber() string {
+	b := make([]byte, 6)
+	// Read never fails, see its documentation
+	rand.Read(b)
+	return TrackingPrefix + strings.ToUpper(hex.EncodeToString(b))
+}
+
+// NormalizeTrackingNumber returns number as stored, accepting lower case,
+// spaces and dashes, and O for 0; false if it cannot be a tracking number
+func NormalizeTrackingNumber(number string) (string, bool) {
+	number = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(number))
+	if strings.HasPrefix(number, TrackingPrefix) {
+		number = TrackingPrefix + strings.ReplaceAll(number[len(TrackingPrefix):], "O", "0")
+	}
+	return number, trackingPattern.MatchString(number)
+}
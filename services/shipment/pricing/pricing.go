@@ -0,0 +1,182 @@
+// Package pricing prices shipments with a tariff: a base rate and a rate per
+// chargeable kilogram of the lane, cargo-type and fuel surcharges, a
+// customer-type discount and a minimum charge.
+package pricing
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/idn"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// Loader returns the current tariff, e.g. from the database
+type Loader func(ctx context.Context) (*entity.Tariff, error)
+
+type engine struct {
+	log    *slog.Logger
+	tariff atomic.Pointer[entity.Tariff]
+}
+
+type Engine interface {
+	// Quote prices req at now with the current tariff; the quote has no ID
+	// until it is stored
+	Quote(req *entity.QuoteReq, now time.Time) (*entity.Quote, error)
+	Tariff() *entity.Tariff
+	// SetTariff replaces the tariff if it is valid; quotes already issued
+	// keep their price
+	SetTariff(tariff *entity.Tariff) error
+	// Run replaces the tariff with the one load returns every interval until
+	// ctx is done, keeping the current one on errors
+	Run(ctx context.Context, load Loader, interval time.Duration)
+}
+
+func New(log *slog.Logger, tariff *entity.Tariff) (Engine, error) {
+	e := &engine{log: log.With("layer", "pricing")}
+	if err := e.SetTariff(tariff); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *engine) Tariff() *entity.Tariff {
+	return e.tariff.Load()
+}
+
+func (e *engine) SetTariff(tariff *entity.Tariff) error {
+	if err := tariff.Validate(); err != nil {
+		return err
+	}
+	if prev := e.tariff.Swap(tariff); prev == nil || prev.Name != tariff.Name {
+		e.log.Info("tariff loaded", slog.String("tariff", tariff.Name), slog.Int("lanes", len(tariff.Lanes)))
+	}
+	return nil
+}
+
+func (e *engine) Run(ctx context.Context, load Loader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		tariff, err := load(ctx)
+		if err == nil {
+			err = e.SetTariff(tariff)
+		}
+		if err != nil {
+			e.log.ErrorContext(ctx, "failed to reload tariff", slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (e *engine) Quote(req *entity.QuoteReq, now time.Time) (*entity.Quote, error) {
+	t := e.tariff.Load()
+	c := req.Cargo
+
+	origin, destination, ok := entity.SplitRoute(req.Route)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse route %q", req.Route)
+	}
+	origin, destination = strings.ToUpper(origin), strings.ToUpper(destination)
+	lane, ok := findLane(t, origin, destination)
+	if !ok {
+		return nil, fmt.Errorf("%w %s%s%s in tariff %s", entity.ErrNoLane, origin, entity.RouteSeparator, destination, t.Name)
+	}
+
+	// The higher of the actual and volumetric weight, in whole kilograms
+	chargeable := math.Ceil(max(c.WeightKg, c.VolumeM3*t.VolumetricKgPerM3))
+	bracket := lane.Brackets[len(lane.Brackets)-1]
+	for _, b := range lane.Brackets {
+		if chargeable <= b.UpToKg {
+			bracket = b
+			break
+		}
+	}
+
+	q := &entity.Quote{
+		Route:     origin + entity.RouteSeparator + destination,
+		Customer:  req.Customer,
+		Cargo:     c.Clone(),
+		Currency:  t.Currency,
+		Tariff:    t.Name,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(t.QuoteTTL)),
+	}
+	add := func(code, description string, amount float64) {
+		q.Items = append(q.Items, entity.QuoteItem{Code: code, Description: description, Amount: int(math.Round(amount))})
+		q.Total += q.Items[len(q.Items)-1].Amount
+	}
+
+	add(entity.ItemBase, fmt.Sprintf("base rate %s%s%s", lane.From, entity.RouteSeparator, lane.To), float64(lane.Base))
+	add(entity.ItemWeight, fmt.Sprintf("%g kg × %g %s/kg", chargeable, bracket.PerKg, t.Currency), chargeable*bracket.PerKg)
+	freight := float64(q.Total)
+
+	if pct := t.CargoSurchargePct[c.Type]; pct > 0 {
+		add(entity.ItemCargoSurcharge, fmt.Sprintf("%s cargo %g%%", c.Type, pct), freight*pct/100)
+	}
+	if pct := t.FuelSurchargePct; pct > 0 {
+		add(entity.ItemFuelSurcharge, fmt.Sprintf("fuel %g%%", pct), freight*pct/100)
+	}
+
+	customer := CustomerType(req.Customer.IDN)
+	if pct := t.CustomerDiscountPct[customer]; pct > 0 {
+		add(entity.ItemCustomerDiscount, fmt.Sprintf("%s customer %g%%", customer, pct), -float64(q.Total)*pct/100)
+	}
+
+	// Last, so no discount takes the total below the minimum
+	if q.Total < t.MinCharge {
+		add(entity.ItemMinimumCharge, fmt.Sprintf("minimum charge %d %s", t.MinCharge, t.Currency), float64(t.MinCharge-q.Total))
+	}
+
+	return q, nil
+}
+
+// CustomerType tells businesses, which have a BIN, from individuals
+func CustomerType(customerIDN string) entity.CustomerType {
+	if idn.IsBIN(customerIDN) {
+		return entity.CustomerBusiness
+	}
+	return entity.CustomerIndividual
+}
+
+// findLane returns the lane whose endpoints match the route most
+// specifically: a city over its zone over AnyEndpoint, in either direction
+func findLane(t *entity.Tariff, origin, destination string) (entity.Lane, bool) {
+	score := func(endpoint, city string) int {
+		switch endpoint {
+		case city:
+			return 2
+		case t.Zones[city]:
+			return 1
+		case entity.AnyEndpoint:
+			return 0
+		}
+		return -1
+	}
+	match := func(from, to string) int {
+		a, b := score(from, origin), score(to, destination)
+		if a < 0 || b < 0 {
+			return -1
+		}
+		return a + b
+	}
+
+	best, bestScore := entity.Lane{}, -1
+	for _, l := range t.Lanes {
+		if s := max(match(l.From, l.To), match(l.To, l.From)); s > bestScore {
+			best, bestScore = l, s
+		}
+	}
+	return best, bestScore >= 0
+}
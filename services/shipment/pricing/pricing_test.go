@@ -0,0 +1,176 @@
+package pricing_test
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/geo"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+)
+
+const (
+	individual = "900101300126"
+	business   = "000740000004"
+)
+
+func testTariff() *entity.Tariff {
+	return &entity.Tariff{
+		Name:              "test",
+		Currency:          "KZT",
+		QuoteTTL:          entity.Duration(time.Hour),
+		VolumetricKgPerM3: 200,
+		FuelSurchargePct:  10,
+		Zones:             map[string]string{"A": "Z1", "B": "Z1", "C": "Z2"},
+		Lanes: []entity.Lane{
+			{From: "*", To: "*", Base: 1000, Brackets: []entity.Bracket{{UpToKg: 100, PerKg: 10}, {PerKg: 5}}},
+			{From: "Z1", To: "Z1", Base: 500, Brackets: []entity.Bracket{{PerKg: 2}}},
+			{From: "A", To: "B", Base: 100, Brackets: []entity.Bracket{{PerKg: 1}}},
+		},
+		CargoSurchargePct:   map[entity.CargoType]float64{entity.CargoFragile: 10},
+		CustomerDiscountPct: map[entity.CustomerType]float64{entity.CustomerBusiness: 20},
+	}
+}
+
+func cargo(typ entity.CargoType, kg, m3 float64) *entity.Cargo {
+	return &entity.Cargo{Type: typ, WeightKg: kg, VolumeM3: m3, DeclaredValue: 1000, PackageCount: 1}
+}
+
+func newEngine(t *testing.T, tariff *entity.Tariff) pricing.Engine {
+	t.Helper()
+	e, err := pricing.New(slog.New(slog.DiscardHandler), tariff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestQuote(t *testing.T) {
+	general := cargo(entity.CargoGeneral, 50, 0.1)
+	tests := []struct {
+		name  string
+		route string
+		idn   string
+		cargo *entity.Cargo
+		items map[string]int
+		total int
+	}{
+		{"city lane", "A→B", individual, general, map[string]int{"base": 100, "weight": 50, "fuel_surcharge": 15}, 165},
+		{"either direction", "b → a", individual, general, map[string]int{"base": 100, "weight": 50, "fuel_surcharge": 15}, 165},
+		{"zone lane", "A→A", individual, general, map[string]int{"base": 500, "weight": 100, "fuel_surcharge": 60}, 660},
+		{"any lane", "A→C", individual, general, map[string]int{"base": 1000, "weight": 500, "fuel_surcharge": 150}, 1650},
+		{"volumetric weight", "A→C", individual, cargo(entity.CargoGeneral, 50, 1),
+			map[string]int{"base": 1000, "weight": 1000, "fuel_surcharge": 200}, 2200},
+		{"cargo surcharge", "A→B", individual, cargo(entity.CargoFragile, 50, 0.1),
+			map[string]int{"base": 100, "weight": 50, "cargo_surcharge": 15, "fuel_surcharge": 15}, 180},
+		{"customer discount", "A→B", business, general,
+			map[string]int{"base": 100, "weight": 50, "fuel_surcharge": 15, "customer_discount": -33}, 132},
+	}
+
+	e := newEngine(t, testTariff())
+	now := time.Date(2025, time.May, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := e.Quote(&entity.QuoteReq{Route: tt.route, Customer: entity.CreateCustomerReq{IDN: tt.idn}, Cargo: tt.cargo}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			items := map[string]int{}
+			for _, item := range q.Items {
+				items[item.Code] = item.Amount
+			}
+			if len(items) != len(tt.items) {
+				t.Errorf("items = %+v, want %v", q.Items, tt.items)
+			}
+			for code, amount := range tt.items {
+				if items[code] != amount {
+					t.Errorf("%s = %d, want %d", code, items[code], amount)
+				}
+			}
+			if q.Total != tt.total {
+				t.Errorf("total = %d, want %d", q.Total, tt.total)
+			}
+			if q.Tariff != "test" || q.Currency != "KZT" || !q.ExpiresAt.Equal(now.Add(time.Hour)) {
+				t.Errorf("quote = %+v, want tariff test in KZT valid for an hour", q)
+			}
+		})
+	}
+}
+
+func TestQuoteMinCharge(t *testing.T) {
+	tariff := testTariff()
+	tariff.MinCharge = 1000
+	e := newEngine(t, tariff)
+
+	q, err := e.Quote(&entity.QuoteReq{
+		Route:    "A→B",
+		Customer: entity.CreateCustomerReq{IDN: business},
+		Cargo:    cargo(entity.CargoGeneral, 50, 0.1),
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The minimum charge applies after the discount
+	last := q.Items[len(q.Items)-2:]
+	if last[0].Code != entity.ItemCustomerDiscount || last[0].Amount != -33 ||
+		last[1].Code != entity.ItemMinimumCharge || last[1].Amount != 868 || q.Total != 1000 {
+		t.Errorf("items = %+v, total %d; want a discount of 33, then a minimum charge of 868", q.Items, q.Total)
+	}
+}
+
+func TestQuoteNoLane(t *testing.T) {
+	tariff := testTariff()
+	tariff.Lanes = tariff.Lanes[1:]
+	e := newEngine(t, tariff)
+
+	_, err := e.Quote(&entity.QuoteReq{Route: "A→C", Cargo: cargo(entity.CargoGeneral, 50, 0.1)}, time.Now())
+	if !errors.Is(err, entity.ErrNoLane) {
+		t.Errorf("error = %v, want ErrNoLane", err)
+	}
+}
+
+func TestDefault(t *testing.T) {
+	e := newEngine(t, pricing.Default())
+	for city := range e.Tariff().Zones {
+		if _, ok := geo.Get(city); !ok {
+			t.Errorf("zone city %s is not a gazetteer code", city)
+		}
+	}
+	minC, maxC := 2.0, 8.0
+
+	c := cargo(entity.CargoTemperatureControlled, 640.5, 3.2)
+	c.TemperatureMinC, c.TemperatureMaxC = &minC, &maxC
+	q, err := e.Quote(&entity.QuoteReq{Route: "ALMATY→ASTANA", Customer: entity.CreateCustomerReq{IDN: individual}, Cargo: c}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 30000 base, 800 volumetric kg × 95, 25% reefer and 12% fuel on 106000
+	if q.Total != 145220 {
+		t.Errorf("total = %d, want 145220: %+v", q.Total, q.Items)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want string
+	}{
+		{"unknown key", `{"name":"x","fuel_surcharge":5}`, "unknown field"},
+		{"bad duration", `{"name":"x","quote_ttl":"a day"}`, "invalid duration"},
+		{"invalid", `{"name":"x","currency":"KZT","quote_ttl":"1h","volumetric_kg_per_m3":250,
+			"lanes":[{"from":"*","to":"*","brackets":[{"up_to_kg":100,"per_kg":5},{"up_to_kg":50,"per_kg":4}]}]}`,
+			"lanes[0].brackets[1].up_to_kg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pricing.Parse([]byte(tt.json))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
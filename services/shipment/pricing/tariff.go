@@ -0,0 +1,93 @@
+package pricing
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+//go:embed default_tariff.json
+var defaultTariff []byte
+
+// Default returns the built-in tariff
+func Default() *entity.Tariff {
+	t, err := Parse(defaultTariff)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in tariff: %v", err))
+	}
+	return t
+}
+
+// Parse decodes and validates a tariff; unknown keys are an error, so a
+// misspelt surcharge is not silently ignored
+func Parse(data []byte) (*entity.Tariff, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	t := &entity.Tariff{}
+	if err := dec.Decode(t); err != nil {
+		return nil, fmt.Errorf("failed to parse tariff: %w", err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadFile reads a tariff from a JSON file
+func LoadFile(path string) (*entity.Tariff, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tariff: %w", err)
+	}
+	return Parse(data)
+}
+
+// NewLoader returns the loader of the tariff source of cfg
+func NewLoader(cfg *config.PricingConfig, st storage.Storage) Loader {
+	switch cfg.TariffSource {
+	case config.TariffFile:
+		return func(ctx context.Context) (*entity.Tariff, error) {
+			return LoadFile(cfg.TariffFile)
+		}
+	case config.TariffDB:
+		return func(ctx context.Context) (*entity.Tariff, error) {
+			t, err := st.GetTariff(ctx)
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, fmt.Errorf("no tariff in the database, save one with the tariff import command: %w", err)
+			}
+			return t, err
+		}
+	}
+	return func(ctx context.Context) (*entity.Tariff, error) {
+		return Default(), nil
+	}
+}
+
+// Open returns an engine with the tariff of cfg. Unless the tariff is built
+// in, the engine reloads it every cfg.ReloadInterval until ctx is done.
+func Open(ctx context.Context, log *slog.Logger, cfg *config.PricingConfig, st storage.Storage) (Engine, error) {
+	load := NewLoader(cfg, st)
+	tariff, err := load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tariff: %w", err)
+	}
+	e, err := New(log, tariff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tariff: %w", err)
+	}
+	if cfg.TariffSource != config.TariffBuiltin {
+		go e.Run(ctx, load, cfg.ReloadInterval)
+	}
+	return e, nil
+}
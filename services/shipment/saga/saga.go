@@ -0,0 +1,286 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+	"github.com/aidosgal/transline-test/specs/proto/customer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCompensated is returned when a saga failed and its completed steps were undone
+var ErrCompensated = errors.New("saga compensated")
+
+// CustomerClient is the part of the customer-service client the saga drives
+type CustomerClient interface {
+	UpsertCustomer(ctx context.Context, in *customer.UpsertCustomerRequest, opts ...grpc.CallOption) (*customer.CustomerResponse, error)
+	GetCustomer(ctx context.Context, in *customer.GetCustomerRequest, opts ...grpc.CallOption) (*customer.CustomerResponse, error)
+	DeleteCustomer(ctx context.Context, in *customer.DeleteCustomerRequest, opts ...grpc.CallOption) (*customer.DeleteCustomerResponse, error)
+	Invalidate(idn string)
+}
+
+type orchestrator struct {
+	log         *slog.Logger
+	storage     storage.Storage
+	customer    CustomerClient
+	maxAttempts int
+}
+
+// Orchestrator runs the create-shipment saga:
+//
+//	STARTED -> upsert customer -> CUSTOMER_UPSERTED -> insert shipment -> COMPLETED
+//
+// If the shipment cannot be inserted, or the upsert failed without telling
+// whether it happened, the saga moves to COMPENSATING, asks customer-service
+// to delete the customer if this saga created it, and ends in COMPENSATED.
+// Every transition is persisted first, so Recover can resume after a crash.
+type Orchestrator interface {
+	CreateShipment(ctx context.Context, req *entity.CreateReq) (*entity.Saga, error)
+	Recover(ctx context.Context, staleAfter time.Duration, limit int) (int, error)
+	Run(ctx context.Context, interval, staleAfter time.Duration)
+}
+
+func New(log *slog.Logger, storage storage.Storage, customer CustomerClient, maxAttempts int) Orchestrator {
+	return &orchestrator{
+		log:         log.With("layer", "saga"),
+		storage:     storage,
+		customer:    customer,
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (o *orchestrator) CreateShipment(ctx context.Context, req *entity.CreateReq) (*entity.Saga, error) {
+	log := o.log.With("method", "CreateShipment")
+
+	saga, err := o.storage.CreateSaga(ctx, &entity.Saga{
+		Type:    entity.SagaTypeCreateShipment,
+		State:   entity.SagaStarted,
+		Request: *req,
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to persist saga", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.CreateSaga: %w", err)
+	}
+	log.InfoContext(ctx, "saga started",
+		slog.String("saga_id", saga.ID),
+		slog.String("shipment_id", saga.ShipmentID))
+
+	// The saga outlives a disconnected caller, so it is not left half-done.
+	// A customer-service failure on the first step is not retried, so the
+	// caller gets the error right away instead of a later retry.
+	if err := o.run(context.WithoutCancel(ctx), saga, false); err != nil {
+		return saga, err
+	}
+	return saga, nil
+}
+
+// Recover resumes unfinished sagas that were not updated for staleAfter,
+// e.g. because the replica running them crashed
+func (o *orchestrator) Recover(ctx context.Context, staleAfter time.Duration, limit int) (int, error) {
+	log := o.log.With("method", "Recover")
+
+	sagas, err := o.storage.ClaimStaleSagas(ctx, staleAfter, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to storage.ClaimStaleSagas: %w", err)
+	}
+
+	for _, saga := range sagas {
+		log.InfoContext(ctx, "resuming saga",
+			slog.String("saga_id", saga.ID),
+			slog.String("state", string(saga.State)),
+			slog.Int("attempts", saga.Attempts))
+
+		if err := o.run(ctx, saga, true); err != nil {
+			log.WarnContext(ctx, "saga not finished",
+				slog.String("saga_id", saga.ID),
+				slog.String("state", string(saga.State)),
+				slog.String("error", err.Error()))
+		}
+	}
+
+	return len(sagas), nil
+}
+
+// Run recovers stale sagas every interval until ctx is done
+func (o *orchestrator) Run(ctx context.Context, interval, staleAfter time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.Recover(ctx, staleAfter, 100); err != nil {
+			o.log.ErrorContext(ctx, "saga recovery failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run drives the saga from its current state until it is terminal or a step
+// fails in a way that must be retried later
+func (o *orchestrator) run(ctx context.Context, saga *entity.Saga, recovering bool) error {
+	log := o.log.With("saga_id", saga.ID)
+	// cause is why this run compensates, so the caller can tell e.g. a used
+	// quote from a failure; a resumed saga only has saga.Error
+	var cause error
+
+	for {
+		switch saga.State {
+		case entity.SagaStarted:
+			resp, err := o.customer.UpsertCustomer(ctx, &customer.UpsertCustomerRequest{
+				Idn:    saga.Request.Customer.IDN,
+				SagaId: saga.ID,
+			})
+			if err != nil {
+				if recovering && saga.Attempts < o.maxAttempts {
+					return fmt.Errorf("failed to customer.UpsertCustomer: %w", err)
+				}
+				saga.Error = err.Error()
+				if unclear(err) {
+					// The customer may have been created, compensation looks it up
+					log.WarnContext(ctx, "saga customer upsert outcome unknown, compensating",
+						slog.String("error", err.Error()))
+					if uerr := o.transition(ctx, saga, entity.SagaCompensating); uerr != nil {
+						return uerr
+					}
+					continue
+				}
+				// customer-service rejected the call, so there is nothing to compensate
+				if uerr := o.transition(ctx, saga, entity.SagaCompensated); uerr != nil {
+					return uerr
+				}
+				return fmt.Errorf("failed to customer.UpsertCustomer: %w", err)
+			}
+
+			saga.CustomerID = resp.GetId()
+			saga.CustomerCreated = resp.GetCreated()
+			if err := o.transition(ctx, saga, entity.SagaCustomerUpserted); err != nil {
+				return err
+			}
+			log.InfoContext(ctx, "saga customer upserted",
+				slog.String("customer_id", saga.CustomerID),
+				slog.Bool("customer_created", saga.CustomerCreated))
+
+		case entity.SagaCustomerUpserted:
+			// The insert and the COMPLETED transition commit together, so a crash
+			// cannot leave a shipment behind a saga that would still compensate
+			var shipment *entity.Shipment
+			err := o.storage.WithTx(ctx, func(tx storage.Storage) error {
+				var err error
+				shipment, err = tx.CreateShipmentWithID(ctx, saga.ShipmentID, &saga.Request, saga.CustomerID)
+				if err != nil {
+					return fmt.Errorf("failed to storage.CreateShipmentWithID: %w", err)
+				}
+				saga.State = entity.SagaCompleted
+				if err := tx.UpdateSaga(ctx, saga); err != nil {
+					return fmt.Errorf("failed to storage.UpdateSaga: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				saga.State = entity.SagaCustomerUpserted
+				// A used quote stays used, retrying cannot help
+				if recovering && saga.Attempts < o.maxAttempts && !errors.Is(err, entity.ErrQuoteUsed) {
+					return err
+				}
+				cause = err
+				log.WarnContext(ctx, "saga shipment insert failed, compensating", slog.String("error", err.Error()))
+				saga.Error = err.Error()
+				if uerr := o.transition(ctx, saga, entity.SagaCompensating); uerr != nil {
+					return uerr
+				}
+				continue
+			}
+
+			saga.Shipment = shipment
+			log.InfoContext(ctx, "saga completed", slog.String("shipment_id", saga.ShipmentID))
+			return nil
+
+		case entity.SagaCompensating:
+			if err := o.compensateCustomer(ctx, saga); err != nil {
+				log.ErrorContext(ctx, "saga compensation failed", slog.String("error", err.Error()))
+				return err
+			}
+			if err := o.transition(ctx, saga, entity.SagaCompensated); err != nil {
+				return err
+			}
+			log.InfoContext(ctx, "saga compensated")
+			if cause != nil {
+				return fmt.Errorf("%w: %w", ErrCompensated, cause)
+			}
+			return fmt.Errorf("%w: %s", ErrCompensated, saga.Error)
+
+		case entity.SagaCompensated:
+			return fmt.Errorf("%w: %s", ErrCompensated, saga.Error)
+
+		case entity.SagaCompleted:
+			return nil
+
+		default:
+			return fmt.Errorf("unknown saga state %q", saga.State)
+		}
+	}
+}
+
+// unclear reports whether a failed call may still have been applied
+func unclear(err error) bool {
+	switch status.Code(err) {
+	case codes.DeadlineExceeded, codes.Unavailable, codes.Canceled, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// compensateCustomer asks customer-service to delete the customer. It only
+// does if this saga created the customer and nobody else has upserted it, and
+// checks that in the same statement as the delete. If the upsert outcome is
+// unknown the customer is first looked up by IDN.
+func (o *orchestrator) compensateCustomer(ctx context.Context, saga *entity.Saga) error {
+	log := o.log.With("saga_id", saga.ID)
+
+	id := saga.CustomerID
+	if id != "" && !saga.CustomerCreated {
+		return nil
+	}
+	if id == "" {
+		resp, err := o.customer.GetCustomer(ctx, &customer.GetCustomerRequest{Idn: saga.Request.Customer.IDN})
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to customer.GetCustomer: %w", err)
+		}
+		id = resp.GetId()
+	}
+
+	o.customer.Invalidate(saga.Request.Customer.IDN)
+	resp, err := o.customer.DeleteCustomer(ctx, &customer.DeleteCustomerRequest{Id: id, SagaId: saga.ID})
+	if err != nil {
+		return fmt.Errorf("failed to customer.DeleteCustomer: %w", err)
+	}
+	log.InfoContext(ctx, "saga customer compensated",
+		slog.String("customer_id", id),
+		slog.Bool("deleted", resp.GetDeleted()))
+	return nil
+}
+
+func (o *orchestrator) transition(ctx context.Context, saga *entity.Saga, state entity.SagaState) error {
+	prev := saga.State
+	saga.State = state
+	if err := o.storage.UpdateSaga(ctx, saga); err != nil {
+		saga.State = prev
+		return fmt.Errorf("failed to storage.UpdateSaga: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package saga_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	customerserver "github.com/aidosgal/transline-test/services/customer/server"
+	customerstorage "github.com/aidosgal/transline-test/services/customer/storage"
+	customerusecase "github.com/aidosgal/transline-test/services/customer/usecase"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/saga"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+	pb "github.com/aidosgal/transline-test/specs/proto/customer"
+)
+
+const idn = "990101300123"
+
+var errInsert = errors.New("insert failed")
+
+// customerClient calls a customer-service backed by memory storage in
+// process. upsertErr fails UpsertCustomer, after applying it if applied is set.
+type customerClient struct {
+	server    pb.CustomerServer
+	upsertErr error
+	applied   bool
+	// afterUpsert runs after every successful upsert, e.g. to let another
+	// saga upsert the same customer
+	afterUpsert func()
+}
+
+func (c *customerClient) UpsertCustomer(ctx context.Context, in *pb.UpsertCustomerRequest, _ ...grpc.CallOption) (*pb.CustomerResponse, error) {
+	if c.upsertErr != nil {
+		if c.applied {
+			if _, err := c.server.UpsertCustomer(ctx, in); err != nil {
+				return nil, err
+			}
+		}
+		return nil, c.upsertErr
+	}
+	resp, err := c.server.UpsertCustomer(ctx, in)
+	if err == nil && c.afterUpsert != nil {
+		c.afterUpsert()
+	}
+	return resp, err
+}
+
+func (c *customerClient) GetCustomer(ctx context.Context, in *pb.GetCustomerRequest, _ ...grpc.CallOption) (*pb.CustomerResponse, error) {
+	return c.server.GetCustomer(ctx, in)
+}
+
+func (c *customerClient) DeleteCustomer(ctx context.Context, in *pb.DeleteCustomerRequest, _ ...grpc.CallOption) (*pb.DeleteCustomerResponse, error) {
+	return c.server.DeleteCustomer(ctx, in)
+}
+
+func (c *customerClient) Invalidate(string) {}
+
+// failingStorage fails every transaction, so the shipment insert fails
+type failingStorage struct {
+	storage.Storage
+}
+
+func (failingStorage) WithTx(context.Context, func(tx storage.Storage) error) error {
+	return errInsert
+}
+
+type fixture struct {
+	orchestrator saga.Orchestrator
+	shipments    storage.Storage
+	customers    customerstorage.Storage
+	client       *customerClient
+}
+
+func newFixture(t *testing.T, failInsert bool) *fixture {
+	t.Helper()
+	log := slog.New(slog.DiscardHandler)
+
+	customers := customerstorage.NewMemory(log)
+	client := &customerClient{server: customerserver.New(log, customerusecase.New(log, customers))}
+
+	var shipments storage.Storage = storage.NewMemory(log)
+	if failInsert {
+		shipments = failingStorage{shipments}
+	}
+	return &fixture{
+		orchestrator: saga.New(log, shipments, client, 5),
+		shipments:    shipments,
+		customers:    customers,
+		client:       client,
+	}
+}
+
+func createReq() *entity.CreateReq {
+	return &entity.CreateReq{
+		Route:    "ALMATY→ASTANA",
+		Price:    120000,
+		Customer: entity.CreateCustomerReq{IDN: idn},
+	}
+}
+
+func (f *fixture) customerExists(t *testing.T) bool {
+	t.Helper()
+	_, err := f.customers.GetCustomerByIDN(context.Background(), idn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		t.Fatalf("GetCustomerByIDN: %v", err)
+	}
+	return true
+}
+
+func TestCreateShipment(t *testing.T) {
+	f := newFixture(t, false)
+
+	s, err := f.orchestrator.CreateShipment(context.Background(), createReq())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if s.State != entity.SagaCompleted || s.Shipment == nil || !s.CustomerCreated {
+		t.Errorf("saga = %+v, want completed with a new customer and the shipment", s)
+	}
+	if !f.customerExists(t) {
+		t.Error("customer missing after a completed saga")
+	}
+}
+
+func TestCompensateNewCustomer(t *testing.T) {
+	f := newFixture(t, true)
+
+	s, err := f.orchestrator.CreateShipment(context.Background(), createReq())
+	if !errors.Is(err, saga.ErrCompensated) {
+		t.Fatalf("CreateShipment error = %v, want ErrCompensated", err)
+	}
+	if s.State != entity.SagaCompensated {
+		t.Errorf("state = %s, want %s", s.State, entity.SagaCompensated)
+	}
+	if f.customerExists(t) {
+		t.Error("customer created by the saga was not deleted")
+	}
+}
+
+func TestCompensateExistingCustomer(t *testing.T) {
+	f := newFixture(t, true)
+	if _, err := f.customers.UpsertCustomer(context.Background(), idn, ""); err != nil {
+		t.Fatalf("UpsertCustomer: %v", err)
+	}
+
+	_, err := f.orchestrator.CreateShipment(context.Background(), createReq())
+	if !errors.Is(err, saga.ErrCompensated) {
+		t.Fatalf("CreateShipment error = %v, want ErrCompensated", err)
+	}
+	if !f.customerExists(t) {
+		t.Error("existing customer was deleted by compensation")
+	}
+}
+
+func TestCompensateReusedCustomer(t *testing.T) {
+	f := newFixture(t, true)
+	// Another saga upserts the customer while this one inserts its shipment
+	f.client.afterUpsert = func() {
+		f.client.afterUpsert = nil
+		if _, err := f.customers.UpsertCustomer(context.Background(), idn, uuid.NewString()); err != nil {
+			t.Errorf("UpsertCustomer: %v", err)
+		}
+	}
+
+	s, err := f.orchestrator.CreateShipment(context.Background(), createReq())
+	if !errors.Is(err, saga.ErrCompensated) {
+		t.Fatalf("CreateShipment error = %v, want ErrCompensated", err)
+	}
+	if !s.CustomerCreated {
+		t.Error("CustomerCreated = false, want the saga to have created the customer")
+	}
+	if !f.customerExists(t) {
+		t.Error("customer another saga relies on was deleted by compensation")
+	}
+}
+
+func TestUpsertError(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		applied bool
+	}{
+		{"unclear and applied", status.Error(codes.DeadlineExceeded, "deadline exceeded"), true},
+		{"unclear and not applied", status.Error(codes.Unavailable, "connection refused"), false},
+		{"rejected", status.Error(codes.InvalidArgument, "bad idn"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, false)
+			f.client.upsertErr, f.client.applied = tt.err, tt.applied
+
+			s, err := f.orchestrator.CreateShipment(context.Background(), createReq())
+			if err == nil {
+				t.Fatal("CreateShipment succeeded")
+			}
+			if s.State != entity.SagaCompensated {
+				t.Errorf("state = %s, want %s", s.State, entity.SagaCompensated)
+			}
+			if f.customerExists(t) {
+				t.Error("customer left behind by a failed upsert")
+			}
+			if _, err := f.shipments.GetShipment(context.Background(), s.ShipmentID); !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("GetShipment error = %v, want sql.ErrNoRows", err)
+			}
+		})
+	}
+}
+
+func TestQuoteUsed(t *testing.T) {
+	f := newFixture(t, false)
+	ctx := context.Background()
+	quote, err := f.shipments.CreateQuote(ctx, &entity.Quote{
+		Route:     "ALMATY→ASTANA",
+		Customer:  entity.CreateCustomerReq{IDN: idn},
+		Total:     120000,
+		Currency:  "KZT",
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("CreateQuote: %v", err)
+	}
+	req := createReq()
+	req.QuoteID = quote.ID
+	if _, err := f.orchestrator.CreateShipment(ctx, req); err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+
+	// The loser of two requests with one quote gets ErrQuoteUsed, not a bare
+	// ErrCompensated, so the API answers 409
+	s, err := f.orchestrator.CreateShipment(ctx, req)
+	if !errors.Is(err, entity.ErrQuoteUsed) || !errors.Is(err, saga.ErrCompensated) {
+		t.Fatalf("second CreateShipment error = %v, want ErrQuoteUsed and ErrCompensated", err)
+	}
+	if s.State != entity.SagaCompensated {
+		t.Errorf("state = %s, want %s", s.State, entity.SagaCompensated)
+	}
+	if !f.customerExists(t) {
+		t.Error("customer of the first shipment was deleted by compensation")
+	}
+}
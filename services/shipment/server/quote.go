@@ -0,0 +1,56 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/aidosgal/transline-test/pkg/json"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *server) CreateQuote(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "CreateQuote")
+	req := &entity.QuoteReq{}
+
+	log.InfoContext(r.Context(), "received create quote request")
+
+	if err := json.ParseJSON(r, req); err != nil {
+		log.ErrorContext(r.Context(), "failed to parse request body", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		log.ErrorContext(r.Context(), "invalid create quote request", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	quote, err := s.usecase.CreateQuote(r.Context(), req)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to create quote", slog.String("error", err.Error()))
+		json.WriteError(w, errorStatus(err), err)
+		return
+	}
+
+	log.InfoContext(r.Context(), "quote created successfully",
+		slog.String("quote_id", quote.ID),
+		slog.Int("total", quote.Total))
+	json.WriteJSON(w, http.StatusCreated, quote)
+}
+
+func (s *server) GetQuote(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "GetQuote")
+	id := chi.URLParam(r, "id")
+
+	log.InfoContext(r.Context(), "received get quote request", slog.String("quote_id", id))
+
+	quote, err := s.usecase.GetQuote(r.Context(), id)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to get quote", slog.String("error", err.Error()))
+		json.WriteError(w, errorStatus(err), err)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, quote)
+}
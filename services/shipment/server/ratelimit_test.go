@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+	l := newRateLimiter(60, 2)
+	l.now = func() time.Time { return now }
+
+	for i := range 2 {
+		if ok, _ := l.allow("10.0.0.1"); !ok {
+			t.Fatalf("request %d within the burst rejected", i+1)
+		}
+	}
+	ok, wait := l.allow("10.0.0.1")
+	if ok || wait != time.Second {
+		t.Errorf("allow after the burst = %t, %s, want false, 1s", ok, wait)
+	}
+	if ok, _ := l.allow("10.0.0.2"); !ok {
+		t.Error("another IP rejected")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.allow("10.0.0.1"); !ok {
+		t.Error("rejected after a token refilled")
+	}
+
+	now = now.Add(time.Hour)
+	l.allow("10.0.0.3")
+	if _, ok := l.buckets["10.0.0.1"]; ok || len(l.buckets) != 1 {
+		t.Errorf("buckets after an idle hour = %v, want only 10.0.0.3", l.buckets)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		forwarded []string
+		trust     bool
+		want      string
+	}{
+		{"remote address", nil, false, "192.0.2.1"},
+		{"forwarded not trusted", []string{"203.0.113.7"}, false, "192.0.2.1"},
+		{"last forwarded", []string{"198.51.100.9, 203.0.113.7"}, true, "203.0.113.7"},
+		{"last header", []string{"198.51.100.9", "203.0.113.7"}, true, "203.0.113.7"},
+		{"empty forwarded", []string{" "}, true, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/tracking/TL000000000000", nil)
+			r.RemoteAddr = "192.0.2.1:52100"
+			for _, f := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			if got := clientIP(r, tt.trust); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
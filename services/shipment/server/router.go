@@ -0,0 +1,79 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/health"
+	"github.com/aidosgal/transline-test/pkg/json"
+	customlogger "github.com/aidosgal/transline-test/pkg/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/cors"
+)
+
+// NewRouter mounts the REST API, the health probes of checker and the admin
+// endpoints changing levels. The admin endpoints require adminToken as a
+// bearer token and are not mounted when it is empty; public tracking is
+// limited per client IP by tracking.
+func NewRouter(log *slog.Logger, s Server, checker *health.Checker, levels *customlogger.Levels,
+	adminToken string, tracking *config.TrackingConfig) chi.Router {
+	router := chi.NewRouter()
+	router.Use(middleware.Logger)
+	router.Use(middleware.URLFormat)
+	router.Use(cors.Handler(cors.Options{
+		AllowedOrigins:   []string{"*"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	}))
+
+	router.Get("/healthz", checker.LiveHandler)
+	router.Get("/readyz", checker.ReadyHandler)
+
+	router.Route("/api/v1", func(apiRouter chi.Router) {
+		apiRouter.Route("/shipments", func(authRouter chi.Router) {
+			authRouter.Get("/", s.ListShipments)
+			authRouter.Post("/", s.CreateShipment)
+			authRouter.Get("/{id}", s.GetShipment)
+			authRouter.Post("/{id}/events", s.AddEvent)
+			authRouter.Get("/{id}/timeline", s.GetTimeline)
+		})
+		// Public: a tracking number is all a recipient has, so it is rate
+		// limited against guessing numbers
+		apiRouter.With(rateLimit(tracking)).Get("/tracking/{number}", s.Track)
+		apiRouter.Route("/quotes", func(quoteRouter chi.Router) {
+			quoteRouter.Post("/", s.CreateQuote)
+			quoteRouter.Get("/{id}", s.GetQuote)
+		})
+	})
+
+	if adminToken != "" {
+		router.Route("/admin", func(adminRouter chi.Router) {
+			adminRouter.Use(requireToken(adminToken))
+			adminRouter.HandleFunc("/log/levels", customlogger.LevelsHandler(log, levels))
+		})
+	}
+
+	return router
+}
+
+// requireToken rejects requests without "Authorization: Bearer <token>"
+func requireToken(token string) func(http.Handler) http.Handler {
+	want := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				json.WriteError(w, http.StatusUnauthorized, errors.New("invalid or missing admin token"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
 
 	"github.com/aidosgal/transline-test/pkg/json"
 	"github.com/aidosgal/transline-test/services/shipment/entity"
@@ -17,7 +20,13 @@ type server struct {
 
 type Server interface {
 	GetShipment(w http.ResponseWriter, r *http.Request)
+	ListShipments(w http.ResponseWriter, r *http.Request)
 	CreateShipment(w http.ResponseWriter, r *http.Request)
+	AddEvent(w http.ResponseWriter, r *http.Request)
+	GetTimeline(w http.ResponseWriter, r *http.Request)
+	Track(w http.ResponseWriter, r *http.Request)
+	CreateQuote(w http.ResponseWriter, r *http.Request)
+	GetQuote(w http.ResponseWriter, r *http.Request)
 }
 
 func New(log *slog.Logger, usecase usecase.Usecase) Server {
@@ -36,7 +45,7 @@ func (s *server) GetShipment(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.usecase.GetShipment(r.Context(), id)
 	if err != nil {
 		log.ErrorContext(r.Context(), "failed to get shipment", slog.String("error", err.Error()))
-		json.WriteError(w, http.StatusInternalServerError, err)
+		json.WriteError(w, errorStatus(err), err)
 		return
 	}
 
@@ -45,6 +54,44 @@ func (s *server) GetShipment(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+func (s *server) ListShipments(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "ListShipments")
+	query := r.URL.Query()
+
+	log.InfoContext(r.Context(), "received list shipments request", slog.String("query", r.URL.RawQuery))
+
+	filter := entity.ShipmentFilter{
+		Status:     query.Get("status"),
+		SLAStatus:  query.Get("sla_status"),
+		CustomerID: query.Get("customer_id"),
+	}
+	var err error
+	if filter.Limit, err = intParam(query.Get("limit")); err != nil {
+		err = fmt.Errorf("invalid limit: %w", err)
+	} else if filter.Offset, err = intParam(query.Get("offset")); err != nil {
+		err = fmt.Errorf("invalid offset: %w", err)
+	} else {
+		err = filter.Validate()
+	}
+	if err != nil {
+		log.ErrorContext(r.Context(), "invalid list shipments request", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	shipments, err := s.usecase.ListShipments(r.Context(), filter)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to list shipments", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if shipments == nil {
+		shipments = []*entity.Shipment{}
+	}
+
+	json.WriteJSON(w, http.StatusOK, entity.ListResp{Shipments: shipments})
+}
+
 func (s *server) CreateShipment(w http.ResponseWriter, r *http.Request) {
 	log := s.log.With("method", "CreateShipment")
 	req := &entity.CreateReq{}
@@ -58,6 +105,12 @@ func (s *server) CreateShipment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := req.Validate(); err != nil {
+		log.ErrorContext(r.Context(), "invalid create shipment request", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
 	log.InfoContext(r.Context(), "creating shipment",
 		slog.String("route", req.Route),
 		slog.Int("price", req.Price),
@@ -66,7 +119,7 @@ func (s *server) CreateShipment(w http.ResponseWriter, r *http.Request) {
 	resp, err := s.usecase.CreateShipment(r.Context(), req)
 	if err != nil {
 		log.ErrorContext(r.Context(), "failed to create shipment", slog.String("error", err.Error()))
-		json.WriteError(w, http.StatusInternalServerError, err)
+		json.WriteError(w, errorStatus(err), err)
 		return
 	}
 
@@ -76,3 +129,27 @@ func (s *server) CreateShipment(w http.ResponseWriter, r *http.Request) {
 	json.WriteJSON(w, http.StatusCreated, resp)
 	return
 }
+
+// errorStatus maps the errors a caller can fix to their status, anything
+// else to 500
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, entity.ErrQuoteNotFound), errors.Is(err, entity.ErrShipmentNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, entity.ErrQuoteUsed):
+		return http.StatusConflict
+	case errors.Is(err, entity.ErrQuoteExpired):
+		return http.StatusGone
+	case errors.Is(err, entity.ErrQuoteCustomer), errors.Is(err, entity.ErrNoLane), errors.Is(err, entity.ErrEventTime):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// intParam parses an optional integer query parameter, 0 when omitted
+func intParam(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/aidosgal/transline-test/pkg/json"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/go-chi/chi/v5"
+)
+
+func (s *server) AddEvent(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "AddEvent")
+	id := chi.URLParam(r, "id")
+	req := &entity.EventReq{}
+
+	log.InfoContext(r.Context(), "received add event request", slog.String("shipment_id", id))
+
+	if err := json.ParseJSON(r, req); err != nil {
+		log.ErrorContext(r.Context(), "failed to parse request body", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		log.ErrorContext(r.Context(), "invalid add event request", slog.String("error", err.Error()))
+		json.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	event, err := s.usecase.AddEvent(r.Context(), id, req)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to add event", slog.String("error", err.Error()))
+		json.WriteError(w, errorStatus(err), err)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusCreated, event)
+}
+
+func (s *server) GetTimeline(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "GetTimeline")
+	id := chi.URLParam(r, "id")
+
+	log.InfoContext(r.Context(), "received get timeline request", slog.String("shipment_id", id))
+
+	timeline, err := s.usecase.GetTimeline(r.Context(), id)
+	if err != nil {
+		log.ErrorContext(r.Context(), "failed to get timeline", slog.String("error", err.Error()))
+		json.WriteError(w, errorStatus(err), err)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, timeline)
+}
+
+// Track serves the public tracking page; it needs no credentials, so it
+// shows only what the tracking number holder may see
+func (s *server) Track(w http.ResponseWriter, r *http.Request) {
+	log := s.log.With("method", "Track")
+
+	timeline, err := s.usecase.Track(r.Context(), chi.URLParam(r, "number"))
+	if err != nil {
+		log.WarnContext(r.Context(), "failed to track shipment", slog.String("error", err.Error()))
+		json.WriteError(w, errorStatus(err), err)
+		return
+	}
+
+	json.WriteJSON(w, http.StatusOK, timeline)
+}
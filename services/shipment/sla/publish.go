@@ -0,0 +1,88 @@
+package sla
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Publisher delivers SLA breaches to whoever acts on them
+type Publisher interface {
+	Publish(ctx context.Context, breach *entity.SLABreach) error
+}
+
+// NewPublisher returns a webhook publisher if cfg has a webhook URL, a log
+// publisher otherwise
+func NewPublisher(log *slog.Logger, cfg *config.SLAConfig) Publisher {
+	if cfg.WebhookURL != "" {
+		return NewWebhookPublisher(log, cfg.WebhookURL, cfg.WebhookTimeout)
+	}
+	return NewLogPublisher(log)
+}
+
+type logPublisher struct {
+	log *slog.Logger
+}
+
+// NewLogPublisher writes breaches to the log, which exports them with the
+// other logs of the service
+func NewLogPublisher(log *slog.Logger) Publisher {
+	return &logPublisher{log: log.With("layer", "sla")}
+}
+
+func (p *logPublisher) Publish(ctx context.Context, breach *entity.SLABreach) error {
+	p.log.WarnContext(ctx, "sla breached",
+		slog.String("shipment_id", breach.ShipmentID),
+		slog.String("route", breach.Route),
+		slog.String("status", breach.Status),
+		slog.String("customer_id", breach.CustomerID),
+		slog.Time("deadline", breach.Deadline))
+	return nil
+}
+
+type webhookPublisher struct {
+	logPublisher
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher logs breaches and POSTs them as JSON to url; a response
+// other than 2xx is an error
+func NewWebhookPublisher(log *slog.Logger, url string, timeout time.Duration) Publisher {
+	return &webhookPublisher{
+		logPublisher: logPublisher{log: log.With("layer", "sla")},
+		url:          url,
+		client:       &http.Client{Timeout: timeout, Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+func (p *webhookPublisher) Publish(ctx context.Context, breach *entity.SLABreach) error {
+	body, err := json.Marshal(breach)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sla breach: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post sla breach: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("failed to post sla breach: webhook responded %s", resp.Status)
+	}
+
+	return p.logPublisher.Publish(ctx, breach)
+}
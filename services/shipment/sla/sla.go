@@ -0,0 +1,227 @@
+// Package sla plans the pickup and delivery windows of shipments, estimates
+// their arrival from the transit history of the lane or, lacking it, the
+// distance, and watches open shipments for ones at risk of missing their
+// delivery window or that missed it.
+package sla
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/pkg/geo"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+// Sources of a Transit
+const (
+	SourceHistory  = "history"
+	SourceDistance = "distance"
+	SourceDefault  = "default"
+)
+
+// Transit is the expected pickup-to-delivery time on a route
+type Transit struct {
+	Duration time.Duration
+	Source   string
+	// Samples is the number of deliveries the history source is based on
+	Samples int
+}
+
+type monitor struct {
+	log       *slog.Logger
+	storage   storage.Storage
+	publisher Publisher
+	cfg       *config.SLAConfig
+}
+
+type Monitor interface {
+	// Plan returns the given windows, filling in the omitted ones for a
+	// shipment on route created at now: pickup within cfg.PickupWithin,
+	// delivery the transit time after pickup with cfg.DeliveryBuffer of slack
+	Plan(ctx context.Context, route string, pickup, delivery *entity.Window, now time.Time) (*entity.Window, *entity.Window)
+	// Transit returns the median transit time of the lane if it has enough
+	// recent deliveries, an estimate from the distance otherwise
+	Transit(ctx context.Context, route string, now time.Time) Transit
+	// Estimate returns the ETA and SLA status of shipment at now; the ETA is
+	// nil once the shipment is delivered or cancelled
+	Estimate(ctx context.Context, shipment *entity.Shipment, now time.Time) (*time.Time, string, error)
+	// Check re-estimates up to cfg.BatchSize open shipments, stores their
+	// ETA and SLA status and publishes new breaches. It returns how many
+	// shipments it checked.
+	Check(ctx context.Context) (int, error)
+	// Run checks every cfg.CheckInterval until ctx is done
+	Run(ctx context.Context)
+}
+
+func New(log *slog.Logger, storage storage.Storage, publisher Publisher, cfg *config.SLAConfig) Monitor {
+	return &monitor{
+		log:       log.With("layer", "sla"),
+		storage:   storage,
+		publisher: publisher,
+		cfg:       cfg,
+	}
+}
+
+func (m *monitor) Plan(ctx context.Context, route string, pickup, delivery *entity.Window, now time.Time) (*entity.Window, *entity.Window) {
+	if pickup == nil {
+		from := now.UTC().Truncate(time.Minute)
+		pickup = &entity.Window{From: from, Until: from.Add(m.cfg.PickupWithin)}
+	}
+	if delivery == nil {
+		transit := m.Transit(ctx, route, now).Duration
+		delivery = &entity.Window{
+			From:  pickup.From.Add(transit),
+			Until: pickup.Until.Add(transit + m.cfg.DeliveryBuffer),
+		}
+	}
+	return pickup, delivery
+}
+
+func (m *monitor) Transit(ctx context.Context, route string, now time.Time) Transit {
+	lane, err := m.storage.LaneTransit(ctx, route, now.Add(-m.cfg.HistoryWindow))
+	if err != nil {
+		// The distance estimate is still good enough to plan with
+		m.log.WarnContext(ctx, "failed to get lane history", slog.String("method", "Transit"),
+			slog.String("route", route), slog.String("error", err.Error()))
+	}
+	if err == nil && lane.Samples >= m.cfg.MinSamples {
+		return Transit{Duration: lane.Median, Source: SourceHistory, Samples: lane.Samples}
+	}
+
+	origin, destination, _ := entity.SplitRoute(route)
+	if km, ok := geo.RoadKmByCode(origin, destination); ok {
+		hours := km / m.cfg.SpeedKmh
+		return Transit{Duration: time.Duration(hours * float64(time.Hour)).Round(time.Minute), Source: SourceDistance}
+	}
+	return Transit{Duration: m.cfg.DefaultTransit, Source: SourceDefault}
+}
+
+func (m *monitor) Estimate(ctx context.Context, shipment *entity.Shipment, now time.Time) (*time.Time, string, error) {
+	if !shipment.Open() {
+		return nil, shipment.SLAStatus, nil
+	}
+	history, err := m.storage.GetStatusHistory(ctx, shipment.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	eta, status := estimate(shipment, history, m.Transit(ctx, shipment.Route, now).Duration, now)
+	return eta, status, nil
+}
+
+// estimate returns the ETA and SLA status of an open shipment: the transit
+// time after the pickup, or after the later of now and the start of the
+// pickup window if there was none yet. A breach is final.
+func estimate(shipment *entity.Shipment, history []entity.StatusChange, transit time.Duration, now time.Time) (*time.Time, string) {
+	var pickedUp time.Time
+	for _, h := range history {
+		if h.Status == entity.StatusPickedUp {
+			pickedUp = h.ChangedAt
+		}
+	}
+
+	var eta time.Time
+	if !pickedUp.IsZero() {
+		eta = pickedUp.Add(transit)
+	} else {
+		start := now
+		if shipment.PickupWindow != nil && shipment.PickupWindow.From.After(now) {
+			start = shipment.PickupWindow.From
+		}
+		eta = start.Add(transit)
+	}
+	// An overdue shipment is expected any moment
+	if eta.Before(now) {
+		eta = now
+	}
+	eta = eta.UTC().Truncate(time.Minute)
+
+	w := shipment.DeliveryWindow
+	switch {
+	case w == nil:
+		return &eta, ""
+	case shipment.SLAStatus == entity.SLABreached || now.After(w.Until):
+		return &eta, entity.SLABreached
+	case eta.After(w.Until),
+		pickedUp.IsZero() && shipment.PickupWindow != nil && now.After(shipment.PickupWindow.Until):
+		return &eta, entity.SLAAtRisk
+	}
+	return &eta, entity.SLAOnTrack
+}
+
+func (m *monitor) Check(ctx context.Context) (int, error) {
+	log := m.log.With("method", "Check")
+
+	shipments, err := m.storage.ClaimSLAChecks(ctx, m.cfg.CheckInterval, m.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	transits := make(map[string]time.Duration)
+	for _, shipment := range shipments {
+		log := log.With("shipment_id", shipment.ID)
+
+		history, err := m.storage.GetStatusHistory(ctx, shipment.ID)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to get status history", slog.String("error", err.Error()))
+			continue
+		}
+		transit, ok := transits[shipment.Route]
+		if !ok {
+			transit = m.Transit(ctx, shipment.Route, now).Duration
+			transits[shipment.Route] = transit
+		}
+
+		eta, status := estimate(shipment, history, transit, now)
+		if status == entity.SLABreached && shipment.SLAStatus != entity.SLABreached {
+			// Published before the status is stored, so a failure is retried
+			// on the next check rather than the breach lost
+			err := m.publisher.Publish(ctx, &entity.SLABreach{
+				ShipmentID: shipment.ID,
+				Route:      shipment.Route,
+				Status:     shipment.Status,
+				CustomerID: shipment.CustomerID,
+				Deadline:   shipment.DeliveryWindow.Until,
+				ETA:        eta,
+				DetectedAt: now,
+			})
+			if err != nil {
+				log.ErrorContext(ctx, "failed to publish sla breach", slog.String("error", err.Error()))
+				continue
+			}
+		}
+
+		if err := m.storage.UpdateSLA(ctx, shipment.ID, eta, status); err != nil {
+			log.ErrorContext(ctx, "failed to store sla status", slog.String("error", err.Error()))
+			continue
+		}
+		if status != shipment.SLAStatus {
+			log.InfoContext(ctx, "sla status changed",
+				slog.String("from", shipment.SLAStatus),
+				slog.String("to", status),
+				slog.Time("eta", *eta))
+		}
+	}
+
+	return len(shipments), nil
+}
+
+func (m *monitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.Check(ctx); err != nil {
+			m.log.ErrorContext(ctx, "sla check failed", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
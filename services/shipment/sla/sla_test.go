@@ -0,0 +1,280 @@
+package sla_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/pkg/config"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/sla"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+var now = time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+
+func testConfig() *config.SLAConfig {
+	return &config.SLAConfig{
+		PickupWithin:   24 * time.Hour,
+		DeliveryBuffer: 12 * time.Hour,
+		SpeedKmh:       50,
+		DefaultTransit: 72 * time.Hour,
+		MinSamples:     3,
+		HistoryWindow:  90 * 24 * time.Hour,
+		CheckInterval:  time.Minute,
+		BatchSize:      100,
+	}
+}
+
+type publisher struct {
+	breaches []*entity.SLABreach
+	err      error
+}
+
+func (p *publisher) Publish(ctx context.Context, breach *entity.SLABreach) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.breaches = append(p.breaches, breach)
+	return nil
+}
+
+func newMonitor(t *testing.T) (sla.Monitor, storage.Storage, *publisher) {
+	t.Helper()
+	log := slog.New(slog.DiscardHandler)
+	s := storage.NewMemory(log)
+	p := &publisher{}
+	return sla.New(log, s, p, testConfig()), s, p
+}
+
+// deliver inserts a shipment on route delivered transit after its pickup
+func deliver(t *testing.T, s storage.Storage, route string, transit time.Duration) {
+	t.Helper()
+	created := now.Add(-10 * 24 * time.Hour)
+	_, err := s.InsertShipment(context.Background(), &entity.Shipment{
+		ID: uuid.NewString(), Route: route, Price: 1000, Status: entity.StatusDelivered,
+		CustomerID: uuid.NewString(), CreatedAt: created,
+	}, []entity.StatusChange{
+		{Status: entity.StatusCreated, ChangedAt: created},
+		{Status: entity.StatusPickedUp, ChangedAt: created.Add(time.Hour)},
+		{Status: entity.StatusDelivered, ChangedAt: created.Add(time.Hour + transit)},
+	})
+	if err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+}
+
+func TestTransit(t *testing.T) {
+	m, s, _ := newMonitor(t)
+	ctx := context.Background()
+
+	for _, hours := range []int{20, 30, 22} {
+		deliver(t, s, "ALMATY→ASTANA", time.Duration(hours)*time.Hour)
+	}
+	// Two deliveries are too few for the history of SHYMKENT→ALMATY
+	deliver(t, s, "SHYMKENT→ALMATY", time.Hour)
+	deliver(t, s, "SHYMKENT→ALMATY", time.Hour)
+
+	tests := []struct {
+		route string
+		want  sla.Transit
+	}{
+		{"ALMATY→ASTANA", sla.Transit{Duration: 22 * time.Hour, Source: sla.SourceHistory, Samples: 3}},
+		// 690 km at 50 km/h
+		{"SHYMKENT→ALMATY", sla.Transit{Duration: 13*time.Hour + 48*time.Minute, Source: sla.SourceDistance}},
+		{"ALMATY→Warehouse 7", sla.Transit{Duration: 72 * time.Hour, Source: sla.SourceDefault}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.route, func(t *testing.T) {
+			if got := m.Transit(ctx, tt.route, now); got != tt.want {
+				t.Errorf("Transit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	m, _, _ := newMonitor(t)
+	ctx := context.Background()
+
+	pickup, delivery := m.Plan(ctx, "SHYMKENT→ALMATY", nil, nil, now.Add(42*time.Second))
+	wantPickup := &entity.Window{From: now, Until: now.Add(24 * time.Hour)}
+	transit := 13*time.Hour + 48*time.Minute
+	wantDelivery := &entity.Window{From: now.Add(transit), Until: now.Add(24*time.Hour + transit + 12*time.Hour)}
+	if *pickup != *wantPickup || *delivery != *wantDelivery {
+		t.Errorf("Plan = %+v, %+v, want %+v, %+v", pickup, delivery, wantPickup, wantDelivery)
+	}
+
+	given := &entity.Window{From: now.Add(48 * time.Hour), Until: now.Add(50 * time.Hour)}
+	pickup, delivery = m.Plan(ctx, "ALMATY→Warehouse 7", given, nil, now)
+	if pickup != given || delivery.From != given.From.Add(72*time.Hour) {
+		t.Errorf("Plan with a pickup window = %+v, %+v", pickup, delivery)
+	}
+
+	pickup, delivery = m.Plan(ctx, "ALMATY→Warehouse 7", nil, given, now)
+	if delivery != given || pickup.From != now {
+		t.Errorf("Plan with a delivery window = %+v, %+v", pickup, delivery)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	ctx := context.Background()
+	// SHYMKENT→ALMATY takes 13h48m by distance
+	transit := 13*time.Hour + 48*time.Minute
+
+	tests := []struct {
+		name       string
+		pickup     *entity.Window
+		delivery   *entity.Window
+		pickedUpAt time.Time
+		slaStatus  string
+		wantETA    time.Time
+		wantStatus string
+	}{
+		{
+			name:       "on track before pickup",
+			pickup:     &entity.Window{From: now.Add(2 * time.Hour), Until: now.Add(26 * time.Hour)},
+			delivery:   &entity.Window{From: now.Add(16 * time.Hour), Until: now.Add(52 * time.Hour)},
+			wantETA:    now.Add(2*time.Hour + transit),
+			wantStatus: entity.SLAOnTrack,
+		},
+		{
+			name:       "on track after pickup",
+			pickup:     &entity.Window{From: now.Add(-6 * time.Hour), Until: now.Add(18 * time.Hour)},
+			delivery:   &entity.Window{From: now, Until: now.Add(12 * time.Hour)},
+			pickedUpAt: now.Add(-5 * time.Hour),
+			wantETA:    now.Add(-5*time.Hour + transit),
+			wantStatus: entity.SLAOnTrack,
+		},
+		{
+			name:       "late eta",
+			delivery:   &entity.Window{From: now, Until: now.Add(10 * time.Hour)},
+			wantETA:    now.Add(transit),
+			wantStatus: entity.SLAAtRisk,
+		},
+		{
+			name:       "pickup window missed",
+			pickup:     &entity.Window{From: now.Add(-30 * time.Hour), Until: now.Add(-6 * time.Hour)},
+			delivery:   &entity.Window{From: now, Until: now.Add(48 * time.Hour)},
+			wantETA:    now.Add(transit),
+			wantStatus: entity.SLAAtRisk,
+		},
+		{
+			name:       "overdue",
+			delivery:   &entity.Window{From: now.Add(-20 * time.Hour), Until: now.Add(-time.Hour)},
+			pickedUpAt: now.Add(-40 * time.Hour),
+			wantETA:    now,
+			wantStatus: entity.SLABreached,
+		},
+		{
+			name:       "breach is final",
+			delivery:   &entity.Window{From: now, Until: now.Add(48 * time.Hour)},
+			slaStatus:  entity.SLABreached,
+			wantETA:    now.Add(transit),
+			wantStatus: entity.SLABreached,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, s, _ := newMonitor(t)
+			shipment := &entity.Shipment{
+				ID: uuid.NewString(), Route: "SHYMKENT→ALMATY", Price: 1000, Status: entity.StatusCreated,
+				CustomerID: uuid.NewString(), CreatedAt: now.Add(-48 * time.Hour),
+				PickupWindow: tt.pickup, DeliveryWindow: tt.delivery, SLAStatus: tt.slaStatus,
+			}
+			history := []entity.StatusChange{{Status: entity.StatusCreated, ChangedAt: shipment.CreatedAt}}
+			if !tt.pickedUpAt.IsZero() {
+				shipment.Status = entity.StatusPickedUp
+				history = append(history, entity.StatusChange{Status: entity.StatusPickedUp, ChangedAt: tt.pickedUpAt})
+			}
+			if _, err := s.InsertShipment(ctx, shipment, history); err != nil {
+				t.Fatalf("InsertShipment: %v", err)
+			}
+
+			eta, status, err := m.Estimate(ctx, shipment, now)
+			if err != nil {
+				t.Fatalf("Estimate: %v", err)
+			}
+			if eta == nil || !eta.Equal(tt.wantETA) || status != tt.wantStatus {
+				t.Errorf("Estimate = %v, %q, want %v, %q", eta, status, tt.wantETA, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestEstimateClosed(t *testing.T) {
+	m, _, _ := newMonitor(t)
+
+	shipment := &entity.Shipment{Route: "SHYMKENT→ALMATY", Status: entity.StatusDelivered, SLAStatus: entity.SLAOnTrack}
+	eta, status, err := m.Estimate(context.Background(), shipment, now)
+	if err != nil || eta != nil || status != entity.SLAOnTrack {
+		t.Errorf("Estimate of a delivered shipment = %v, %q, %v", eta, status, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	log := slog.New(slog.DiscardHandler)
+	s := storage.NewMemory(log)
+	p := &publisher{}
+	cfg := testConfig()
+	// Every check re-estimates every open shipment
+	cfg.CheckInterval = 0
+	m := sla.New(log, s, p, cfg)
+	ctx := context.Background()
+	created := time.Now().UTC().Truncate(time.Second).Add(-72 * time.Hour)
+
+	insert := func(delivery *entity.Window) *entity.Shipment {
+		t.Helper()
+		shipment := &entity.Shipment{
+			ID: uuid.NewString(), Route: "SHYMKENT→ALMATY", Price: 1000, Status: entity.StatusCreated,
+			CustomerID: uuid.NewString(), CreatedAt: created, DeliveryWindow: delivery,
+		}
+		history := []entity.StatusChange{{Status: entity.StatusCreated, ChangedAt: created}}
+		if _, err := s.InsertShipment(ctx, shipment, history); err != nil {
+			t.Fatalf("InsertShipment: %v", err)
+		}
+		return shipment
+	}
+	late := insert(&entity.Window{From: created, Until: created.Add(24 * time.Hour)})
+	onTime := insert(&entity.Window{From: created, Until: created.Add(240 * time.Hour)})
+
+	p.err = errors.New("webhook down")
+	if checked, err := m.Check(ctx); err != nil || checked != 2 {
+		t.Fatalf("Check = %d, %v, want 2 shipments", checked, err)
+	}
+	got, err := s.GetShipment(ctx, late.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if got.SLAStatus != entity.SLAOnTrack {
+		t.Errorf("sla_status after a failed publish = %q, want it kept for the retry", got.SLAStatus)
+	}
+	if got, err := s.GetShipment(ctx, onTime.ID); err != nil || got.SLAStatus != entity.SLAOnTrack || got.ETA == nil {
+		t.Errorf("on time shipment after Check = %+v, %v", got, err)
+	}
+
+	p.err = nil
+	time.Sleep(10 * time.Millisecond)
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(p.breaches) != 1 || p.breaches[0].ShipmentID != late.ID || !p.breaches[0].Deadline.Equal(late.DeliveryWindow.Until) {
+		t.Fatalf("breaches = %+v, want the late shipment", p.breaches)
+	}
+	if got, err := s.GetShipment(ctx, late.ID); err != nil || got.SLAStatus != entity.SLABreached {
+		t.Errorf("late shipment after Check = %+v, %v", got, err)
+	}
+
+	// A breached shipment is not checked again
+	time.Sleep(10 * time.Millisecond)
+	if _, err := m.Check(ctx); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if len(p.breaches) != 1 {
+		t.Errorf("breach published %d times", len(p.breaches))
+	}
+}
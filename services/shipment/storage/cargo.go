@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// cargoColumns are the cargo fields of shipments, all NULL without cargo
+const cargoColumns = `cargo_type, weight_kg, volume_m3, declared_value, package_count, packages, ` +
+	`dangerous_class, un_number, temperature_min_c, temperature_max_c`
+
+// cargoArgs returns the values of cargoColumns in order
+func cargoArgs(c *entity.Cargo) ([]any, error) {
+	if c == nil {
+		return make([]any, 10), nil
+	}
+
+	var packages []byte
+	if len(c.Packages) > 0 {
+		var err error
+		if packages, err = json.Marshal(c.Packages); err != nil {
+			return nil, fmt.Errorf("failed to marshal packages: %w", err)
+		}
+	}
+	return []any{
+		string(c.Type), c.WeightKg, c.VolumeM3, c.DeclaredValue, c.PackageCount, packages,
+		nullString(c.DangerousClass), nullString(c.UNNumber),
+		c.TemperatureMinC, c.TemperatureMaxC,
+	}, nil
+}
+
+// cargoRow scans cargoColumns
+type cargoRow struct {
+	cargoType       sql.NullString
+	weightKg        sql.NullFloat64
+	volumeM3        sql.NullFloat64
+	declaredValue   sql.NullInt64
+	packageCount    sql.NullInt64
+	packages        []byte
+	dangerousClass  sql.NullString
+	unNumber        sql.NullString
+	temperatureMinC sql.NullFloat64
+	temperatureMaxC sql.NullFloat64
+}
+
+func (r *cargoRow) dest() []any {
+	return []any{
+		&r.cargoType, &r.weightKg, &r.volumeM3, &r.declaredValue, &r.packageCount, &r.packages,
+		&r.dangerousClass, &r.unNumber, &r.temperatureMinC, &r.temperatureMaxC,
+	}
+}
+
+func (r *cargoRow) cargo() (*entity.Cargo, error) {
+	if !r.cargoType.Valid {
+		return nil, nil
+	}
+
+	c := &entity.Cargo{
+		Type:           entity.CargoType(r.cargoType.String),
+		WeightKg:       r.weightKg.Float64,
+		VolumeM3:       r.volumeM3.Float64,
+		DeclaredValue:  int(r.declaredValue.Int64),
+		PackageCount:   int(r.packageCount.Int64),
+		DangerousClass: r.dangerousClass.String,
+		UNNumber:       r.unNumber.String,
+	}
+	if len(r.packages) > 0 {
+		if err := json.Unmarshal(r.packages, &c.Packages); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal packages: %w", err)
+		}
+	}
+	if r.temperatureMinC.Valid {
+		c.TemperatureMinC = &r.temperatureMinC.Float64
+	}
+	if r.temperatureMaxC.Valid {
+		c.TemperatureMaxC = &r.temperatureMaxC.Float64
+	}
+	return c, nil
+}
@@ -0,0 +1,677 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+type memoryState struct {
+	// cargo, windows and ETA of a stored shipment are copied in and out,
+	// never changed in place
+	shipments map[string]entity.Shipment
+	// slaChecked is sla_checked_at of shipments
+	slaChecked map[string]time.Time
+	// history slices are replaced, never appended to in place, so a shallow
+	// clone is enough
+	history map[string][]entity.StatusChange
+	// events are kept like history, in insertion order; their GPS is copied
+	// in and out
+	events map[string][]entity.Event
+	sagas  map[string]entity.Saga
+	// quotes are never changed once stored
+	quotes map[string]entity.Quote
+	// tariffs are kept as JSON, oldest first, as in the tariffs table
+	tariffs [][]byte
+}
+
+func (st *memoryState) clone() *memoryState {
+	return &memoryState{
+		shipments:  maps.Clone(st.shipments),
+		slaChecked: maps.Clone(st.slaChecked),
+		history:    maps.Clone(st.history),
+		events:     maps.Clone(st.events),
+		sagas:      maps.Clone(st.sagas),
+		quotes:     maps.Clone(st.quotes),
+		tariffs:    slices.Clone(st.tariffs),
+	}
+}
+
+type memory struct {
+	log *slog.Logger
+	// mu serializes every call and is held for the whole of a transaction
+	mu    *sync.Mutex
+	state *memoryState
+	// inTx is set on the Storage passed to WithTx; mu is already held and
+	// now is fixed, as NOW() is in a Postgres transaction
+	inTx bool
+	now  time.Time
+}
+
+// NewMemory returns a Storage that keeps shipments and sagas in memory with
+// the same semantics as the Postgres one. Transactions are serialized: they
+// work on a copy that replaces the state on commit, and calls on the outer
+// Storage wait until the transaction ends.
+func NewMemory(log *slog.Logger) Storage {
+	return &memory{
+		log: log.With("layer", "storage"),
+		mu:  &sync.Mutex{},
+		state: &memoryState{
+			shipments:  make(map[string]entity.Shipment),
+			slaChecked: make(map[string]time.Time),
+			history:    make(map[string][]entity.StatusChange),
+			events:     make(map[string][]entity.Event),
+			sagas:      make(map[string]entity.Saga),
+			quotes:     make(map[string]entity.Quote),
+		},
+	}
+}
+
+func (s *memory) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *memory) clock() time.Time {
+	if s.inTx {
+		return s.now
+	}
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (s *memory) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memory{log: s.log, mu: s.mu, state: s.state.clone(), inTx: true, now: s.clock()}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	*s.state = *tx.state
+	return nil
+}
+
+func (s *memory) GetShipment(ctx context.Context, id string) (*entity.Shipment, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+
+	defer s.lock()()
+
+	shipment, ok := s.state.shipments[parsed.String()]
+	if !ok {
+		return nil, fmt.Errorf("failed to get shipment: %w", sql.ErrNoRows)
+	}
+	return copyShipment(shipment), nil
+}
+
+// GetShipmentPrimary is GetShipment, there is no replica
+func (s *memory) GetShipmentPrimary(ctx context.Context, id string) (*entity.Shipment, error) {
+	return s.GetShipment(ctx, id)
+}
+
+func (s *memory) CreateShipment(ctx context.Context, req *entity.CreateReq, customerID string) (*entity.Shipment, error) {
+	return s.CreateShipmentWithID(ctx, uuid.NewString(), req, customerID)
+}
+
+func (s *memory) CreateShipmentWithID(ctx context.Context, id string, req *entity.CreateReq, customerID string) (*entity.Shipment, error) {
+	parsedID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	parsedCustomerID, err := uuid.Parse(customerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	if req.Price < 0 {
+		return nil, errors.New("failed db insert shipment: price must not be negative")
+	}
+	if err := checkSLA(req.PickupWindow, req.DeliveryWindow, ""); err != nil {
+		return nil, err
+	}
+
+	defer s.lock()()
+
+	if shipment, ok := s.state.shipments[parsedID.String()]; ok {
+		return copyShipment(shipment), nil
+	}
+	quoteID, err := s.checkQuote(req.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	shipment := entity.Shipment{
+		ID:         parsedID.String(),
+		Route:      req.Route,
+		Price:      req.Price,
+		Status:     entity.StatusCreated,
+		CustomerID: parsedCustomerID.String(),
+		QuoteID:    quoteID,
+		Cargo:      req.Cargo.Clone(),
+		CreatedAt:  s.clock(),
+
+		TrackingNumber: s.newTrackingNumber(),
+		PickupWindow:   copyWindow(req.PickupWindow),
+		DeliveryWindow: copyWindow(req.DeliveryWindow),
+	}
+	if shipment.DeliveryWindow != nil {
+		shipment.SLAStatus = entity.SLAOnTrack
+	}
+	s.state.shipments[shipment.ID] = shipment
+	s.state.history[shipment.ID] = []entity.StatusChange{{Status: shipment.Status, ChangedAt: shipment.CreatedAt}}
+
+	return copyShipment(shipment), nil
+}
+
+func (s *memory) InsertShipment(ctx context.Context, shipment *entity.Shipment, history []entity.StatusChange) (bool, error) {
+	parsedID, err := uuid.Parse(shipment.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	parsedCustomerID, err := uuid.Parse(shipment.CustomerID)
+	if err != nil {
+		return false, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	if shipment.Price < 0 {
+		return false, errors.New("failed db insert shipment: price must not be negative")
+	}
+	if err := checkSLA(shipment.PickupWindow, shipment.DeliveryWindow, shipment.SLAStatus); err != nil {
+		return false, err
+	}
+
+	defer s.lock()()
+
+	if _, ok := s.state.shipments[parsedID.String()]; ok {
+		return false, nil
+	}
+	quoteID, err := s.checkQuote(shipment.QuoteID)
+	if err != nil {
+		return false, err
+	}
+
+	stored := *copyShipment(*shipment)
+	stored.ID, stored.CustomerID, stored.QuoteID = parsedID.String(), parsedCustomerID.String(), quoteID
+	stored.TrackingNumber = trackingNumber(shipment)
+	if s.findTrackingNumber(stored.TrackingNumber) != nil {
+		return false, fmt.Errorf("failed db insert shipment: tracking number %s violates idx_shipments_tracking_number", stored.TrackingNumber)
+	}
+	stored.CreatedAt = shipment.CreatedAt.UTC().Truncate(time.Microsecond)
+	if stored.DeliveryWindow != nil && stored.SLAStatus == "" {
+		stored.SLAStatus = entity.SLAOnTrack
+	}
+	s.state.shipments[stored.ID] = stored
+
+	changes := make([]entity.StatusChange, len(history))
+	for i, h := range history {
+		changes[i] = entity.StatusChange{Status: h.Status, ChangedAt: h.ChangedAt.UTC().Truncate(time.Microsecond)}
+	}
+	s.state.history[stored.ID] = changes
+
+	return true, nil
+}
+
+func (s *memory) GetStatusHistory(ctx context.Context, id string) ([]entity.StatusChange, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+
+	defer s.lock()()
+
+	history := slices.Clone(s.state.history[parsed.String()])
+	slices.SortStableFunc(history, func(a, b entity.StatusChange) int {
+		return a.ChangedAt.Compare(b.ChangedAt)
+	})
+	return history, nil
+}
+
+func (s *memory) ListShipments(ctx context.Context, filter entity.ShipmentFilter) ([]*entity.Shipment, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, errors.New("failed to list shipments: limit and offset must not be negative")
+	}
+
+	defer s.lock()()
+
+	var matched []entity.Shipment
+	for _, shipment := range s.state.shipments {
+		if (filter.Status == "" || shipment.Status == filter.Status) &&
+			(filter.SLAStatus == "" || shipment.SLAStatus == filter.SLAStatus) &&
+			(filter.CustomerID == "" || shipment.CustomerID == filter.CustomerID) {
+			matched = append(matched, shipment)
+		}
+	}
+	slices.SortFunc(matched, func(a, b entity.Shipment) int {
+		if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	matched = matched[min(filter.Offset, len(matched)):]
+	matched = matched[:min(filter.Limit, len(matched))]
+
+	var shipments []*entity.Shipment
+	for _, shipment := range matched {
+		shipments = append(shipments, copyShipment(shipment))
+	}
+	return shipments, nil
+}
+
+func (s *memory) GetShipmentByTrackingNumber(ctx context.Context, number string) (*entity.Shipment, error) {
+	defer s.lock()()
+
+	shipment := s.findTrackingNumber(number)
+	if shipment == nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, number)
+	}
+	return copyShipment(*shipment), nil
+}
+
+// findTrackingNumber returns the stored shipment with number, nil if there
+// is none
+func (s *memory) findTrackingNumber(number string) *entity.Shipment {
+	for _, shipment := range s.state.shipments {
+		if shipment.TrackingNumber == number {
+			return &shipment
+		}
+	}
+	return nil
+}
+
+// newTrackingNumber returns a tracking number no shipment has, as the
+// unique index would reject a taken one
+func (s *memory) newTrackingNumber() string {
+	for {
+		if number := entity.NewTrackingNumber(); s.findTrackingNumber(number) == nil {
+			return number
+		}
+	}
+}
+
+func (s *memory) AddEvent(ctx context.Context, event *entity.Event) (*entity.Event, error) {
+	parsed, err := uuid.Parse(event.ShipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed db insert event: %w", err)
+	}
+	if !slices.Contains(entity.EventTypes, event.Type) {
+		return nil, fmt.Errorf("failed db insert event: %q violates the type check", event.Type)
+	}
+	if gps := event.GPS; gps != nil && (gps.Lat < -90 || gps.Lat > 90 || gps.Lon < -180 || gps.Lon > 180) {
+		return nil, errors.New("failed db insert event: violates the lat and lon checks")
+	}
+
+	defer s.lock()()
+
+	if _, ok := s.state.shipments[parsed.String()]; !ok {
+		return nil, fmt.Errorf("failed db insert event: shipment %s does not exist", parsed)
+	}
+
+	stored := *event
+	stored.ID = uuid.NewString()
+	stored.ShipmentID = parsed.String()
+	stored.GPS = copyGPS(event.GPS)
+	stored.OccurredAt = event.OccurredAt.UTC().Truncate(time.Microsecond)
+	stored.CreatedAt = s.clock()
+	s.state.events[stored.ShipmentID] = append(slices.Clip(s.state.events[stored.ShipmentID]), stored)
+
+	added := stored
+	added.GPS = copyGPS(stored.GPS)
+	return &added, nil
+}
+
+func (s *memory) ListEvents(ctx context.Context, shipmentID string) ([]*entity.Event, error) {
+	parsed, err := uuid.Parse(shipmentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	defer s.lock()()
+
+	stored := slices.Clone(s.state.events[parsed.String()])
+	slices.SortStableFunc(stored, func(a, b entity.Event) int {
+		return a.OccurredAt.Compare(b.OccurredAt)
+	})
+	var events []*entity.Event
+	for _, event := range stored {
+		event.GPS = copyGPS(event.GPS)
+		events = append(events, &event)
+	}
+	return events, nil
+}
+
+func copyGPS(gps *entity.GPS) *entity.GPS {
+	if gps == nil {
+		return nil
+	}
+	c := *gps
+	return &c
+}
+
+func (s *memory) ClaimSLAChecks(ctx context.Context, recheckAfter time.Duration, limit int) ([]*entity.Shipment, error) {
+	if limit < 0 {
+		return nil, errors.New("failed db claim sla checks: limit must not be negative")
+	}
+
+	defer s.lock()()
+
+	now := s.clock()
+	deadline := now.Add(-recheckAfter)
+
+	var due []entity.Shipment
+	for _, shipment := range s.state.shipments {
+		checked, ok := s.state.slaChecked[shipment.ID]
+		if (shipment.SLAStatus == entity.SLAOnTrack || shipment.SLAStatus == entity.SLAAtRisk) &&
+			shipment.Open() && (!ok || checked.Before(deadline)) {
+			due = append(due, shipment)
+		}
+	}
+	// NULLS FIRST, then the least recently checked
+	slices.SortFunc(due, func(a, b entity.Shipment) int {
+		return s.state.slaChecked[a.ID].Compare(s.state.slaChecked[b.ID])
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var shipments []*entity.Shipment
+	for _, shipment := range due {
+		s.state.slaChecked[shipment.ID] = now
+		shipments = append(shipments, copyShipment(shipment))
+	}
+	return shipments, nil
+}
+
+func (s *memory) UpdateSLA(ctx context.Context, id string, eta *time.Time, status string) error {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return fmt.Errorf("failed db update sla: %w", err)
+	}
+	if !slices.Contains(entity.SLAStatuses, status) {
+		return fmt.Errorf("failed db update sla: %q violates the sla_status check", status)
+	}
+
+	defer s.lock()()
+
+	shipment, ok := s.state.shipments[parsed.String()]
+	if !ok || !shipment.Open() || shipment.SLAStatus == entity.SLABreached {
+		return nil
+	}
+	shipment.ETA = copyTime(eta)
+	shipment.SLAStatus = status
+	s.state.shipments[shipment.ID] = shipment
+	return nil
+}
+
+func (s *memory) LaneTransit(ctx context.Context, route string, since time.Time) (entity.LaneTransit, error) {
+	defer s.lock()()
+
+	var transits []time.Duration
+	for _, shipment := range s.state.shipments {
+		if shipment.Route != route {
+			continue
+		}
+		var pickedUp, delivered []time.Time
+		for _, h := range s.state.history[shipment.ID] {
+			switch h.Status {
+			case entity.StatusPickedUp:
+				pickedUp = append(pickedUp, h.ChangedAt)
+			case entity.StatusDelivered:
+				delivered = append(delivered, h.ChangedAt)
+			}
+		}
+		// As the join, every pair of a pickup and a later delivery counts
+		for _, p := range pickedUp {
+			for _, d := range delivered {
+				if !d.Before(since) && d.After(p) {
+					transits = append(transits, d.Sub(p))
+				}
+			}
+		}
+	}
+
+	transit := entity.LaneTransit{Samples: len(transits)}
+	if len(transits) == 0 {
+		return transit, nil
+	}
+	// percentile_cont interpolates between the middle two
+	slices.Sort(transits)
+	mid := len(transits) / 2
+	transit.Median = transits[mid]
+	if len(transits)%2 == 0 {
+		transit.Median = (transits[mid-1] + transits[mid]) / 2
+	}
+	transit.Median = transit.Median.Round(time.Second)
+	return transit, nil
+}
+
+// checkSLA enforces the window and sla_status checks of shipments
+func checkSLA(pickup, delivery *entity.Window, status string) error {
+	if pickup != nil && !pickup.Until.After(pickup.From) {
+		return errors.New("failed db insert shipment: violates check constraint shipments_pickup_window")
+	}
+	if delivery != nil && !delivery.Until.After(delivery.From) {
+		return errors.New("failed db insert shipment: violates check constraint shipments_delivery_window")
+	}
+	if status != "" && !slices.Contains(entity.SLAStatuses, status) {
+		return fmt.Errorf("failed db insert shipment: %q violates the sla_status check", status)
+	}
+	return nil
+}
+
+// copyShipment returns a copy of shipment sharing nothing with it, with times
+// at the precision of a TIMESTAMP
+func copyShipment(shipment entity.Shipment) *entity.Shipment {
+	shipment.Cargo = shipment.Cargo.Clone()
+	shipment.PickupWindow = copyWindow(shipment.PickupWindow)
+	shipment.DeliveryWindow = copyWindow(shipment.DeliveryWindow)
+	shipment.ETA = copyTime(shipment.ETA)
+	return &shipment
+}
+
+func copyWindow(w *entity.Window) *entity.Window {
+	if w == nil {
+		return nil
+	}
+	return &entity.Window{From: w.From.UTC().Truncate(time.Microsecond), Until: w.Until.UTC().Truncate(time.Microsecond)}
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := t.UTC().Truncate(time.Microsecond)
+	return &c
+}
+
+// checkQuote enforces the foreign key and the unique constraint of
+// shipments.quote_id, returning the normalized ID; "" is NULL
+func (s *memory) checkQuote(id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return "", fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	if _, ok := s.state.quotes[parsed.String()]; !ok {
+		return "", fmt.Errorf("failed db insert shipment: quote %s does not exist", parsed)
+	}
+	for _, shipment := range s.state.shipments {
+		if shipment.QuoteID == parsed.String() {
+			return "", fmt.Errorf("failed db insert shipment: %w: %s by shipment %s", entity.ErrQuoteUsed, parsed, shipment.ID)
+		}
+	}
+	return parsed.String(), nil
+}
+
+func (s *memory) CreateQuote(ctx context.Context, quote *entity.Quote) (*entity.Quote, error) {
+	if quote.Total < 0 {
+		return nil, errors.New("failed db insert quote: total must not be negative")
+	}
+
+	defer s.lock()()
+
+	stored := *quote
+	stored.ID = uuid.NewString()
+	stored.ShipmentID = ""
+	stored.Cargo = quote.Cargo.Clone()
+	stored.Items = slices.Clone(quote.Items)
+	stored.CreatedAt = quote.CreatedAt.UTC().Truncate(time.Microsecond)
+	stored.ExpiresAt = quote.ExpiresAt.UTC().Truncate(time.Microsecond)
+	s.state.quotes[stored.ID] = stored
+
+	created := *quote
+	created.ID = stored.ID
+	return &created, nil
+}
+
+func (s *memory) GetQuote(ctx context.Context, id string) (*entity.Quote, error) {
+	parsed, err := uuid.Parse(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+
+	defer s.lock()()
+
+	quote, ok := s.state.quotes[parsed.String()]
+	if !ok {
+		return nil, fmt.Errorf("failed to get quote: %w", sql.ErrNoRows)
+	}
+	quote.Cargo = quote.Cargo.Clone()
+	quote.Items = slices.Clone(quote.Items)
+	for _, shipment := range s.state.shipments {
+		if shipment.QuoteID == quote.ID {
+			quote.ShipmentID = shipment.ID
+		}
+	}
+	return &quote, nil
+}
+
+func (s *memory) GetTariff(ctx context.Context) (*entity.Tariff, error) {
+	defer s.lock()()
+
+	if len(s.state.tariffs) == 0 {
+		return nil, fmt.Errorf("failed to get tariff: %w", sql.ErrNoRows)
+	}
+	tariff := &entity.Tariff{}
+	if err := json.Unmarshal(s.state.tariffs[len(s.state.tariffs)-1], tariff); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tariff: %w", err)
+	}
+	return tariff, nil
+}
+
+func (s *memory) SaveTariff(ctx context.Context, tariff *entity.Tariff) error {
+	body, err := json.Marshal(tariff)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tariff: %w", err)
+	}
+
+	defer s.lock()()
+
+	s.state.tariffs = append(s.state.tariffs, body)
+	return nil
+}
+
+func (s *memory) CreateSaga(ctx context.Context, saga *entity.Saga) (*entity.Saga, error) {
+	defer s.lock()()
+
+	now := s.clock()
+	stored := entity.Saga{
+		ID:         uuid.NewString(),
+		Type:       saga.Type,
+		State:      saga.State,
+		Request:    saga.Request,
+		ShipmentID: uuid.NewString(),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	s.state.sagas[stored.ID] = stored
+
+	created := *saga
+	created.ID, created.ShipmentID = stored.ID, stored.ShipmentID
+	created.CreatedAt, created.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	return &created, nil
+}
+
+func (s *memory) UpdateSaga(ctx context.Context, saga *entity.Saga) error {
+	id, err := uuid.Parse(saga.ID)
+	if err != nil {
+		return fmt.Errorf("failed db update saga: %w", err)
+	}
+	customerID := ""
+	if saga.CustomerID != "" {
+		parsed, err := uuid.Parse(saga.CustomerID)
+		if err != nil {
+			return fmt.Errorf("failed db update saga: %w", err)
+		}
+		customerID = parsed.String()
+	}
+
+	defer s.lock()()
+
+	stored, ok := s.state.sagas[id.String()]
+	if !ok {
+		return nil
+	}
+	stored.State = saga.State
+	stored.CustomerID = customerID
+	stored.CustomerCreated = saga.CustomerCreated
+	stored.Error = saga.Error
+	stored.UpdatedAt = s.clock()
+	s.state.sagas[stored.ID] = stored
+
+	return nil
+}
+
+func (s *memory) ClaimStaleSagas(ctx context.Context, staleAfter time.Duration, limit int) ([]*entity.Saga, error) {
+	if limit < 0 {
+		return nil, errors.New("failed db claim sagas: limit must not be negative")
+	}
+
+	defer s.lock()()
+
+	now := s.clock()
+	deadline := now.Add(-staleAfter)
+
+	var stale []entity.Saga
+	for _, saga := range s.state.sagas {
+		if !saga.Terminal() && saga.UpdatedAt.Before(deadline) {
+			stale = append(stale, saga)
+		}
+	}
+	slices.SortFunc(stale, func(a, b entity.Saga) int {
+		return a.UpdatedAt.Compare(b.UpdatedAt)
+	})
+	if len(stale) > limit {
+		stale = stale[:limit]
+	}
+
+	var sagas []*entity.Saga
+	for _, saga := range stale {
+		saga.Attempts++
+		saga.UpdatedAt = now
+		s.state.sagas[saga.ID] = saga
+		sagas = append(sagas, &saga)
+	}
+
+	return sagas, nil
+}
@@ -0,0 +1,8 @@
+package storage
+
+import "embed"
+
+// Migrations holds the SQL migrations compiled into the binary
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
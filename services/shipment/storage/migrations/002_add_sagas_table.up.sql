@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS sagas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    type TEXT NOT NULL,
+    state TEXT NOT NULL,
+    request JSONB NOT NULL,
+    shipment_id UUID NOT NULL DEFAULT gen_random_uuid(),
+    customer_id UUID,
+    customer_created BOOLEAN NOT NULL DEFAULT FALSE,
+    error TEXT NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_sagas_state_updated_at ON sagas(state, updated_at);
+
+COMMENT ON TABLE sagas IS 'Orchestrated sagas spanning shipment-service and customer-service';
+COMMENT ON COLUMN sagas.state IS 'Saga state: STARTED, CUSTOMER_UPSERTED, COMPLETED, COMPENSATING, COMPENSATED';
+COMMENT ON COLUMN sagas.shipment_id IS 'Shipment ID assigned up front so the insert step is idempotent';
+COMMENT ON COLUMN sagas.customer_created IS 'Whether the saga inserted the customer and must delete it on compensation';
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS shipment_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    shipment_id UUID NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_status_history_shipment_id ON shipment_status_history(shipment_id, changed_at);
+
+INSERT INTO shipment_status_history (shipment_id, status, changed_at)
+SELECT id, status, created_at FROM shipments;
+
+COMMENT ON TABLE shipment_status_history IS 'Every status a shipment had, starting with the one it was created in';
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_shipments_cargo_type;
+
+ALTER TABLE shipments
+    DROP COLUMN IF EXISTS cargo_type,
+    DROP COLUMN IF EXISTS weight_kg,
+    DROP COLUMN IF EXISTS volume_m3,
+    DROP COLUMN IF EXISTS declared_value,
+    DROP COLUMN IF EXISTS package_count,
+    DROP COLUMN IF EXISTS packages,
+    DROP COLUMN IF EXISTS dangerous_class,
+    DROP COLUMN IF EXISTS un_number,
+    DROP COLUMN IF EXISTS temperature_min_c,
+    DROP COLUMN IF EXISTS temperature_max_c;
@@ -0,0 +1,19 @@
+ALTER TABLE shipments
+    ADD COLUMN IF NOT EXISTS cargo_type TEXT
+        CHECK (cargo_type IN ('GENERAL', 'FRAGILE', 'DANGEROUS', 'TEMPERATURE_CONTROLLED')),
+    ADD COLUMN IF NOT EXISTS weight_kg NUMERIC CHECK (weight_kg > 0),
+    ADD COLUMN IF NOT EXISTS volume_m3 NUMERIC CHECK (volume_m3 > 0),
+    ADD COLUMN IF NOT EXISTS declared_value NUMERIC CHECK (declared_value >= 0),
+    ADD COLUMN IF NOT EXISTS package_count INTEGER CHECK (package_count > 0),
+    ADD COLUMN IF NOT EXISTS packages JSONB,
+    ADD COLUMN IF NOT EXISTS dangerous_class TEXT,
+    ADD COLUMN IF NOT EXISTS un_number TEXT,
+    ADD COLUMN IF NOT EXISTS temperature_min_c NUMERIC,
+    ADD COLUMN IF NOT EXISTS temperature_max_c NUMERIC;
+
+CREATE INDEX IF NOT EXISTS idx_shipments_cargo_type ON shipments(cargo_type);
+
+COMMENT ON COLUMN shipments.cargo_type IS 'Cargo type: GENERAL, FRAGILE, DANGEROUS, TEMPERATURE_CONTROLLED; NULL if no cargo was given';
+COMMENT ON COLUMN shipments.weight_kg IS 'Gross weight, packaging included';
+COMMENT ON COLUMN shipments.packages IS 'Outer dimensions in cm and quantity of each kind of package';
+COMMENT ON COLUMN shipments.dangerous_class IS 'ADR class of DANGEROUS cargo';
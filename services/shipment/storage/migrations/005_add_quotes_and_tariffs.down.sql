@@ -0,0 +1,4 @@
+ALTER TABLE shipments DROP COLUMN IF EXISTS quote_id;
+
+DROP TABLE IF EXISTS quotes;
+DROP TABLE IF EXISTS tariffs;
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS tariffs (
+    id BIGSERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    body JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS quotes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    route TEXT NOT NULL,
+    customer_idn TEXT NOT NULL,
+    cargo JSONB NOT NULL,
+    items JSONB NOT NULL,
+    total NUMERIC NOT NULL CHECK (total >= 0),
+    currency TEXT NOT NULL,
+    tariff TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE shipments ADD COLUMN IF NOT EXISTS quote_id UUID UNIQUE REFERENCES quotes(id);
+
+COMMENT ON TABLE tariffs IS 'Tariff versions; the newest one prices quotes when pricing.tariff_source is db';
+COMMENT ON TABLE quotes IS 'Itemized prices valid until expires_at';
+COMMENT ON COLUMN shipments.quote_id IS 'Quote the price was locked with; a quote is used at most once';
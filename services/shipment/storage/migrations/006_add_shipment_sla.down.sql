@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS idx_shipments_route;
+DROP INDEX IF EXISTS idx_shipments_sla_checked_at;
+DROP INDEX IF EXISTS idx_shipments_sla_status;
+
+ALTER TABLE shipments
+    DROP CONSTRAINT IF EXISTS shipments_delivery_window,
+    DROP CONSTRAINT IF EXISTS shipments_pickup_window,
+    DROP COLUMN IF EXISTS pickup_from,
+    DROP COLUMN IF EXISTS pickup_until,
+    DROP COLUMN IF EXISTS delivery_from,
+    DROP COLUMN IF EXISTS delivery_until,
+    DROP COLUMN IF EXISTS eta,
+    DROP COLUMN IF EXISTS sla_status,
+    DROP COLUMN IF EXISTS sla_checked_at;
@@ -0,0 +1,20 @@
+ALTER TABLE shipments
+    ADD COLUMN IF NOT EXISTS pickup_from TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS pickup_until TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS delivery_from TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS delivery_until TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS eta TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS sla_status TEXT CHECK (sla_status IN ('ON_TRACK', 'AT_RISK', 'BREACHED')),
+    ADD COLUMN IF NOT EXISTS sla_checked_at TIMESTAMP,
+    ADD CONSTRAINT shipments_pickup_window CHECK (pickup_until > pickup_from),
+    ADD CONSTRAINT shipments_delivery_window CHECK (delivery_until > delivery_from);
+
+CREATE INDEX IF NOT EXISTS idx_shipments_sla_status ON shipments(sla_status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_shipments_sla_checked_at ON shipments(sla_checked_at NULLS FIRST)
+    WHERE sla_status IN ('ON_TRACK', 'AT_RISK') AND status NOT IN ('DELIVERED', 'CANCELLED');
+CREATE INDEX IF NOT EXISTS idx_shipments_route ON shipments(route);
+
+COMMENT ON COLUMN shipments.delivery_until IS 'Delivery deadline of the SLA';
+COMMENT ON COLUMN shipments.eta IS 'Estimated delivery time, refreshed by the SLA monitor while the shipment is open';
+COMMENT ON COLUMN shipments.sla_status IS 'SLA status: ON_TRACK, AT_RISK, BREACHED; NULL without a delivery window';
+COMMENT ON COLUMN shipments.sla_checked_at IS 'When the SLA monitor last claimed the shipment';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS shipment_events;
+
+DROP INDEX IF EXISTS idx_shipments_tracking_number;
+ALTER TABLE shipments DROP COLUMN IF EXISTS tracking_number;
@@ -0,0 +1,25 @@
+-- The default is volatile, so adding the column fills every existing row
+-- with its own value
+ALTER TABLE shipments
+    ADD COLUMN IF NOT EXISTS tracking_number TEXT NOT NULL
+        DEFAULT ('TL' || upper(substr(md5(gen_random_uuid()::text), 1, 12)));
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_tracking_number ON shipments(tracking_number);
+
+CREATE TABLE IF NOT EXISTS shipment_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    shipment_id UUID NOT NULL REFERENCES shipments(id) ON DELETE CASCADE,
+    type TEXT NOT NULL CHECK (type IN ('CHECKPOINT', 'ARRIVAL', 'DEPARTURE', 'DELAY', 'EXCEPTION')),
+    location TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    lat DOUBLE PRECISION CHECK (lat BETWEEN -90 AND 90),
+    lon DOUBLE PRECISION CHECK (lon BETWEEN -180 AND 180),
+    occurred_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    CONSTRAINT shipment_events_gps CHECK ((lat IS NULL) = (lon IS NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_events_shipment_id ON shipment_events(shipment_id, occurred_at);
+
+COMMENT ON TABLE shipment_events IS 'Tracking checkpoints of shipments, reported by carriers';
+COMMENT ON COLUMN shipments.tracking_number IS 'Public identifier of the shipment on the tracking page';
+COMMENT ON COLUMN shipment_events.occurred_at IS 'When the event happened; created_at is when it was reported';
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+func (s *storage) CreateQuote(ctx context.Context, quote *entity.Quote) (*entity.Quote, error) {
+	log := s.log.With("method", "CreateQuote")
+
+	cargo, err := json.Marshal(quote.Cargo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quote cargo: %w", err)
+	}
+	items, err := json.Marshal(quote.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal quote items: %w", err)
+	}
+
+	created := *quote
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO quotes (route, customer_idn, cargo, items, total, currency, tariff, created_at, expires_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
+		RETURNING id`,
+		quote.Route, quote.Customer.IDN, cargo, items, quote.Total, quote.Currency, quote.Tariff,
+		quote.CreatedAt.UTC(), quote.ExpiresAt.UTC()).Scan(&created.ID)
+	if err != nil {
+		log.Error("failed db insert quote", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db insert quote: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (s *storage) GetQuote(ctx context.Context, id string) (*entity.Quote, error) {
+	log := s.log.With("method", "GetQuote")
+
+	// The primary, not the replica: a quote is read right after it is
+	// issued, and whether it was used must be current
+	quote := &entity.Quote{}
+	var cargo, items []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT q.id, q.route, q.customer_idn, q.cargo, q.items, q.total, q.currency, q.tariff,
+			q.created_at, q.expires_at, COALESCE(sh.id::text, '')
+		FROM quotes q LEFT JOIN shipments sh ON sh.quote_id = q.id
+		WHERE q.id=$1`, id).
+		Scan(&quote.ID, &quote.Route, &quote.Customer.IDN, &cargo, &items, &quote.Total, &quote.Currency, &quote.Tariff,
+			&quote.CreatedAt, &quote.ExpiresAt, &quote.ShipmentID)
+	if err != nil {
+		log.Error("failed db select quote", slog.String("quote_id", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to get quote: %w", err)
+	}
+	if err := json.Unmarshal(cargo, &quote.Cargo); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote cargo: %w", err)
+	}
+	if err := json.Unmarshal(items, &quote.Items); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal quote items: %w", err)
+	}
+
+	return quote, nil
+}
+
+func (s *storage) GetTariff(ctx context.Context) (*entity.Tariff, error) {
+	log := s.log.With("method", "GetTariff")
+
+	var body []byte
+	err := s.db.QueryRowContext(ctx, `SELECT body FROM tariffs ORDER BY id DESC LIMIT 1`).Scan(&body)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Error("failed db select tariff", slog.String("error", err.Error()))
+		}
+		return nil, fmt.Errorf("failed to get tariff: %w", err)
+	}
+
+	tariff := &entity.Tariff{}
+	if err := json.Unmarshal(body, tariff); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tariff: %w", err)
+	}
+	return tariff, nil
+}
+
+func (s *storage) SaveTariff(ctx context.Context, tariff *entity.Tariff) error {
+	log := s.log.With("method", "SaveTariff")
+
+	body, err := json.Marshal(tariff)
+	if err != nil {
+		return fmt.Errorf("failed to marshal tariff: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `INSERT INTO tariffs (name, body) VALUES ($1,$2)`, tariff.Name, body); err != nil {
+		log.Error("failed db insert tariff", slog.String("tariff", tariff.Name), slog.String("error", err.Error()))
+		return fmt.Errorf("failed db insert tariff: %w", err)
+	}
+
+	return nil
+}
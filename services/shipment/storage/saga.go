@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+func (s *storage) CreateSaga(ctx context.Context, saga *entity.Saga) (*entity.Saga, error) {
+	log := s.log.With("method", "CreateSaga")
+
+	request, err := json.Marshal(saga.Request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal saga request: %w", err)
+	}
+
+	created := *saga
+	err = s.db.QueryRowContext(ctx,
+		`INSERT INTO sagas (type, state, request) VALUES ($1,$2,$3)
+		RETURNING id, shipment_id, created_at, updated_at`,
+		saga.Type, saga.State, request).
+		Scan(&created.ID, &created.ShipmentID, &created.CreatedAt, &created.UpdatedAt)
+	if err != nil {
+		log.Error("failed db insert saga", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db insert saga: %w", err)
+	}
+
+	return &created, nil
+}
+
+func (s *storage) UpdateSaga(ctx context.Context, saga *entity.Saga) error {
+	log := s.log.With("method", "UpdateSaga")
+
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE sagas
+		SET state=$2, customer_id=NULLIF($3, '')::uuid, customer_created=$4, error=$5, updated_at=NOW()
+		WHERE id=$1`,
+		saga.ID, saga.State, saga.CustomerID, saga.CustomerCreated, saga.Error)
+	if err != nil {
+		log.Error("failed db update saga", slog.String("saga_id", saga.ID), slog.String("error", err.Error()))
+		return fmt.Errorf("failed db update saga: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimStaleSagas locks up to limit unfinished sagas that were not touched for
+// staleAfter, bumps their attempts and returns them. Claiming updates
+// updated_at, so concurrent replicas do not pick the same saga.
+func (s *storage) ClaimStaleSagas(ctx context.Context, staleAfter time.Duration, limit int) ([]*entity.Saga, error) {
+	log := s.log.With("method", "ClaimStaleSagas")
+
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE sagas SET attempts = attempts + 1, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM sagas
+			WHERE state NOT IN ($1, $2) AND updated_at < NOW() - make_interval(secs => $3)
+			ORDER BY updated_at
+			LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, type, state, request, shipment_id, COALESCE(customer_id::text, ''),
+			customer_created, error, attempts, created_at, updated_at`,
+		entity.SagaCompleted, entity.SagaCompensated, staleAfter.Seconds(), limit)
+	if err != nil {
+		log.Error("failed db claim sagas", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db claim sagas: %w", err)
+	}
+	defer rows.Close()
+
+	var sagas []*entity.Saga
+	for rows.Next() {
+		saga := &entity.Saga{}
+		var request []byte
+		if err := rows.Scan(&saga.ID, &saga.Type, &saga.State, &request, &saga.ShipmentID, &saga.CustomerID,
+			&saga.CustomerCreated, &saga.Error, &saga.Attempts, &saga.CreatedAt, &saga.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan saga: %w", err)
+		}
+		if err := json.Unmarshal(request, &saga.Request); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal saga %s request: %w", saga.ID, err)
+		}
+		sagas = append(sagas, saga)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read sagas: %w", err)
+	}
+
+	return sagas, nil
+}
@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// slaColumns are the planned windows, ETA and SLA status of shipments
+const slaColumns = `pickup_from, pickup_until, delivery_from, delivery_until, eta, sla_status`
+
+// slaArgs returns the values of slaColumns in order; a shipment with a
+// delivery window starts ON_TRACK unless status is given
+func slaArgs(pickup, delivery *entity.Window, eta *time.Time, status string) []any {
+	if delivery != nil && status == "" {
+		status = entity.SLAOnTrack
+	}
+	return append(append(windowArgs(pickup), windowArgs(delivery)...), nullTime(eta), nullString(status))
+}
+
+func windowArgs(w *entity.Window) []any {
+	if w == nil {
+		return []any{nil, nil}
+	}
+	return []any{w.From.UTC(), w.Until.UTC()}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// slaRow scans slaColumns
+type slaRow struct {
+	pickupFrom, pickupUntil     sql.NullTime
+	deliveryFrom, deliveryUntil sql.NullTime
+	eta                         sql.NullTime
+	slaStatus                   sql.NullString
+}
+
+func (r *slaRow) dest() []any {
+	return []any{&r.pickupFrom, &r.pickupUntil, &r.deliveryFrom, &r.deliveryUntil, &r.eta, &r.slaStatus}
+}
+
+func (r *slaRow) apply(s *entity.Shipment) {
+	if r.pickupFrom.Valid {
+		s.PickupWindow = &entity.Window{From: r.pickupFrom.Time, Until: r.pickupUntil.Time}
+	}
+	if r.deliveryFrom.Valid {
+		s.DeliveryWindow = &entity.Window{From: r.deliveryFrom.Time, Until: r.deliveryUntil.Time}
+	}
+	if r.eta.Valid {
+		s.ETA = &r.eta.Time
+	}
+	s.SLAStatus = r.slaStatus.String
+}
+
+func (s *storage) ListShipments(ctx context.Context, filter entity.ShipmentFilter) ([]*entity.Shipment, error) {
+	log := s.log.With("method", "ListShipments")
+
+	rows, err := s.replica.QueryContext(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR sla_status = $2) AND ($3 = '' OR customer_id::text = $3)
+		ORDER BY created_at DESC, id
+		LIMIT $4 OFFSET $5`,
+		filter.Status, filter.SLAStatus, filter.CustomerID, filter.Limit, filter.Offset)
+	if err != nil {
+		log.Error("failed db select shipments", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to list shipments: %w", err)
+	}
+	return scanShipments(rows)
+}
+
+// ClaimSLAChecks returns up to limit open shipments whose SLA is not yet
+// breached and was not checked for recheckAfter, least recently checked
+// first. Claiming sets sla_checked_at, so concurrent replicas do not pick the
+// same shipment.
+func (s *storage) ClaimSLAChecks(ctx context.Context, recheckAfter time.Duration, limit int) ([]*entity.Shipment, error) {
+	log := s.log.With("method", "ClaimSLAChecks")
+
+	rows, err := s.db.QueryContext(ctx,
+		`UPDATE shipments SET sla_checked_at = NOW()
+		WHERE id IN (
+			SELECT id FROM shipments
+			WHERE sla_status IN ($1, $2) AND status NOT IN ($3, $4)
+				AND (sla_checked_at IS NULL OR sla_checked_at < NOW() - make_interval(secs => $5))
+			ORDER BY sla_checked_at NULLS FIRST
+			LIMIT $6
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+shipmentColumns,
+		entity.SLAOnTrack, entity.SLAAtRisk, entity.StatusDelivered, entity.StatusCancelled, recheckAfter.Seconds(), limit)
+	if err != nil {
+		log.Error("failed db claim sla checks", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db claim sla checks: %w", err)
+	}
+	return scanShipments(rows)
+}
+
+func (s *storage) UpdateSLA(ctx context.Context, id string, eta *time.Time, status string) error {
+	log := s.log.With("method", "UpdateSLA")
+
+	// The guards keep a check that raced with a delivery, a cancellation or
+	// another replica's breach from overwriting the final state
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE shipments SET eta = $2, sla_status = $3
+		WHERE id = $1 AND status NOT IN ($4, $5) AND sla_status IS DISTINCT FROM $6`,
+		id, nullTime(eta), status, entity.StatusDelivered, entity.StatusCancelled, entity.SLABreached)
+	if err != nil {
+		log.Error("failed db update sla", slog.String("shipment_id", id), slog.String("error", err.Error()))
+		return fmt.Errorf("failed db update sla: %w", err)
+	}
+	return nil
+}
+
+func (s *storage) LaneTransit(ctx context.Context, route string, since time.Time) (entity.LaneTransit, error) {
+	log := s.log.With("method", "LaneTransit")
+
+	var transit entity.LaneTransit
+	var median sql.NullFloat64
+	err := s.replica.QueryRowContext(ctx,
+		`SELECT COUNT(*), percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM d.changed_at - p.changed_at))
+		FROM shipments sh
+		JOIN shipment_status_history p ON p.shipment_id = sh.id AND p.status = $2
+		JOIN shipment_status_history d ON d.shipment_id = sh.id AND d.status = $3
+		WHERE sh.route = $1 AND d.changed_at >= $4 AND d.changed_at > p.changed_at`,
+		route, entity.StatusPickedUp, entity.StatusDelivered, since.UTC()).Scan(&transit.Samples, &median)
+	if err != nil {
+		log.Error("failed db select lane transit", slog.String("route", route), slog.String("error", err.Error()))
+		return transit, fmt.Errorf("failed to get lane transit: %w", err)
+	}
+	transit.Median = time.Duration(median.Float64 * float64(time.Second)).Round(time.Second)
+	return transit, nil
+}
+
+func scanShipments(rows *sql.Rows) ([]*entity.Shipment, error) {
+	defer rows.Close()
+
+	var shipments []*entity.Shipment
+	for rows.Next() {
+		shipment, err := scanShipment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan shipment: %w", err)
+		}
+		shipments = append(shipments, shipment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shipments: %w", err)
+	}
+	return shipments, nil
+}
@@ -3,35 +3,120 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
+	"github.com/aidosgal/transline-test/pkg/postgres"
 	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/lib/pq"
 )
 
+const shipmentColumns = `id, route, price, status, customer_id, quote_id, ` + cargoColumns + `, created_at, ` + slaColumns + `, tracking_number`
+
+// timestampLayout formats a UTC time for a TIMESTAMP array element
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
 type storage struct {
 	log *slog.Logger
-	db  *sql.DB
+	db  postgres.DBTX
+	// replica serves read-only queries; the primary or the transaction if
+	// there is no replica or inside a transaction
+	replica postgres.DBTX
+	// conn is nil inside a transaction
+	conn *sql.DB
 }
 
 type Storage interface {
+	// WithTx runs fn against a Storage bound to one transaction, committing
+	// if fn returns nil. Calls on a transactional Storage join its transaction.
+	WithTx(ctx context.Context, fn func(tx Storage) error) error
+
 	GetShipment(ctx context.Context, id string) (*entity.Shipment, error)
-	CreateShipment(ctx context.Context, req *entity.CreateReq, customerID string) (string, error)
+	// GetShipmentPrimary reads the primary, for a caller that must see its
+	// own writes, e.g. a shipment it just created
+	GetShipmentPrimary(ctx context.Context, id string) (*entity.Shipment, error)
+	CreateShipment(ctx context.Context, req *entity.CreateReq, customerID string) (*entity.Shipment, error)
+	CreateShipmentWithID(ctx context.Context, id string, req *entity.CreateReq, customerID string) (*entity.Shipment, error)
+	// InsertShipment inserts a shipment with all its fields and its status
+	// history, oldest first. It reports false and changes nothing if the ID
+	// exists, so seeding can be repeated.
+	InsertShipment(ctx context.Context, shipment *entity.Shipment, history []entity.StatusChange) (bool, error)
+	// GetStatusHistory returns the statuses of a shipment, oldest first
+	GetStatusHistory(ctx context.Context, id string) ([]entity.StatusChange, error)
+	// ListShipments returns the shipments matching filter, newest first
+	ListShipments(ctx context.Context, filter entity.ShipmentFilter) ([]*entity.Shipment, error)
+	// GetShipmentByTrackingNumber returns entity.ErrShipmentNotFound for an
+	// unknown number
+	GetShipmentByTrackingNumber(ctx context.Context, number string) (*entity.Shipment, error)
+
+	// AddEvent stores a tracking event and returns it with its ID
+	AddEvent(ctx context.Context, event *entity.Event) (*entity.Event, error)
+	// ListEvents returns the tracking events of a shipment, oldest first
+	ListEvents(ctx context.Context, shipmentID string) ([]*entity.Event, error)
+
+	// ClaimSLAChecks returns up to limit open shipments with an SLA that is
+	// not breached yet and was not checked for recheckAfter, and marks them
+	// checked, so concurrent replicas do not pick the same shipment
+	ClaimSLAChecks(ctx context.Context, recheckAfter time.Duration, limit int) ([]*entity.Shipment, error)
+	// UpdateSLA stores the ETA and SLA status of a shipment unless it was
+	// delivered or cancelled, or its SLA is breached, in the meantime
+	UpdateSLA(ctx context.Context, id string, eta *time.Time, status string) error
+	// LaneTransit summarises the pickup-to-delivery times of the shipments on
+	// route delivered since
+	LaneTransit(ctx context.Context, route string, since time.Time) (entity.LaneTransit, error)
+
+	// CreateQuote stores a priced quote and returns it with its ID
+	CreateQuote(ctx context.Context, quote *entity.Quote) (*entity.Quote, error)
+	// GetQuote returns a quote with the ID of the shipment created from it, if any
+	GetQuote(ctx context.Context, id string) (*entity.Quote, error)
+	// GetTariff returns the newest saved tariff, sql.ErrNoRows if there is none
+	GetTariff(ctx context.Context) (*entity.Tariff, error)
+	SaveTariff(ctx context.Context, tariff *entity.Tariff) error
+
+	CreateSaga(ctx context.Context, saga *entity.Saga) (*entity.Saga, error)
+	UpdateSaga(ctx context.Context, saga *entity.Saga) error
+	ClaimStaleSagas(ctx context.Context, staleAfter time.Duration, limit int) ([]*entity.Saga, error)
 }
 
-func New(log *slog.Logger, db *sql.DB) Storage {
-	return &storage{
-		log: log.With("layer", "storage"),
-		db:  db,
+// New returns a Storage on db; replica, if not nil, serves read-only queries
+// that tolerate replication lag
+func New(log *slog.Logger, db *sql.DB, replica *sql.DB) Storage {
+	s := &storage{
+		log:     log.With("layer", "storage"),
+		db:      db,
+		replica: db,
+		conn:    db,
 	}
+	if replica != nil {
+		s.replica = replica
+	}
+	return s
+}
+
+func (s *storage) WithTx(ctx context.Context, fn func(tx Storage) error) error {
+	if s.conn == nil {
+		return fn(s)
+	}
+	return postgres.InTx(ctx, s.conn, func(tx *sql.Tx) error {
+		return fn(&storage{log: s.log, db: tx, replica: tx})
+	})
 }
 
 func (s *storage) GetShipment(ctx context.Context, id string) (*entity.Shipment, error) {
-	log := s.log.With("method", "CreateShipment")
+	return s.getShipment(ctx, s.replica, "GetShipment", id)
+}
 
-	shipment := &entity.Shipment{}
-	err := s.db.QueryRowContext(ctx, `SELECT id, route, price, status, customer_id, created_at FROM shipments WHERE id=$1`, id).
-		Scan(&shipment.ID, &shipment.Route, &shipment.Price, &shipment.Status, &shipment.CustomerID, &shipment.CreatedAt)
+func (s *storage) GetShipmentPrimary(ctx context.Context, id string) (*entity.Shipment, error) {
+	return s.getShipment(ctx, s.db, "GetShipmentPrimary", id)
+}
+
+func (s *storage) getShipment(ctx context.Context, db postgres.DBTX, method, id string) (*entity.Shipment, error) {
+	log := s.log.With("method", method)
+
+	shipment, err := scanShipment(db.QueryRowContext(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE id=$1`, id))
 	if err != nil {
 		log.Error("failed db select shipment", slog.String("id", id), slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to get shipment: %w", err)
@@ -40,16 +125,172 @@ func (s *storage) GetShipment(ctx context.Context, id string) (*entity.Shipment,
 	return shipment, nil
 }
 
-func (s *storage) CreateShipment(ctx context.Context, req *entity.CreateReq, customerID string) (string, error) {
+func (s *storage) CreateShipment(ctx context.Context, req *entity.CreateReq, customerID string) (*entity.Shipment, error) {
 	log := s.log.With("method", "CreateShipment")
-	var shipID string
-	err := s.db.QueryRowContext(ctx,
-		`INSERT INTO shipments (route, price, customer_id) VALUES ($1,$2,$3) RETURNING id`,
-		req.Route, req.Price, customerID).Scan(&shipID)
+
+	cargo, err := cargoArgs(req.Cargo)
+	if err != nil {
+		return nil, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+
+	shipment, err := scanShipment(s.db.QueryRowContext(ctx,
+		`WITH inserted AS (
+			INSERT INTO shipments (route, price, customer_id, quote_id, `+cargoColumns+`, `+slaColumns+`)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20)
+			RETURNING `+shipmentColumns+`
+		), history AS (
+			INSERT INTO shipment_status_history (shipment_id, status, changed_at)
+			SELECT id, status, created_at FROM inserted
+		)
+		SELECT `+shipmentColumns+` FROM inserted`,
+		createArgs([]any{req.Route, req.Price, customerID, nullString(req.QuoteID)}, cargo, req)...))
 	if err != nil {
 		log.Error("failed db insert shipment", slog.String("error", err.Error()))
-		return "", fmt.Errorf("failed db insert shipment: %w", err)
+		return nil, fmt.Errorf("failed db insert shipment: %w", quoteUsed(err, req.QuoteID))
+	}
+
+	return shipment, nil
+}
+
+// quoteUsed maps a violation of the unique shipments.quote_id, which the
+// loser of two concurrent requests with one quote gets, to entity.ErrQuoteUsed
+func quoteUsed(err error, quoteID string) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "shipments_quote_id_key" {
+		return fmt.Errorf("%w: %s", entity.ErrQuoteUsed, quoteID)
+	}
+	return err
+}
+
+// CreateShipmentWithID inserts a shipment under a preassigned ID and returns
+// the existing row if it was already inserted, so a resumed saga can repeat the step
+func (s *storage) CreateShipmentWithID(ctx context.Context, id string, req *entity.CreateReq, customerID string) (*entity.Shipment, error) {
+	log := s.log.With("method", "CreateShipmentWithID")
+
+	// The no-op update makes RETURNING yield the existing row on conflict;
+	// xmax is 0 only for a new row, which alone gets a history entry
+	cargo, err := cargoArgs(req.Cargo)
+	if err != nil {
+		return nil, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+
+	shipment, err := scanShipment(s.db.QueryRowContext(ctx,
+		`WITH upserted AS (
+			INSERT INTO shipments (id, route, price, customer_id, quote_id, `+cargoColumns+`, `+slaColumns+`)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21)
+			ON CONFLICT (id) DO UPDATE SET id = EXCLUDED.id
+			RETURNING `+shipmentColumns+`, (xmax = 0) AS inserted
+		), history AS (
+			INSERT INTO shipment_status_history (shipment_id, status, changed_at)
+			SELECT id, status, created_at FROM upserted WHERE inserted
+		)
+		SELECT `+shipmentColumns+` FROM upserted`,
+		createArgs([]any{id, req.Route, req.Price, customerID, nullString(req.QuoteID)}, cargo, req)...))
+	if err != nil {
+		log.Error("failed db insert shipment", slog.String("shipment_id", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db insert shipment: %w", quoteUsed(err, req.QuoteID))
 	}
 
-	return shipID, nil
+	return shipment, nil
+}
+
+func (s *storage) InsertShipment(ctx context.Context, shipment *entity.Shipment, history []entity.StatusChange) (bool, error) {
+	log := s.log.With("method", "InsertShipment")
+
+	statuses := make([]string, len(history))
+	changedAt := make([]string, len(history))
+	for i, h := range history {
+		statuses[i] = h.Status
+		changedAt[i] = h.ChangedAt.UTC().Format(timestampLayout)
+	}
+
+	cargo, err := cargoArgs(shipment.Cargo)
+	if err != nil {
+		return false, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+	args := append([]any{shipment.ID, shipment.Route, shipment.Price, shipment.Status, shipment.CustomerID, nullString(shipment.QuoteID)}, cargo...)
+	args = append(args, shipment.CreatedAt)
+	args = append(args, slaArgs(shipment.PickupWindow, shipment.DeliveryWindow, shipment.ETA, shipment.SLAStatus)...)
+	args = append(args, trackingNumber(shipment), pq.Array(statuses), pq.Array(changedAt))
+
+	// One statement, so the history is inserted with the shipment or not at all
+	var inserted bool
+	err = s.db.QueryRowContext(ctx,
+		`WITH inserted AS (
+			INSERT INTO shipments (`+shipmentColumns+`)
+			VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,$23,$24)
+			ON CONFLICT (id) DO NOTHING
+			RETURNING id
+		), history AS (
+			INSERT INTO shipment_status_history (shipment_id, status, changed_at)
+			SELECT inserted.id, h.status, h.changed_at
+			FROM inserted, unnest($25::text[], $26::timestamp[]) WITH ORDINALITY AS h(status, changed_at, n)
+			ORDER BY h.n
+		)
+		SELECT EXISTS (SELECT 1 FROM inserted)`,
+		args...).Scan(&inserted)
+	if err != nil {
+		log.Error("failed db insert shipment", slog.String("shipment_id", shipment.ID), slog.String("error", err.Error()))
+		return false, fmt.Errorf("failed db insert shipment: %w", err)
+	}
+
+	return inserted, nil
+}
+
+func (s *storage) GetStatusHistory(ctx context.Context, id string) ([]entity.StatusChange, error) {
+	log := s.log.With("method", "GetStatusHistory")
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT status, changed_at FROM shipment_status_history WHERE shipment_id=$1 ORDER BY changed_at, id`, id)
+	if err != nil {
+		log.Error("failed db select status history", slog.String("shipment_id", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []entity.StatusChange
+	for rows.Next() {
+		h := entity.StatusChange{}
+		if err := rows.Scan(&h.Status, &h.ChangedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status history: %w", err)
+		}
+		history = append(history, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get status history: %w", err)
+	}
+
+	return history, nil
+}
+
+// createArgs appends the cargo and the planned windows of req to args
+func createArgs(args, cargo []any, req *entity.CreateReq) []any {
+	args = append(args, cargo...)
+	return append(args, slaArgs(req.PickupWindow, req.DeliveryWindow, nil, "")...)
+}
+
+func scanShipment(row interface{ Scan(dest ...any) error }) (*entity.Shipment, error) {
+	shipment := &entity.Shipment{}
+	var quoteID sql.NullString
+	cargo := &cargoRow{}
+	sla := &slaRow{}
+	dest := append([]any{&shipment.ID, &shipment.Route, &shipment.Price, &shipment.Status, &shipment.CustomerID, &quoteID}, cargo.dest()...)
+	dest = append(dest, &shipment.CreatedAt)
+	dest = append(dest, sla.dest()...)
+	if err := row.Scan(append(dest, &shipment.TrackingNumber)...); err != nil {
+		return nil, err
+	}
+	shipment.QuoteID = quoteID.String
+	sla.apply(shipment)
+
+	var err error
+	if shipment.Cargo, err = cargo.cargo(); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// nullString maps "" to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
 }
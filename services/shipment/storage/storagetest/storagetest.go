@@ -0,0 +1,1051 @@
+// Package storagetest is the conformance suite every shipment Storage must pass
+package storagetest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/storage"
+)
+
+// tick separates timestamps of consecutive statements, so updated_at of an
+// earlier one is strictly before NOW() of a later one
+const tick = 10 * time.Millisecond
+
+var errRollback = errors.New("rollback")
+
+// Run runs the suite; newStorage returns an empty Storage for each test
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Storage)
+	}{
+		{"CreateShipment", testCreateShipment},
+		{"CreateShipmentNegativePrice", testCreateShipmentNegativePrice},
+		{"CreateShipmentWithIDIdempotent", testCreateShipmentWithIDIdempotent},
+		{"GetShipmentNotFound", testGetShipmentNotFound},
+		{"CreateShipmentHistory", testCreateShipmentHistory},
+		{"CreateShipmentCargo", testCreateShipmentCargo},
+		{"InsertShipment", testInsertShipment},
+		{"InsertShipmentExisting", testInsertShipmentExisting},
+		{"Quote", testQuote},
+		{"ShipmentFromQuote", testShipmentFromQuote},
+		{"Tariff", testTariff},
+		{"TxCommit", testTxCommit},
+		{"TxRollback", testTxRollback},
+		{"CreateSaga", testCreateSaga},
+		{"UpdateAndClaimSaga", testUpdateAndClaimSaga},
+		{"ClaimSkipsFreshAndTerminal", testClaimSkipsFreshAndTerminal},
+		{"ClaimOldestFirst", testClaimOldestFirst},
+		{"ShipmentWindows", testShipmentWindows},
+		{"ListShipments", testListShipments},
+		{"ClaimSLAChecks", testClaimSLAChecks},
+		{"UpdateSLA", testUpdateSLA},
+		{"UpdateSLAClosed", testUpdateSLAClosed},
+		{"LaneTransit", testLaneTransit},
+		{"TrackingNumber", testTrackingNumber},
+		{"Events", testEvents},
+		{"AddEventUnknownShipment", testAddEventUnknownShipment},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage(t))
+		})
+	}
+}
+
+func createReq() *entity.CreateReq {
+	return &entity.CreateReq{
+		Route:    "ALMATY→ASTANA",
+		Price:    120000,
+		Customer: entity.CreateCustomerReq{IDN: "990101300123"},
+	}
+}
+
+func testCreateShipment(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	customerID := uuid.NewString()
+
+	shipment, err := s.CreateShipment(ctx, createReq(), customerID)
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if _, err := uuid.Parse(shipment.ID); err != nil {
+		t.Errorf("ID %q is not a UUID", shipment.ID)
+	}
+	if shipment.Route != "ALMATY→ASTANA" || shipment.Price != 120000 || shipment.CustomerID != customerID {
+		t.Errorf("CreateShipment = %+v, want the request fields", shipment)
+	}
+	if shipment.Status != "CREATED" {
+		t.Errorf("Status = %q, want CREATED", shipment.Status)
+	}
+	if shipment.CreatedAt.IsZero() {
+		t.Error("CreatedAt is zero")
+	}
+
+	got, err := s.GetShipment(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if !sameShipment(got, shipment) {
+		t.Errorf("GetShipment = %+v, want %+v", got, shipment)
+	}
+	got, err = s.GetShipmentPrimary(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipmentPrimary: %v", err)
+	}
+	if !sameShipment(got, shipment) {
+		t.Errorf("GetShipmentPrimary = %+v, want %+v", got, shipment)
+	}
+
+	other, err := s.CreateShipment(ctx, createReq(), customerID)
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if other.ID == shipment.ID {
+		t.Error("two shipments share an ID")
+	}
+}
+
+func testCreateShipmentNegativePrice(t *testing.T, s storage.Storage) {
+	req := createReq()
+	req.Price = -1
+	if _, err := s.CreateShipment(context.Background(), req, uuid.NewString()); err == nil {
+		t.Error("CreateShipment with a negative price succeeded")
+	}
+}
+
+func testCreateShipmentWithIDIdempotent(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	id, customerID := uuid.NewString(), uuid.NewString()
+
+	first, err := s.CreateShipmentWithID(ctx, id, createReq(), customerID)
+	if err != nil {
+		t.Fatalf("CreateShipmentWithID: %v", err)
+	}
+	if first.ID != id {
+		t.Errorf("ID = %q, want %q", first.ID, id)
+	}
+
+	req := createReq()
+	req.Route = "SHYMKENT→ATYRAU"
+	second, err := s.CreateShipmentWithID(ctx, id, req, uuid.NewString())
+	if err != nil {
+		t.Fatalf("repeated CreateShipmentWithID: %v", err)
+	}
+	if !sameShipment(second, first) {
+		t.Errorf("repeated CreateShipmentWithID = %+v, want the existing %+v", second, first)
+	}
+}
+
+func testGetShipmentNotFound(t *testing.T, s storage.Storage) {
+	_, err := s.GetShipment(context.Background(), uuid.NewString())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetShipment error = %v, want sql.ErrNoRows", err)
+	}
+	_, err = s.GetShipmentPrimary(context.Background(), uuid.NewString())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetShipmentPrimary error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testCreateShipmentHistory(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	shipment, err := s.CreateShipmentWithID(ctx, id, createReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipmentWithID: %v", err)
+	}
+	if _, err := s.CreateShipmentWithID(ctx, id, createReq(), uuid.NewString()); err != nil {
+		t.Fatalf("repeated CreateShipmentWithID: %v", err)
+	}
+
+	history, err := s.GetStatusHistory(ctx, id)
+	if err != nil {
+		t.Fatalf("GetStatusHistory: %v", err)
+	}
+	if len(history) != 1 || history[0].Status != entity.StatusCreated || !history[0].ChangedAt.Equal(shipment.CreatedAt) {
+		t.Errorf("history = %+v, want one CREATED at %v", history, shipment.CreatedAt)
+	}
+
+	history, err = s.GetStatusHistory(ctx, uuid.NewString())
+	if err != nil {
+		t.Fatalf("GetStatusHistory of an unknown shipment: %v", err)
+	}
+	if len(history) != 0 {
+		t.Errorf("history of an unknown shipment = %+v, want none", history)
+	}
+}
+
+func testCreateShipmentCargo(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	minC, maxC := -18.0, -15.5
+	cargos := []*entity.Cargo{
+		{
+			Type: entity.CargoTemperatureControlled, WeightKg: 1250.5, VolumeM3: 4.2, DeclaredValue: 3500000, PackageCount: 3,
+			Packages:        []entity.Package{{LengthCm: 120, WidthCm: 80, HeightCm: 150, Quantity: 3}},
+			TemperatureMinC: &minC, TemperatureMaxC: &maxC,
+		},
+		{Type: entity.CargoDangerous, WeightKg: 800, VolumeM3: 1, PackageCount: 4, DangerousClass: "3", UNNumber: "UN1203"},
+		nil,
+	}
+
+	for _, cargo := range cargos {
+		req := createReq()
+		req.Cargo = cargo.Clone()
+		shipment, err := s.CreateShipment(ctx, req, uuid.NewString())
+		if err != nil {
+			t.Fatalf("CreateShipment: %v", err)
+		}
+		if !reflect.DeepEqual(shipment.Cargo, cargo) {
+			t.Errorf("CreateShipment cargo = %+v, want %+v", shipment.Cargo, cargo)
+		}
+		if req.Cargo != nil {
+			req.Cargo.Packages = nil
+			req.Cargo.WeightKg++
+		}
+
+		got, err := s.GetShipment(ctx, shipment.ID)
+		if err != nil {
+			t.Fatalf("GetShipment: %v", err)
+		}
+		if !reflect.DeepEqual(got.Cargo, cargo) {
+			t.Errorf("GetShipment cargo = %+v, want %+v", got.Cargo, cargo)
+		}
+	}
+}
+
+func seededShipment() (*entity.Shipment, []entity.StatusChange) {
+	created := time.Date(2024, time.May, 6, 8, 0, 0, 0, time.UTC)
+	history := []entity.StatusChange{
+		{Status: entity.StatusCreated, ChangedAt: created},
+		{Status: entity.StatusPickedUp, ChangedAt: created.Add(5 * time.Hour)},
+		{Status: entity.StatusInTransit, ChangedAt: created.Add(7*time.Hour + 123456*time.Microsecond)},
+	}
+	return &entity.Shipment{
+		ID:         uuid.NewString(),
+		Route:      "ALMATY→ASTANA",
+		Price:      98000,
+		Status:     entity.StatusInTransit,
+		CustomerID: uuid.NewString(),
+		Cargo: &entity.Cargo{
+			Type: entity.CargoFragile, WeightKg: 320, VolumeM3: 2.5, DeclaredValue: 1200000, PackageCount: 6,
+			Packages: []entity.Package{
+				{LengthCm: 60, WidthCm: 40, HeightCm: 40, Quantity: 4},
+				{LengthCm: 120, WidthCm: 100, HeightCm: 80, Quantity: 2},
+			},
+		},
+		CreatedAt:      created,
+		TrackingNumber: entity.NewTrackingNumber(),
+	}, history
+}
+
+func testInsertShipment(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	shipment, history := seededShipment()
+
+	inserted, err := s.InsertShipment(ctx, shipment, history)
+	if err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+	if !inserted {
+		t.Error("InsertShipment = false for a new shipment")
+	}
+
+	got, err := s.GetShipment(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if !sameShipment(got, shipment) {
+		t.Errorf("GetShipment = %+v, want %+v", got, shipment)
+	}
+
+	gotHistory, err := s.GetStatusHistory(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetStatusHistory: %v", err)
+	}
+	if !slices.EqualFunc(gotHistory, history, sameStatusChange) {
+		t.Errorf("GetStatusHistory = %+v, want %+v", gotHistory, history)
+	}
+}
+
+func testInsertShipmentExisting(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	shipment, history := seededShipment()
+
+	if _, err := s.InsertShipment(ctx, shipment, history); err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+
+	changed := *shipment
+	changed.Route = "SHYMKENT→ATYRAU"
+	inserted, err := s.InsertShipment(ctx, &changed, history[:1])
+	if err != nil {
+		t.Fatalf("repeated InsertShipment: %v", err)
+	}
+	if inserted {
+		t.Error("repeated InsertShipment = true")
+	}
+
+	got, err := s.GetShipment(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if got.Route != shipment.Route {
+		t.Errorf("Route = %q, want the existing %q", got.Route, shipment.Route)
+	}
+	gotHistory, err := s.GetStatusHistory(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetStatusHistory: %v", err)
+	}
+	if len(gotHistory) != len(history) {
+		t.Errorf("history has %d entries after a repeated insert, want %d", len(gotHistory), len(history))
+	}
+}
+
+func newQuote() *entity.Quote {
+	created := time.Date(2025, time.March, 3, 9, 30, 0, 123456000, time.UTC)
+	return &entity.Quote{
+		Route:    "ALMATY→ASTANA",
+		Customer: entity.CreateCustomerReq{IDN: "990101300123"},
+		Cargo:    &entity.Cargo{Type: entity.CargoGeneral, WeightKg: 500, VolumeM3: 2, PackageCount: 2},
+		Items: []entity.QuoteItem{
+			{Code: entity.ItemBase, Description: "base rate ALMATY→ASTANA", Amount: 30000},
+			{Code: entity.ItemWeight, Description: "500 kg × 95 KZT/kg", Amount: 47500},
+		},
+		Total:     77500,
+		Currency:  "KZT",
+		Tariff:    "test",
+		CreatedAt: created,
+		ExpiresAt: created.Add(24 * time.Hour),
+	}
+}
+
+func testQuote(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	quote := newQuote()
+
+	created, err := s.CreateQuote(ctx, quote)
+	if err != nil {
+		t.Fatalf("CreateQuote: %v", err)
+	}
+	if _, err := uuid.Parse(created.ID); err != nil {
+		t.Errorf("ID %q is not a UUID", created.ID)
+	}
+
+	got, err := s.GetQuote(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if got.ID != created.ID || got.Route != quote.Route || got.Customer != quote.Customer || got.Total != quote.Total ||
+		got.Currency != quote.Currency || got.Tariff != quote.Tariff || got.ShipmentID != "" ||
+		!got.CreatedAt.Equal(quote.CreatedAt) || !got.ExpiresAt.Equal(quote.ExpiresAt) ||
+		!reflect.DeepEqual(got.Cargo, quote.Cargo) || !reflect.DeepEqual(got.Items, quote.Items) {
+		t.Errorf("GetQuote = %+v, want %+v", got, quote)
+	}
+
+	if _, err := s.GetQuote(ctx, uuid.NewString()); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetQuote of an unknown quote error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testShipmentFromQuote(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	quote, err := s.CreateQuote(ctx, newQuote())
+	if err != nil {
+		t.Fatalf("CreateQuote: %v", err)
+	}
+
+	req := createReq()
+	req.QuoteID = quote.ID
+	shipment, err := s.CreateShipmentWithID(ctx, uuid.NewString(), req, uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipmentWithID: %v", err)
+	}
+	if shipment.QuoteID != quote.ID {
+		t.Errorf("QuoteID = %q, want %q", shipment.QuoteID, quote.ID)
+	}
+	if _, err := s.CreateShipmentWithID(ctx, shipment.ID, req, shipment.CustomerID); err != nil {
+		t.Errorf("repeated CreateShipmentWithID: %v", err)
+	}
+
+	got, err := s.GetQuote(ctx, quote.ID)
+	if err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if got.ShipmentID != shipment.ID {
+		t.Errorf("ShipmentID = %q, want %q", got.ShipmentID, shipment.ID)
+	}
+
+	if _, err := s.CreateShipment(ctx, req, uuid.NewString()); !errors.Is(err, entity.ErrQuoteUsed) {
+		t.Errorf("second shipment from the same quote error = %v, want ErrQuoteUsed", err)
+	}
+	if _, err := s.CreateShipmentWithID(ctx, uuid.NewString(), req, uuid.NewString()); !errors.Is(err, entity.ErrQuoteUsed) {
+		t.Errorf("second CreateShipmentWithID from the same quote error = %v, want ErrQuoteUsed", err)
+	}
+	req.QuoteID = uuid.NewString()
+	if _, err := s.CreateShipment(ctx, req, uuid.NewString()); err == nil {
+		t.Error("a shipment from an unknown quote was created")
+	}
+}
+
+func testTariff(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	if _, err := s.GetTariff(ctx); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetTariff without tariffs error = %v, want sql.ErrNoRows", err)
+	}
+
+	tariff := &entity.Tariff{
+		Name: "v1", Currency: "KZT", QuoteTTL: entity.Duration(time.Hour), VolumetricKgPerM3: 250,
+		Zones:             map[string]string{"ALMATY": "SOUTH"},
+		Lanes:             []entity.Lane{{From: "*", To: "*", Base: 1000, Brackets: []entity.Bracket{{PerKg: 10}}}},
+		CargoSurchargePct: map[entity.CargoType]float64{entity.CargoFragile: 15},
+	}
+	for _, name := range []string{"v1", "v2"} {
+		tariff.Name = name
+		if err := s.SaveTariff(ctx, tariff); err != nil {
+			t.Fatalf("SaveTariff: %v", err)
+		}
+	}
+
+	got, err := s.GetTariff(ctx)
+	if err != nil {
+		t.Fatalf("GetTariff: %v", err)
+	}
+	if !reflect.DeepEqual(got, tariff) {
+		t.Errorf("GetTariff = %+v, want the newest %+v", got, tariff)
+	}
+}
+
+func testTxCommit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	saga, err := s.CreateSaga(ctx, &entity.Saga{
+		Type: entity.SagaTypeCreateShipment, State: entity.SagaCustomerUpserted, Request: *createReq(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSaga: %v", err)
+	}
+	saga.CustomerID = uuid.NewString()
+
+	err = s.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.CreateShipmentWithID(ctx, saga.ShipmentID, &saga.Request, saga.CustomerID); err != nil {
+			return err
+		}
+		saga.State = entity.SagaCompleted
+		if err := tx.UpdateSaga(ctx, saga); err != nil {
+			return err
+		}
+		if _, err := tx.GetShipment(ctx, saga.ShipmentID); err != nil {
+			t.Errorf("read in tx: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx: %v", err)
+	}
+
+	if _, err := s.GetShipment(ctx, saga.ShipmentID); err != nil {
+		t.Errorf("GetShipment after commit: %v", err)
+	}
+	time.Sleep(tick)
+	if claimed := claimAll(t, s); len(claimed) != 0 {
+		t.Errorf("completed saga was claimed: %+v", claimed)
+	}
+}
+
+func testTxRollback(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	id := uuid.NewString()
+
+	err := s.WithTx(ctx, func(tx storage.Storage) error {
+		if _, err := tx.CreateShipmentWithID(ctx, id, createReq(), uuid.NewString()); err != nil {
+			return err
+		}
+		return tx.WithTx(ctx, func(inner storage.Storage) error {
+			if _, err := inner.CreateSaga(ctx, &entity.Saga{
+				Type: entity.SagaTypeCreateShipment, State: entity.SagaStarted, Request: *createReq(),
+			}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("WithTx error = %v, want the error of fn", err)
+	}
+
+	if _, err := s.GetShipment(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("shipment inserted in a rolled back tx: error = %v", err)
+	}
+	time.Sleep(tick)
+	if claimed := claimAll(t, s); len(claimed) != 0 {
+		t.Errorf("saga inserted in a rolled back tx: %+v", claimed)
+	}
+}
+
+func testCreateSaga(t *testing.T, s storage.Storage) {
+	saga, err := s.CreateSaga(context.Background(), &entity.Saga{
+		Type: entity.SagaTypeCreateShipment, State: entity.SagaStarted, Request: *createReq(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSaga: %v", err)
+	}
+	if _, err := uuid.Parse(saga.ID); err != nil {
+		t.Errorf("ID %q is not a UUID", saga.ID)
+	}
+	if _, err := uuid.Parse(saga.ShipmentID); err != nil {
+		t.Errorf("ShipmentID %q is not a UUID", saga.ShipmentID)
+	}
+	if saga.ShipmentID == saga.ID {
+		t.Error("ShipmentID equals ID")
+	}
+	if saga.CreatedAt.IsZero() || !saga.UpdatedAt.Equal(saga.CreatedAt) {
+		t.Errorf("CreatedAt = %v, UpdatedAt = %v, want the same non-zero time", saga.CreatedAt, saga.UpdatedAt)
+	}
+	if saga.State != entity.SagaStarted || saga.Request != *createReq() {
+		t.Errorf("CreateSaga = %+v, want the given state and request", saga)
+	}
+}
+
+func testUpdateAndClaimSaga(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	saga, err := s.CreateSaga(ctx, &entity.Saga{
+		Type: entity.SagaTypeCreateShipment, State: entity.SagaStarted, Request: *createReq(),
+	})
+	if err != nil {
+		t.Fatalf("CreateSaga: %v", err)
+	}
+
+	time.Sleep(tick)
+	saga.State = entity.SagaCustomerUpserted
+	saga.CustomerID = uuid.NewString()
+	saga.CustomerCreated = true
+	saga.Error = "customer-service unavailable"
+	if err := s.UpdateSaga(ctx, saga); err != nil {
+		t.Fatalf("UpdateSaga: %v", err)
+	}
+	// Updating a missing saga is not an error
+	if err := s.UpdateSaga(ctx, &entity.Saga{ID: uuid.NewString(), State: entity.SagaStarted}); err != nil {
+		t.Errorf("UpdateSaga of a missing saga: %v", err)
+	}
+
+	time.Sleep(tick)
+	claimed := claimAll(t, s)
+	if len(claimed) != 1 {
+		t.Fatalf("claimed %d sagas, want 1", len(claimed))
+	}
+	got := claimed[0]
+	if got.ID != saga.ID || got.Type != saga.Type || got.State != saga.State || got.Request != saga.Request ||
+		got.ShipmentID != saga.ShipmentID || got.CustomerID != saga.CustomerID ||
+		got.CustomerCreated != saga.CustomerCreated || got.Error != saga.Error {
+		t.Errorf("claimed %+v, want %+v", got, saga)
+	}
+	if got.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", got.Attempts)
+	}
+	if !got.CreatedAt.Equal(saga.CreatedAt) || !got.UpdatedAt.After(saga.CreatedAt) {
+		t.Errorf("CreatedAt = %v, UpdatedAt = %v, want the creation time and a later claim time",
+			got.CreatedAt, got.UpdatedAt)
+	}
+
+	// Claiming touches the saga, so it is not stale right after
+	if again, err := s.ClaimStaleSagas(ctx, time.Hour, 10); err != nil || len(again) != 0 {
+		t.Errorf("ClaimStaleSagas right after a claim = %d sagas, %v", len(again), err)
+	}
+
+	time.Sleep(tick)
+	claimed = claimAll(t, s)
+	if len(claimed) != 1 || claimed[0].Attempts != 2 {
+		t.Errorf("second claim = %+v, want the saga with 2 attempts", claimed)
+	}
+
+	// Clearing the customer stores no customer
+	saga.CustomerID = ""
+	if err := s.UpdateSaga(ctx, saga); err != nil {
+		t.Fatalf("UpdateSaga: %v", err)
+	}
+	time.Sleep(tick)
+	if claimed := claimAll(t, s); len(claimed) != 1 || claimed[0].CustomerID != "" {
+		t.Errorf("claim after clearing the customer = %+v", claimed)
+	}
+}
+
+func testClaimSkipsFreshAndTerminal(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, state := range []entity.SagaState{entity.SagaStarted, entity.SagaCompleted, entity.SagaCompensated} {
+		if _, err := s.CreateSaga(ctx, &entity.Saga{
+			Type: entity.SagaTypeCreateShipment, State: state, Request: *createReq(),
+		}); err != nil {
+			t.Fatalf("CreateSaga: %v", err)
+		}
+	}
+
+	if claimed, err := s.ClaimStaleSagas(ctx, time.Hour, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("ClaimStaleSagas of fresh sagas = %d sagas, %v", len(claimed), err)
+	}
+
+	time.Sleep(tick)
+	claimed := claimAll(t, s)
+	if len(claimed) != 1 || claimed[0].State != entity.SagaStarted {
+		t.Errorf("claimed %+v, want only the unfinished saga", claimed)
+	}
+}
+
+func testClaimOldestFirst(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	var ids []string
+	for range 3 {
+		saga, err := s.CreateSaga(ctx, &entity.Saga{
+			Type: entity.SagaTypeCreateShipment, State: entity.SagaStarted, Request: *createReq(),
+		})
+		if err != nil {
+			t.Fatalf("CreateSaga: %v", err)
+		}
+		ids = append(ids, saga.ID)
+		time.Sleep(tick)
+	}
+
+	claimed, err := s.ClaimStaleSagas(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("ClaimStaleSagas: %v", err)
+	}
+	got := make([]string, 0, len(claimed))
+	for _, saga := range claimed {
+		got = append(got, saga.ID)
+	}
+	slices.Sort(got)
+	want := slices.Clone(ids[:2])
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("claimed %v, want the two oldest %v", got, want)
+	}
+
+	if claimed, err := s.ClaimStaleSagas(ctx, 0, 0); err != nil || len(claimed) != 0 {
+		t.Errorf("ClaimStaleSagas with limit 0 = %d sagas, %v", len(claimed), err)
+	}
+}
+
+// claimAll claims every saga not touched since the previous tick
+func claimAll(t *testing.T, s storage.Storage) []*entity.Saga {
+	t.Helper()
+	claimed, err := s.ClaimStaleSagas(context.Background(), 0, 100)
+	if err != nil {
+		t.Fatalf("ClaimStaleSagas: %v", err)
+	}
+	return claimed
+}
+
+func sameStatusChange(a, b entity.StatusChange) bool {
+	return a.Status == b.Status && a.ChangedAt.Equal(b.ChangedAt)
+}
+
+func sameShipment(a, b *entity.Shipment) bool {
+	return a.ID == b.ID && a.Route == b.Route && a.Price == b.Price && a.Status == b.Status &&
+		a.CustomerID == b.CustomerID && a.QuoteID == b.QuoteID && reflect.DeepEqual(a.Cargo, b.Cargo) &&
+		a.CreatedAt.Equal(b.CreatedAt) && a.TrackingNumber == b.TrackingNumber && sameWindow(a.PickupWindow, b.PickupWindow) &&
+		sameWindow(a.DeliveryWindow, b.DeliveryWindow) && sameTime(a.ETA, b.ETA) && a.SLAStatus == b.SLAStatus
+}
+
+func sameWindow(a, b *entity.Window) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.From.Equal(b.From) && a.Until.Equal(b.Until)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func windowsReq() *entity.CreateReq {
+	req := createReq()
+	from := time.Date(2025, time.June, 2, 9, 0, 0, 0, time.UTC)
+	req.PickupWindow = &entity.Window{From: from, Until: from.Add(24 * time.Hour)}
+	req.DeliveryWindow = &entity.Window{From: from.Add(22 * time.Hour), Until: from.Add(58 * time.Hour)}
+	return req
+}
+
+func testShipmentWindows(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	req := windowsReq()
+
+	shipment, err := s.CreateShipment(ctx, req, uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if !sameWindow(shipment.PickupWindow, req.PickupWindow) || !sameWindow(shipment.DeliveryWindow, req.DeliveryWindow) {
+		t.Errorf("CreateShipment windows = %+v, %+v, want %+v, %+v",
+			shipment.PickupWindow, shipment.DeliveryWindow, req.PickupWindow, req.DeliveryWindow)
+	}
+	if shipment.SLAStatus != entity.SLAOnTrack || shipment.ETA != nil {
+		t.Errorf("CreateShipment sla_status = %q, eta = %v, want ON_TRACK without an ETA", shipment.SLAStatus, shipment.ETA)
+	}
+
+	got, err := s.GetShipment(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if !sameShipment(got, shipment) {
+		t.Errorf("GetShipment = %+v, want %+v", got, shipment)
+	}
+
+	plain, err := s.CreateShipment(ctx, createReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment without windows: %v", err)
+	}
+	if plain.PickupWindow != nil || plain.DeliveryWindow != nil || plain.SLAStatus != "" {
+		t.Errorf("CreateShipment without windows = %+v", plain)
+	}
+
+	req.PickupWindow = &entity.Window{From: req.PickupWindow.Until, Until: req.PickupWindow.From}
+	if _, err := s.CreateShipment(ctx, req, uuid.NewString()); err == nil {
+		t.Error("CreateShipment with an inverted pickup window succeeded")
+	}
+}
+
+func testListShipments(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	customerID := uuid.NewString()
+
+	var ids []string
+	for i := range 4 {
+		req := windowsReq()
+		owner := customerID
+		if i == 3 {
+			req = createReq()
+			owner = uuid.NewString()
+		}
+		shipment, err := s.CreateShipment(ctx, req, owner)
+		if err != nil {
+			t.Fatalf("CreateShipment: %v", err)
+		}
+		ids = append(ids, shipment.ID)
+		time.Sleep(tick)
+	}
+	if err := s.UpdateSLA(ctx, ids[1], nil, entity.SLAAtRisk); err != nil {
+		t.Fatalf("UpdateSLA: %v", err)
+	}
+
+	list := func(filter entity.ShipmentFilter) []string {
+		t.Helper()
+		if filter.Limit == 0 {
+			filter.Limit = entity.DefaultListLimit
+		}
+		shipments, err := s.ListShipments(ctx, filter)
+		if err != nil {
+			t.Fatalf("ListShipments(%+v): %v", filter, err)
+		}
+		got := make([]string, 0, len(shipments))
+		for _, shipment := range shipments {
+			got = append(got, shipment.ID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name   string
+		filter entity.ShipmentFilter
+		want   []string
+	}{
+		{"all newest first", entity.ShipmentFilter{}, []string{ids[3], ids[2], ids[1], ids[0]}},
+		{"status", entity.ShipmentFilter{Status: entity.StatusCreated}, []string{ids[3], ids[2], ids[1], ids[0]}},
+		{"other status", entity.ShipmentFilter{Status: entity.StatusDelivered}, []string{}},
+		{"sla status", entity.ShipmentFilter{SLAStatus: entity.SLAAtRisk}, []string{ids[1]}},
+		{"customer", entity.ShipmentFilter{CustomerID: customerID}, []string{ids[2], ids[1], ids[0]}},
+		{"page", entity.ShipmentFilter{Limit: 2, Offset: 1}, []string{ids[2], ids[1]}},
+		{"past the end", entity.ShipmentFilter{Offset: 4}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list(tt.filter); !slices.Equal(got, tt.want) {
+				t.Errorf("ListShipments = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testClaimSLAChecks(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	open, err := s.CreateShipment(ctx, windowsReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	breached, err := s.CreateShipment(ctx, windowsReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if err := s.UpdateSLA(ctx, breached.ID, nil, entity.SLABreached); err != nil {
+		t.Fatalf("UpdateSLA: %v", err)
+	}
+	// Without a delivery window there is nothing to check
+	if _, err := s.CreateShipment(ctx, createReq(), uuid.NewString()); err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	delivered, history := seededShipment()
+	delivered.Status = entity.StatusDelivered
+	delivered.DeliveryWindow = windowsReq().DeliveryWindow
+	delivered.SLAStatus = entity.SLAOnTrack
+	if _, err := s.InsertShipment(ctx, delivered, history); err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+
+	claimed, err := s.ClaimSLAChecks(ctx, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("ClaimSLAChecks: %v", err)
+	}
+	if len(claimed) != 1 || !sameShipment(claimed[0], open) {
+		t.Fatalf("ClaimSLAChecks = %+v, want only the open shipment", claimed)
+	}
+
+	if claimed, err := s.ClaimSLAChecks(ctx, time.Hour, 10); err != nil || len(claimed) != 0 {
+		t.Errorf("ClaimSLAChecks right after a claim = %d shipments, %v", len(claimed), err)
+	}
+	time.Sleep(tick)
+	if claimed, err := s.ClaimSLAChecks(ctx, 0, 10); err != nil || len(claimed) != 1 {
+		t.Errorf("ClaimSLAChecks after recheckAfter = %d shipments, %v, want 1", len(claimed), err)
+	}
+	if claimed, err := s.ClaimSLAChecks(ctx, 0, 0); err != nil || len(claimed) != 0 {
+		t.Errorf("ClaimSLAChecks with limit 0 = %d shipments, %v", len(claimed), err)
+	}
+}
+
+func testUpdateSLA(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	shipment, err := s.CreateShipment(ctx, windowsReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+
+	eta := time.Date(2025, time.June, 4, 11, 30, 0, 0, time.UTC)
+	if err := s.UpdateSLA(ctx, shipment.ID, &eta, entity.SLAAtRisk); err != nil {
+		t.Fatalf("UpdateSLA: %v", err)
+	}
+	got, err := s.GetShipment(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("GetShipment: %v", err)
+	}
+	if !sameTime(got.ETA, &eta) || got.SLAStatus != entity.SLAAtRisk {
+		t.Errorf("after UpdateSLA eta = %v, sla_status = %q, want %v, AT_RISK", got.ETA, got.SLAStatus, eta)
+	}
+
+	if err := s.UpdateSLA(ctx, shipment.ID, nil, "LATE"); err == nil {
+		t.Error("UpdateSLA with an unknown status succeeded")
+	}
+
+	// BREACHED is final
+	if err := s.UpdateSLA(ctx, shipment.ID, &eta, entity.SLABreached); err != nil {
+		t.Fatalf("UpdateSLA: %v", err)
+	}
+	if err := s.UpdateSLA(ctx, shipment.ID, nil, entity.SLAOnTrack); err != nil {
+		t.Fatalf("UpdateSLA: %v", err)
+	}
+	if got, err := s.GetShipment(ctx, shipment.ID); err != nil || got.SLAStatus != entity.SLABreached {
+		t.Errorf("sla_status after UpdateSLA of a breached shipment = %q, %v, want BREACHED", got.SLAStatus, err)
+	}
+}
+
+func testUpdateSLAClosed(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	for _, status := range []string{entity.StatusDelivered, entity.StatusCancelled} {
+		shipment, history := seededShipment()
+		closedAt := history[len(history)-1].ChangedAt.Add(time.Hour)
+		shipment.Status, shipment.SLAStatus = status, entity.SLAAtRisk
+		history = append(history, entity.StatusChange{Status: status, ChangedAt: closedAt})
+		if _, err := s.InsertShipment(ctx, shipment, history); err != nil {
+			t.Fatalf("InsertShipment: %v", err)
+		}
+
+		// A check that claimed the shipment before it was closed
+		if err := s.UpdateSLA(ctx, shipment.ID, &closedAt, entity.SLABreached); err != nil {
+			t.Fatalf("UpdateSLA: %v", err)
+		}
+		got, err := s.GetShipment(ctx, shipment.ID)
+		if err != nil {
+			t.Fatalf("GetShipment: %v", err)
+		}
+		if got.SLAStatus != entity.SLAAtRisk || got.ETA != nil {
+			t.Errorf("%s shipment after UpdateSLA: eta = %v, sla_status = %q, want unchanged", status, got.ETA, got.SLAStatus)
+		}
+	}
+}
+
+func testLaneTransit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	since := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	if transit, err := s.LaneTransit(ctx, "ALMATY→ASTANA", since); err != nil || transit.Samples != 0 {
+		t.Errorf("LaneTransit of an empty lane = %+v, %v", transit, err)
+	}
+
+	// Transits of 20h, 24h, 30h and 40h on the lane; the median is 27h
+	for i, hours := range []int{30, 20, 40, 24} {
+		shipment, _ := seededShipment()
+		shipment.Status = entity.StatusDelivered
+		pickedUp := shipment.CreatedAt.Add(time.Duration(i) * time.Hour)
+		history := []entity.StatusChange{
+			{Status: entity.StatusCreated, ChangedAt: shipment.CreatedAt},
+			{Status: entity.StatusPickedUp, ChangedAt: pickedUp},
+			{Status: entity.StatusDelivered, ChangedAt: pickedUp.Add(time.Duration(hours) * time.Hour)},
+		}
+		if _, err := s.InsertShipment(ctx, shipment, history); err != nil {
+			t.Fatalf("InsertShipment: %v", err)
+		}
+	}
+	// Neither an undelivered shipment nor another lane counts
+	inTransit, history := seededShipment()
+	if _, err := s.InsertShipment(ctx, inTransit, history); err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+	other, _ := seededShipment()
+	other.Route = "SHYMKENT→ATYRAU"
+	other.Status = entity.StatusDelivered
+	if _, err := s.InsertShipment(ctx, other, []entity.StatusChange{
+		{Status: entity.StatusPickedUp, ChangedAt: other.CreatedAt},
+		{Status: entity.StatusDelivered, ChangedAt: other.CreatedAt.Add(time.Hour)},
+	}); err != nil {
+		t.Fatalf("InsertShipment: %v", err)
+	}
+
+	transit, err := s.LaneTransit(ctx, "ALMATY→ASTANA", since)
+	if err != nil {
+		t.Fatalf("LaneTransit: %v", err)
+	}
+	if want := (entity.LaneTransit{Samples: 4, Median: 27 * time.Hour}); transit != want {
+		t.Errorf("LaneTransit = %+v, want %+v", transit, want)
+	}
+
+	if transit, err := s.LaneTransit(ctx, "ALMATY→ASTANA", time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)); err != nil || transit.Samples != 0 {
+		t.Errorf("LaneTransit since after the deliveries = %+v, %v", transit, err)
+	}
+}
+
+func testTrackingNumber(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	a, err := s.CreateShipment(ctx, createReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	b, err := s.CreateShipmentWithID(ctx, uuid.NewString(), createReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipmentWithID: %v", err)
+	}
+	for _, shipment := range []*entity.Shipment{a, b} {
+		if number, ok := entity.NormalizeTrackingNumber(shipment.TrackingNumber); !ok || number != shipment.TrackingNumber {
+			t.Errorf("tracking number %q is not canonical", shipment.TrackingNumber)
+		}
+	}
+	if a.TrackingNumber == b.TrackingNumber {
+		t.Errorf("two shipments have tracking number %s", a.TrackingNumber)
+	}
+
+	got, err := s.GetShipmentByTrackingNumber(ctx, b.TrackingNumber)
+	if err != nil {
+		t.Fatalf("GetShipmentByTrackingNumber: %v", err)
+	}
+	if !sameShipment(got, b) {
+		t.Errorf("GetShipmentByTrackingNumber = %+v, want %+v", got, b)
+	}
+	if _, err := s.GetShipmentByTrackingNumber(ctx, "TL000000000000"); !errors.Is(err, entity.ErrShipmentNotFound) {
+		t.Errorf("GetShipmentByTrackingNumber of an unknown number error = %v, want entity.ErrShipmentNotFound", err)
+	}
+
+	seeded, history := seededShipment()
+	seeded.TrackingNumber = a.TrackingNumber
+	if _, err := s.InsertShipment(ctx, seeded, history); err == nil {
+		t.Error("InsertShipment with a taken tracking number succeeded")
+	}
+	seeded.TrackingNumber = ""
+	if _, err := s.InsertShipment(ctx, seeded, history); err != nil {
+		t.Fatalf("InsertShipment without a tracking number: %v", err)
+	}
+	if got, err := s.GetShipment(ctx, seeded.ID); err != nil || got.TrackingNumber == "" {
+		t.Errorf("shipment inserted without a tracking number = %+v, %v", got, err)
+	}
+}
+
+func testEvents(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+
+	shipment, err := s.CreateShipment(ctx, createReq(), uuid.NewString())
+	if err != nil {
+		t.Fatalf("CreateShipment: %v", err)
+	}
+	if events, err := s.ListEvents(ctx, shipment.ID); err != nil || len(events) != 0 {
+		t.Errorf("ListEvents without events = %v, %v", events, err)
+	}
+
+	at := time.Date(2025, time.June, 2, 9, 0, 0, 123456789, time.UTC)
+	// Reported out of order: the departure reaches the server late
+	reported := []*entity.Event{
+		{ShipmentID: shipment.ID, Type: entity.EventArrival, Location: "Караганда", OccurredAt: at.Add(10 * time.Hour)},
+		{
+			ShipmentID: shipment.ID, Type: entity.EventDeparture, Location: "Алматы, склад 1", Note: "пломба 0451",
+			GPS: &entity.GPS{Lat: 43.2567, Lon: 76.9286}, OccurredAt: at,
+		},
+	}
+	var added []*entity.Event
+	for _, event := range reported {
+		got, err := s.AddEvent(ctx, event)
+		if err != nil {
+			t.Fatalf("AddEvent: %v", err)
+		}
+		if got.ID == "" || got.CreatedAt.IsZero() {
+			t.Errorf("AddEvent = %+v, want an ID and created_at", got)
+		}
+		added = append(added, got)
+		time.Sleep(tick)
+	}
+
+	events, err := s.ListEvents(ctx, shipment.ID)
+	if err != nil {
+		t.Fatalf("ListEvents: %v", err)
+	}
+	want := []*entity.Event{added[1], added[0]}
+	if !slices.EqualFunc(events, want, sameEvent) {
+		t.Errorf("ListEvents = %+v, want %+v", events, want)
+	}
+	if !events[0].OccurredAt.Equal(at.Truncate(time.Microsecond)) {
+		t.Errorf("occurred_at = %v, want %v at TIMESTAMP precision", events[0].OccurredAt, at)
+	}
+
+	if _, err := s.AddEvent(ctx, &entity.Event{
+		ShipmentID: shipment.ID, Type: "LOST", Location: "Балхаш", OccurredAt: at,
+	}); err == nil {
+		t.Error("AddEvent with an unknown type succeeded")
+	}
+}
+
+func testAddEventUnknownShipment(t *testing.T, s storage.Storage) {
+	_, err := s.AddEvent(context.Background(), &entity.Event{
+		ShipmentID: uuid.NewString(), Type: entity.EventCheckpoint, Location: "Балхаш", OccurredAt: time.Now(),
+	})
+	if err == nil {
+		t.Error("AddEvent for an unknown shipment succeeded")
+	}
+}
+
+func sameEvent(a, b *entity.Event) bool {
+	return a.ID == b.ID && a.ShipmentID == b.ShipmentID && a.Type == b.Type && a.Location == b.Location &&
+		a.Note == b.Note && reflect.DeepEqual(a.GPS, b.GPS) && a.OccurredAt.Equal(b.OccurredAt) &&
+		a.CreatedAt.Equal(b.CreatedAt)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// trackingNumber returns the tracking number of a shipment to insert, a new
+// one if it has none
+func trackingNumber(shipment *entity.Shipment) string {
+	if shipment.TrackingNumber != "" {
+		return shipment.TrackingNumber
+	}
+	return entity.NewTrackingNumber()
+}
+
+func (s *storage) GetShipmentByTrackingNumber(ctx context.Context, number string) (*entity.Shipment, error) {
+	log := s.log.With("method", "GetShipmentByTrackingNumber")
+
+	shipment, err := scanShipment(s.replica.QueryRowContext(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE tracking_number=$1`, number))
+	// Anyone can guess numbers on the public endpoint, so a miss is not logged
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, number)
+	}
+	if err != nil {
+		log.Error("failed db select shipment", slog.String("tracking_number", number), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to get shipment: %w", err)
+	}
+
+	return shipment, nil
+}
+
+func (s *storage) AddEvent(ctx context.Context, event *entity.Event) (*entity.Event, error) {
+	log := s.log.With("method", "AddEvent")
+
+	var lat, lon sql.NullFloat64
+	if event.GPS != nil {
+		lat = sql.NullFloat64{Float64: event.GPS.Lat, Valid: true}
+		lon = sql.NullFloat64{Float64: event.GPS.Lon, Valid: true}
+	}
+
+	added := *event
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO shipment_events (shipment_id, type, location, note, lat, lon, occurred_at)
+		VALUES ($1,$2,$3,$4,$5,$6,$7)
+		RETURNING id, occurred_at, created_at`,
+		event.ShipmentID, event.Type, event.Location, event.Note, lat, lon, event.OccurredAt.UTC()).
+		Scan(&added.ID, &added.OccurredAt, &added.CreatedAt)
+	if err != nil {
+		log.Error("failed db insert event", slog.String("shipment_id", event.ShipmentID), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed db insert event: %w", err)
+	}
+
+	return &added, nil
+}
+
+func (s *storage) ListEvents(ctx context.Context, shipmentID string) ([]*entity.Event, error) {
+	log := s.log.With("method", "ListEvents")
+
+	// The primary, as for the status history: a carrier reads the timeline
+	// right after adding to it
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, shipment_id, type, location, note, lat, lon, occurred_at, created_at
+		FROM shipment_events WHERE shipment_id=$1 ORDER BY occurred_at, created_at`, shipmentID)
+	if err != nil {
+		log.Error("failed db select events", slog.String("shipment_id", shipmentID), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*entity.Event
+	for rows.Next() {
+		event := &entity.Event{}
+		var lat, lon sql.NullFloat64
+		err := rows.Scan(&event.ID, &event.ShipmentID, &event.Type, &event.Location, &event.Note,
+			&lat, &lon, &event.OccurredAt, &event.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		if lat.Valid {
+			event.GPS = &entity.GPS{Lat: lat.Float64, Lon: lon.Float64}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,32 @@
+package usecase
+
+import (
+	"math"
+
+	"github.com/aidosgal/transline-test/pkg/geo"
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+)
+
+// normalizeRoute replaces the stops of route found in the gazetteer with their
+// codes, so "Алматы → Астана" and "ALMATY→ASTANA" are one route; other stops
+// are kept as given
+func normalizeRoute(route string) string {
+	origin, destination, ok := entity.SplitRoute(route)
+	if !ok {
+		return route
+	}
+	for _, s := range []*string{&origin, &destination} {
+		if p, err := geo.Lookup(*s); err == nil {
+			*s = p.Code
+		}
+	}
+	return origin + entity.RouteSeparator + destination
+}
+
+// routeKm returns the road distance of route in whole kilometres, 0 unless
+// both stops are in the gazetteer
+func routeKm(route string) int {
+	origin, destination, _ := entity.SplitRoute(route)
+	km, _ := geo.RoadKmByCode(origin, destination)
+	return int(math.Round(km))
+}
@@ -0,0 +1,119 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/google/uuid"
+)
+
+func (u *usecase) AddEvent(ctx context.Context, shipmentID string, req *entity.EventReq) (*entity.Event, error) {
+	log := u.log.With("method", "AddEvent", "shipment_id", shipmentID, "type", req.Type)
+
+	shipment, err := u.findShipment(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	occurredAt := now
+	if req.OccurredAt != nil {
+		occurredAt = req.OccurredAt.UTC()
+	}
+	if occurredAt.Before(shipment.CreatedAt) || occurredAt.After(now.Add(entity.MaxClockSkew)) {
+		return nil, fmt.Errorf("%w: %s is not between %s and now", entity.ErrEventTime,
+			occurredAt.Format(time.RFC3339), shipment.CreatedAt.Format(time.RFC3339))
+	}
+
+	event, err := u.storage.AddEvent(ctx, &entity.Event{
+		ShipmentID: shipment.ID,
+		Type:       req.Type,
+		Location:   strings.TrimSpace(req.Location),
+		Note:       req.Note,
+		GPS:        req.GPS,
+		OccurredAt: occurredAt,
+	})
+	if err != nil {
+		log.ErrorContext(ctx, "failed to store event", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.AddEvent: %w", err)
+	}
+
+	log.InfoContext(ctx, "event added",
+		slog.String("event_id", event.ID),
+		slog.String("location", event.Location))
+	return event, nil
+}
+
+func (u *usecase) GetTimeline(ctx context.Context, shipmentID string) (*entity.Timeline, error) {
+	shipment, err := u.findShipment(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	return u.timeline(ctx, shipment)
+}
+
+func (u *usecase) Track(ctx context.Context, trackingNumber string) (*entity.Timeline, error) {
+	log := u.log.With("method", "Track")
+
+	number, ok := entity.NormalizeTrackingNumber(trackingNumber)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", entity.ErrShipmentNotFound, trackingNumber)
+	}
+	shipment, err := u.storage.GetShipmentByTrackingNumber(ctx, number)
+	if errors.Is(err, entity.ErrShipmentNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		log.ErrorContext(ctx, "failed to retrieve shipment from storage", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.GetShipmentByTrackingNumber: %w", err)
+	}
+
+	timeline, err := u.timeline(ctx, shipment)
+	if err != nil {
+		return nil, err
+	}
+	return timeline.Public(), nil
+}
+
+// findShipment returns the shipment with id, ErrShipmentNotFound if there is
+// none. It reads the primary: a carrier adds events right after creating the
+// shipment, and replication lag must not make it not found.
+func (u *usecase) findShipment(ctx context.Context, id string) (*entity.Shipment, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, id)
+	}
+	shipment, err := u.storage.GetShipmentPrimary(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, id)
+	}
+	if err != nil {
+		u.log.ErrorContext(ctx, "failed to retrieve shipment from storage", slog.String("method", "findShipment"),
+			slog.String("shipment_id", id), slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.GetShipmentPrimary: %w", err)
+	}
+	return shipment, nil
+}
+
+func (u *usecase) timeline(ctx context.Context, shipment *entity.Shipment) (*entity.Timeline, error) {
+	log := u.log.With("method", "timeline", "shipment_id", shipment.ID)
+
+	history, err := u.storage.GetStatusHistory(ctx, shipment.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to retrieve status history", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.GetStatusHistory: %w", err)
+	}
+	events, err := u.storage.ListEvents(ctx, shipment.ID)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to retrieve events", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.ListEvents: %w", err)
+	}
+
+	u.withETA(ctx, shipment)
+	return entity.NewTimeline(shipment, history, events), nil
+}
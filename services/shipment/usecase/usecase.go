@@ -2,66 +2,94 @@ package usecase
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"time"
 
-	"github.com/aidosgal/transline-test/services/shipment/client"
 	"github.com/aidosgal/transline-test/services/shipment/entity"
+	"github.com/aidosgal/transline-test/services/shipment/pricing"
+	"github.com/aidosgal/transline-test/services/shipment/saga"
+	"github.com/aidosgal/transline-test/services/shipment/sla"
 	"github.com/aidosgal/transline-test/services/shipment/storage"
-	"github.com/aidosgal/transline-test/specs/proto/customer"
+	"github.com/google/uuid"
 )
 
 type usecase struct {
-	log      *slog.Logger
-	storage  storage.Storage
-	customer *client.CustomerClient
+	log     *slog.Logger
+	storage storage.Storage
+	saga    saga.Orchestrator
+	pricing pricing.Engine
+	sla     sla.Monitor
 }
 
 type Usecase interface {
 	CreateShipment(ctx context.Context, req *entity.CreateReq) (*entity.CreateResp, error)
 	GetShipment(ctx context.Context, id string) (*entity.Shipment, error)
+	ListShipments(ctx context.Context, filter entity.ShipmentFilter) ([]*entity.Shipment, error)
+	// AddEvent appends a tracking event to a shipment
+	AddEvent(ctx context.Context, shipmentID string, req *entity.EventReq) (*entity.Event, error)
+	// GetTimeline returns the status history and events of a shipment, oldest first
+	GetTimeline(ctx context.Context, shipmentID string) (*entity.Timeline, error)
+	// Track returns the public timeline of the shipment with a tracking number
+	Track(ctx context.Context, trackingNumber string) (*entity.Timeline, error)
+	CreateQuote(ctx context.Context, req *entity.QuoteReq) (*entity.Quote, error)
+	GetQuote(ctx context.Context, id string) (*entity.Quote, error)
 }
 
-func New(log *slog.Logger, storage storage.Storage, customer *client.CustomerClient) Usecase {
+func New(log *slog.Logger, storage storage.Storage, saga saga.Orchestrator, pricing pricing.Engine, sla sla.Monitor) Usecase {
 	return &usecase{
-		log:      log.With("layer", "usecase"),
-		storage:  storage,
-		customer: customer,
+		log:     log.With("layer", "usecase"),
+		storage: storage,
+		saga:    saga,
+		pricing: pricing,
+		sla:     sla,
 	}
 }
 
 func (u *usecase) CreateShipment(ctx context.Context, req *entity.CreateReq) (*entity.CreateResp, error) {
+	if req.QuoteID != "" {
+		quoted, err := u.fromQuote(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		req = quoted
+	} else {
+		normalized := *req
+		normalized.Route = normalizeRoute(req.Route)
+		req = &normalized
+	}
+	req.PickupWindow, req.DeliveryWindow = u.sla.Plan(ctx, req.Route, req.PickupWindow, req.DeliveryWindow, time.Now())
+
 	log := u.log.With("method", "CreateShipment",
 		"route", req.Route,
 		"price", req.Price,
 		"customer_idn", req.Customer.IDN)
 
-	log.InfoContext(ctx, "starting shipment creation process")
-
-	log.InfoContext(ctx, "calling customer service to upsert customer")
-	customer, err := u.customer.UpsertCustomer(ctx, &customer.UpsertCustomerRequest{
-		Idn: req.Customer.IDN,
-	})
+	log.InfoContext(ctx, "starting shipment creation saga")
+	saga, err := u.saga.CreateShipment(ctx, req)
 	if err != nil {
-		log.ErrorContext(ctx, "failed to upsert customer via gRPC", slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to customer.UpsertCustomer: %w", err)
+		log.ErrorContext(ctx, "shipment creation saga failed", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to saga.CreateShipment: %w", err)
 	}
-	log.InfoContext(ctx, "customer upserted via gRPC", slog.String("customer_id", customer.Id))
-
-	log.InfoContext(ctx, "saving shipment to storage")
-	shipmentID, err := u.storage.CreateShipment(ctx, req, customer.Id)
-	if err != nil {
-		log.ErrorContext(ctx, "failed to save shipment to storage", slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to storage.CreateShipment: %w", err)
+	log.InfoContext(ctx, "shipment creation saga completed",
+		slog.String("saga_id", saga.ID),
+		slog.String("shipment_id", saga.ShipmentID))
+
+	shipment := saga.Shipment
+	if shipment == nil {
+		// Only reachable if the saga was completed by another replica. The
+		// primary, since a read replica may not have the row yet.
+		log.InfoContext(ctx, "retrieving created shipment")
+		shipment, err = u.storage.GetShipmentPrimary(ctx, saga.ShipmentID)
+		if err != nil {
+			log.ErrorContext(ctx, "failed to retrieve created shipment", slog.String("error", err.Error()))
+			return nil, fmt.Errorf("failed to storage.GetShipmentPrimary: %w", err)
+		}
 	}
-	log.InfoContext(ctx, "shipment saved to storage", slog.String("shipment_id", shipmentID))
 
-	log.InfoContext(ctx, "retrieving created shipment")
-	shipment, err := u.storage.GetShipment(ctx, shipmentID)
-	if err != nil {
-		log.ErrorContext(ctx, "failed to retrieve created shipment", slog.String("error", err.Error()))
-		return nil, fmt.Errorf("failed to storage.GetShipment: %w", err)
-	}
+	u.withETA(ctx, shipment)
 
 	log.InfoContext(ctx, "shipment creation completed successfully",
 		slog.String("shipment_id", shipment.ID),
@@ -78,12 +106,20 @@ func (u *usecase) GetShipment(ctx context.Context, id string) (*entity.Shipment,
 
 	log.InfoContext(ctx, "retrieving shipment from storage")
 
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, id)
+	}
 	shipment, err := u.storage.GetShipment(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrShipmentNotFound, id)
+	}
 	if err != nil {
 		log.ErrorContext(ctx, "failed to retrieve shipment from storage", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("failed to storage.GetShipment: %w", err)
 	}
 
+	u.withETA(ctx, shipment)
+
 	log.InfoContext(ctx, "shipment retrieved successfully",
 		slog.String("route", shipment.Route),
 		slog.String("status", shipment.Status),
@@ -91,3 +127,120 @@ func (u *usecase) GetShipment(ctx context.Context, id string) (*entity.Shipment,
 
 	return shipment, nil
 }
+
+func (u *usecase) ListShipments(ctx context.Context, filter entity.ShipmentFilter) ([]*entity.Shipment, error) {
+	log := u.log.With("method", "ListShipments",
+		"status", filter.Status,
+		"sla_status", filter.SLAStatus)
+
+	if filter.Limit == 0 {
+		filter.Limit = entity.DefaultListLimit
+	}
+	shipments, err := u.storage.ListShipments(ctx, filter)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to list shipments", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.ListShipments: %w", err)
+	}
+
+	log.InfoContext(ctx, "shipments listed", slog.Int("count", len(shipments)))
+	return shipments, nil
+}
+
+// withETA estimates the ETA of an open shipment the SLA monitor has not
+// checked yet; without it the shipment is returned as stored
+func (u *usecase) withETA(ctx context.Context, shipment *entity.Shipment) {
+	if !shipment.Open() || shipment.ETA != nil {
+		return
+	}
+	eta, _, err := u.sla.Estimate(ctx, shipment, time.Now())
+	if err != nil {
+		u.log.WarnContext(ctx, "failed to estimate eta", slog.String("method", "withETA"),
+			slog.String("shipment_id", shipment.ID), slog.String("error", err.Error()))
+		return
+	}
+	shipment.ETA = eta
+}
+
+// fromQuote returns the request with the route, price and cargo of its quote,
+// checking the quote can still be used
+func (u *usecase) fromQuote(ctx context.Context, req *entity.CreateReq) (*entity.CreateReq, error) {
+	log := u.log.With("method", "CreateShipment", "quote_id", req.QuoteID)
+
+	quote, err := u.GetQuote(ctx, req.QuoteID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case quote.ShipmentID != "":
+		err = fmt.Errorf("%w by shipment %s", entity.ErrQuoteUsed, quote.ShipmentID)
+	case quote.Expired(time.Now()):
+		err = fmt.Errorf("%w at %s", entity.ErrQuoteExpired, quote.ExpiresAt.Format(time.RFC3339))
+	case req.Customer.IDN != "" && req.Customer.IDN != quote.Customer.IDN:
+		err = entity.ErrQuoteCustomer
+	}
+	if err != nil {
+		log.WarnContext(ctx, "quote cannot be used", slog.String("error", err.Error()))
+		return nil, err
+	}
+
+	return &entity.CreateReq{
+		Route:    quote.Route,
+		Price:    quote.Total,
+		Customer: quote.Customer,
+		Cargo:    quote.Cargo,
+		QuoteID:  quote.ID,
+
+		PickupWindow:   req.PickupWindow,
+		DeliveryWindow: req.DeliveryWindow,
+	}, nil
+}
+
+func (u *usecase) CreateQuote(ctx context.Context, req *entity.QuoteReq) (*entity.Quote, error) {
+	normalized := *req
+	normalized.Route = normalizeRoute(req.Route)
+	req = &normalized
+
+	log := u.log.With("method", "CreateQuote",
+		"route", req.Route,
+		"customer_idn", req.Customer.IDN)
+
+	quote, err := u.pricing.Quote(req, time.Now().UTC().Truncate(time.Microsecond))
+	if err != nil {
+		log.WarnContext(ctx, "failed to price quote", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to pricing.Quote: %w", err)
+	}
+
+	quote, err = u.storage.CreateQuote(ctx, quote)
+	if err != nil {
+		log.ErrorContext(ctx, "failed to store quote", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.CreateQuote: %w", err)
+	}
+	quote.DistanceKm = routeKm(quote.Route)
+
+	log.InfoContext(ctx, "quote issued",
+		slog.String("quote_id", quote.ID),
+		slog.String("tariff", quote.Tariff),
+		slog.Int("total", quote.Total))
+
+	return quote, nil
+}
+
+func (u *usecase) GetQuote(ctx context.Context, id string) (*entity.Quote, error) {
+	log := u.log.With("method", "GetQuote", "quote_id", id)
+
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %s", entity.ErrQuoteNotFound, id)
+	}
+	quote, err := u.storage.GetQuote(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", entity.ErrQuoteNotFound, id)
+	}
+	if err != nil {
+		log.ErrorContext(ctx, "failed to retrieve quote from storage", slog.String("error", err.Error()))
+		return nil, fmt.Errorf("failed to storage.GetQuote: %w", err)
+	}
+	quote.DistanceKm = routeKm(quote.Route)
+
+	return quote, nil
+}
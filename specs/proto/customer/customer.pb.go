@@ -22,8 +22,11 @@ const (
 )
 
 type UpsertCustomerRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Idn           string                 `protobuf:"bytes,1,opt,name=idn,proto3" json:"idn,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Idn   string                 `protobuf:"bytes,1,opt,name=idn,proto3" json:"idn,omitempty"`
+	// saga_id identifies the saga making the call, so only that saga may
+	// delete the customer if the call inserts it
+	SagaId        string `protobuf:"bytes,2,opt,name=saga_id,json=sagaId,proto3" json:"saga_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -65,6 +68,13 @@ func (x *UpsertCustomerRequest) GetIdn() string {
 	return ""
 }
 
+func (x *UpsertCustomerRequest) GetSagaId() string {
+	if x != nil {
+		return x.SagaId
+	}
+	return ""
+}
+
 type GetCustomerRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Idn           string                 `protobuf:"bytes,1,opt,name=idn,proto3" json:"idn,omitempty"`
@@ -109,18 +119,120 @@ func (x *GetCustomerRequest) GetIdn() string {
 	return ""
 }
 
+type DeleteCustomerRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// saga_id must be the saga that created the customer, and nobody else
+	// may have upserted it since; otherwise the customer is kept
+	SagaId        string `protobuf:"bytes,2,opt,name=saga_id,json=sagaId,proto3" json:"saga_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCustomerRequest) Reset() {
+	*x = DeleteCustomerRequest{}
+	mi := &file_customer_customer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCustomerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCustomerRequest) ProtoMessage() {}
+
+func (x *DeleteCustomerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_customer_customer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCustomerRequest.ProtoReflect.Descriptor instead.
+func (*DeleteCustomerRequest) Descriptor() ([]byte, []int) {
+	return file_customer_customer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *DeleteCustomerRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteCustomerRequest) GetSagaId() string {
+	if x != nil {
+		return x.SagaId
+	}
+	return ""
+}
+
+type DeleteCustomerResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// deleted is false if the customer was kept or did not exist
+	Deleted       bool `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteCustomerResponse) Reset() {
+	*x = DeleteCustomerResponse{}
+	mi := &file_customer_customer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteCustomerResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteCustomerResponse) ProtoMessage() {}
+
+func (x *DeleteCustomerResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_customer_customer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteCustomerResponse.ProtoReflect.Descriptor instead.
+func (*DeleteCustomerResponse) Descriptor() ([]byte, []int) {
+	return file_customer_customer_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DeleteCustomerResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
 type CustomerResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Idn           string                 `protobuf:"bytes,2,opt,name=idn,proto3" json:"idn,omitempty"`
-	CreatedAt     string                 `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Idn       string                 `protobuf:"bytes,2,opt,name=idn,proto3" json:"idn,omitempty"`
+	CreatedAt string                 `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	// created is set by UpsertCustomer when the call, or an earlier call of
+	// the same saga, inserted the customer
+	Created       bool `protobuf:"varint,4,opt,name=created,proto3" json:"created,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CustomerResponse) Reset() {
 	*x = CustomerResponse{}
-	mi := &file_customer_customer_proto_msgTypes[2]
+	mi := &file_customer_customer_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -132,7 +244,7 @@ func (x *CustomerResponse) String() string {
 func (*CustomerResponse) ProtoMessage() {}
 
 func (x *CustomerResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_customer_customer_proto_msgTypes[2]
+	mi := &file_customer_customer_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -145,7 +257,7 @@ func (x *CustomerResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CustomerResponse.ProtoReflect.Descriptor instead.
 func (*CustomerResponse) Descriptor() ([]byte, []int) {
-	return file_customer_customer_proto_rawDescGZIP(), []int{2}
+	return file_customer_customer_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *CustomerResponse) GetId() string {
@@ -169,23 +281,38 @@ func (x *CustomerResponse) GetCreatedAt() string {
 	return ""
 }
 
+func (x *CustomerResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
 var File_customer_customer_proto protoreflect.FileDescriptor
 
 const file_customer_customer_proto_rawDesc = "" +
 	"\n" +
-	"\x17customer/customer.proto\x12\bcustomer\")\n" +
+	"\x17customer/customer.proto\x12\bcustomer\"B\n" +
 	"\x15UpsertCustomerRequest\x12\x10\n" +
-	"\x03idn\x18\x01 \x01(\tR\x03idn\"&\n" +
+	"\x03idn\x18\x01 \x01(\tR\x03idn\x12\x17\n" +
+	"\asaga_id\x18\x02 \x01(\tR\x06sagaId\"&\n" +
 	"\x12GetCustomerRequest\x12\x10\n" +
-	"\x03idn\x18\x01 \x01(\tR\x03idn\"S\n" +
+	"\x03idn\x18\x01 \x01(\tR\x03idn\"@\n" +
+	"\x15DeleteCustomerRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x17\n" +
+	"\asaga_id\x18\x02 \x01(\tR\x06sagaId\"2\n" +
+	"\x16DeleteCustomerResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\bR\adeleted\"m\n" +
 	"\x10CustomerResponse\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x10\n" +
 	"\x03idn\x18\x02 \x01(\tR\x03idn\x12\x1d\n" +
 	"\n" +
-	"created_at\x18\x03 \x01(\tR\tcreatedAt2\xa2\x01\n" +
+	"created_at\x18\x03 \x01(\tR\tcreatedAt\x12\x18\n" +
+	"\acreated\x18\x04 \x01(\bR\acreated2\xf7\x01\n" +
 	"\bCustomer\x12M\n" +
 	"\x0eUpsertCustomer\x12\x1f.customer.UpsertCustomerRequest\x1a\x1a.customer.CustomerResponse\x12G\n" +
-	"\vGetCustomer\x12\x1c.customer.GetCustomerRequest\x1a\x1a.customer.CustomerResponseB\x16Z\x14specs/proto/customerb\x06proto3"
+	"\vGetCustomer\x12\x1c.customer.GetCustomerRequest\x1a\x1a.customer.CustomerResponse\x12S\n" +
+	"\x0eDeleteCustomer\x12\x1f.customer.DeleteCustomerRequest\x1a .customer.DeleteCustomerResponseB\x16Z\x14specs/proto/customerb\x06proto3"
 
 var (
 	file_customer_customer_proto_rawDescOnce sync.Once
@@ -199,19 +326,23 @@ func file_customer_customer_proto_rawDescGZIP() []byte {
 	return file_customer_customer_proto_rawDescData
 }
 
-var file_customer_customer_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_customer_customer_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
 var file_customer_customer_proto_goTypes = []any{
-	(*UpsertCustomerRequest)(nil), // 0: customer.UpsertCustomerRequest
-	(*GetCustomerRequest)(nil),    // 1: customer.GetCustomerRequest
-	(*CustomerResponse)(nil),      // 2: customer.CustomerResponse
+	(*UpsertCustomerRequest)(nil),  // 0: customer.UpsertCustomerRequest
+	(*GetCustomerRequest)(nil),     // 1: customer.GetCustomerRequest
+	(*DeleteCustomerRequest)(nil),  // 2: customer.DeleteCustomerRequest
+	(*DeleteCustomerResponse)(nil), // 3: customer.DeleteCustomerResponse
+	(*CustomerResponse)(nil),       // 4: customer.CustomerResponse
 }
 var file_customer_customer_proto_depIdxs = []int32{
 	0, // 0: customer.Customer.UpsertCustomer:input_type -> customer.UpsertCustomerRequest
 	1, // 1: customer.Customer.GetCustomer:input_type -> customer.GetCustomerRequest
-	2, // 2: customer.Customer.UpsertCustomer:output_type -> customer.CustomerResponse
-	2, // 3: customer.Customer.GetCustomer:output_type -> customer.CustomerResponse
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
+	2, // 2: customer.Customer.DeleteCustomer:input_type -> customer.DeleteCustomerRequest
+	4, // 3: customer.Customer.UpsertCustomer:output_type -> customer.CustomerResponse
+	4, // 4: customer.Customer.GetCustomer:output_type -> customer.CustomerResponse
+	3, // 5: customer.Customer.DeleteCustomer:output_type -> customer.DeleteCustomerResponse
+	3, // [3:6] is the sub-list for method output_type
+	0, // [0:3] is the sub-list for method input_type
 	0, // [0:0] is the sub-list for extension type_name
 	0, // [0:0] is the sub-list for extension extendee
 	0, // [0:0] is the sub-list for field type_name
@@ -228,7 +359,7 @@ func file_customer_customer_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_customer_customer_proto_rawDesc), len(file_customer_customer_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   5,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
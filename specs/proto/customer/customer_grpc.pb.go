@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	Customer_UpsertCustomer_FullMethodName = "/customer.Customer/UpsertCustomer"
 	Customer_GetCustomer_FullMethodName    = "/customer.Customer/GetCustomer"
+	Customer_DeleteCustomer_FullMethodName = "/customer.Customer/DeleteCustomer"
 )
 
 // CustomerClient is the client API for Customer service.
@@ -29,6 +30,9 @@ const (
 type CustomerClient interface {
 	UpsertCustomer(ctx context.Context, in *UpsertCustomerRequest, opts ...grpc.CallOption) (*CustomerResponse, error)
 	GetCustomer(ctx context.Context, in *GetCustomerRequest, opts ...grpc.CallOption) (*CustomerResponse, error)
+	// DeleteCustomer compensates an UpsertCustomer that created the customer.
+	// It is internal to the saga and not exposed through the edge proxy.
+	DeleteCustomer(ctx context.Context, in *DeleteCustomerRequest, opts ...grpc.CallOption) (*DeleteCustomerResponse, error)
 }
 
 type customerClient struct {
@@ -59,12 +63,25 @@ func (c *customerClient) GetCustomer(ctx context.Context, in *GetCustomerRequest
 	return out, nil
 }
 
+func (c *customerClient) DeleteCustomer(ctx context.Context, in *DeleteCustomerRequest, opts ...grpc.CallOption) (*DeleteCustomerResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteCustomerResponse)
+	err := c.cc.Invoke(ctx, Customer_DeleteCustomer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CustomerServer is the server API for Customer service.
 // All implementations must embed UnimplementedCustomerServer
 // for forward compatibility.
 type CustomerServer interface {
 	UpsertCustomer(context.Context, *UpsertCustomerRequest) (*CustomerResponse, error)
 	GetCustomer(context.Context, *GetCustomerRequest) (*CustomerResponse, error)
+	// DeleteCustomer compensates an UpsertCustomer that created the customer.
+	// It is internal to the saga and not exposed through the edge proxy.
+	DeleteCustomer(context.Context, *DeleteCustomerRequest) (*DeleteCustomerResponse, error)
 	mustEmbedUnimplementedCustomerServer()
 }
 
@@ -81,6 +98,9 @@ func (UnimplementedCustomerServer) UpsertCustomer(context.Context, *UpsertCustom
 func (UnimplementedCustomerServer) GetCustomer(context.Context, *GetCustomerRequest) (*CustomerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetCustomer not implemented")
 }
+func (UnimplementedCustomerServer) DeleteCustomer(context.Context, *DeleteCustomerRequest) (*DeleteCustomerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteCustomer not implemented")
+}
 func (UnimplementedCustomerServer) mustEmbedUnimplementedCustomerServer() {}
 func (UnimplementedCustomerServer) testEmbeddedByValue()                  {}
 
@@ -138,6 +158,24 @@ func _Customer_GetCustomer_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Customer_DeleteCustomer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCustomerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CustomerServer).DeleteCustomer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Customer_DeleteCustomer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CustomerServer).DeleteCustomer(ctx, req.(*DeleteCustomerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Customer_ServiceDesc is the grpc.ServiceDesc for Customer service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +191,10 @@ var Customer_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetCustomer",
 			Handler:    _Customer_GetCustomer_Handler,
 		},
+		{
+			MethodName: "DeleteCustomer",
+			Handler:    _Customer_DeleteCustomer_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "customer/customer.proto",